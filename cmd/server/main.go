@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	webpush "github.com/SherClockHolmes/webpush-go"
+	"github.com/collinjanssen/thingstodo/internal/atrest"
 	"github.com/collinjanssen/thingstodo/internal/config"
 	"github.com/collinjanssen/thingstodo/internal/database"
 	"github.com/collinjanssen/thingstodo/internal/push"
@@ -16,6 +22,7 @@ import (
 	"github.com/collinjanssen/thingstodo/internal/router"
 	"github.com/collinjanssen/thingstodo/internal/scheduler"
 	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -47,8 +54,26 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Generate an encryption-at-rest key and exit.
+	if len(os.Args) > 1 && os.Args[1] == "-generate-encryption-key" {
+		key, err := atrest.GenerateKey()
+		if err != nil {
+			log.Fatalf("failed to generate encryption key: %v", err)
+		}
+		fmt.Printf("ENCRYPTION_KEY=%s\n", key)
+		os.Exit(0)
+	}
+
 	cfg := config.Load()
 
+	// Rotate the encryption-at-rest key (or encrypt/decrypt existing data) and exit.
+	if len(os.Args) > 1 && os.Args[1] == "rekey" {
+		if err := rekey(cfg); err != nil {
+			log.Fatalf("rekey failed: %v", err)
+		}
+		os.Exit(0)
+	}
+
 	// Validate VAPID keys at startup if configured.
 	if cfg.VAPIDPublicKey != "" {
 		// Normalize: accept both standard base64 and base64url
@@ -66,11 +91,41 @@ func main() {
 
 	log.Printf("ThingsToDo v%s (commit %s)", Version, Commit)
 
-	db, err := database.Open(cfg.DBPath)
+	// Spans are flushed last, after the server and scheduler have stopped.
+	shutdownTracing := tracing.Setup(tracing.Options{
+		Endpoint:    cfg.OTLPEndpoint,
+		Headers:     cfg.OTLPHeaders,
+		ServiceName: cfg.OTELServiceName,
+	})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("failed to flush traces: %v", err)
+		}
+	}()
+	if cfg.OTLPEndpoint != "" {
+		log.Printf("tracing enabled, exporting spans to %s", cfg.OTLPEndpoint)
+	}
+
+	var pools *database.DB
+	var err error
+	if cfg.EncryptionKey != nil {
+		pools, err = database.OpenEncrypted(cfg.DBPath, cfg.EncryptionKey)
+	} else {
+		pools, err = database.Open(cfg.DBPath)
+	}
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
 	}
-	defer db.Close()
+	defer pools.Close()
+	if cfg.EncryptionKey != nil {
+		log.Printf("encryption at rest enabled (key %s)", cfg.EncryptionKey.ID())
+	}
+	if cfg.DBReadConns > 0 {
+		pools.SetReadConns(cfg.DBReadConns)
+	}
+	db := pools.Write
 
 	broker := sse.NewBroker()
 
@@ -106,14 +161,59 @@ func main() {
 	notifier := push.NewDispatcher(pushSender, ntfySender, settingsRepo, userRepo)
 	log.Printf("timezone: %s", cfg.Location)
 	sched := scheduler.New(db, taskRepo, ruleRepo, checklistRepo, attachRepo, scheduleRepo, reminderRepo, settingsRepo, userRepo, changeLogRepo, notifier, broker, cfg.Location)
+	sched.SetChangeLogRetention(cfg.ChangeLogCompactDays, cfg.ChangeLogRetentionDays)
+	sched.SetAttachmentIndexing(cfg.AttachmentsPath, cfg.EncryptionKey)
 	sched.Start()
 	defer sched.Stop()
 
-	handler := router.New(db, cfg, broker, sched)
+	handler := router.New(pools, cfg, broker, sched)
+
+	// Shut down cleanly on SIGINT/SIGTERM so deferred cleanup runs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
 	log.Printf("starting server on %s", addr)
-	if err := http.ListenAndServe(addr, handler); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server error: %v", err)
 	}
+	log.Println("server stopped")
+}
+
+// rekey moves the database and attachment files from ENCRYPTION_KEY to
+// NEW_ENCRYPTION_KEY (or their _FILE variants). Leaving the current key empty
+// encrypts plaintext data; leaving the new key empty decrypts it. The server
+// must be stopped. Rekeying is idempotent, so an interrupted run can simply be
+// repeated.
+func rekey(cfg config.Config) error {
+	newKey, err := atrest.LoadKey(os.Getenv("NEW_ENCRYPTION_KEY"), os.Getenv("NEW_ENCRYPTION_KEY_FILE"))
+	if err != nil {
+		return fmt.Errorf("invalid new encryption key: %w", err)
+	}
+	if cfg.EncryptionKey == nil && newKey == nil {
+		return errors.New("set ENCRYPTION_KEY and/or NEW_ENCRYPTION_KEY")
+	}
+	if err := database.Rekey(cfg.DBPath, cfg.EncryptionKey, newKey); err != nil {
+		return err
+	}
+	log.Printf("database %s rekeyed", cfg.DBPath)
+	n, err := atrest.RekeyDir(cfg.AttachmentsPath, cfg.EncryptionKey, newKey)
+	if err != nil {
+		return fmt.Errorf("rekey attachments: %w", err)
+	}
+	log.Printf("%d attachment files rekeyed", n)
+	if newKey != nil {
+		log.Printf("now start the server with the new key (id %s) as ENCRYPTION_KEY", newKey.ID())
+	} else {
+		log.Println("data is decrypted; start the server without ENCRYPTION_KEY")
+	}
+	return nil
 }
@@ -5,6 +5,8 @@ import type {
   CreateProjectRequest,
   UpdateProjectRequest,
   ProjectStatus,
+  ProjectRepeatRule,
+  UpsertProjectRepeatRuleRequest,
   SimpleReorderItem,
 } from './types'
 
@@ -42,3 +44,11 @@ export function completeProject(id: string) {
 export function reorderProjects(items: SimpleReorderItem[]) {
   return api.patch<{ ok: boolean }>('/projects/reorder', { items })
 }
+
+export function upsertProjectRepeatRule(id: string, data: UpsertProjectRepeatRuleRequest) {
+  return api.put<ProjectRepeatRule>(`/projects/${id}/repeat`, data)
+}
+
+export function deleteProjectRepeatRule(id: string) {
+  return api.delete<void>(`/projects/${id}/repeat`)
+}
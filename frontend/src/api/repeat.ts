@@ -1,5 +1,5 @@
 import { api } from './client'
-import type { RepeatRule, UpsertRepeatRuleRequest } from './types'
+import type { ParseRecurrenceResponse, RepeatRule, UpsertRepeatRuleRequest } from './types'
 
 export function upsertRepeatRule(taskId: string, data: UpsertRepeatRuleRequest) {
   return api.put<RepeatRule>(`/tasks/${taskId}/repeat`, data)
@@ -8,3 +8,8 @@ export function upsertRepeatRule(taskId: string, data: UpsertRepeatRuleRequest)
 export function deleteRepeatRule(taskId: string) {
   return api.delete<void>(`/tasks/${taskId}/repeat`)
 }
+
+export function parseRecurrence(text: string) {
+  const search = new URLSearchParams({ text })
+  return api.get<ParseRecurrenceResponse>(`/parse/recurrence?${search.toString()}`)
+}
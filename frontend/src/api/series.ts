@@ -0,0 +1,18 @@
+import { api } from './client'
+import type { SeriesStats } from './types'
+
+function seriesQuery(params?: { from?: string; to?: string }) {
+  const search = new URLSearchParams()
+  if (params?.from) search.set('from', params.from)
+  if (params?.to) search.set('to', params.to)
+  const qs = search.toString()
+  return qs ? `?${qs}` : ''
+}
+
+export function listSeries(params?: { from?: string; to?: string }) {
+  return api.get<SeriesStats[]>(`/series${seriesQuery(params)}`)
+}
+
+export function getSeriesStats(seriesId: string, params?: { from?: string; to?: string }) {
+  return api.get<SeriesStats>(`/series/${seriesId}/stats${seriesQuery(params)}`)
+}
@@ -1,5 +1,5 @@
 import { api } from './client'
-import type { UserSettings } from './types'
+import type { Holiday, UserSettings, WorkdayCalendarInfo } from './types'
 
 export function getSettings() {
   return api.get<UserSettings>('/user/settings')
@@ -8,3 +8,12 @@ export function getSettings() {
 export function updateSettings(data: Partial<UserSettings>) {
   return api.patch<UserSettings>('/user/settings', data)
 }
+
+export function getWorkdayCalendars() {
+  return api.get<{ calendars: WorkdayCalendarInfo[] }>('/user/settings/calendars')
+}
+
+export function getHolidays(year?: number) {
+  const qs = year ? `?year=${year}` : ''
+  return api.get<{ calendar: string; year: number; holidays: Holiday[] }>(`/user/settings/holidays${qs}`)
+}
@@ -79,10 +79,18 @@ export type RecurrencePatternType =
   | 'monthly_workday'
   | 'yearly_date'
   | 'yearly_dow'
+  | 'hourly'
+  | 'rrule'
 
 interface PatternBase {
   every: number
   mode: RecurrenceMode
+  count?: number // total occurrences in the series
+  until?: string // YYYY-MM-DD, last possible date
+  exceptions?: string[] // YYYY-MM-DD dates to skip
+  occurrence?: number // server-managed position of the current task
+  roll?: 'forward' | 'backward' // move dates off non-workdays
+  time?: string // HH:MM time of day; occurrences become YYYY-MM-DDTHH:MM
 }
 
 export interface DailyPattern extends PatternBase {
@@ -120,7 +128,7 @@ export interface MonthlyWorkdayPattern extends PatternBase {
 
 export interface YearlyDatePattern extends PatternBase {
   type: 'yearly_date'
-  month: number // 1-12
+  month?: number // 1-12; omitted keeps the task's month
   day?: number | null // 1-31
 }
 
@@ -131,6 +139,18 @@ export interface YearlyDOWPattern extends PatternBase {
   weekday: DayOfWeekFull
 }
 
+export interface HourlyPattern extends PatternBase {
+  type: 'hourly'
+  every: number // hours, 1-23
+  time_until?: string // HH:MM end of the daily window, inclusive
+  on?: DayOfWeek[]
+}
+
+export interface RRulePattern extends PatternBase {
+  type: 'rrule'
+  rrule: string // RFC 5545, e.g. "FREQ=MONTHLY;BYDAY=2TU,4TU"
+}
+
 export type RecurrencePattern =
   | DailyPattern
   | DailyWeekdayPattern
@@ -141,15 +161,30 @@ export type RecurrencePattern =
   | MonthlyWorkdayPattern
   | YearlyDatePattern
   | YearlyDOWPattern
+  | HourlyPattern
+  | RRulePattern
 
 // Deprecated aliases for backwards compat
 export type RepeatFrequency = 'daily' | 'weekly' | 'monthly' | 'yearly'
 export type RepeatMode = RecurrenceMode
 export type DayConstraint = DayOfWeek
 
+// What the next instance copies from the finished task
+export interface RepeatCopyOptions {
+  notes: boolean
+  checklist: boolean
+  attachments: boolean
+}
+
+// What happens to occurrences of a fixed rule that come due while the open
+// task is still waiting
+export type RepeatCatchUp = 'none' | 'all' | 'latest' | 'skip'
+
 export interface RepeatRule {
   id: string
   pattern: RecurrencePattern
+  copy: RepeatCopyOptions
+  catch_up: RepeatCatchUp
   // Deprecated flat fields (still present in API responses)
   frequency?: RepeatFrequency
   interval_value?: number
@@ -222,6 +257,7 @@ export interface Task {
   deleted_at: string | null
   created_at: string
   updated_at: string
+  series_id?: string | null
   tags: TagRef[]
   checklist_count: number
   checklist_done: number
@@ -242,6 +278,7 @@ export interface Task {
   past_schedule_count?: number
   has_actionable_schedules?: boolean
   all_today_schedules_completed?: boolean
+  projected?: boolean // virtual future occurrence of a repeating task
 }
 
 export interface TaskDetail extends Task {
@@ -323,6 +360,7 @@ export interface Project {
   tags: TagRef[]
   created_at: string
   updated_at: string
+  series_id?: string // shared by the instances of a repeating project
 }
 
 export interface Heading {
@@ -340,6 +378,22 @@ export interface ProjectDetail extends Project {
   headings: HeadingWithTasks[]
   tasks_without_heading: Task[]
   completed_tasks: Task[]
+  repeat_rule: ProjectRepeatRule | null
+}
+
+// Repeats a whole project: finishing it copies it, headings and tasks
+// reopened, to the next occurrence. Hourly patterns and time do not apply.
+export interface ProjectRepeatRule {
+  id: string
+  project_id: string
+  pattern: RecurrencePattern
+  copy: RepeatCopyOptions
+  created_at?: string
+}
+
+export interface UpsertProjectRepeatRuleRequest {
+  pattern: RecurrencePattern
+  copy?: RepeatCopyOptions // omitted: keep the rule's current options
 }
 
 export interface CreateProjectRequest {
@@ -436,11 +490,18 @@ export interface UpdateTagRequest {
 // Repeat Rules
 export interface UpsertRepeatRuleRequest {
   pattern: RecurrencePattern
+  copy?: Partial<RepeatCopyOptions> // omitted: keep the rule's current options
+  catch_up?: RepeatCatchUp // omitted: keep the rule's current policy
 }
 
 // Deprecated: use UpsertRepeatRuleRequest
 export type CreateRepeatRuleRequest = UpsertRepeatRuleRequest
 
+export interface ParseRecurrenceResponse {
+  text: string
+  pattern: RecurrencePattern
+}
+
 // View responses
 export interface InboxView {
   tasks: Task[]
@@ -509,6 +570,7 @@ export interface LogbookViewGroup {
 export interface LogbookView {
   groups: LogbookViewGroup[]
   total: number
+  next_cursor: string
 }
 
 export type TrashView = LogbookView
@@ -524,16 +586,37 @@ export interface ViewCounts {
   trash: number
 }
 
+// GET /api/views/counts also counts the user's smart lists, by ID.
+export interface ServerViewCounts extends ViewCounts {
+  smart: Record<string, number>
+}
+
 // Search
+export type SearchResultType = 'task' | 'project' | 'area' | 'heading' | 'checklist_item'
+
 export interface SearchResult {
-  task: Task
+  type: SearchResultType
+  id: string
+  title: string
+  status?: string
+  task?: Task // task and checklist_item results
+  parent?: { id: string; title: string } // area of a project, project of a heading, task of a checklist item
   title_snippet: string
   notes_snippet: string
   rank: number
+  attachment?: { id: string; title: string } // attached file whose text matched
+  attachment_snippet?: string
+}
+
+export interface SearchGroup {
+  type: SearchResultType
+  count: number
 }
 
 export interface SearchResponse {
   results: SearchResult[]
+  groups: SearchGroup[]
+  next_cursor: string
 }
 
 // User Settings
@@ -562,6 +645,18 @@ export interface UserSettings {
   ntfy_access_token: string
   base_url: string
   privacy_mode: boolean
+  workday_calendar: string // '' (Mon-Fri), a built-in id, or 'ics'
+  workday_calendar_ics: string
+}
+
+export interface WorkdayCalendarInfo {
+  id: string
+  name: string
+}
+
+export interface Holiday {
+  date: string
+  name: string
 }
 
 // Saved Filters
@@ -579,6 +674,33 @@ export interface CreateSavedFilterRequest {
   config: string
 }
 
+// Smart lists
+export type SmartListSort = 'when' | 'deadline' | 'created' | 'updated' | 'title' | 'priority'
+export type SmartListGroupBy = 'none' | 'project' | 'area' | 'tag' | 'when' | 'deadline'
+
+export interface SmartList {
+  id: string
+  name: string
+  query: string
+  sort: SmartListSort | `-${SmartListSort}`
+  group_by: SmartListGroupBy
+  sort_order: number
+  created_at: string
+  updated_at: string
+}
+
+export interface SmartListGroup {
+  project: { id: string; title: string } | null
+  title?: string
+  tasks: Task[]
+}
+
+export interface SmartListView {
+  smart_list: SmartList
+  groups: SmartListGroup[]
+  count: number
+}
+
 // Task query params
 export interface TaskQueryParams {
   status?: TaskStatus
@@ -592,3 +714,26 @@ export interface TaskQueryParams {
   has_deadline?: boolean
   search?: string
 }
+
+// Series (habit statistics of repeating tasks)
+export interface SeriesDay {
+  date: string
+  due: number
+  completed: number
+}
+
+export interface SeriesStats {
+  series_id: string
+  title: string
+  task_id: string | null
+  occurrences: number
+  completed: number
+  missed: number
+  completion_rate: number
+  current_streak: number
+  longest_streak: number
+  missed_dates: string[]
+  from: string
+  to: string
+  days: SeriesDay[]
+}
@@ -18,9 +18,11 @@ export function getToday() {
   return api.get<TodayView>('/views/today')
 }
 
-export function getUpcoming(params?: { from?: string }) {
+export function getUpcoming(params?: { from?: string; projected?: boolean; projectedUntil?: string }) {
   const search = new URLSearchParams()
   if (params?.from) search.set('from', params.from)
+  if (params?.projected) search.set('projected', 'true')
+  if (params?.projectedUntil) search.set('projected_until', params.projectedUntil)
   const qs = search.toString()
   return api.get<UpcomingView>(`/views/upcoming${qs ? `?${qs}` : ''}`)
 }
@@ -33,18 +35,18 @@ export function getSomeday() {
   return api.get<SomedayView>('/views/someday')
 }
 
-export function getLogbook(params?: { limit?: number; offset?: number }) {
+export function getLogbook(params?: { limit?: number; cursor?: string }) {
   const search = new URLSearchParams()
   if (params?.limit) search.set('limit', String(params.limit))
-  if (params?.offset) search.set('offset', String(params.offset))
+  if (params?.cursor) search.set('cursor', params.cursor)
   const qs = search.toString()
   return api.get<LogbookView>(`/views/logbook${qs ? `?${qs}` : ''}`)
 }
 
-export function getTrash(params?: { limit?: number; offset?: number }) {
+export function getTrash(params?: { limit?: number; cursor?: string }) {
   const search = new URLSearchParams()
   if (params?.limit) search.set('limit', String(params.limit))
-  if (params?.offset) search.set('offset', String(params.offset))
+  if (params?.cursor) search.set('cursor', params.cursor)
   const qs = search.toString()
   return api.get<TrashView>(`/views/trash${qs ? `?${qs}` : ''}`)
 }
@@ -52,19 +52,27 @@ function SearchOverlayInner() {
   const selectResult = useCallback(
     (result: SearchResult) => {
       const task = result.task
-      if (task.project_id) {
-        navigate(`/project/${task.project_id}`)
-      } else if (task.area_id) {
-        navigate(`/area/${task.area_id}`)
-      } else if (task.when_date === 'someday') {
-        navigate('/someday')
-      } else if (task.when_date) {
-        const today = new Date().toISOString().slice(0, 10)
-        navigate(task.when_date <= today ? '/today' : '/upcoming')
-      } else {
-        navigate('/inbox')
+      if (result.type === 'project') {
+        navigate(`/project/${result.id}`)
+      } else if (result.type === 'area') {
+        navigate(`/area/${result.id}`)
+      } else if (result.type === 'heading' && result.parent) {
+        navigate(`/project/${result.parent.id}`)
+      } else if (task) {
+        if (task.project_id) {
+          navigate(`/project/${task.project_id}`)
+        } else if (task.area_id) {
+          navigate(`/area/${task.area_id}`)
+        } else if (task.when_date === 'someday') {
+          navigate('/someday')
+        } else if (task.when_date) {
+          const today = new Date().toISOString().slice(0, 10)
+          navigate(task.when_date <= today ? '/today' : '/upcoming')
+        } else {
+          navigate('/inbox')
+        }
+        expandAndScrollToTask(task.id)
       }
-      expandAndScrollToTask(task.id)
       close()
     },
     [navigate, close],
@@ -132,7 +140,7 @@ function SearchOverlayInner() {
           ) : (
             results.map((result, i) => (
               <button
-                key={result.task.id}
+                key={`${result.type}-${result.id}`}
                 className={`flex w-full cursor-pointer flex-col gap-0.5 rounded-lg px-3 py-2 text-left ${
                   i === selectedIndex
                     ? 'bg-neutral-100 dark:bg-neutral-700'
@@ -144,12 +152,12 @@ function SearchOverlayInner() {
                 <span
                   className="text-sm text-neutral-700 dark:text-neutral-300 [&_mark]:bg-yellow-200 [&_mark]:text-neutral-900 dark:[&_mark]:bg-yellow-500/40 dark:[&_mark]:text-neutral-100"
                   dangerouslySetInnerHTML={{
-                    __html: sanitizeSnippet(result.title_snippet) || escapeHtml(result.task.title),
+                    __html: sanitizeSnippet(result.title_snippet) || escapeHtml(result.title),
                   }}
                 />
-                {getTaskContext(result.task) && (
+                {resultContext(result) && (
                   <span className="text-[10px] text-neutral-400">
-                    {getTaskContext(result.task)}
+                    {resultContext(result)}
                   </span>
                 )}
                 {result.notes_snippet && (
@@ -158,6 +166,12 @@ function SearchOverlayInner() {
                     dangerouslySetInnerHTML={{ __html: sanitizeSnippet(result.notes_snippet) }}
                   />
                 )}
+                {result.attachment && result.attachment_snippet && (
+                  <span className="line-clamp-1 text-xs text-neutral-500 dark:text-neutral-400 [&_mark]:bg-yellow-200 [&_mark]:text-neutral-700 dark:[&_mark]:bg-yellow-500/40 dark:[&_mark]:text-neutral-300">
+                    <span className="text-neutral-400">{result.attachment.title}: </span>
+                    <span dangerouslySetInnerHTML={{ __html: sanitizeSnippet(result.attachment_snippet) }} />
+                  </span>
+                )}
               </button>
             ))
           )}
@@ -172,6 +186,21 @@ function SearchOverlayInner() {
   )
 }
 
+const typeLabels: Record<SearchResult['type'], string> = {
+  task: 'Task',
+  project: 'Project',
+  area: 'Area',
+  heading: 'Heading',
+  checklist_item: 'Checklist item',
+}
+
+/** Where a result lives: the task's list for tasks, otherwise its type and parent. */
+function resultContext(result: SearchResult): string {
+  if (result.type === 'task') return result.task ? getTaskContext(result.task) ?? '' : ''
+  const label = typeLabels[result.type]
+  return result.parent ? `${label} · ${result.parent.title}` : label
+}
+
 function escapeHtml(str: string): string {
   return str
     .replace(/&/g, '&amp;')
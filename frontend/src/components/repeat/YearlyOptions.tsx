@@ -46,7 +46,7 @@ export function YearlyOptions({ pattern, onChange }: YearlyOptionsProps) {
   const mode = pattern.mode as RecurrenceMode
   const subType: YearlySubType = pattern.type === 'yearly_dow' ? 'dow' : 'date'
 
-  const dateMonth = pattern.type === 'yearly_date' ? pattern.month : 1
+  const dateMonth = pattern.type === 'yearly_date' ? (pattern.month ?? 1) : 1
   const dateDay = pattern.type === 'yearly_date' && pattern.day != null ? pattern.day : 1
   const dowMonth = pattern.type === 'yearly_dow' ? pattern.month : 1
   const dowOrdinal = pattern.type === 'yearly_dow' ? pattern.ordinal : 'first'
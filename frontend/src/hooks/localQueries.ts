@@ -1180,6 +1180,7 @@ export function useLocalProjectDetail(projectId: string): ProjectDetail | undefi
       headings: headingsWithTasks,
       tasks_without_heading: tasksWithoutHeading,
       completed_tasks: await enrichTasks(completedTasks),
+      repeat_rule: null, // not synced to the local database
     } as ProjectDetail
   }, [projectId])
 }
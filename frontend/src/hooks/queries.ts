@@ -76,8 +76,8 @@ export const queryKeys = {
     upcoming: (from?: string) => ['views', 'upcoming', from] as const,
     anytime: ['views', 'anytime'] as const,
     someday: ['views', 'someday'] as const,
-    logbook: (limit?: number, offset?: number) => ['views', 'logbook', limit, offset] as const,
-    trash: (limit?: number, offset?: number) => ['views', 'trash', limit, offset] as const,
+    logbook: (limit?: number, cursor?: string) => ['views', 'logbook', limit, cursor] as const,
+    trash: (limit?: number, cursor?: string) => ['views', 'trash', limit, cursor] as const,
     counts: ['views', 'counts'] as const,
   },
   search: (q: string) => ['search', q] as const,
@@ -125,17 +125,17 @@ export function useSomeday() {
   })
 }
 
-export function useLogbook(limit?: number, offset?: number) {
+export function useLogbook(limit?: number, cursor?: string) {
   return useQuery({
-    queryKey: queryKeys.views.logbook(limit, offset),
-    queryFn: () => viewsApi.getLogbook({ limit, offset }),
+    queryKey: queryKeys.views.logbook(limit, cursor),
+    queryFn: () => viewsApi.getLogbook({ limit, cursor }),
   })
 }
 
-export function useTrash(limit?: number, offset?: number) {
+export function useTrash(limit?: number, cursor?: string) {
   return useQuery({
-    queryKey: queryKeys.views.trash(limit, offset),
-    queryFn: () => viewsApi.getTrash({ limit, offset }),
+    queryKey: queryKeys.views.trash(limit, cursor),
+    queryFn: () => viewsApi.getTrash({ limit, cursor }),
   })
 }
 
@@ -78,7 +78,7 @@ const formatters: Record<string, Formatter> = {
   yearly_date(p) {
     const yp = p as RecurrencePattern & { type: 'yearly_date' }
     const prefix = yp.every === 1 ? 'Yearly' : formatInterval(yp.every, 'year')
-    const month = MONTH_NAMES[yp.month] ?? ''
+    const month = MONTH_NAMES[yp.month ?? 0] ?? ''
     if (month && yp.day != null && yp.day > 0) return `${prefix} on ${month} ${yp.day}`
     if (month) return `${prefix} in ${month}`
     return prefix
@@ -92,6 +92,19 @@ const formatters: Record<string, Formatter> = {
     const month = MONTH_NAMES[yp.month] ?? ''
     return `${prefix} on the ${ord} ${wd} of ${month}`
   },
+
+  hourly(p) {
+    const hp = p as RecurrencePattern & { type: 'hourly' }
+    let text = hp.every === 1 ? 'Hourly' : `Every ${hp.every} hours`
+    if (hp.time || hp.time_until) text += ` from ${hp.time ?? '00:00'} to ${hp.time_until ?? '23:59'}`
+    if (hp.on && hp.on.length > 0) text += ` on ${hp.on.map((d) => DAY_LABELS[d] ?? d).join(', ')}`
+    return text
+  },
+
+  rrule(p) {
+    const rp = p as RecurrencePattern & { type: 'rrule' }
+    return `Custom (${rp.rrule})`
+  },
 }
 
 function ordinalSuffix(n: number): string {
@@ -107,8 +120,16 @@ export function formatRepeatRule(rule: RepeatRule): string {
   if (!formatter) return 'Repeating'
 
   let text = formatter(pattern as RecurrencePattern & { type: string })
+  if (pattern.time && pattern.type !== 'hourly') {
+    text += ` at ${pattern.time}`
+  }
   if (pattern.mode === 'after_completion') {
     text += ' after completion'
   }
+  if (pattern.count) {
+    text += `, ${pattern.count} times`
+  } else if (pattern.until) {
+    text += ` until ${pattern.until}`
+  }
   return text
 }
@@ -96,6 +96,7 @@ export const mockProjectDetail: ProjectDetail = {
     { ...mockTask, id: 'task-4', title: 'Setup project repo', project_id: 'proj-1' },
   ],
   completed_tasks: [],
+  repeat_rule: null,
 }
 
 export const mockArea: Area = {
@@ -162,7 +162,7 @@ export const handlers = [
 
   // Search
   http.get('/api/search', () => {
-    return HttpResponse.json({ results: [] })
+    return HttpResponse.json({ results: [], groups: [] })
   }),
 
   // Auth
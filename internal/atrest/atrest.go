@@ -0,0 +1,320 @@
+// Package atrest implements server-side encryption at rest for the database
+// file and attachment directory.
+//
+// Files are sealed with envelope encryption: each file gets a random 256-bit
+// data key (DEK) that encrypts the content with AES-256-GCM, and the DEK is
+// itself encrypted with the server's master key. Rotating the master key only
+// re-wraps the DEK in each file header; content is never re-encrypted.
+//
+// Sealed layout:
+//
+//	magic (8) | key id (8) | wrapped DEK (12 nonce + 32 key + 16 tag) | nonce (12) | ciphertext + tag
+package atrest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	keySize   = 32
+	idSize    = 8
+	nonceSize = 12
+	tagSize   = 16
+
+	wrappedSize = nonceSize + keySize + tagSize
+	headerSize  = len(magic) + idSize + wrappedSize
+)
+
+const magic = "TTDREST1"
+
+var (
+	ErrNotSealed  = errors.New("data is not sealed")
+	ErrUnknownKey = errors.New("data is sealed with an unknown key")
+	ErrCorrupt    = errors.New("sealed data is corrupt or was tampered with")
+)
+
+// Key is a master key used to wrap per-file data keys.
+type Key struct {
+	id     [idSize]byte
+	secret []byte
+}
+
+// ParseKey parses a 32-byte key given as standard base64 or hex.
+func ParseKey(s string) (*Key, error) {
+	s = strings.TrimSpace(s)
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(raw) != keySize {
+		raw, err = hex.DecodeString(s)
+	}
+	if err != nil || len(raw) != keySize {
+		return nil, errors.New("encryption key must be 32 bytes, base64 or hex encoded")
+	}
+	k := &Key{secret: raw}
+	sum := sha256.Sum256(raw)
+	copy(k.id[:], sum[:idSize])
+	return k, nil
+}
+
+// LoadKey loads a key from an inline secret or, if that is empty, from a key
+// file. It returns nil when neither is set, meaning encryption is disabled.
+func LoadKey(secret, file string) (*Key, error) {
+	if secret != "" {
+		return ParseKey(secret)
+	}
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	if len(data) == keySize {
+		return ParseKey(base64.StdEncoding.EncodeToString(data))
+	}
+	return ParseKey(string(data))
+}
+
+// GenerateKey returns a new random key, base64 encoded.
+func GenerateKey() (string, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// ID returns a short fingerprint identifying the key in sealed headers.
+func (k *Key) ID() string {
+	return hex.EncodeToString(k.id[:])
+}
+
+// IsSealed reports whether data was produced by Seal.
+func IsSealed(data []byte) bool {
+	return len(data) >= headerSize+nonceSize+tagSize && string(data[:len(magic)]) == magic
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped with k.
+func (k *Key) Seal(plaintext []byte) ([]byte, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, headerSize+nonceSize+len(plaintext)+tagSize)
+	out = append(out, magic...)
+	out = append(out, k.id[:]...)
+	wrapped, err := seal(k.secret, dek, out)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, wrapped...)
+	body, err := seal(dek, plaintext, out[:len(magic)])
+	if err != nil {
+		return nil, err
+	}
+	return append(out, body...), nil
+}
+
+// Open decrypts data sealed with any of keys.
+func Open(data []byte, keys ...*Key) ([]byte, error) {
+	dek, err := unwrap(data, keys)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := open(dek, data[headerSize:], data[:len(magic)])
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plain, nil
+}
+
+// Rewrap re-wraps the data key of sealed data under newKey. The content is
+// left untouched, so this is cheap regardless of file size.
+func Rewrap(data []byte, newKey *Key, keys ...*Key) ([]byte, error) {
+	dek, err := unwrap(data, keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, magic...)
+	out = append(out, newKey.id[:]...)
+	wrapped, err := seal(newKey.secret, dek, out)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, wrapped...)
+	return append(out, data[headerSize:]...), nil
+}
+
+// ReadFile reads a file, opening it with key when it is sealed. Plaintext
+// files are returned as-is so data written before encryption was enabled stays
+// readable until it is rekeyed.
+func ReadFile(path string, key *Key) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s is encrypted but no encryption key is configured", filepath.Base(path))
+	}
+	return Open(data, key)
+}
+
+// WriteFile atomically writes data to path, sealing it with key when key is
+// not nil.
+func WriteFile(path string, data []byte, key *Key, perm os.FileMode) error {
+	if key != nil {
+		sealed, err := key.Seal(data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func unwrap(data []byte, keys []*Key) ([]byte, error) {
+	if !IsSealed(data) {
+		return nil, ErrNotSealed
+	}
+	return unwrapHeader(data, keys)
+}
+
+// unwrapHeader returns the data key in a header of either layout; the magic
+// is authenticated along with the key id.
+func unwrapHeader(data []byte, keys []*Key) ([]byte, error) {
+	id := data[len(magic) : len(magic)+idSize]
+	for _, k := range keys {
+		if k == nil || !bytes.Equal(k.id[:], id) {
+			continue
+		}
+		dek, err := open(k.secret, data[len(magic)+idSize:headerSize], data[:len(magic)+idSize])
+		if err != nil || len(dek) != keySize {
+			return nil, ErrCorrupt
+		}
+		return dek, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// seal returns nonce || ciphertext, authenticating ad.
+func seal(key, plaintext, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(key, sealed, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < nonceSize+tagSize {
+		return nil, ErrCorrupt
+	}
+	return gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], ad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RekeyFile moves a file from oldKey to newKey. A nil oldKey means the file is
+// expected to be plaintext; a nil newKey decrypts it. It reports whether the
+// file was rewritten.
+func RekeyFile(path string, oldKey, newKey *Key) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	var out []byte
+	switch {
+	case IsSealed(data) && newKey != nil:
+		if bytes.Equal(data[len(magic):len(magic)+idSize], newKey.id[:]) {
+			return false, nil
+		}
+		out, err = Rewrap(data, newKey, oldKey)
+	case IsSealed(data):
+		out, err = Open(data, oldKey)
+	case newKey != nil:
+		out, err = newKey.Seal(data)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return true, WriteFile(path, out, nil, info.Mode().Perm())
+}
+
+// RekeyDir applies RekeyFile to every regular file in dir and returns how many
+// files were rewritten. A missing directory is not an error.
+func RekeyDir(dir string, oldKey, newKey *Key) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		changed, err := RekeyFile(filepath.Join(dir, e.Name()), oldKey, newKey)
+		if err != nil {
+			return n, err
+		}
+		if changed {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,121 @@
+package atrest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	s, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := ParseKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	k := testKey(t)
+	sealed, err := k.Seal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("hello")) {
+		t.Fatal("expected sealed output without plaintext")
+	}
+	plain, err := Open(sealed, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "hello" {
+		t.Errorf("expected hello, got %q", plain)
+	}
+}
+
+func TestOpenRejectsWrongKeyAndTampering(t *testing.T) {
+	k := testKey(t)
+	sealed, _ := k.Seal([]byte("secret"))
+
+	if _, err := Open(sealed, testKey(t)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	sealed[len(sealed)-1] ^= 0xff
+	if _, err := Open(sealed, k); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestRewrapKeepsContent(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	sealed, _ := oldKey.Seal([]byte("payload"))
+	rewrapped, err := Rewrap(sealed, newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rewrapped[headerSize:], sealed[headerSize:]) {
+		t.Error("expected content to be left untouched")
+	}
+	if _, err := Open(rewrapped, oldKey); err == nil {
+		t.Error("expected old key to no longer open the data")
+	}
+	plain, err := Open(rewrapped, newKey)
+	if err != nil || string(plain) != "payload" {
+		t.Errorf("expected payload, got %q (%v)", plain, err)
+	}
+}
+
+func TestParseKeyFormats(t *testing.T) {
+	hexKey := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	if _, err := ParseKey(hexKey); err != nil {
+		t.Errorf("expected hex key to parse: %v", err)
+	}
+	if _, err := ParseKey("too-short"); err == nil {
+		t.Error("expected short key to be rejected")
+	}
+
+	file := filepath.Join(t.TempDir(), "key")
+	raw := make([]byte, keySize)
+	os.WriteFile(file, raw, 0o600)
+	k, err := LoadKey("", file)
+	if err != nil || k == nil {
+		t.Fatalf("expected raw key file to load, got %v", err)
+	}
+	if k, err := LoadKey("", ""); k != nil || err != nil {
+		t.Errorf("expected no key when unset, got %v / %v", k, err)
+	}
+}
+
+func TestRekeyDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("bravo"), 0o644)
+	first, second := testKey(t), testKey(t)
+
+	if n, err := RekeyDir(dir, nil, first); err != nil || n != 2 {
+		t.Fatalf("expected 2 files encrypted, got %d (%v)", n, err)
+	}
+	if n, err := RekeyDir(dir, nil, first); err != nil || n != 0 {
+		t.Fatalf("expected rerun to be a no-op, got %d (%v)", n, err)
+	}
+	if n, err := RekeyDir(dir, first, second); err != nil || n != 2 {
+		t.Fatalf("expected 2 files rotated, got %d (%v)", n, err)
+	}
+	data, err := ReadFile(filepath.Join(dir, "a.txt"), second)
+	if err != nil || string(data) != "alpha" {
+		t.Fatalf("expected alpha, got %q (%v)", data, err)
+	}
+	if n, err := RekeyDir(dir, second, nil); err != nil || n != 2 {
+		t.Fatalf("expected 2 files decrypted, got %d (%v)", n, err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, "b.txt"))
+	if string(raw) != "bravo" {
+		t.Errorf("expected plaintext bravo, got %q", raw)
+	}
+}
@@ -0,0 +1,128 @@
+package atrest
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+)
+
+// keyMagic starts the header of a file encrypted block by block. The header
+// has the sealed layout without content: magic, key id and wrapped DEK.
+const keyMagic = "TTDRBLK1"
+
+const (
+	// KeyHeaderSize is the size of a block-encrypted file's key header.
+	KeyHeaderSize = headerSize
+	// BlockOverhead is what sealing adds to each block: a nonce and a tag.
+	BlockOverhead = nonceSize + tagSize
+)
+
+// DataKey encrypts a file in independently sealed blocks, so the file can be
+// updated in place one block at a time.
+type DataKey struct {
+	aead cipher.AEAD
+}
+
+// NewDataKey returns a fresh data key and its header, wrapped with k.
+func (k *Key) NewDataKey() (*DataKey, []byte, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, err
+	}
+	header := make([]byte, 0, KeyHeaderSize)
+	header = append(header, keyMagic...)
+	header = append(header, k.id[:]...)
+	wrapped, err := seal(k.secret, dek, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := newDataKey(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, append(header, wrapped...), nil
+}
+
+// NewEphemeralDataKey returns a random data key that is never stored, for
+// temporary files that do not outlive the process.
+func NewEphemeralDataKey() (*DataKey, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	return newDataKey(dek)
+}
+
+func newDataKey(dek []byte) (*DataKey, error) {
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{aead: aead}, nil
+}
+
+// IsKeyHeader reports whether data starts with a key header.
+func IsKeyHeader(data []byte) bool {
+	return len(data) >= KeyHeaderSize && string(data[:len(keyMagic)]) == keyMagic
+}
+
+// OpenDataKey unwraps the data key in a key header with any of keys.
+func OpenDataKey(header []byte, keys ...*Key) (*DataKey, error) {
+	if !IsKeyHeader(header) {
+		return nil, ErrNotSealed
+	}
+	dek, err := unwrapHeader(header[:KeyHeaderSize], keys)
+	if err != nil {
+		return nil, err
+	}
+	return newDataKey(dek)
+}
+
+// RewrapKeyHeader re-wraps the data key in a key header under newKey. The
+// blocks it encrypts stay valid.
+func RewrapKeyHeader(header []byte, newKey *Key, keys ...*Key) ([]byte, error) {
+	if !IsKeyHeader(header) {
+		return nil, ErrNotSealed
+	}
+	dek, err := unwrapHeader(header[:KeyHeaderSize], keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, KeyHeaderSize)
+	out = append(out, keyMagic...)
+	out = append(out, newKey.id[:]...)
+	wrapped, err := seal(newKey.secret, dek, out)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, wrapped...), nil
+}
+
+// Wraps reports whether the data key in a sealed file or key header is
+// wrapped with k.
+func (k *Key) Wraps(header []byte) bool {
+	return len(header) >= len(magic)+idSize && bytes.Equal(header[len(magic):len(magic)+idSize], k.id[:])
+}
+
+// SealBlock appends nonce || ciphertext of plaintext to dst. ad binds the
+// block to its position, so blocks cannot be moved around undetected.
+func (d *DataKey) SealBlock(dst, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return d.aead.Seal(dst, nonce, plaintext, ad), nil
+}
+
+// OpenBlock appends the plaintext of a block sealed with SealBlock to dst.
+func (d *DataKey) OpenBlock(dst, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < BlockOverhead {
+		return nil, ErrCorrupt
+	}
+	out, err := d.aead.Open(dst, sealed[:nonceSize], sealed[nonceSize:], ad)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return out, nil
+}
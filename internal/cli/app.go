@@ -3,6 +3,7 @@ package cli
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -111,15 +112,15 @@ func (a *App) Run(args []string) int {
 	ctx := context.Background()
 	switch rest[0] {
 	case "inbox":
-		return a.runInbox(ctx, client, resolved)
+		return a.runInbox(ctx, client, resolved, rest[1:])
 	case "today":
-		return a.runToday(ctx, client, resolved)
+		return a.runToday(ctx, client, resolved, rest[1:])
 	case "upcoming":
 		return a.runUpcoming(ctx, client, resolved, rest[1:])
 	case "anytime":
-		return a.runAnytime(ctx, client, resolved)
+		return a.runAnytime(ctx, client, resolved, rest[1:])
 	case "someday":
-		return a.runSomeday(ctx, client, resolved)
+		return a.runSomeday(ctx, client, resolved, rest[1:])
 	case "logbook":
 		return a.runLogbook(ctx, client, resolved, rest[1:])
 	case "add":
@@ -140,6 +141,8 @@ func (a *App) Run(args []string) int {
 		return a.runTaskMutation(ctx, client, resolved, "reopen", "/reopen", "logbook", rest[1:])
 	case "restore":
 		return a.runTaskMutation(ctx, client, resolved, "restore", "/restore", "trash", rest[1:])
+	case "skip":
+		return a.runTaskMutation(ctx, client, resolved, "skip", "/skip", "open", rest[1:])
 	case "delete":
 		return a.runDelete(ctx, client, resolved, rest[1:])
 	case "projects":
@@ -150,6 +153,12 @@ func (a *App) Run(args []string) int {
 		return a.runTags(ctx, client, resolved)
 	case "areas":
 		return a.runAreas(ctx, client, resolved)
+	case "habits":
+		return a.runHabits(ctx, client, resolved, rest[1:])
+	case "list":
+		return a.runList(ctx, client, resolved, rest[1:])
+	case "encryption":
+		return a.runEncryption(ctx, client, resolved, rest[1:])
 	default:
 		return a.fail(2, fmt.Sprintf("unknown command %q", rest[0]))
 	}
@@ -278,18 +287,83 @@ func (a *App) runDoctor(resolved ResolvedConfig) int {
 	return 0
 }
 
-func (a *App) runInbox(ctx context.Context, client *Client, cfg ResolvedConfig) int {
+// viewFlags are the filter and sort flags every view command takes. The
+// server resolves names, so they are passed through as given.
+type viewFlags struct {
+	tags     stringList
+	project  *string
+	area     *string
+	priority *string
+	deadline *string
+	sort     *string
+}
+
+func addViewFlags(fs *flag.FlagSet) *viewFlags {
+	v := &viewFlags{
+		project:  fs.String("project", "", ""),
+		area:     fs.String("area", "", ""),
+		priority: fs.String("priority", "", ""),
+		deadline: fs.String("deadline", "", ""),
+		sort:     fs.String("sort", "", ""),
+	}
+	fs.Var(&v.tags, "tag", "")
+	return v
+}
+
+// query adds the flags that were given to q, creating it if nil.
+func (v *viewFlags) query(q url.Values) url.Values {
+	if q == nil {
+		q = url.Values{}
+	}
+	for _, tag := range v.tags {
+		q.Add("tag", tag)
+	}
+	for key, value := range map[string]string{
+		"project": *v.project, "area": *v.area, "priority": *v.priority,
+		"deadline": *v.deadline, "sort": *v.sort,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	return q
+}
+
+// parseViewFlags parses the arguments of a view command that takes nothing
+// but the view flags.
+func parseViewFlags(name string, args []string) (*viewFlags, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	v := addViewFlags(fs)
+	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return v, nil
+}
+
+func (a *App) runInbox(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	filters, err := parseViewFlags("inbox", args)
+	if err != nil {
+		return a.fail(2, err.Error())
+	}
 	var view model.InboxView
-	raw, err := client.Get(ctx, "/api/views/inbox", nil, &view)
+	raw, err := client.Get(ctx, "/api/views/inbox", filters.query(nil), &view)
 	if err != nil {
 		return a.renderError(err)
 	}
 	return a.writeJSONOrText(cfg, raw, renderInbox(view))
 }
 
-func (a *App) runToday(ctx context.Context, client *Client, cfg ResolvedConfig) int {
+func (a *App) runToday(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	filters, err := parseViewFlags("today", args)
+	if err != nil {
+		return a.fail(2, err.Error())
+	}
 	var view model.TodayView
-	raw, err := client.Get(ctx, "/api/views/today", nil, &view)
+	raw, err := client.Get(ctx, "/api/views/today", filters.query(nil), &view)
 	if err != nil {
 		return a.renderError(err)
 	}
@@ -300,11 +374,12 @@ func (a *App) runUpcoming(ctx context.Context, client *Client, cfg ResolvedConfi
 	fs := flag.NewFlagSet("upcoming", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	from := fs.String("from", "", "")
+	filters := addViewFlags(fs)
 	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
 		return a.fail(2, err.Error())
 	}
 	var view model.UpcomingView
-	query := url.Values{}
+	query := filters.query(nil)
 	if *from != "" {
 		query.Set("from", *from)
 	}
@@ -315,18 +390,26 @@ func (a *App) runUpcoming(ctx context.Context, client *Client, cfg ResolvedConfi
 	return a.writeJSONOrText(cfg, raw, renderUpcoming(view))
 }
 
-func (a *App) runAnytime(ctx context.Context, client *Client, cfg ResolvedConfig) int {
+func (a *App) runAnytime(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	filters, err := parseViewFlags("anytime", args)
+	if err != nil {
+		return a.fail(2, err.Error())
+	}
 	var view model.AnytimeView
-	raw, err := client.Get(ctx, "/api/views/anytime", nil, &view)
+	raw, err := client.Get(ctx, "/api/views/anytime", filters.query(nil), &view)
 	if err != nil {
 		return a.renderError(err)
 	}
 	return a.writeJSONOrText(cfg, raw, renderAnytime("Anytime", view))
 }
 
-func (a *App) runSomeday(ctx context.Context, client *Client, cfg ResolvedConfig) int {
+func (a *App) runSomeday(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	filters, err := parseViewFlags("someday", args)
+	if err != nil {
+		return a.fail(2, err.Error())
+	}
 	var view model.AnytimeView
-	raw, err := client.Get(ctx, "/api/views/someday", nil, &view)
+	raw, err := client.Get(ctx, "/api/views/someday", filters.query(nil), &view)
 	if err != nil {
 		return a.renderError(err)
 	}
@@ -337,20 +420,46 @@ func (a *App) runLogbook(ctx context.Context, client *Client, cfg ResolvedConfig
 	fs := flag.NewFlagSet("logbook", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	limit := fs.Int("limit", 50, "")
-	offset := fs.Int("offset", 0, "")
-	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
+	cursor := fs.String("cursor", "", "")
+	all := fs.Bool("all", false, "")
+	filters := addViewFlags(fs)
+	if err := fs.Parse(normalizeFlagArgs(args, map[string]bool{"--all": true})); err != nil {
 		return a.fail(2, err.Error())
 	}
-	var view model.LogbookView
-	query := url.Values{
-		"limit":  {fmt.Sprintf("%d", *limit)},
-		"offset": {fmt.Sprintf("%d", *offset)},
+	query := filters.query(url.Values{"limit": {fmt.Sprintf("%d", *limit)}})
+	if *cursor != "" {
+		query.Set("cursor", *cursor)
 	}
+	var view model.LogbookView
 	raw, err := client.Get(ctx, "/api/views/logbook", query, &view)
 	if err != nil {
 		return a.renderError(err)
 	}
-	return a.writeJSONOrText(cfg, raw, renderLogbook("Logbook", view))
+	// --all follows the cursors to the end, joining the day a page ends on
+	// with its continuation on the next.
+	for *all && view.NextCursor != "" {
+		var next model.LogbookView
+		query.Set("cursor", view.NextCursor)
+		if _, err := client.Get(ctx, "/api/views/logbook", query, &next); err != nil {
+			return a.renderError(err)
+		}
+		for _, group := range next.Groups {
+			if n := len(view.Groups); n > 0 && view.Groups[n-1].Date == group.Date {
+				view.Groups[n-1].Tasks = append(view.Groups[n-1].Tasks, group.Tasks...)
+				continue
+			}
+			view.Groups = append(view.Groups, group)
+		}
+		view.NextCursor = next.NextCursor
+		if raw, err = json.Marshal(view); err != nil {
+			return a.fail(1, err.Error())
+		}
+	}
+	text := renderLogbook("Logbook", view)
+	if view.NextCursor != "" {
+		text += "\n\nMore: ttd logbook --cursor " + view.NextCursor
+	}
+	return a.writeJSONOrText(cfg, raw, text)
 }
 
 func (a *App) runAdd(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
@@ -364,6 +473,7 @@ func (a *App) runAdd(ctx context.Context, client *Client, cfg ResolvedConfig, ar
 	area := fs.String("area", "", "")
 	heading := fs.String("heading", "", "")
 	priority := fs.String("priority", "", "")
+	repeat := fs.String("repeat", "", "")
 	fs.Var(&tags, "tag", "")
 	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
 		return a.fail(2, err.Error())
@@ -372,10 +482,28 @@ func (a *App) runAdd(ctx context.Context, client *Client, cfg ResolvedConfig, ar
 		return a.fail(2, "usage: ttd add <title>")
 	}
 
-	inline, err := parseInlineAdd(strings.Join(fs.Args(), " "), a.now())
+	input := strings.Join(fs.Args(), " ")
+	inline, err := parseInline(input, a.now(), *repeat == "")
 	if err != nil {
 		return a.fail(2, err.Error())
 	}
+	repeatText := *repeat
+	if repeatText == "" {
+		repeatText = inline.Repeat
+	}
+	var pattern *model.RecurrencePattern
+	if repeatText != "" {
+		pattern, err = parseRecurrence(ctx, client, repeatText)
+		var apiErr *APIError
+		if err != nil && *repeat == "" && errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest {
+			// Not a recurrence after all ("Read every book"): keep the words
+			// in the title.
+			inline, err = parseInline(input, a.now(), false)
+		}
+		if err != nil {
+			return a.renderError(err)
+		}
+	}
 	if inline.Title == "" {
 		return a.fail(2, "title is required")
 	}
@@ -395,11 +523,20 @@ func (a *App) runAdd(ctx context.Context, client *Client, cfg ResolvedConfig, ar
 		}
 	}
 
+	key, err := a.contentKey(ctx, client)
+	if err != nil {
+		return a.renderError(err)
+	}
+	notesValue, err := encryptNotes(key, *notes)
+	if err != nil {
+		return a.fail(1, "error: "+err.Error())
+	}
+
 	tagNames := append([]string{}, inline.Tags...)
 	tagNames = append(tagNames, tags...)
 	payload := map[string]any{
 		"title": inline.Title,
-		"notes": *notes,
+		"notes": notesValue,
 	}
 	if whenValue != nil {
 		payload["when_date"] = *whenValue
@@ -440,10 +577,33 @@ func (a *App) runAdd(ctx context.Context, client *Client, cfg ResolvedConfig, ar
 	if err != nil {
 		return a.renderError(err)
 	}
+	if pattern != nil {
+		if _, err := client.Put(ctx, "/api/tasks/"+task.ID+"/repeat", map[string]any{"pattern": pattern}, nil); err != nil {
+			return a.renderError(err)
+		}
+		// The rule may have moved the task to its first occurrence.
+		id := task.ID
+		task = model.TaskDetail{}
+		if raw, err = client.Get(ctx, "/api/tasks/"+id, nil, &task); err != nil {
+			return a.renderError(err)
+		}
+	}
 	if cfg.Quiet {
 		return 0
 	}
-	return a.writeJSONOrText(cfg, raw, renderTaskDetail(task))
+	return a.renderTask(cfg, key, raw, task)
+}
+
+// parseRecurrence turns a phrase such as "every other friday" into a
+// pattern using the server's parser.
+func parseRecurrence(ctx context.Context, client *Client, text string) (*model.RecurrencePattern, error) {
+	var out struct {
+		Pattern model.RecurrencePattern `json:"pattern"`
+	}
+	if _, err := client.Get(ctx, "/api/parse/recurrence", url.Values{"text": {text}}, &out); err != nil {
+		return nil, err
+	}
+	return &out.Pattern, nil
 }
 
 func (a *App) runShow(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
@@ -464,38 +624,98 @@ func (a *App) runShow(ctx context.Context, client *Client, cfg ResolvedConfig, a
 	if err != nil {
 		return a.renderError(err)
 	}
+	key, err := a.contentKey(ctx, client)
+	if err != nil {
+		return a.renderError(err)
+	}
 	var task model.TaskDetail
 	raw, err := client.Get(ctx, "/api/tasks/"+id, nil, &task)
 	if err != nil {
 		return a.renderError(err)
 	}
-	return a.writeJSONOrText(cfg, raw, renderTaskDetail(task))
+	return a.renderTask(cfg, key, raw, task)
 }
 
 func (a *App) runSearch(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
 	fs := flag.NewFlagSet("search", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
 	limit := fs.Int("limit", 20, "")
-	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
+	reindex := fs.Bool("reindex", false, "")
+	if err := fs.Parse(normalizeFlagArgs(args, map[string]bool{"--reindex": true})); err != nil {
 		return a.fail(2, err.Error())
 	}
+	if *reindex {
+		return a.runReindex(ctx, client, cfg)
+	}
 	if fs.NArg() < 1 {
-		return a.fail(2, "usage: ttd search <query>")
+		return a.fail(2, "usage: ttd search <query> | ttd search --reindex")
 	}
 	var resp struct {
 		Results []model.SearchResult `json:"results"`
 	}
+	q := searchQuery(fs.Args())
 	query := url.Values{
-		"q":     {strings.Join(fs.Args(), " ")},
+		"q":     {q},
 		"limit": {fmt.Sprintf("%d", *limit)},
 	}
 	raw, err := client.Get(ctx, "/api/search", query, &resp)
 	if err != nil {
-		return a.renderError(err)
+		code := a.renderError(err)
+		// Point at the part of the query the server could not parse.
+		var problem struct {
+			Position *int `json:"position"`
+		}
+		if json.Unmarshal(raw, &problem) == nil && problem.Position != nil {
+			_, _ = fmt.Fprintf(a.stderr, "  %s\n  %s^\n", q, strings.Repeat(" ", *problem.Position))
+		}
+		return code
 	}
 	return a.writeJSONOrText(cfg, raw, renderSearch(resp.Results))
 }
 
+// runReindex asks the server to extract the text of all uploaded files again.
+func (a *App) runReindex(ctx context.Context, client *Client, cfg ResolvedConfig) int {
+	var resp struct {
+		Queued int `json:"queued"`
+	}
+	raw, err := client.Post(ctx, "/api/search/reindex", nil, &resp)
+	if err != nil {
+		return a.renderError(err)
+	}
+	return a.writeJSONOrText(cfg, raw, fmt.Sprintf("Reindexing %d attachments", resp.Queued))
+}
+
+// searchQuery joins search arguments into one query. A single argument is
+// the query as typed; with several, a filter the shell has unquoted, like
+// `project:Q3 close` from `project:"Q3 close"`, gets its quotes back.
+func searchQuery(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg
+		key, value, ok := strings.Cut(arg, ":")
+		if ok && strings.ContainsAny(value, " \t") && !strings.Contains(value, `"`) && isFilterKey(key) {
+			parts[i] = key + `:"` + value + `"`
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func isFilterKey(key string) bool {
+	key = strings.TrimPrefix(key, "-")
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *App) runEdit(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
 	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
 	fs.SetOutput(io.Discard)
@@ -531,12 +751,21 @@ func (a *App) runEdit(ctx context.Context, client *Client, cfg ResolvedConfig, a
 		return a.renderError(err)
 	}
 
+	key, err := a.contentKey(ctx, client)
+	if err != nil {
+		return a.renderError(err)
+	}
+
 	payload := map[string]any{}
 	if *title != "" {
 		payload["title"] = *title
 	}
 	if *notes != "" {
-		payload["notes"] = *notes
+		notesValue, err := encryptNotes(key, *notes)
+		if err != nil {
+			return a.fail(1, "error: "+err.Error())
+		}
+		payload["notes"] = notesValue
 	}
 	if *when != "" {
 		if strings.EqualFold(*when, "none") {
@@ -615,7 +844,7 @@ func (a *App) runEdit(ctx context.Context, client *Client, cfg ResolvedConfig, a
 	if cfg.Quiet {
 		return 0
 	}
-	return a.writeJSONOrText(cfg, raw, renderTaskDetail(task))
+	return a.renderTask(cfg, key, raw, task)
 }
 
 func (a *App) runTaskMutation(ctx context.Context, client *Client, cfg ResolvedConfig, name, suffix, scope string, args []string) int {
@@ -738,6 +967,86 @@ func (a *App) runAreas(ctx context.Context, client *Client, cfg ResolvedConfig)
 	return a.writeJSONOrText(cfg, raw, renderAreas(resp.Areas))
 }
 
+// runList prints the smart lists, or with a name the tasks of one of them.
+func (a *App) runList(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	filters := addViewFlags(fs)
+	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
+		return a.fail(2, err.Error())
+	}
+	args = fs.Args()
+	var lists struct {
+		SmartLists []model.SmartList `json:"smart_lists"`
+	}
+	raw, err := client.Get(ctx, "/api/smart-lists", nil, &lists)
+	if err != nil {
+		return a.renderError(err)
+	}
+	if len(args) == 0 {
+		return a.writeJSONOrText(cfg, raw, renderSmartLists(lists.SmartLists))
+	}
+	refs := make([]namedRef, 0, len(lists.SmartLists))
+	for _, l := range lists.SmartLists {
+		refs = append(refs, namedRef{ID: l.ID, Title: l.Name})
+	}
+	// Names often look like IDs ("Errands"), so match them as names too.
+	id, err := matchByName("smart list", strings.Join(args, " "), refs)
+	if err != nil {
+		return a.renderError(err)
+	}
+	var view model.SmartListView
+	raw, err = client.Get(ctx, "/api/views/smart/"+id, filters.query(nil), &view)
+	if err != nil {
+		return a.renderError(err)
+	}
+	return a.writeJSONOrText(cfg, raw, renderSmartList(view))
+}
+
+func (a *App) runHabits(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	fs := flag.NewFlagSet("habits", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	weeks := fs.Int("weeks", 12, "")
+	if err := fs.Parse(normalizeFlagArgs(args, nil)); err != nil {
+		return a.fail(2, err.Error())
+	}
+	if *weeks < 1 || *weeks > 104 {
+		return a.fail(2, "--weeks must be between 1 and 104")
+	}
+
+	// The heat map starts on the Monday of the first week shown.
+	now := a.now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -7*(*weeks-1)-(int(to.Weekday())+6)%7)
+	query := url.Values{
+		"from": {from.Format("2006-01-02")},
+		"to":   {to.Format("2006-01-02")},
+	}
+
+	var series []model.SeriesStats
+	raw, err := client.Get(ctx, "/api/series", query, &series)
+	if err != nil {
+		return a.renderError(err)
+	}
+	if fs.NArg() > 0 {
+		refs := make([]namedRef, 0, len(series))
+		for _, s := range series {
+			refs = append(refs, namedRef{ID: s.SeriesID, Title: s.Title})
+		}
+		id, err := matchByName("habit", strings.Join(fs.Args(), " "), refs)
+		if err != nil {
+			return a.renderError(err)
+		}
+		var stats model.SeriesStats
+		raw, err = client.Get(ctx, "/api/series/"+id+"/stats", query, &stats)
+		if err != nil {
+			return a.renderError(err)
+		}
+		series = []model.SeriesStats{stats}
+	}
+	return a.writeJSONOrText(cfg, raw, renderHabits(series))
+}
+
 func (a *App) resolveTaskRelations(ctx context.Context, client *Client, projectRef, areaRef, headingRef, currentProjectID string, tagRefs []string) (*string, *string, *string, []string, int) {
 	var projectID *string
 	var areaID *string
@@ -1001,15 +1310,27 @@ commands:
   reopen
   cancel
   wontdo
+  skip
   delete
   restore
   projects
   project show
   tags
   areas
+  habits
+  list [name]
   version
   doctor
   config
+  encryption status|init
+
+view flags (inbox, today, upcoming, anytime, someday, logbook, list):
+  --tag <name>  --project <name>  --area <name>  --priority high|normal
+  --deadline any|none|overdue|<date>  --sort <field>
+
+environment:
+  THINGSTODO_PASSPHRASE  unlocks client-side encryption; notes written by add
+                         and edit are encrypted and show decrypts them
 `)
 }
 
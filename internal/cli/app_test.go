@@ -21,7 +21,8 @@ import (
 
 func newTestCLI(t *testing.T) (*App, *Client) {
 	t.Helper()
-	db := testutil.SetupTestDB(t)
+	pools := testutil.SetupTestPools(t)
+	db := pools.Write
 	userRepo := repository.NewUserRepository(db)
 	if _, err := userRepo.Create("admin", "x"); err != nil {
 		t.Fatal(err)
@@ -46,7 +47,7 @@ func newTestCLI(t *testing.T) (*App, *Client) {
 	pushSender := push.NewSender(pushSubRepo, "", "", "")
 	changeLogRepo := repository.NewChangeLogRepository(db)
 	sched := scheduler.New(db, taskRepo, ruleRepo, checklistRepo, attachRepo, scheduleRepo, reminderRepo, settingsRepo, userRepo, changeLogRepo, pushSender, broker, time.UTC)
-	handler := router.New(db, cfg, broker, sched)
+	handler := router.New(pools, cfg, broker, sched)
 
 	httpClient := &http.Client{
 		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
@@ -126,6 +127,219 @@ func TestCLIAddInlineDateAndTag(t *testing.T) {
 	}
 }
 
+func TestCLIAddRepeat(t *testing.T) {
+	app, client := newTestCLI(t)
+	findTask := func(title string) map[string]any {
+		t.Helper()
+		var tasks struct {
+			Tasks []map[string]any `json:"tasks"`
+		}
+		if _, err := client.Get(t.Context(), "/api/tasks", urlValues("search", title), &tasks); err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks.Tasks) != 1 || tasks.Tasks[0]["title"] != title {
+			t.Fatalf("expected one task %q, got %v", title, tasks.Tasks)
+		}
+		var task map[string]any
+		if _, err := client.Get(t.Context(), "/api/tasks/"+tasks.Tasks[0]["id"].(string), nil, &task); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	pattern := func(task map[string]any) map[string]any {
+		t.Helper()
+		rule, _ := task["repeat_rule"].(map[string]any)
+		if rule == nil {
+			t.Fatalf("expected a repeat rule on %v", task["title"])
+		}
+		return rule["pattern"].(map[string]any)
+	}
+
+	code, _, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "add", "Water plants tomorrow every other friday")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	task := findTask("Water plants")
+	if task["when_date"] != "2026-04-10" {
+		t.Fatalf("expected when_date 2026-04-10, got %v", task["when_date"])
+	}
+	if p := pattern(task); p["type"] != "weekly" || p["every"] != float64(2) {
+		t.Fatalf("expected every other week, got %v", p)
+	}
+
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "add", "Stretch", "--repeat", "every weekday after completion")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if p := pattern(findTask("Stretch")); p["type"] != "daily_weekday" || p["mode"] != "after_completion" {
+		t.Fatalf("expected weekdays after completion, got %v", p)
+	}
+
+	// Words that only look like a recurrence stay in the title.
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "add", "Read every book tomorrow")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	task = findTask("Read every book")
+	if task["repeat_rule"] != nil || task["when_date"] != "2026-04-10" {
+		t.Fatalf("expected a plain task for tomorrow, got %v", task)
+	}
+
+	code, _, _ = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "add", "Stretch again", "--repeat", "every book")
+	if code != 2 {
+		t.Fatalf("expected exit 2 for an unrecognized --repeat, got %d", code)
+	}
+}
+
+func TestCLISearchQuery(t *testing.T) {
+	app, client := newTestCLI(t)
+	var area, project map[string]any
+	if _, err := client.Post(t.Context(), "/api/areas", map[string]any{"title": "Finance"}, &area); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Post(t.Context(), "/api/projects", map[string]any{"title": "Q3 close", "area_id": area["id"]}, &project); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Send invoice", "Invoice template"} {
+		body := map[string]any{"title": title}
+		if title == "Send invoice" {
+			body["project_id"] = project["id"]
+		}
+		if _, err := client.Post(t.Context(), "/api/tasks", body, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The shell strips the quotes of project:"Q3 close".
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "search", "invoice", "project:Q3 close")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Send invoice") || strings.Contains(stdout, "Invoice template") {
+		t.Fatalf("unexpected results:\n%s", stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "search", "close")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Projects\nQ3 close  Finance") {
+		t.Fatalf("expected the project under its group, got:\n%s", stdout)
+	}
+
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "search", "invoice due:<soon")
+	if code != 2 {
+		t.Fatalf("expected exit 2, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "invoice due:<soon\n               ^") {
+		t.Fatalf("expected a caret under the bad value, got:\n%s", stderr)
+	}
+
+	code, stdout, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "search", "--reindex")
+	if code != 0 || strings.TrimSpace(stdout) != "Reindexing 0 attachments" {
+		t.Fatalf("unexpected reindex output: code=%d stdout=%s stderr=%s", code, stdout, stderr)
+	}
+}
+
+func TestCLIViewFilters(t *testing.T) {
+	app, client := newTestCLI(t)
+	for _, body := range []map[string]any{
+		{"title": "Book flights", "when_date": "2026-04-09", "high_priority": true},
+		{"title": "Pack bags", "when_date": "2026-04-09"},
+	} {
+		if _, err := client.Post(t.Context(), "/api/tasks", body, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "today", "--priority", "high")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Book flights") || strings.Contains(stdout, "Pack bags") {
+		t.Fatalf("expected only the high priority task:\n%s", stdout)
+	}
+
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "today", "--sort", "size")
+	if code == 0 {
+		t.Fatalf("expected an unknown sort to fail, stderr=%s", stderr)
+	}
+}
+
+func TestCLILogbookPages(t *testing.T) {
+	app, client := newTestCLI(t)
+	for _, title := range []string{"File taxes", "Renew passport", "Return library books"} {
+		var task struct {
+			ID string `json:"id"`
+		}
+		if _, err := client.Post(t.Context(), "/api/tasks", map[string]any{"title": title}, &task); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Patch(t.Context(), "/api/tasks/"+task.ID+"/complete", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "logbook", "--limit", "2", "--sort", "title")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	i := strings.Index(stdout, "More: ttd logbook --cursor ")
+	if i < 0 || !strings.Contains(stdout, "File taxes") || strings.Contains(stdout, "Return library books") {
+		t.Fatalf("expected the first page and a cursor:\n%s", stdout)
+	}
+	cursor := strings.TrimSpace(stdout[i+len("More: ttd logbook --cursor "):])
+
+	code, stdout, _ = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "logbook", "--limit", "2", "--sort", "title", "--cursor", cursor)
+	if code != 0 || !strings.Contains(stdout, "Return library books") || strings.Contains(stdout, "File taxes") || strings.Contains(stdout, "More:") {
+		t.Fatalf("expected the last page, got code=%d:\n%s", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "logbook", "--limit", "1", "--all")
+	if code != 0 || !strings.Contains(stdout, "File taxes") || !strings.Contains(stdout, "Renew passport") || !strings.Contains(stdout, "Return library books") {
+		t.Fatalf("expected every task with --all, got code=%d:\n%s", code, stdout)
+	}
+	if strings.Contains(stdout, "More:") {
+		t.Errorf("expected no cursor after --all:\n%s", stdout)
+	}
+}
+
+func TestCLISmartList(t *testing.T) {
+	app, client := newTestCLI(t)
+	for _, body := range []map[string]any{
+		{"title": "Buy stamps", "deadline": "2026-04-12", "high_priority": true},
+		{"title": "Buy milk", "deadline": "2026-04-10"},
+		{"title": "Call plumber"},
+	} {
+		if _, err := client.Post(t.Context(), "/api/tasks", body, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := map[string]any{"name": "Errands", "query": "buy", "sort": "deadline", "group_by": "deadline"}
+	if _, err := client.Post(t.Context(), "/api/smart-lists", list, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "list", "errands")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	milk, stamps := strings.Index(stdout, "2026-04-10\n"), strings.Index(stdout, "2026-04-12\n")
+	if !strings.HasPrefix(stdout, "Errands\n") || milk < 0 || stamps < milk || strings.Contains(stdout, "plumber") {
+		t.Fatalf("unexpected smart list output:\n%s", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "list")
+	if code != 0 || !strings.Contains(stdout, "Errands  buy") {
+		t.Fatalf("expected the list of smart lists, got code=%d:\n%s", code, stdout)
+	}
+
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "list", "groceries")
+	if code == 0 {
+		t.Fatalf("expected an unknown smart list to fail, stderr=%s", stderr)
+	}
+}
+
 func TestCLIAmbiguousDone(t *testing.T) {
 	app, client := newTestCLI(t)
 	for _, title := range []string{"Send invoice", "Pay invoice"} {
@@ -153,6 +367,33 @@ func TestCLIUnknownTagFails(t *testing.T) {
 	}
 }
 
+func TestCLISkipMovesToNextOccurrence(t *testing.T) {
+	app, client := newTestCLI(t)
+	var task map[string]any
+	if _, err := client.Post(t.Context(), "/api/tasks", map[string]any{
+		"title": "Water plants", "when_date": "2026-04-09",
+	}, &task); err != nil {
+		t.Fatal(err)
+	}
+	id := task["id"].(string)
+	if _, err := client.Put(t.Context(), "/api/tasks/"+id+"/repeat", map[string]any{
+		"pattern": map[string]any{"type": "weekly", "every": 1, "mode": "fixed", "on": []string{"thu"}},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "skip", "Water plants")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if _, err := client.Get(t.Context(), "/api/tasks/"+id, nil, &task); err != nil {
+		t.Fatal(err)
+	}
+	if task["when_date"] != "2026-04-16" {
+		t.Fatalf("expected when_date 2026-04-16, got %v", task["when_date"])
+	}
+}
+
 func urlValues(k, v string) url.Values {
 	return url.Values{k: []string{v}}
 }
@@ -162,3 +403,45 @@ type roundTripFunc func(req *http.Request) (*http.Response, error)
 func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
+
+func TestCLIHabitsShowsSeriesStreak(t *testing.T) {
+	app, client := newTestCLI(t)
+	// Completion timestamps come from the server clock.
+	app.now = time.Now
+	today := time.Now().Format("2006-01-02")
+
+	var task map[string]any
+	if _, err := client.Post(t.Context(), "/api/tasks", map[string]any{
+		"title": "Meditate", "when_date": today,
+	}, &task); err != nil {
+		t.Fatal(err)
+	}
+	id := task["id"].(string)
+	if _, err := client.Put(t.Context(), "/api/tasks/"+id+"/repeat", map[string]any{
+		"pattern": map[string]any{"type": "daily", "every": 1, "mode": "fixed"},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Patch(t.Context(), "/api/tasks/"+id+"/complete", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats map[string]any
+	if _, err := client.Get(t.Context(), "/api/series/"+id+"/stats", nil, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats["completed"] != float64(1) || stats["task_id"] == nil || stats["task_id"] == id {
+		t.Fatalf("expected one completion and a new open instance: %v", stats)
+	}
+
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "habits", "--weeks", "2", "meditate")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Meditate") || !strings.Contains(stdout, "streak 1  longest 1  rate 100%") {
+		t.Fatalf("unexpected output:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "#") {
+		t.Fatalf("expected today's completion in the heat map:\n%s", stdout)
+	}
+}
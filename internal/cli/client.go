@@ -54,6 +54,10 @@ func (c *Client) Patch(ctx context.Context, path string, body any, dest any) ([]
 	return c.do(ctx, http.MethodPatch, path, nil, body, dest)
 }
 
+func (c *Client) Put(ctx context.Context, path string, body any, dest any) ([]byte, error) {
+	return c.do(ctx, http.MethodPut, path, nil, body, dest)
+}
+
 func (c *Client) Delete(ctx context.Context, path string) ([]byte, error) {
 	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
 }
@@ -7,9 +7,19 @@ import (
 )
 
 type InlineAddMeta struct {
-	Title string
-	When  *string
-	Tags  []string
+	Title  string
+	When   *string
+	Tags   []string
+	Repeat string
+}
+
+// repeatStarters begin a recurrence phrase at the end of an inline title,
+// e.g. "Water plants every other friday". The server decides whether the
+// phrase really is one.
+var repeatStarters = map[string]bool{
+	"daily": true, "weekly": true, "biweekly": true, "fortnightly": true,
+	"monthly": true, "quarterly": true, "yearly": true, "annually": true,
+	"hourly": true, "weekdays": true, "weekends": true,
 }
 
 func parseDateExpression(input string, now time.Time) (*string, error) {
@@ -60,6 +70,12 @@ func parseDateExpression(input string, now time.Time) (*string, error) {
 }
 
 func parseInlineAdd(input string, now time.Time) (InlineAddMeta, error) {
+	return parseInline(input, now, true)
+}
+
+// parseInline splits inline capture into title, trailing #tags, a trailing
+// recurrence phrase (when withRepeat is set) and a date expression before it.
+func parseInline(input string, now time.Time, withRepeat bool) (InlineAddMeta, error) {
 	meta := InlineAddMeta{Title: strings.TrimSpace(input)}
 	if meta.Title == "" {
 		return meta, nil
@@ -78,6 +94,13 @@ func parseInlineAdd(input string, now time.Time) (InlineAddMeta, error) {
 		words = words[:len(words)-1]
 	}
 
+	if withRepeat {
+		if i := repeatStart(words); i >= 0 {
+			meta.Repeat = strings.Join(words[i:], " ")
+			words = words[:i]
+		}
+	}
+
 	candidates := []int{2, 1}
 	for _, n := range candidates {
 		if len(words) < n {
@@ -95,3 +118,22 @@ func parseInlineAdd(input string, now time.Time) (InlineAddMeta, error) {
 	meta.Title = strings.TrimSpace(strings.Join(words, " "))
 	return meta, nil
 }
+
+// repeatStart returns the index of the word a trailing recurrence phrase
+// starts at, or -1. The last "every" or "each" wins, so "Read every book
+// every friday" repeats on fridays.
+func repeatStart(words []string) int {
+	every, other := -1, -1
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if w == "every" || w == "each" {
+			every = i
+		} else if repeatStarters[w] {
+			other = i
+		}
+	}
+	if every >= 0 {
+		return every
+	}
+	return other
+}
@@ -42,3 +42,25 @@ func TestParseInlineAdd(t *testing.T) {
 		t.Fatalf("unexpected tags: %#v", meta.Tags)
 	}
 }
+
+func TestParseInlineAddRepeat(t *testing.T) {
+	now := time.Date(2026, 4, 9, 12, 0, 0, 0, time.UTC)
+	meta, err := parseInlineAdd("Read every book tomorrow every other friday #reading", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Read every book" || meta.Repeat != "every other friday" {
+		t.Fatalf("unexpected title %q and repeat %q", meta.Title, meta.Repeat)
+	}
+	if meta.When == nil || *meta.When != "2026-04-10" {
+		t.Fatalf("expected tomorrow to parse, got %v", meta.When)
+	}
+
+	meta, err = parseInlineAdd("Pay rent monthly on the 1st", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Pay rent" || meta.Repeat != "monthly on the 1st" {
+		t.Fatalf("unexpected title %q and repeat %q", meta.Title, meta.Repeat)
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+const passphraseEnv = "THINGSTODO_PASSPHRASE"
+
+func (a *App) runEncryption(ctx context.Context, client *Client, cfg ResolvedConfig, args []string) int {
+	if len(args) == 0 {
+		return a.fail(2, "usage: ttd encryption <status|init>")
+	}
+	switch args[0] {
+	case "status":
+		var key e2ee.WrappedKey
+		raw, err := client.Get(ctx, "/api/encryption/key", nil, &key)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+				return a.writeJSONOrText(cfg, []byte(`{"enabled":false}`), "encryption: not set up")
+			}
+			return a.renderError(err)
+		}
+		text := fmt.Sprintf("encryption: enabled (%s, %s, %d iterations)", key.Algorithm, key.KDF, key.KDFIterations)
+		if a.env(passphraseEnv) != "" {
+			if _, err := e2ee.UnwrapKey(key, a.env(passphraseEnv)); err != nil {
+				text += "\npassphrase: " + err.Error()
+			} else {
+				text += "\npassphrase: ok"
+			}
+		}
+		return a.writeJSONOrText(cfg, raw, text)
+	case "init":
+		passphrase := a.env(passphraseEnv)
+		if passphrase == "" {
+			return a.fail(2, "missing passphrase; set "+passphraseEnv)
+		}
+		_, err := client.Get(ctx, "/api/encryption/key", nil, nil)
+		if err == nil {
+			return a.fail(2, "encryption is already set up")
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+			return a.renderError(err)
+		}
+		key, err := e2ee.GenerateKey()
+		if err != nil {
+			return a.fail(1, "error: "+err.Error())
+		}
+		wrapped, err := e2ee.WrapKey(key, passphrase)
+		if err != nil {
+			return a.fail(1, "error: "+err.Error())
+		}
+		raw, err := client.Put(ctx, "/api/encryption/key", wrapped, nil)
+		if err != nil {
+			return a.renderError(err)
+		}
+		if cfg.Quiet {
+			return 0
+		}
+		return a.writeJSONOrText(cfg, raw, "encryption: enabled; notes and checklist items written with "+passphraseEnv+" set are now encrypted")
+	default:
+		return a.fail(2, fmt.Sprintf("unknown encryption command %q", args[0]))
+	}
+}
+
+// contentKey unlocks the user's data key with the passphrase from the
+// environment. It returns nil when no passphrase is set, so commands fall back
+// to plaintext.
+func (a *App) contentKey(ctx context.Context, client *Client) ([]byte, error) {
+	passphrase := a.env(passphraseEnv)
+	if passphrase == "" {
+		return nil, nil
+	}
+	var wrapped e2ee.WrappedKey
+	if _, err := client.Get(ctx, "/api/encryption/key", nil, &wrapped); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil, errors.New("encryption is not set up; run ttd encryption init")
+		}
+		return nil, err
+	}
+	return e2ee.UnwrapKey(wrapped, passphrase)
+}
+
+// encryptNotes encrypts notes when a data key is unlocked.
+func encryptNotes(key []byte, notes string) (string, error) {
+	if key == nil {
+		return notes, nil
+	}
+	return e2ee.EncryptString(key, notes)
+}
+
+// renderTask writes a task, decrypting it first when a data key is unlocked.
+func (a *App) renderTask(cfg ResolvedConfig, key []byte, raw []byte, task model.TaskDetail) int {
+	if key != nil {
+		var err error
+		if raw, err = decryptTaskDetail(key, &task); err != nil {
+			return a.fail(1, "error: "+err.Error())
+		}
+	}
+	return a.writeJSONOrText(cfg, raw, renderTaskDetail(task))
+}
+
+// decryptTaskDetail decrypts notes and checklist titles in place and returns
+// the task re-encoded as JSON.
+func decryptTaskDetail(key []byte, task *model.TaskDetail) ([]byte, error) {
+	notes, err := e2ee.DecryptString(key, task.Notes)
+	if err != nil {
+		return nil, err
+	}
+	task.Notes = notes
+	for i := range task.Checklist {
+		title, err := e2ee.DecryptString(key, task.Checklist[i].Title)
+		if err != nil {
+			return nil, err
+		}
+		task.Checklist[i].Title = title
+	}
+	return json.Marshal(task)
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
+)
+
+func TestCLIEncryptedNotesRoundTrip(t *testing.T) {
+	app, client := newTestCLI(t)
+	app.env = func(k string) string {
+		if k == passphraseEnv {
+			return "correct horse battery staple"
+		}
+		return ""
+	}
+
+	code, _, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "encryption", "init")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	code, _, stderr = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "add", "Passport renewal", "--notes", "number 1234")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+
+	var tasks struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	if _, err := client.Get(t.Context(), "/api/tasks", urlValues("search", "Passport"), &tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks.Tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks.Tasks))
+	}
+	id := tasks.Tasks[0]["id"].(string)
+	var stored struct {
+		Notes string `json:"notes"`
+	}
+	if _, err := client.Get(t.Context(), "/api/tasks/"+id, nil, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !e2ee.IsEncrypted(stored.Notes) {
+		t.Fatalf("expected server to store ciphertext, got %q", stored.Notes)
+	}
+
+	code, stdout, stderr := runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "show", "--id", id)
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d stderr=%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "number 1234") {
+		t.Fatalf("expected decrypted notes, got %s", stdout)
+	}
+
+	app.env = func(string) string { return "" }
+	_, stdout, _ = runCLI(t, app, "--url", client.baseURL, "--api-key", "test-key", "show", "--id", id)
+	if strings.Contains(stdout, "number 1234") || !strings.Contains(stdout, "(encrypted") {
+		t.Fatalf("expected notes hidden without passphrase, got %s", stdout)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
 	"github.com/collinjanssen/thingstodo/internal/model"
 )
 
@@ -57,7 +58,11 @@ func renderTaskDetail(task model.TaskDetail) string {
 	if task.Notes != "" {
 		fmt.Fprintln(&b)
 		fmt.Fprintln(&b, "notes:")
-		fmt.Fprintln(&b, task.Notes)
+		if e2ee.IsEncrypted(task.Notes) {
+			fmt.Fprintln(&b, "(encrypted; set THINGSTODO_PASSPHRASE to read)")
+		} else {
+			fmt.Fprintln(&b, task.Notes)
+		}
 	}
 	return strings.TrimRight(b.String(), "\n")
 }
@@ -163,11 +168,69 @@ func renderLogbook(title string, view model.LogbookView) string {
 	return strings.TrimRight(b.String(), "\n")
 }
 
+var searchGroupTitles = map[string]string{
+	"project":        "Projects",
+	"area":           "Areas",
+	"heading":        "Headings",
+	"checklist_item": "Checklist Items",
+}
+
 func renderSearch(results []model.SearchResult) string {
 	var b strings.Builder
 	fmt.Fprintln(&b, "Search")
+	group := "task"
 	for _, result := range results {
-		fmt.Fprintln(&b, renderTaskLine(result.Task))
+		if result.Type == "task" || result.Type == "" {
+			if result.Task != nil {
+				fmt.Fprintln(&b, renderTaskLine(*result.Task))
+			}
+			continue
+		}
+		if result.Type != group {
+			group = result.Type
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, searchGroupTitles[group])
+		}
+		parts := []string{result.Title}
+		if result.Type == "checklist_item" {
+			mark := "[ ]"
+			if result.Status == "completed" {
+				mark = "[x]"
+			}
+			parts = []string{mark, result.Title}
+		}
+		if result.Parent != nil && result.Parent.Title != "" {
+			parts = append(parts, result.Parent.Title)
+		}
+		fmt.Fprintln(&b, strings.Join(parts, "  "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func renderSmartLists(lists []model.SmartList) string {
+	var b strings.Builder
+	for _, l := range lists {
+		fmt.Fprintf(&b, "%s  %s  %s\n", l.ID, l.Name, l.Query)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func renderSmartList(view model.SmartListView) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, view.SmartList.Name)
+	for _, group := range view.Groups {
+		title := group.Title
+		switch {
+		case group.Project != nil:
+			title = group.Project.Title
+		case view.SmartList.GroupBy == "project":
+			title = "No Project"
+		}
+		if title != "" {
+			fmt.Fprintln(&b)
+			fmt.Fprintln(&b, title)
+		}
+		writeTaskLines(&b, group.Tasks)
 	}
 	return strings.TrimRight(b.String(), "\n")
 }
@@ -196,6 +259,41 @@ func renderTags(tags []model.Tag) string {
 	return strings.TrimRight(b.String(), "\n")
 }
 
+// renderHabits prints each series with its streaks and a heat map of the
+// requested days: one row per weekday, one column per week. "#" marks a day
+// with a completion, "x" a due day without one and "." any other day.
+func renderHabits(series []model.SeriesStats) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "Habits")
+	for _, s := range series {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, s.Title)
+		fmt.Fprintf(&b, "streak %d  longest %d  rate %.0f%%  missed %d\n",
+			s.CurrentStreak, s.LongestStreak, s.CompletionRate*100, s.Missed)
+		weeks := (len(s.Days) + 6) / 7
+		for weekday, label := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+			row := make([]byte, weeks)
+			for week := range row {
+				row[week] = ' '
+				i := week*7 + weekday
+				if i >= len(s.Days) {
+					continue
+				}
+				switch day := s.Days[i]; {
+				case day.Completed > 0:
+					row[week] = '#'
+				case day.Due > 0:
+					row[week] = 'x'
+				default:
+					row[week] = '.'
+				}
+			}
+			fmt.Fprintf(&b, "%s %s\n", label, strings.TrimRight(string(row), " "))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func writeTaskLines(b *strings.Builder, tasks []model.TaskListItem) {
 	for _, task := range tasks {
 		fmt.Fprintln(b, renderTaskLine(task))
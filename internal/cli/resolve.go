@@ -124,9 +124,15 @@ func resolveByName(kind, selector string, refs []namedRef) (string, error) {
 	if isIDLike(selector) {
 		return selector, nil
 	}
+	return matchByName(kind, selector, refs)
+}
+
+// matchByName picks the ref whose ID equals selector or whose title matches
+// it, without assuming an ID-like selector is an ID.
+func matchByName(kind, selector string, refs []namedRef) (string, error) {
 	var matches []namedRef
 	for _, ref := range refs {
-		if strings.EqualFold(ref.Title, selector) {
+		if ref.ID == selector || strings.EqualFold(ref.Title, selector) {
 			return ref.ID, nil
 		}
 		if strings.Contains(strings.ToLower(ref.Title), strings.ToLower(selector)) {
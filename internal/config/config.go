@@ -2,10 +2,14 @@ package config
 
 import (
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
 )
 
 type Config struct {
@@ -31,6 +35,27 @@ type Config struct {
 	VAPIDPublicKey  string
 	VAPIDContact    string
 
+	// Change log: entries older than ChangeLogCompactDays are compacted to the
+	// latest entry per entity; entries older than ChangeLogRetentionDays are
+	// purged entirely (0 disables purging).
+	ChangeLogCompactDays   int
+	ChangeLogRetentionDays int
+
+	// DBReadConns is the size of the read-only connection pool that serves
+	// list and view reads.
+	DBReadConns int
+
+	// OpenTelemetry tracing: spans are exported over OTLP/HTTP to
+	// OTLPEndpoint when it is set (OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or
+	// OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces appended).
+	OTLPEndpoint    string
+	OTLPHeaders     map[string]string
+	OTELServiceName string
+
+	// Encryption at rest: when set, the database and attachment files are
+	// stored encrypted with this key (ENCRYPTION_KEY or ENCRYPTION_KEY_FILE).
+	EncryptionKey *atrest.Key
+
 	// Timezone for date/time calculations (IANA name, e.g. "America/Chicago")
 	Location *time.Location
 }
@@ -58,7 +83,22 @@ func Load() Config {
 		VAPIDPrivateKey: envStr("VAPID_PRIVATE_KEY", ""),
 		VAPIDPublicKey:  envStr("VAPID_PUBLIC_KEY", ""),
 		VAPIDContact:    envStr("VAPID_CONTACT", ""),
+
+		ChangeLogCompactDays:   envInt("CHANGE_LOG_COMPACT_DAYS", 30),
+		ChangeLogRetentionDays: envInt("CHANGE_LOG_RETENTION_DAYS", 0),
+
+		DBReadConns: envInt("DB_READ_CONNS", 8),
+
+		OTLPEndpoint:    otlpEndpoint(),
+		OTLPHeaders:     parseOTLPHeaders(envStr("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		OTELServiceName: envStr("OTEL_SERVICE_NAME", "thingstodo"),
+	}
+
+	key, err := atrest.LoadKey(envStr("ENCRYPTION_KEY", ""), envStr("ENCRYPTION_KEY_FILE", ""))
+	if err != nil {
+		log.Fatalf("invalid encryption key: %v", err)
 	}
+	cfg.EncryptionKey = key
 
 	if (cfg.AuthMode == "builtin" || cfg.AuthMode == "oidc") && cfg.JWTSecret == "" {
 		log.Fatal("JWT_SECRET must be set when AUTH_MODE is builtin or oidc")
@@ -78,6 +118,33 @@ func Load() Config {
 	return cfg
 }
 
+func otlpEndpoint() string {
+	if v := envStr("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""); v != "" {
+		return v
+	}
+	if v := envStr("OTEL_EXPORTER_OTLP_ENDPOINT", ""); v != "" {
+		return strings.TrimRight(v, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// parseOTLPHeaders reads OTEL_EXPORTER_OTLP_HEADERS: comma-separated
+// key=value pairs with URL-encoded values.
+func parseOTLPHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			v = unescaped
+		}
+		headers[strings.TrimSpace(k)] = v
+	}
+	return headers
+}
+
 func envStr(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
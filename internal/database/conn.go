@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/tracing"
+	sqlite "modernc.org/sqlite"
+)
+
+// connector opens SQLite connections for one pool, wrapped so the pool's
+// statements are timed and traced.
+type connector struct {
+	dsn     string
+	pool    string
+	metrics *poolMetrics
+}
+
+var sqliteDriver = &sqlite.Driver{}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := sqliteDriver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, pool: c.pool, metrics: c.metrics}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return sqliteDriver }
+
+// conn times the statements run on a driver connection, traces them when
+// their context carries a span, and passes everything else through.
+type conn struct {
+	driver.Conn
+	pool    string
+	metrics *poolMetrics
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	span := c.startSpan(ctx, "db.exec", query)
+	start := time.Now()
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	c.metrics.observe(start, err)
+	span.End(err)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	span := c.startSpan(ctx, "db.query", query)
+	start := time.Now()
+	rs, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		c.metrics.observe(start, err)
+		span.End(err)
+		return nil, err
+	}
+	return &rows{Rows: rs, metrics: c.metrics, span: span, start: start}, nil
+}
+
+// startSpan starts the span of one statement, or returns nil when ctx is not
+// part of a trace.
+func (c *conn) startSpan(ctx context.Context, name, query string) *tracing.Span {
+	span := tracing.StartChild(ctx, name)
+	span.SetAttr("db.system", "sqlite")
+	span.SetAttr("db.pool", c.pool)
+	span.SetAttr("db.statement", query)
+	return span
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *conn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// rows records its query when closed, so the time spent stepping through
+// the results counts too.
+type rows struct {
+	driver.Rows
+	metrics *poolMetrics
+	span    *tracing.Span
+	start   time.Time
+	err     error
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *rows) Close() error {
+	err := r.Rows.Close()
+	r.metrics.observe(r.start, r.err)
+	r.span.End(r.err)
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -17,7 +18,35 @@ import (
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
-func Open(dbPath string) (*sql.DB, error) {
+// DefaultReadConns is the size of the reader pool Open creates.
+const DefaultReadConns = 8
+
+// DB is the application database, opened as two connection pools. Write has
+// a single connection, so writers queue in Go instead of contending for
+// SQLite's write lock under busy_timeout. Read holds read-only connections
+// that WAL lets run alongside the writer; list and view reads go there.
+type DB struct {
+	Write *sql.DB
+	Read  *sql.DB
+
+	pools []*pool
+}
+
+type pool struct {
+	name    string
+	db      *sql.DB
+	metrics poolMetrics
+}
+
+// Open opens the database at dbPath, creating it if missing, and runs
+// migrations.
+func Open(dbPath string) (*DB, error) {
+	return openFile(dbPath, "")
+}
+
+// openFile opens dbPath through the named SQLite VFS, or the default one
+// when vfs is empty.
+func openFile(dbPath, vfs string) (*DB, error) {
 	// Ensure the directory exists.
 	if dir := filepath.Dir(dbPath); dir != "." {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -25,25 +54,92 @@ func Open(dbPath string) (*sql.DB, error) {
 		}
 	}
 
-	dsn := dbPath + "?_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
-	db, err := sql.Open("sqlite", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+	params := "?"
+	if vfs != "" {
+		params += "vfs=" + vfs + "&"
 	}
-
-	if err := db.Ping(); err != nil {
+	db := &DB{}
+	db.Write = db.open("write", dbPath+params+"_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)&_txlock=immediate")
+	db.Write.SetMaxOpenConns(1)
+	if err := db.Write.Ping(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
 
-	if err := runMigrations(db); err != nil {
+	if err := runMigrations(db.Write); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("run migrations: %w", err)
 	}
 
+	// Readers open once the writer has switched the file to WAL.
+	db.Read = db.open("read", dbPath+params+"_pragma=busy_timeout(5000)&_pragma=query_only(1)")
+	db.SetReadConns(DefaultReadConns)
+	if err := db.Read.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
 	return db, nil
 }
 
+// open adds a pool over dsn whose statements are counted in Stats.
+func (db *DB) open(name, dsn string) *sql.DB {
+	p := &pool{name: name, metrics: newPoolMetrics(name)}
+	p.db = sql.OpenDB(&connector{dsn: dsn, pool: name, metrics: &p.metrics})
+	db.pools = append(db.pools, p)
+	livePools.Store(p, struct{}{})
+	return p.db
+}
+
+// SetReadConns sets how many connections the reader pool keeps open.
+func (db *DB) SetReadConns(n int) {
+	if db.Read == db.Write {
+		return
+	}
+	db.Read.SetMaxOpenConns(n)
+	db.Read.SetMaxIdleConns(n)
+}
+
+// Stats reports the connections and statement counts of each pool.
+func (db *DB) Stats() []PoolStats {
+	stats := make([]PoolStats, 0, len(db.pools))
+	for _, p := range db.pools {
+		s := p.db.Stats()
+		stats = append(stats, PoolStats{
+			Name:       p.name,
+			MaxOpen:    s.MaxOpenConnections,
+			Open:       s.OpenConnections,
+			InUse:      s.InUse,
+			Idle:       s.Idle,
+			WaitCount:  s.WaitCount,
+			WaitMs:     milliseconds(s.WaitDuration),
+			Queries:    p.metrics.queries.Load(),
+			Errors:     p.metrics.errors.Load(),
+			Busy:       p.metrics.busy.Load(),
+			Slow:       p.metrics.slow.Load(),
+			QueryMs:    milliseconds(time.Duration(p.metrics.total.Load())),
+			MaxQueryMs: milliseconds(time.Duration(p.metrics.max.Load())),
+		})
+	}
+	return stats
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Close closes both pools.
+func (db *DB) Close() error {
+	var err error
+	for _, p := range db.pools {
+		livePools.Delete(p)
+		if cerr := p.db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 func runMigrations(db *sql.DB) error {
 	// Create migrations tracking table.
 	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS _migrations (
@@ -1,9 +1,18 @@
 package database_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 
+	"github.com/collinjanssen/thingstodo/internal/database"
 	"github.com/collinjanssen/thingstodo/internal/testutil"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 )
 
 func TestOpenAndMigrate(t *testing.T) {
@@ -50,3 +59,175 @@ func TestForeignKeysEnabled(t *testing.T) {
 		t.Errorf("expected foreign_keys=1, got %d", fk)
 	}
 }
+
+func TestReadPoolIsReadOnly(t *testing.T) {
+	db := testutil.SetupTestPools(t)
+
+	if _, err := db.Read.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Home')"); err == nil {
+		t.Fatal("expected a write through the read pool to fail")
+	}
+	if _, err := db.Write.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Home')"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var title string
+	if err := db.Read.QueryRow("SELECT title FROM areas WHERE id = 'a1'").Scan(&title); err != nil {
+		t.Fatalf("read after write: %v", err)
+	}
+	if title != "Home" {
+		t.Errorf("expected the committed title, got %q", title)
+	}
+}
+
+func TestReadsDoNotWaitForWriter(t *testing.T) {
+	db := testutil.SetupTestPools(t)
+	if _, err := db.Write.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Home')"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Write.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE areas SET title = 'Work' WHERE id = 'a1'"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writer holds its only connection and the write lock; a reader
+	// still sees the last committed state.
+	var title string
+	if err := db.Read.QueryRow("SELECT title FROM areas WHERE id = 'a1'").Scan(&title); err != nil {
+		t.Fatalf("read during write: %v", err)
+	}
+	if title != "Home" {
+		t.Errorf("expected the committed title, got %q", title)
+	}
+}
+
+func TestStatsCountStatements(t *testing.T) {
+	db := testutil.SetupTestPools(t)
+	before := db.Stats()
+
+	if _, err := db.Write.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Home')"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Read.Query("SELECT id FROM areas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	_, _ = db.Read.Exec("DELETE FROM areas")
+
+	stats := db.Stats()
+	if len(stats) != 2 || stats[0].Name != "write" || stats[1].Name != "read" {
+		t.Fatalf("expected write and read pools, got %+v", stats)
+	}
+	if stats[0].MaxOpen != 1 {
+		t.Errorf("expected a single writer connection, got %d", stats[0].MaxOpen)
+	}
+	if got := stats[0].Queries - before[0].Queries; got != 1 {
+		t.Errorf("expected 1 write statement, got %d", got)
+	}
+	if got := stats[1].Queries - before[1].Queries; got != 2 {
+		t.Errorf("expected 2 read statements, got %d", got)
+	}
+	if got := stats[1].Errors - before[1].Errors; got != 1 {
+		t.Errorf("expected the rejected write to count as an error, got %d", got)
+	}
+}
+
+func TestChangeLogStateKeepsEarlierPurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roll back to before the compaction migration, with a log whose first
+	// five entries were purged.
+	for i := 0; i < 8; i++ {
+		if _, err := db.Write.Exec(`INSERT INTO change_log (entity, entity_id, action, snapshot) VALUES ('task', 't1', 'update', '{}')`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range []string{
+		`DELETE FROM change_log WHERE seq <= 5`,
+		`DROP TABLE change_log_state`,
+		`DELETE FROM _migrations WHERE name = '029_change_log_compaction.sql'`,
+	} {
+		if _, err := db.Write.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	db.Close()
+
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var purged int64
+	if err := db.Write.QueryRow(`SELECT purged_through_seq FROM change_log_state WHERE id = 1`).Scan(&purged); err != nil {
+		t.Fatal(err)
+	}
+	if purged != 5 {
+		t.Errorf("purged_through_seq = %d, want 5", purged)
+	}
+}
+
+func TestStatementsAreTracedWithinASpan(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		names []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						Name string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					names = append(names, s.Name)
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+	db := testutil.SetupTestPools(t)
+	shutdown := tracing.Setup(tracing.Options{Endpoint: srv.URL})
+
+	// Statements outside a span are not traced.
+	if _, err := db.Write.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Home')"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, span := tracing.Start(context.Background(), "view.today")
+	if _, err := db.Write.ExecContext(ctx, "UPDATE areas SET title = 'Work'"); err != nil {
+		t.Fatal(err)
+	}
+	var title string
+	if err := db.Read.QueryRowContext(ctx, "SELECT title FROM areas").Scan(&title); err != nil {
+		t.Fatal(err)
+	}
+	span.End(nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"db.exec", "db.query", "view.today"}; !slices.Equal(names, want) {
+		t.Errorf("spans = %v, want %v", names, want)
+	}
+}
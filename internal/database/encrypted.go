@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+)
+
+// sqliteMagic is the header of a plaintext SQLite database file.
+const sqliteMagic = "SQLite format 3\x00"
+
+// OpenEncrypted opens the database at dbPath like Open, through a VFS that
+// encrypts every page with AES-256-GCM as SQLite writes it. The data key is
+// stored in the file's header, wrapped with key. A plaintext database is
+// refused; Rekey encrypts it.
+func OpenEncrypted(dbPath string, key *atrest.Key) (*DB, error) {
+	if err := checkKey(dbPath, key); err != nil {
+		return nil, err
+	}
+	vfs, err := registerVFS(key)
+	if err != nil {
+		return nil, err
+	}
+	return openFile(dbPath, vfs)
+}
+
+// checkKey explains why a database cannot be opened with key, before SQLite
+// reports it as "file is not a database".
+func checkKey(dbPath string, key *atrest.Key) error {
+	header, err := readHeader(dbPath)
+	switch {
+	case err != nil:
+		return fmt.Errorf("read database: %w", err)
+	case len(header) == 0:
+		return nil
+	case IsPlaintext(header):
+		return errors.New("database is not encrypted; run `ttd-server rekey` to encrypt it")
+	case !atrest.IsKeyHeader(header):
+		return errors.New("database file is neither SQLite nor encrypted")
+	}
+	if _, err := atrest.OpenDataKey(header, key); err != nil {
+		return fmt.Errorf("open database key: %w", err)
+	}
+	return nil
+}
+
+// readHeader returns the start of the file at path: its key header if it is
+// encrypted. A missing file has an empty header.
+func readHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, atrest.KeyHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// IsPlaintext reports whether data is the start of an unencrypted SQLite
+// database file.
+func IsPlaintext(data []byte) bool {
+	return len(data) >= len(sqliteMagic) && string(data[:len(sqliteMagic)]) == sqliteMagic
+}
+
+// Rekey moves the database file at dbPath from oldKey to newKey. A nil oldKey
+// encrypts a plaintext database and a nil newKey decrypts it. Rotating the
+// key only re-wraps the data key in the file header; encrypting or
+// decrypting copies the database. The server must not be running while the
+// file is rekeyed.
+func Rekey(dbPath string, oldKey, newKey *atrest.Key) error {
+	header, err := readHeader(dbPath)
+	if err != nil {
+		return fmt.Errorf("read database: %w", err)
+	}
+	switch {
+	case len(header) == 0:
+		return nil
+	case IsPlaintext(header):
+		if newKey == nil {
+			return nil
+		}
+		vfs, err := registerVFS(newKey)
+		if err != nil {
+			return err
+		}
+		return convert(dbPath, dbPath, defaultVFS(), vfs)
+	case !atrest.IsKeyHeader(header):
+		return errors.New("database file is neither SQLite nor encrypted")
+	case newKey != nil:
+		if newKey.Wraps(header) {
+			return nil
+		}
+		return rewrapHeader(dbPath, header, oldKey, newKey)
+	default:
+		if oldKey == nil {
+			return errors.New("database is encrypted; set the current key to decrypt it")
+		}
+		if _, err := atrest.OpenDataKey(header, oldKey); err != nil {
+			return fmt.Errorf("open database key: %w", err)
+		}
+		vfs, err := registerVFS(oldKey)
+		if err != nil {
+			return err
+		}
+		return convert(dbPath, dbPath, vfs, defaultVFS())
+	}
+}
+
+// rewrapHeader re-wraps the data key in the header of the database at path
+// under newKey. The header is written in place in one sector-sized write, and
+// the pages, WAL and journal stay valid since the data key is unchanged.
+func rewrapHeader(path string, header []byte, oldKey, newKey *atrest.Key) error {
+	out, err := atrest.RewrapKeyHeader(header, newKey, oldKey)
+	if err != nil {
+		return fmt.Errorf("rekey database: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(out, 0); err != nil {
+		f.Close()
+		return fmt.Errorf("rekey database: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("rekey database: %w", err)
+	}
+	return f.Close()
+}
+
+// convert copies the database at src, opened with srcVFS, to dst through
+// dstVFS. The copy is written next to
+// dst and renamed over it once complete, so an interrupted run leaves the
+// original in place.
+func convert(src, dst, srcVFS, dstVFS string) error {
+	db, err := sql.Open("sqlite", src+"?vfs="+srcVFS)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	// Fold the WAL into the main file, so none is left behind for the copy.
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpoint database: %w", err)
+	}
+
+	tmp := dst + ".rekey"
+	os.Remove(tmp)
+	abs, err := filepath.Abs(tmp)
+	if err != nil {
+		return err
+	}
+	// VACUUM INTO would otherwise write through the source's VFS.
+	target := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: "vfs=" + dstVFS}
+	if _, err := db.Exec("VACUUM INTO ?", target.String()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copy database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	os.Remove(dst + "-wal")
+	os.Remove(dst + "-shm")
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncFile(filepath.Dir(dst))
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
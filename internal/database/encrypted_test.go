@@ -0,0 +1,257 @@
+package database_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"github.com/collinjanssen/thingstodo/internal/database"
+)
+
+func newKey(t *testing.T) *atrest.Key {
+	t.Helper()
+	s, err := atrest.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := atrest.ParseKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptedDatabasePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enc.db")
+	key := newKey(t)
+
+	db, err := database.OpenEncrypted(path, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Write.Exec(`INSERT INTO areas (id, title) VALUES ('a1', 'Confidential area')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	var title string
+	if err := db.Read.QueryRow(`SELECT title FROM areas WHERE id = 'a1'`).Scan(&title); err != nil {
+		t.Fatalf("query from reader pool: %v", err)
+	}
+	// The commit is in the WAL until a checkpoint; both files are sealed.
+	for _, name := range []string{path, path + "-wal"} {
+		if raw, err := os.ReadFile(name); err != nil || bytes.Contains(raw, []byte("Confidential")) {
+			t.Fatalf("expected %s to be encrypted (%v)", filepath.Base(name), err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if database.IsPlaintext(raw) || bytes.Contains(raw, []byte("Confidential")) {
+		t.Fatal("expected database file to be encrypted")
+	}
+
+	db, err = database.OpenEncrypted(path, key)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	if err := db.Read.QueryRow(`SELECT title FROM areas WHERE id = 'a1'`).Scan(&title); err != nil {
+		t.Fatalf("query after reopen: %v", err)
+	}
+	if title != "Confidential area" {
+		t.Errorf("expected persisted title, got %q", title)
+	}
+
+	if _, err := database.OpenEncrypted(path, newKey(t)); err == nil {
+		t.Error("expected open with the wrong key to fail")
+	}
+}
+
+func TestEncryptedCommitSurvivesCrash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "enc.db")
+	key := newKey(t)
+
+	db, err := database.OpenEncrypted(path, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Write.Exec(`INSERT INTO areas (id, title) VALUES ('a1', 'Autocommit')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	tx, err := db.Write.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`INSERT INTO areas (id, title) VALUES ('a2', 'Transaction')`); err != nil {
+		t.Fatalf("insert in tx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// Copy the files without closing the database, as after a crash; the
+	// copy recovers the commits from its WAL.
+	copyDir := t.TempDir()
+	for _, suffix := range []string{"", "-wal"} {
+		raw, err := os.ReadFile(path + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(copyDir, "enc.db"+suffix), raw, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reopened, err := database.OpenEncrypted(filepath.Join(copyDir, "enc.db"), key)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	var n int
+	if err := reopened.Write.QueryRow(`SELECT count(*) FROM areas WHERE id IN ('a1', 'a2')`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected both commits to survive, found %d", n)
+	}
+}
+
+func TestEncryptedDatabaseGrowsAndShrinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enc.db")
+	db, err := database.OpenEncrypted(path, newKey(t))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Rows of uneven sizes spill over many pages and overflow pages.
+	for i := 0; i < 300; i++ {
+		notes := strings.Repeat("note ", i*7)
+		if _, err := db.Write.Exec(`INSERT INTO areas (id, title) VALUES (?, ?)`, fmt.Sprint("a", i), notes); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+	if _, err := db.Write.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if _, err := db.Write.Exec(`DELETE FROM areas WHERE rowid % 2 = 0`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// A rollback journal and a shrinking file go through the VFS too;
+	// leaving WAL mode needs the readers gone.
+	db.Read.Close()
+	for _, stmt := range []string{`PRAGMA journal_mode=DELETE`, `VACUUM`, `PRAGMA journal_mode=WAL`} {
+		if _, err := db.Write.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	var check string
+	if err := db.Write.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Fatalf("integrity check: %q (%v)", check, err)
+	}
+	var n int
+	if err := db.Write.QueryRow(`SELECT count(*) FROM areas`).Scan(&n); err != nil || n != 150 {
+		t.Fatalf("expected 150 areas, got %d (%v)", n, err)
+	}
+}
+
+func TestEncryptedDatabaseDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enc.db")
+	key := newKey(t)
+	db, err := database.OpenEncrypted(path, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := db.Write.Exec(`INSERT INTO areas (id, title) VALUES ('a1', 'Home')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a byte in the first page, which every connection reads.
+	raw[atrest.KeyHeaderSize+100] ^= 0xff
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err = database.OpenEncrypted(path, key)
+	if err == nil {
+		defer db.Close()
+		var check string
+		err = db.Write.QueryRow(`PRAGMA integrity_check`).Scan(&check)
+	}
+	if err == nil {
+		t.Fatal("expected a tampered page to fail to read")
+	}
+}
+
+func TestEncryptedOpenRejectsPlaintextDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	_, err = database.OpenEncrypted(path, newKey(t))
+	if err == nil || !strings.Contains(err.Error(), "ttd-server rekey") {
+		t.Fatalf("expected a rekey hint, got %v", err)
+	}
+}
+
+func TestRekeyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Write.Exec(`INSERT INTO areas (id, title) VALUES ('a1', 'Home')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	first, second := newKey(t), newKey(t)
+	if err := database.Rekey(path, nil, first); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if err := database.Rekey(path, first, second); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	if _, err := database.OpenEncrypted(path, first); err == nil {
+		t.Fatal("expected the old key to be rejected after rotation")
+	}
+	db, err = database.OpenEncrypted(path, second)
+	if err != nil {
+		t.Fatalf("open with rotated key: %v", err)
+	}
+	var title string
+	if err := db.Write.QueryRow(`SELECT title FROM areas WHERE id = 'a1'`).Scan(&title); err != nil || title != "Home" {
+		t.Fatalf("expected Home, got %q (%v)", title, err)
+	}
+	db.Close()
+
+	if err := database.Rekey(path, second, nil); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatalf("open decrypted: %v", err)
+	}
+	defer db.Close()
+	if err := db.Write.QueryRow(`SELECT title FROM areas WHERE id = 'a1'`).Scan(&title); err != nil || title != "Home" {
+		t.Fatalf("expected Home after decrypt, got %q (%v)", title, err)
+	}
+}
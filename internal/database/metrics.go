@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/metrics"
+	sqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SlowQuery is how long a statement runs before it counts as slow.
+const SlowQuery = 100 * time.Millisecond
+
+// PoolStats describes one connection pool: its connections as reported by
+// database/sql, and the statements run on it since the database was opened.
+// A query runs until its rows are closed.
+type PoolStats struct {
+	Name       string  `json:"name"`
+	MaxOpen    int     `json:"max_open"`
+	Open       int     `json:"open"`
+	InUse      int     `json:"in_use"`
+	Idle       int     `json:"idle"`
+	WaitCount  int64   `json:"wait_count"`
+	WaitMs     float64 `json:"wait_ms"`
+	Queries    int64   `json:"queries"`
+	Errors     int64   `json:"errors"`
+	Busy       int64   `json:"busy"`
+	Slow       int64   `json:"slow"`
+	QueryMs    float64 `json:"query_ms"`
+	MaxQueryMs float64 `json:"max_query_ms"`
+}
+
+var (
+	queryDuration = metrics.NewHistogram("thingstodo_sqlite_query_duration_seconds",
+		"Time from running an SQLite statement to closing its rows.", metrics.DefBuckets, "pool")
+	queryErrors = metrics.NewCounter("thingstodo_sqlite_query_errors_total",
+		"SQLite statements that failed.", "pool")
+	queryBusy = metrics.NewCounter("thingstodo_sqlite_busy_total",
+		"SQLite statements that failed with SQLITE_BUSY.", "pool")
+)
+
+// livePools are the pools of every open DB, for the connection metrics.
+var livePools sync.Map // *pool -> struct{}
+
+func init() {
+	metrics.NewGaugeFunc("thingstodo_sqlite_connections",
+		"Open SQLite connections by pool and state.", []string{"pool", "state"}, func(emit metrics.Emit) {
+			for name, s := range poolDBStats() {
+				emit(float64(s.InUse), name, "in_use")
+				emit(float64(s.Idle), name, "idle")
+			}
+		})
+	metrics.NewCounterFunc("thingstodo_sqlite_connection_waits_total",
+		"Times a statement waited for a free connection.", []string{"pool"}, func(emit metrics.Emit) {
+			for name, s := range poolDBStats() {
+				emit(float64(s.WaitCount), name)
+			}
+		})
+}
+
+// poolDBStats sums the database/sql stats of the live pools by name.
+func poolDBStats() map[string]sql.DBStats {
+	out := make(map[string]sql.DBStats)
+	livePools.Range(func(k, _ any) bool {
+		p := k.(*pool)
+		s, cur := p.db.Stats(), out[p.name]
+		cur.InUse += s.InUse
+		cur.Idle += s.Idle
+		cur.WaitCount += s.WaitCount
+		out[p.name] = cur
+		return true
+	})
+	return out
+}
+
+// poolMetrics counts the statements run on one pool. Durations are in
+// nanoseconds.
+type poolMetrics struct {
+	queries atomic.Int64
+	errors  atomic.Int64
+	busy    atomic.Int64
+	slow    atomic.Int64
+	total   atomic.Int64
+	max     atomic.Int64
+
+	duration   metrics.Histogram
+	errorCount metrics.Counter
+	busyCount  metrics.Counter
+}
+
+func newPoolMetrics(name string) poolMetrics {
+	return poolMetrics{
+		duration:   queryDuration.With(name),
+		errorCount: queryErrors.With(name),
+		busyCount:  queryBusy.With(name),
+	}
+}
+
+func (m *poolMetrics) observe(start time.Time, err error) {
+	d := int64(time.Since(start))
+	m.duration.Observe(time.Duration(d).Seconds())
+	m.queries.Add(1)
+	m.total.Add(d)
+	for cur := m.max.Load(); d > cur && !m.max.CompareAndSwap(cur, d); cur = m.max.Load() {
+	}
+	if d >= int64(SlowQuery) {
+		m.slow.Add(1)
+	}
+	if err != nil {
+		m.errors.Add(1)
+		m.errorCount.Inc()
+		var se *sqlite.Error
+		if errors.As(err, &se) && se.Code()&0xff == sqlite3.SQLITE_BUSY {
+			m.busy.Add(1)
+			m.busyCount.Inc()
+		}
+	}
+}
@@ -0,0 +1,13 @@
+-- Change log compaction: old ranges keep only the latest entry per entity.
+-- purged_through_seq records the highest seq removed by retention purging so
+-- cursor expiry no longer depends on MIN(seq), which compaction moves. It
+-- starts just below the oldest entry left, since a log purged before this
+-- migration already expires cursors there.
+CREATE TABLE IF NOT EXISTS change_log_state (
+    id                 INTEGER PRIMARY KEY CHECK (id = 1),
+    purged_through_seq INTEGER NOT NULL DEFAULT 0
+);
+INSERT OR IGNORE INTO change_log_state (id, purged_through_seq)
+VALUES (1, (SELECT COALESCE(MIN(seq), 1) - 1 FROM change_log));
+
+CREATE INDEX IF NOT EXISTS idx_change_log_created_at ON change_log(created_at);
@@ -0,0 +1,8 @@
+-- Undo bookkeeping for the change log. 'undone' marks a change that /api/undo
+-- has reversed; 'undo' marks a change written by the undo itself so it is not
+-- picked up by a later undo.
+CREATE TABLE IF NOT EXISTS change_log_undo (
+    seq        INTEGER PRIMARY KEY REFERENCES change_log(seq) ON DELETE CASCADE,
+    kind       TEXT NOT NULL CHECK (kind IN ('undone', 'undo')),
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
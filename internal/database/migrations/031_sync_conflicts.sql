@@ -0,0 +1,19 @@
+-- Conflict inbox: when a pushed update overwrites a newer server version, the
+-- client's change is kept here with the server snapshot it replaced.
+CREATE TABLE IF NOT EXISTS sync_conflicts (
+    id                TEXT PRIMARY KEY,
+    entity            TEXT NOT NULL,
+    entity_id         TEXT NOT NULL,
+    device_id         TEXT NOT NULL DEFAULT '',
+    fields            TEXT NOT NULL DEFAULT '[]',
+    client_data       TEXT NOT NULL,
+    client_updated_at TEXT NOT NULL,
+    server_snapshot   TEXT NOT NULL,
+    server_updated_at TEXT NOT NULL,
+    status            TEXT NOT NULL DEFAULT 'open'
+                      CHECK (status IN ('open', 'accepted_client', 'kept_server', 'merged')),
+    created_at        TEXT NOT NULL DEFAULT (datetime('now')),
+    resolved_at       TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_conflicts_status ON sync_conflicts(status, created_at);
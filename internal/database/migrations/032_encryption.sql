@@ -0,0 +1,35 @@
+-- Client-side encryption: the server stores the user's data key only in
+-- wrapped form, and keeps encrypted notes ('ttd:enc:v1:' prefix) out of FTS.
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    user_id        TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    algorithm      TEXT NOT NULL,
+    kdf            TEXT NOT NULL,
+    kdf_iterations INTEGER NOT NULL,
+    salt           TEXT NOT NULL,
+    wrapped_key    TEXT NOT NULL,
+    created_at     TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at     TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+ALTER TABLE attachments ADD COLUMN encrypted INTEGER NOT NULL DEFAULT 0;
+
+-- The 'delete' rows must repeat exactly what was indexed, so both sides apply
+-- the same CASE. Existing rows hold no encrypted notes, so nothing to rebuild.
+DROP TRIGGER IF EXISTS tasks_fts_insert;
+DROP TRIGGER IF EXISTS tasks_fts_update;
+DROP TRIGGER IF EXISTS tasks_fts_delete;
+
+CREATE TRIGGER tasks_fts_insert AFTER INSERT ON tasks BEGIN
+    INSERT INTO tasks_fts(rowid, title, notes) VALUES (new.rowid, new.title,
+        CASE WHEN new.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE new.notes END);
+END;
+CREATE TRIGGER tasks_fts_update AFTER UPDATE ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, notes) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE old.notes END);
+    INSERT INTO tasks_fts(rowid, title, notes) VALUES (new.rowid, new.title,
+        CASE WHEN new.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE new.notes END);
+END;
+CREATE TRIGGER tasks_fts_delete AFTER DELETE ON tasks BEGIN
+    INSERT INTO tasks_fts(tasks_fts, rowid, title, notes) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE old.notes END);
+END;
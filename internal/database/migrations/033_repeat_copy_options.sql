@@ -0,0 +1,4 @@
+-- Per-rule choice of what each new repeat instance copies from the previous
+-- one (JSON). An empty object means the defaults: notes, checklist and
+-- attachments are all copied, as before.
+ALTER TABLE repeat_rules ADD COLUMN copy_options TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+-- Workday calendar for the recurrence engine: '' (Monday to Friday), a
+-- built-in holiday set ('us', 'gb', 'de', 'nl') or 'ics' for the uploaded
+-- calendar in workday_calendar_ics.
+ALTER TABLE user_settings ADD COLUMN workday_calendar TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_settings ADD COLUMN workday_calendar_ics TEXT NOT NULL DEFAULT '';
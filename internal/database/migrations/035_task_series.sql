@@ -0,0 +1,6 @@
+-- Every instance of a repeating task shares a series_id: the id of the task
+-- the repeat rule was first set on. Existing repeating tasks start their
+-- series here; earlier instances cannot be linked after the fact.
+ALTER TABLE tasks ADD COLUMN series_id TEXT;
+CREATE INDEX idx_tasks_series_id ON tasks(series_id);
+UPDATE tasks SET series_id = id WHERE id IN (SELECT task_id FROM repeat_rules);
@@ -0,0 +1,3 @@
+-- Per-rule policy for occurrences of a fixed-schedule rule that come due
+-- while the open instance is still waiting: none, all, latest or skip.
+ALTER TABLE repeat_rules ADD COLUMN catch_up TEXT NOT NULL DEFAULT 'none';
@@ -0,0 +1,19 @@
+-- Repeat rules on whole projects. When a repeating project is finished, the
+-- next occurrence gets a copy of it with its headings and tasks reopened.
+CREATE TABLE project_repeat_rules (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL UNIQUE REFERENCES projects(id) ON DELETE CASCADE,
+    pattern TEXT NOT NULL,
+    copy_options TEXT NOT NULL DEFAULT '{}',
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Every instance of a repeating project shares a series_id: the id of the
+-- project the rule was first set on.
+ALTER TABLE projects ADD COLUMN series_id TEXT;
+CREATE INDEX idx_projects_series_id ON projects(series_id);
+
+-- Instances of a repeating project share its title, so titles only need to
+-- be unique among open projects.
+DROP INDEX idx_projects_title;
+CREATE UNIQUE INDEX idx_projects_title ON projects(title) WHERE status = 'open';
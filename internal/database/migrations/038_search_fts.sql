@@ -0,0 +1,100 @@
+-- Full-text search beyond tasks: projects, areas, headings, checklist items,
+-- attachments and tags, kept in sync by triggers like tasks_fts. Encrypted
+-- values ('ttd:enc:v1:' prefix) are indexed as '', and file attachments
+-- only by title since their url is a storage path.
+
+CREATE VIRTUAL TABLE projects_fts USING fts5(title, notes, content=projects, content_rowid=rowid);
+CREATE VIRTUAL TABLE areas_fts USING fts5(title, content=areas, content_rowid=rowid);
+CREATE VIRTUAL TABLE headings_fts USING fts5(title, content=headings, content_rowid=rowid);
+CREATE VIRTUAL TABLE checklist_items_fts USING fts5(title, content=checklist_items, content_rowid=rowid);
+CREATE VIRTUAL TABLE attachments_fts USING fts5(title, url, content=attachments, content_rowid=rowid);
+CREATE VIRTUAL TABLE tags_fts USING fts5(title, content=tags, content_rowid=rowid);
+
+CREATE TRIGGER projects_fts_insert AFTER INSERT ON projects BEGIN
+    INSERT INTO projects_fts(rowid, title, notes) VALUES (new.rowid, new.title,
+        CASE WHEN new.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE new.notes END);
+END;
+CREATE TRIGGER projects_fts_update AFTER UPDATE OF title, notes ON projects BEGIN
+    INSERT INTO projects_fts(projects_fts, rowid, title, notes) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE old.notes END);
+    INSERT INTO projects_fts(rowid, title, notes) VALUES (new.rowid, new.title,
+        CASE WHEN new.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE new.notes END);
+END;
+CREATE TRIGGER projects_fts_delete AFTER DELETE ON projects BEGIN
+    INSERT INTO projects_fts(projects_fts, rowid, title, notes) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.notes LIKE 'ttd:enc:v1:%' THEN '' ELSE old.notes END);
+END;
+
+CREATE TRIGGER areas_fts_insert AFTER INSERT ON areas BEGIN
+    INSERT INTO areas_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER areas_fts_update AFTER UPDATE OF title ON areas BEGIN
+    INSERT INTO areas_fts(areas_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+    INSERT INTO areas_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER areas_fts_delete AFTER DELETE ON areas BEGIN
+    INSERT INTO areas_fts(areas_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+END;
+
+CREATE TRIGGER headings_fts_insert AFTER INSERT ON headings BEGIN
+    INSERT INTO headings_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER headings_fts_update AFTER UPDATE OF title ON headings BEGIN
+    INSERT INTO headings_fts(headings_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+    INSERT INTO headings_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER headings_fts_delete AFTER DELETE ON headings BEGIN
+    INSERT INTO headings_fts(headings_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+END;
+
+CREATE TRIGGER checklist_items_fts_insert AFTER INSERT ON checklist_items BEGIN
+    INSERT INTO checklist_items_fts(rowid, title) VALUES (new.rowid,
+        CASE WHEN new.title LIKE 'ttd:enc:v1:%' THEN '' ELSE new.title END);
+END;
+CREATE TRIGGER checklist_items_fts_update AFTER UPDATE OF title ON checklist_items BEGIN
+    INSERT INTO checklist_items_fts(checklist_items_fts, rowid, title) VALUES ('delete', old.rowid,
+        CASE WHEN old.title LIKE 'ttd:enc:v1:%' THEN '' ELSE old.title END);
+    INSERT INTO checklist_items_fts(rowid, title) VALUES (new.rowid,
+        CASE WHEN new.title LIKE 'ttd:enc:v1:%' THEN '' ELSE new.title END);
+END;
+CREATE TRIGGER checklist_items_fts_delete AFTER DELETE ON checklist_items BEGIN
+    INSERT INTO checklist_items_fts(checklist_items_fts, rowid, title) VALUES ('delete', old.rowid,
+        CASE WHEN old.title LIKE 'ttd:enc:v1:%' THEN '' ELSE old.title END);
+END;
+
+CREATE TRIGGER attachments_fts_insert AFTER INSERT ON attachments BEGIN
+    INSERT INTO attachments_fts(rowid, title, url) VALUES (new.rowid, new.title,
+        CASE WHEN new.type = 'link' THEN new.url ELSE '' END);
+END;
+CREATE TRIGGER attachments_fts_update AFTER UPDATE OF title, url, type ON attachments BEGIN
+    INSERT INTO attachments_fts(attachments_fts, rowid, title, url) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.type = 'link' THEN old.url ELSE '' END);
+    INSERT INTO attachments_fts(rowid, title, url) VALUES (new.rowid, new.title,
+        CASE WHEN new.type = 'link' THEN new.url ELSE '' END);
+END;
+CREATE TRIGGER attachments_fts_delete AFTER DELETE ON attachments BEGIN
+    INSERT INTO attachments_fts(attachments_fts, rowid, title, url) VALUES ('delete', old.rowid, old.title,
+        CASE WHEN old.type = 'link' THEN old.url ELSE '' END);
+END;
+
+CREATE TRIGGER tags_fts_insert AFTER INSERT ON tags BEGIN
+    INSERT INTO tags_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER tags_fts_update AFTER UPDATE OF title ON tags BEGIN
+    INSERT INTO tags_fts(tags_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+    INSERT INTO tags_fts(rowid, title) VALUES (new.rowid, new.title);
+END;
+CREATE TRIGGER tags_fts_delete AFTER DELETE ON tags BEGIN
+    INSERT INTO tags_fts(tags_fts, rowid, title) VALUES ('delete', old.rowid, old.title);
+END;
+
+-- Index existing rows the way the triggers would.
+INSERT INTO projects_fts(rowid, title, notes)
+    SELECT rowid, title, CASE WHEN notes LIKE 'ttd:enc:v1:%' THEN '' ELSE notes END FROM projects;
+INSERT INTO areas_fts(rowid, title) SELECT rowid, title FROM areas;
+INSERT INTO headings_fts(rowid, title) SELECT rowid, title FROM headings;
+INSERT INTO checklist_items_fts(rowid, title)
+    SELECT rowid, CASE WHEN title LIKE 'ttd:enc:v1:%' THEN '' ELSE title END FROM checklist_items;
+INSERT INTO attachments_fts(rowid, title, url)
+    SELECT rowid, title, CASE WHEN type = 'link' THEN url ELSE '' END FROM attachments;
+INSERT INTO tags_fts(rowid, title) SELECT rowid, title FROM tags;
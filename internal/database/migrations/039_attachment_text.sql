@@ -0,0 +1,16 @@
+-- Text extracted from uploaded files, indexed for search. attachment_text
+-- records every file the scheduler has looked at (status 'indexed',
+-- 'unsupported' or 'failed') so it isn't read again; the text itself lives
+-- in attachment_text_fts, keyed by attachment ID.
+CREATE TABLE attachment_text (
+    attachment_id TEXT PRIMARY KEY REFERENCES attachments(id) ON DELETE CASCADE,
+    status        TEXT NOT NULL CHECK (status IN ('indexed', 'unsupported', 'failed')),
+    error         TEXT NOT NULL DEFAULT '',
+    extracted_at  TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE VIRTUAL TABLE attachment_text_fts USING fts5(attachment_id UNINDEXED, body);
+
+CREATE TRIGGER attachment_text_delete AFTER DELETE ON attachment_text BEGIN
+    DELETE FROM attachment_text_fts WHERE attachment_id = old.attachment_id;
+END;
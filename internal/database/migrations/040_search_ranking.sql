@@ -0,0 +1,16 @@
+-- Search synonyms and the vocabularies fuzzy matching draws on. Each synonym
+-- group is a JSON array of lowercase words or phrases that match each other.
+CREATE TABLE search_synonyms (
+    id         TEXT PRIMARY KEY,
+    terms      TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE VIRTUAL TABLE tasks_fts_vocab USING fts5vocab(tasks_fts, row);
+CREATE VIRTUAL TABLE projects_fts_vocab USING fts5vocab(projects_fts, row);
+CREATE VIRTUAL TABLE areas_fts_vocab USING fts5vocab(areas_fts, row);
+CREATE VIRTUAL TABLE headings_fts_vocab USING fts5vocab(headings_fts, row);
+CREATE VIRTUAL TABLE checklist_items_fts_vocab USING fts5vocab(checklist_items_fts, row);
+CREATE VIRTUAL TABLE tags_fts_vocab USING fts5vocab(tags_fts, row);
+CREATE VIRTUAL TABLE attachments_fts_vocab USING fts5vocab(attachments_fts, row);
+CREATE VIRTUAL TABLE attachment_text_fts_vocab USING fts5vocab(attachment_text_fts, row);
@@ -0,0 +1,14 @@
+-- Smart lists: named searches shown as views of their own. query is in the
+-- search query language; sort and group_by shape the resulting view.
+CREATE TABLE smart_lists (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL COLLATE NOCASE,
+    query      TEXT NOT NULL,
+    sort       TEXT NOT NULL DEFAULT 'when',
+    group_by   TEXT NOT NULL DEFAULT 'none',
+    sort_order REAL NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE (user_id, name)
+);
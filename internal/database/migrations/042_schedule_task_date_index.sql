@@ -0,0 +1,5 @@
+-- Views look up a task's schedule entries for one day. With task_id and
+-- when_date indexed apart, SQLite can pick the when_date index and read
+-- every entry of the day for each task, which makes the Today view
+-- quadratic in the size of the day; one index over both avoids that.
+CREATE INDEX idx_task_schedules_task_date ON task_schedules(task_id, when_date);
@@ -0,0 +1,11 @@
+-- Each change log entry keeps the entity's snapshot from before the change,
+-- so undo does not depend on the earlier entry, which compaction removes.
+ALTER TABLE change_log ADD COLUMN previous_snapshot TEXT;
+
+UPDATE change_log SET previous_snapshot = (
+    SELECT prev.snapshot FROM change_log prev
+    WHERE prev.entity = change_log.entity AND prev.entity_id = change_log.entity_id
+      AND prev.seq < change_log.seq
+    ORDER BY prev.seq DESC
+    LIMIT 1
+);
@@ -0,0 +1,589 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"modernc.org/libc"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The encrypting VFS is a shim over SQLite's default VFS. Every file it opens
+// is stored as a sequence of blocks of blockSize plaintext bytes, each sealed
+// on its own, so SQLite's writes stay page-sized and in place:
+//
+//	main database:  key header | block 0 | block 1 | ...
+//	WAL, journal:   block 0 | block 1 | ...
+//
+// A sealed block is nonce | ciphertext | tag. Only the last block of a file
+// may be short, so a file's plaintext size follows from its length. The
+// database's data key is wrapped with the server key in the key header; its
+// WAL and rollback journal use the same data key, and temporary files get a
+// key that is never stored. The WAL index (-shm) holds only page numbers and
+// checksums and is left to the default VFS, as are locking and syncing.
+const (
+	// blockSize matches SQLite's default page size, so a page is one block.
+	blockSize     = 4096
+	sealedBlock   = blockSize + atrest.BlockOverhead
+	keyHeaderSize = int64(atrest.KeyHeaderSize)
+)
+
+// Block kinds, bound into each block so blocks cannot be copied between a
+// database and its WAL or journal.
+const (
+	kindDatabase = 'd'
+	kindWAL      = 'w'
+	kindJournal  = 'j'
+	kindTemp     = 't'
+)
+
+var (
+	vfsMu    sync.Mutex
+	vfsNames = map[string]string{} // key ID -> registered VFS name
+
+	objects    sync.Map // handle -> *encVFS or *encFile
+	lastObject atomic.Uintptr
+)
+
+func addObject(o any) uintptr {
+	h := lastObject.Add(1)
+	objects.Store(h, o)
+	return h
+}
+
+// encVFS is the Go side of a registered VFS.
+type encVFS struct {
+	inner uintptr // the default VFS
+	key   *atrest.Key
+
+	mu   sync.Mutex
+	keys map[string]*atrest.DataKey // database path -> data key, for its WAL and journal
+}
+
+// vfsFile is the sqlite3_file SQLite allocates for each open file. The
+// default VFS's own sqlite3_file follows it in the same allocation.
+type vfsFile struct {
+	base   sqlite3.Tsqlite3_file
+	handle uintptr
+}
+
+const innerOffset = unsafe.Sizeof(vfsFile{})
+
+// encFile is the Go side of an open file.
+type encFile struct {
+	inner  uintptr // the default VFS's sqlite3_file
+	key    *atrest.DataKey
+	kind   byte
+	offset int64   // bytes before the first block: the key header
+	strict bool    // a block that fails to open is an error rather than zeros
+	buf    uintptr // C memory for one sealed block
+}
+
+// registerVFS registers an encrypting VFS for key, once per key, and returns
+// its name for the vfs= DSN parameter.
+func registerVFS(key *atrest.Key) (string, error) {
+	vfsMu.Lock()
+	defer vfsMu.Unlock()
+	if name, ok := vfsNames[key.ID()]; ok {
+		return name, nil
+	}
+
+	tls := libc.NewTLS()
+	defer tls.Close()
+	inner := sqlite3.Xsqlite3_vfs_find(tls, 0)
+	if inner == 0 {
+		return "", errors.New("no default SQLite VFS")
+	}
+	in := (*sqlite3.Tsqlite3_vfs)(cptr(inner))
+
+	name := "thingstodo-" + key.ID()
+	cname, err := libc.CString(name)
+	if err != nil {
+		return "", err
+	}
+	p := libc.Xmalloc(tls, libc.Tsize_t(unsafe.Sizeof(sqlite3.Tsqlite3_vfs{})))
+	if p == 0 {
+		libc.Xfree(tls, cname)
+		return "", errors.New("register VFS: out of memory")
+	}
+	// The default VFS reads its own app data only in xOpen, so every other
+	// method is shared as is.
+	*(*sqlite3.Tsqlite3_vfs)(cptr(p)) = sqlite3.Tsqlite3_vfs{
+		FiVersion:          2,
+		FszOsFile:          int32(innerOffset) + in.FszOsFile,
+		FmxPathname:        in.FmxPathname,
+		FzName:             cname,
+		FpAppData:          addObject(&encVFS{inner: inner, key: key, keys: map[string]*atrest.DataKey{}}),
+		FxOpen:             cfunc(vfsOpen),
+		FxDelete:           in.FxDelete,
+		FxAccess:           in.FxAccess,
+		FxFullPathname:     in.FxFullPathname,
+		FxDlOpen:           in.FxDlOpen,
+		FxDlError:          in.FxDlError,
+		FxDlSym:            in.FxDlSym,
+		FxDlClose:          in.FxDlClose,
+		FxRandomness:       in.FxRandomness,
+		FxSleep:            in.FxSleep,
+		FxCurrentTime:      in.FxCurrentTime,
+		FxGetLastError:     in.FxGetLastError,
+		FxCurrentTimeInt64: in.FxCurrentTimeInt64,
+	}
+	if rc := sqlite3.Xsqlite3_vfs_register(tls, p, 0); rc != sqlite3.SQLITE_OK {
+		libc.Xfree(tls, cname)
+		libc.Xfree(tls, p)
+		return "", fmt.Errorf("register VFS: SQLite error %d", rc)
+	}
+	vfsNames[key.ID()] = name
+	return name, nil
+}
+
+// defaultVFS returns the name of SQLite's default VFS.
+func defaultVFS() string {
+	tls := libc.NewTLS()
+	defer tls.Close()
+	return libc.GoString((*sqlite3.Tsqlite3_vfs)(cptr(sqlite3.Xsqlite3_vfs_find(tls, 0))).FzName)
+}
+
+// cptr converts the address of C memory allocated by the transpiled SQLite
+// to a pointer. Go does not manage that memory, so holding its address as a
+// uintptr is safe.
+func cptr(p uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&p))
+}
+
+// cfunc turns a Go function into a function pointer for the transpiled
+// SQLite, as its generated code does.
+func cfunc[F any](f F) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&f))
+}
+
+// gofunc turns a function pointer from the transpiled SQLite into a callable
+// Go function of type F.
+func gofunc[F any](p uintptr) F {
+	return *(*F)(unsafe.Pointer(&struct{ uintptr }{p}))
+}
+
+func vfsOpen(tls *libc.TLS, pVfs, zName, pFile uintptr, flags int32, pOutFlags uintptr) int32 {
+	v, _ := objects.Load((*sqlite3.Tsqlite3_vfs)(cptr(pVfs)).FpAppData)
+	vfs := v.(*encVFS)
+	*(*vfsFile)(cptr(pFile)) = vfsFile{}
+	inner := pFile + innerOffset
+	in := (*sqlite3.Tsqlite3_vfs)(cptr(vfs.inner))
+	rc := gofunc[func(*libc.TLS, uintptr, uintptr, uintptr, int32, uintptr) int32](in.FxOpen)(tls, vfs.inner, zName, inner, flags, pOutFlags)
+	if rc != sqlite3.SQLITE_OK {
+		// SQLite only closes files whose methods are set, and ours are not.
+		if (*sqlite3.Tsqlite3_file)(cptr(inner)).FpMethods != 0 {
+			innerMethods(inner).close(tls, inner)
+		}
+		return rc
+	}
+
+	f := &encFile{inner: inner, buf: libc.Xmalloc(tls, sealedBlock)}
+	if f.buf == 0 {
+		rc = sqlite3.SQLITE_NOMEM
+	} else {
+		rc = vfs.setKey(tls, f, zName, flags)
+	}
+	if rc != sqlite3.SQLITE_OK {
+		f.close(tls)
+		return rc
+	}
+	(*vfsFile)(cptr(pFile)).handle = addObject(f)
+	(*vfsFile)(cptr(pFile)).base.FpMethods = uintptr(unsafe.Pointer(&ioMethods))
+	return sqlite3.SQLITE_OK
+}
+
+// setKey picks the data key for a newly opened file. A database's key is
+// read from its header, or created with one when the file is new; its WAL
+// and journal are always opened after it.
+func (v *encVFS) setKey(tls *libc.TLS, f *encFile, zName uintptr, flags int32) int32 {
+	var name string
+	if zName != 0 {
+		name = libc.GoString(zName)
+	}
+	switch {
+	case flags&sqlite3.SQLITE_OPEN_MAIN_DB != 0:
+		f.kind, f.offset, f.strict = kindDatabase, keyHeaderSize, true
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		key, rc := v.databaseKey(tls, f)
+		if rc != sqlite3.SQLITE_OK {
+			return rc
+		}
+		f.key = key
+		v.keys[name] = key
+		return sqlite3.SQLITE_OK
+	case flags&sqlite3.SQLITE_OPEN_WAL != 0:
+		f.kind = kindWAL
+		name = strings.TrimSuffix(name, "-wal")
+	case flags&sqlite3.SQLITE_OPEN_MAIN_JOURNAL != 0:
+		f.kind = kindJournal
+		name = strings.TrimSuffix(name, "-journal")
+	default:
+		f.kind = kindTemp
+		key, err := atrest.NewEphemeralDataKey()
+		if err != nil {
+			return sqlite3.SQLITE_IOERR
+		}
+		f.key = key
+		return sqlite3.SQLITE_OK
+	}
+
+	v.mu.Lock()
+	f.key = v.keys[name]
+	v.mu.Unlock()
+	if f.key == nil {
+		return sqlite3.SQLITE_CANTOPEN
+	}
+	return sqlite3.SQLITE_OK
+}
+
+// databaseKey reads the data key from a database's header, writing a new
+// header first when the file is empty. v.mu keeps two connections from
+// creating the same database at once.
+func (v *encVFS) databaseKey(tls *libc.TLS, f *encFile) (*atrest.DataKey, int32) {
+	m := innerMethods(f.inner)
+	size, rc := m.fileSize(tls, f.inner)
+	if rc != sqlite3.SQLITE_OK {
+		return nil, rc
+	}
+	header := unsafe.Slice((*byte)(cptr(f.buf)), atrest.KeyHeaderSize)
+	if size == 0 {
+		key, h, err := v.key.NewDataKey()
+		if err != nil {
+			return nil, sqlite3.SQLITE_IOERR
+		}
+		copy(header, h)
+		if rc := m.write(tls, f.inner, f.buf, int32(keyHeaderSize), 0); rc != sqlite3.SQLITE_OK {
+			return nil, rc
+		}
+		if rc := m.sync(tls, f.inner, sqlite3.SQLITE_SYNC_NORMAL); rc != sqlite3.SQLITE_OK {
+			return nil, rc
+		}
+		return key, sqlite3.SQLITE_OK
+	}
+	if size < keyHeaderSize {
+		return nil, sqlite3.SQLITE_NOTADB
+	}
+	if rc := m.read(tls, f.inner, f.buf, int32(keyHeaderSize), 0); rc != sqlite3.SQLITE_OK {
+		return nil, rc
+	}
+	key, err := atrest.OpenDataKey(header, v.key)
+	if err != nil {
+		return nil, sqlite3.SQLITE_NOTADB
+	}
+	return key, sqlite3.SQLITE_OK
+}
+
+func fileOf(pFile uintptr) *encFile {
+	f, _ := objects.Load((*vfsFile)(cptr(pFile)).handle)
+	return f.(*encFile)
+}
+
+// close closes the default VFS's file and releases f.
+func (f *encFile) close(tls *libc.TLS) int32 {
+	rc := innerMethods(f.inner).close(tls, f.inner)
+	if f.buf != 0 {
+		libc.Xfree(tls, f.buf)
+		f.buf = 0
+	}
+	return rc
+}
+
+// ad is the associated data of block i: the file kind and block number.
+func (f *encFile) ad(i int64) []byte {
+	ad := make([]byte, 9)
+	ad[0] = f.kind
+	binary.BigEndian.PutUint64(ad[1:], uint64(i))
+	return ad
+}
+
+// size returns the plaintext size of a file whose stored size is stored.
+func (f *encFile) size(stored int64) int64 {
+	n := stored - f.offset
+	if n <= 0 {
+		return 0
+	}
+	size := n / sealedBlock * blockSize
+	if rem := n % sealedBlock; rem > atrest.BlockOverhead {
+		size += rem - atrest.BlockOverhead
+	}
+	return size
+}
+
+// readBlock returns the plaintext of block i, or nil past the end of a file
+// whose stored size is stored.
+func (f *encFile) readBlock(tls *libc.TLS, i, stored int64) ([]byte, int32) {
+	start := f.offset + i*sealedBlock
+	n := min(stored-start, sealedBlock)
+	if n <= atrest.BlockOverhead {
+		return nil, sqlite3.SQLITE_OK
+	}
+	if rc := innerMethods(f.inner).read(tls, f.inner, f.buf, int32(n), start); rc != sqlite3.SQLITE_OK {
+		return nil, rc
+	}
+	sealed := unsafe.Slice((*byte)(cptr(f.buf)), n)
+	plain, err := f.key.OpenBlock(nil, sealed, f.ad(i))
+	if err != nil {
+		if f.strict {
+			return nil, sqlite3.SQLITE_IOERR_DATA
+		}
+		// A torn write at the end of a WAL or journal. SQLite checksums
+		// their records, so zeros end recovery there as garbage would.
+		return make([]byte, n-atrest.BlockOverhead), sqlite3.SQLITE_OK
+	}
+	return plain, sqlite3.SQLITE_OK
+}
+
+func (f *encFile) writeBlock(tls *libc.TLS, i int64, plain []byte) int32 {
+	sealed := unsafe.Slice((*byte)(cptr(f.buf)), sealedBlock)
+	out, err := f.key.SealBlock(sealed[:0], plain, f.ad(i))
+	if err != nil {
+		return sqlite3.SQLITE_IOERR_WRITE
+	}
+	return innerMethods(f.inner).write(tls, f.inner, f.buf, int32(len(out)), f.offset+i*sealedBlock)
+}
+
+var ioMethods = sqlite3.Tsqlite3_io_methods{
+	// Version 2 leaves out xFetch, so SQLite never memory-maps the
+	// ciphertext.
+	FiVersion:               2,
+	FxClose:                 cfunc(fileClose),
+	FxRead:                  cfunc(fileRead),
+	FxWrite:                 cfunc(fileWrite),
+	FxTruncate:              cfunc(fileTruncate),
+	FxSync:                  cfunc(fileSync),
+	FxFileSize:              cfunc(fileFileSize),
+	FxLock:                  cfunc(fileLock),
+	FxUnlock:                cfunc(fileUnlock),
+	FxCheckReservedLock:     cfunc(fileCheckReservedLock),
+	FxFileControl:           cfunc(fileFileControl),
+	FxSectorSize:            cfunc(fileSectorSize),
+	FxDeviceCharacteristics: cfunc(fileDeviceCharacteristics),
+	FxShmMap:                cfunc(fileShmMap),
+	FxShmLock:               cfunc(fileShmLock),
+	FxShmBarrier:            cfunc(fileShmBarrier),
+	FxShmUnmap:              cfunc(fileShmUnmap),
+}
+
+func fileClose(tls *libc.TLS, pFile uintptr) int32 {
+	h := (*vfsFile)(cptr(pFile)).handle
+	rc := fileOf(pFile).close(tls)
+	objects.Delete(h)
+	return rc
+}
+
+func fileRead(tls *libc.TLS, pFile, zBuf uintptr, iAmt int32, iOfst int64) int32 {
+	f := fileOf(pFile)
+	stored, rc := innerMethods(f.inner).fileSize(tls, f.inner)
+	if rc != sqlite3.SQLITE_OK {
+		return rc
+	}
+	size := f.size(stored)
+	dst := unsafe.Slice((*byte)(cptr(zBuf)), iAmt)
+	n := 0
+	for n < len(dst) {
+		pos := iOfst + int64(n)
+		if pos >= size {
+			break
+		}
+		i := pos / blockSize
+		plain, rc := f.readBlock(tls, i, stored)
+		if rc != sqlite3.SQLITE_OK {
+			return rc
+		}
+		within := int(pos - i*blockSize)
+		if within >= len(plain) {
+			break
+		}
+		n += copy(dst[n:], plain[within:])
+	}
+	if n < len(dst) {
+		clear(dst[n:])
+		return sqlite3.SQLITE_IOERR_SHORT_READ
+	}
+	return sqlite3.SQLITE_OK
+}
+
+func fileWrite(tls *libc.TLS, pFile, zBuf uintptr, iAmt int32, iOfst int64) int32 {
+	f := fileOf(pFile)
+	stored, rc := innerMethods(f.inner).fileSize(tls, f.inner)
+	if rc != sqlite3.SQLITE_OK {
+		return rc
+	}
+	src := unsafe.Slice((*byte)(cptr(zBuf)), iAmt)
+	if size := f.size(stored); iOfst > size {
+		// Fill the gap up to the write with zeros, as a plain file would.
+		src = append(make([]byte, iOfst-size), src...)
+		iOfst = size
+	}
+	for n := 0; n < len(src); {
+		pos := iOfst + int64(n)
+		i := pos / blockSize
+		within := int(pos - i*blockSize)
+		chunk := min(blockSize-within, len(src)-n)
+		plain := src[n : n+chunk]
+		if chunk < blockSize {
+			old, rc := f.readBlock(tls, i, stored)
+			if rc != sqlite3.SQLITE_OK {
+				return rc
+			}
+			plain = make([]byte, max(len(old), within+chunk))
+			copy(plain, old)
+			copy(plain[within:], src[n:n+chunk])
+		}
+		if rc := f.writeBlock(tls, i, plain); rc != sqlite3.SQLITE_OK {
+			return rc
+		}
+		n += chunk
+	}
+	return sqlite3.SQLITE_OK
+}
+
+func fileTruncate(tls *libc.TLS, pFile uintptr, size int64) int32 {
+	f := fileOf(pFile)
+	m := innerMethods(f.inner)
+	stored, rc := m.fileSize(tls, f.inner)
+	if rc != sqlite3.SQLITE_OK {
+		return rc
+	}
+	if size >= f.size(stored) {
+		return sqlite3.SQLITE_OK
+	}
+	i, rem := size/blockSize, size%blockSize
+	newStored := f.offset + i*sealedBlock
+	if rem > 0 {
+		plain, rc := f.readBlock(tls, i, stored)
+		if rc != sqlite3.SQLITE_OK {
+			return rc
+		}
+		if rc := f.writeBlock(tls, i, plain[:rem]); rc != sqlite3.SQLITE_OK {
+			return rc
+		}
+		newStored += rem + atrest.BlockOverhead
+	}
+	return m.truncate(tls, f.inner, newStored)
+}
+
+func fileFileSize(tls *libc.TLS, pFile, pSize uintptr) int32 {
+	f := fileOf(pFile)
+	stored, rc := innerMethods(f.inner).fileSize(tls, f.inner)
+	if rc != sqlite3.SQLITE_OK {
+		return rc
+	}
+	*(*int64)(cptr(pSize)) = f.size(stored)
+	return sqlite3.SQLITE_OK
+}
+
+func fileFileControl(tls *libc.TLS, pFile uintptr, op int32, pArg uintptr) int32 {
+	switch op {
+	case sqlite3.SQLITE_FCNTL_SIZE_HINT, sqlite3.SQLITE_FCNTL_CHUNK_SIZE:
+		// Preallocation works on the stored size, which differs from the
+		// plaintext size SQLite asks for.
+		return sqlite3.SQLITE_OK
+	}
+	inner := pFile + innerOffset
+	return innerMethods(inner).fileControl(tls, inner, op, pArg)
+}
+
+func fileSectorSize(tls *libc.TLS, pFile uintptr) int32 {
+	return blockSize
+}
+
+// fileDeviceCharacteristics drops the guarantees that sealing breaks: a write
+// rewrites its whole block, so neither atomic writes nor power-safe
+// overwrite hold any more.
+func fileDeviceCharacteristics(tls *libc.TLS, pFile uintptr) int32 {
+	inner := pFile + innerOffset
+	return innerMethods(inner).deviceCharacteristics(tls, inner) & sqlite3.SQLITE_IOCAP_UNDELETABLE_WHEN_OPEN
+}
+
+// The remaining methods pass straight through to the default VFS.
+
+func fileSync(tls *libc.TLS, pFile uintptr, flags int32) int32 {
+	inner := pFile + innerOffset
+	return innerMethods(inner).sync(tls, inner, flags)
+}
+
+func fileLock(tls *libc.TLS, pFile uintptr, eLock int32) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, int32) int32](innerMethods(inner).FxLock)(tls, inner, eLock)
+}
+
+func fileUnlock(tls *libc.TLS, pFile uintptr, eLock int32) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, int32) int32](innerMethods(inner).FxUnlock)(tls, inner, eLock)
+}
+
+func fileCheckReservedLock(tls *libc.TLS, pFile, pResOut uintptr) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, uintptr) int32](innerMethods(inner).FxCheckReservedLock)(tls, inner, pResOut)
+}
+
+func fileShmMap(tls *libc.TLS, pFile uintptr, iPg, pgsz, bExtend int32, pp uintptr) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, int32, int32, int32, uintptr) int32](innerMethods(inner).FxShmMap)(tls, inner, iPg, pgsz, bExtend, pp)
+}
+
+func fileShmLock(tls *libc.TLS, pFile uintptr, offset, n, flags int32) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, int32, int32, int32) int32](innerMethods(inner).FxShmLock)(tls, inner, offset, n, flags)
+}
+
+func fileShmBarrier(tls *libc.TLS, pFile uintptr) {
+	inner := pFile + innerOffset
+	gofunc[func(*libc.TLS, uintptr)](innerMethods(inner).FxShmBarrier)(tls, inner)
+}
+
+func fileShmUnmap(tls *libc.TLS, pFile uintptr, deleteFlag int32) int32 {
+	inner := pFile + innerOffset
+	return gofunc[func(*libc.TLS, uintptr, int32) int32](innerMethods(inner).FxShmUnmap)(tls, inner, deleteFlag)
+}
+
+// methods are the default VFS's methods for one of its files.
+type methods struct{ *sqlite3.Tsqlite3_io_methods }
+
+func innerMethods(inner uintptr) methods {
+	return methods{(*sqlite3.Tsqlite3_io_methods)(cptr((*sqlite3.Tsqlite3_file)(cptr(inner)).FpMethods))}
+}
+
+func (m methods) close(tls *libc.TLS, p uintptr) int32 {
+	return gofunc[func(*libc.TLS, uintptr) int32](m.FxClose)(tls, p)
+}
+
+func (m methods) read(tls *libc.TLS, p, buf uintptr, n int32, off int64) int32 {
+	return gofunc[func(*libc.TLS, uintptr, uintptr, int32, int64) int32](m.FxRead)(tls, p, buf, n, off)
+}
+
+func (m methods) write(tls *libc.TLS, p, buf uintptr, n int32, off int64) int32 {
+	return gofunc[func(*libc.TLS, uintptr, uintptr, int32, int64) int32](m.FxWrite)(tls, p, buf, n, off)
+}
+
+func (m methods) truncate(tls *libc.TLS, p uintptr, size int64) int32 {
+	return gofunc[func(*libc.TLS, uintptr, int64) int32](m.FxTruncate)(tls, p, size)
+}
+
+func (m methods) sync(tls *libc.TLS, p uintptr, flags int32) int32 {
+	return gofunc[func(*libc.TLS, uintptr, int32) int32](m.FxSync)(tls, p, flags)
+}
+
+func (m methods) fileSize(tls *libc.TLS, p uintptr) (int64, int32) {
+	size := tls.Alloc(8)
+	defer tls.Free(8)
+	rc := gofunc[func(*libc.TLS, uintptr, uintptr) int32](m.FxFileSize)(tls, p, size)
+	return *(*int64)(cptr(size)), rc
+}
+
+func (m methods) fileControl(tls *libc.TLS, p uintptr, op int32, arg uintptr) int32 {
+	return gofunc[func(*libc.TLS, uintptr, int32, uintptr) int32](m.FxFileControl)(tls, p, op, arg)
+}
+
+func (m methods) deviceCharacteristics(tls *libc.TLS, p uintptr) int32 {
+	return gofunc[func(*libc.TLS, uintptr) int32](m.FxDeviceCharacteristics)(tls, p)
+}
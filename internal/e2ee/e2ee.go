@@ -0,0 +1,221 @@
+// Package e2ee implements the client-side encryption used for task notes,
+// checklist item titles and attachment blobs.
+//
+// Content is encrypted with a random 256-bit data key (AES-256-GCM). The data
+// key never leaves the client in the clear: it is wrapped with a key derived
+// from the user's passphrase (PBKDF2-SHA256) and only the wrapped form is
+// stored on the server. The server treats encrypted values as opaque strings
+// and bytes; it only recognises them by their prefix so it can keep them out
+// of the full-text index.
+package e2ee
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Prefix marks an encrypted text value: Prefix + base64(nonce || ciphertext).
+	Prefix = "ttd:enc:v1:"
+
+	Algorithm = "aes-256-gcm"
+	KDF       = "pbkdf2-sha256"
+
+	// DefaultIterations is the PBKDF2 work factor used for new wrapped keys.
+	DefaultIterations = 600000
+	// MinIterations is the lowest work factor accepted when unwrapping.
+	MinIterations = 100000
+
+	keySize  = 32
+	saltSize = 16
+)
+
+// blobMagic prefixes encrypted attachment blobs.
+var blobMagic = []byte("TTDENC1\x00")
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key")
+	ErrDecrypt         = errors.New("cannot decrypt value")
+)
+
+// WrappedKey is a data key wrapped with a passphrase-derived key. It is the
+// form stored on the server.
+type WrappedKey struct {
+	Algorithm     string `json:"algorithm"`
+	KDF           string `json:"kdf"`
+	KDFIterations int    `json:"kdf_iterations"`
+	Salt          string `json:"salt"`
+	WrappedKey    string `json:"wrapped_key"`
+}
+
+// GenerateKey returns a new random data key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// WrapKey wraps a data key with a key derived from passphrase.
+func WrapKey(key []byte, passphrase string) (WrappedKey, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return WrappedKey{}, err
+	}
+	kek, err := pbkdf2.Key(sha256.New, passphrase, salt, DefaultIterations, keySize)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+	sealed, err := seal(kek, key)
+	if err != nil {
+		return WrappedKey{}, err
+	}
+	return WrappedKey{
+		Algorithm:     Algorithm,
+		KDF:           KDF,
+		KDFIterations: DefaultIterations,
+		Salt:          base64.StdEncoding.EncodeToString(salt),
+		WrappedKey:    base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// UnwrapKey recovers the data key from w using passphrase.
+func UnwrapKey(w WrappedKey, passphrase string) ([]byte, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	salt, _ := base64.StdEncoding.DecodeString(w.Salt)
+	sealed, _ := base64.StdEncoding.DecodeString(w.WrappedKey)
+	kek, err := pbkdf2.Key(sha256.New, passphrase, salt, w.KDFIterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+	key, err := open(kek, sealed)
+	if err != nil || len(key) != keySize {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// Validate checks that w is well formed and uses supported parameters.
+func (w WrappedKey) Validate() error {
+	if w.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported algorithm %q", w.Algorithm)
+	}
+	if w.KDF != KDF {
+		return fmt.Errorf("unsupported kdf %q", w.KDF)
+	}
+	if w.KDFIterations < MinIterations {
+		return fmt.Errorf("kdf_iterations must be at least %d", MinIterations)
+	}
+	if salt, err := base64.StdEncoding.DecodeString(w.Salt); err != nil || len(salt) < saltSize {
+		return errors.New("salt must be base64 and at least 16 bytes")
+	}
+	if sealed, err := base64.StdEncoding.DecodeString(w.WrappedKey); err != nil || len(sealed) == 0 {
+		return errors.New("wrapped_key must be non-empty base64")
+	}
+	return nil
+}
+
+// IsEncrypted reports whether s is an encrypted text value.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// EncryptString encrypts plaintext into a prefixed text value. Empty strings
+// stay empty so "has notes" checks keep working.
+func EncryptString(key []byte, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	sealed, err := seal(key, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString decrypts a value produced by EncryptString. Values without the
+// prefix are returned unchanged, so mixed plaintext/encrypted data reads fine.
+func DecryptString(key []byte, s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, Prefix))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	plain, err := open(key, sealed)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plain), nil
+}
+
+// IsEncryptedBlob reports whether data is an encrypted attachment blob.
+func IsEncryptedBlob(data []byte) bool {
+	return bytes.HasPrefix(data, blobMagic)
+}
+
+// EncryptBlob encrypts attachment content.
+func EncryptBlob(key, data []byte) ([]byte, error) {
+	sealed, err := seal(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, blobMagic...), sealed...), nil
+}
+
+// DecryptBlob decrypts content produced by EncryptBlob.
+func DecryptBlob(key, data []byte) ([]byte, error) {
+	if !IsEncryptedBlob(data) {
+		return nil, ErrDecrypt
+	}
+	plain, err := open(key, data[len(blobMagic):])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,97 @@
+package e2ee
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWrapUnwrapKey(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wrapped, err := WrapKey(key, "correct horse")
+	if err != nil {
+		t.Fatalf("WrapKey: %v", err)
+	}
+	if err := wrapped.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	got, err := UnwrapKey(wrapped, "correct horse")
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Error("unwrapped key differs from original")
+	}
+
+	if _, err := UnwrapKey(wrapped, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestValidateRejectsWeakParameters(t *testing.T) {
+	key, _ := GenerateKey()
+	wrapped, _ := WrapKey(key, "pw")
+
+	weak := wrapped
+	weak.KDFIterations = 1000
+	if err := weak.Validate(); err == nil {
+		t.Error("expected low iteration count to be rejected")
+	}
+	bad := wrapped
+	bad.Algorithm = "rot13"
+	if err := bad.Validate(); err == nil {
+		t.Error("expected unknown algorithm to be rejected")
+	}
+}
+
+func TestEncryptDecryptString(t *testing.T) {
+	key, _ := GenerateKey()
+
+	enc, err := EncryptString(key, "client: ACME, account 1234")
+	if err != nil {
+		t.Fatalf("EncryptString: %v", err)
+	}
+	if !IsEncrypted(enc) {
+		t.Fatalf("expected prefixed value, got %q", enc)
+	}
+	dec, err := DecryptString(key, enc)
+	if err != nil || dec != "client: ACME, account 1234" {
+		t.Errorf("round trip failed: %q, %v", dec, err)
+	}
+
+	if empty, _ := EncryptString(key, ""); empty != "" {
+		t.Errorf("expected empty plaintext to stay empty, got %q", empty)
+	}
+	if plain, _ := DecryptString(key, "not encrypted"); plain != "not encrypted" {
+		t.Errorf("expected plaintext passthrough, got %q", plain)
+	}
+
+	other, _ := GenerateKey()
+	if _, err := DecryptString(other, enc); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt with the wrong key, got %v", err)
+	}
+}
+
+func TestEncryptDecryptBlob(t *testing.T) {
+	key, _ := GenerateKey()
+	data := []byte("%PDF-1.7 confidential")
+
+	enc, err := EncryptBlob(key, data)
+	if err != nil {
+		t.Fatalf("EncryptBlob: %v", err)
+	}
+	if !IsEncryptedBlob(enc) || bytes.Contains(enc, []byte("confidential")) {
+		t.Fatal("expected an opaque encrypted blob")
+	}
+	dec, err := DecryptBlob(key, enc)
+	if err != nil || !bytes.Equal(dec, data) {
+		t.Errorf("round trip failed: %q, %v", dec, err)
+	}
+	if _, err := DecryptBlob(key, data); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for plaintext blob, got %v", err)
+	}
+}
@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
 	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
 	"github.com/collinjanssen/thingstodo/internal/sse"
@@ -18,14 +21,18 @@ type AttachmentHandler struct {
 	broker          *sse.Broker
 	attachmentsPath string
 	maxUploadSize   int64
+	key             *atrest.Key
 }
 
-func NewAttachmentHandler(repo *repository.AttachmentRepository, broker *sse.Broker, attachmentsPath string, maxUploadSize int64) *AttachmentHandler {
+// NewAttachmentHandler creates the handler. When key is not nil, uploaded
+// files are encrypted at rest with it.
+func NewAttachmentHandler(repo *repository.AttachmentRepository, broker *sse.Broker, attachmentsPath string, maxUploadSize int64, key *atrest.Key) *AttachmentHandler {
 	return &AttachmentHandler{
 		repo:            repo,
 		broker:          broker,
 		attachmentsPath: attachmentsPath,
 		maxUploadSize:   maxUploadSize,
+		key:             key,
 	}
 }
 
@@ -95,18 +102,23 @@ func (h *AttachmentHandler) createFile(w http.ResponseWriter, r *http.Request, t
 		return
 	}
 
-	dst, err := os.Create(storedPath)
+	data, err := io.ReadAll(file)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "cannot save file", "INTERNAL")
+		writeError(w, http.StatusInternalServerError, "cannot read file", "INTERNAL")
 		return
 	}
-	defer dst.Close()
-
-	written, err := io.Copy(dst, file)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "cannot write file", "INTERNAL")
+	// A client-encrypted upload must be an encrypted blob, or clients would
+	// try to decrypt plaintext.
+	encrypted := r.FormValue("encrypted") == "true"
+	if encrypted && !e2ee.IsEncryptedBlob(data) {
+		writeError(w, http.StatusBadRequest, "encrypted upload is not an encrypted blob", "VALIDATION")
+		return
+	}
+	if err := atrest.WriteFile(storedPath, data, h.key, 0o644); err != nil {
+		writeError(w, http.StatusInternalServerError, "cannot save file", "INTERNAL")
 		return
 	}
+	written := int64(len(data))
 
 	input := model.CreateAttachmentInput{
 		Type:     "file",
@@ -115,6 +127,12 @@ func (h *AttachmentHandler) createFile(w http.ResponseWriter, r *http.Request, t
 		MimeType: header.Header.Get("Content-Type"),
 		FileSize: written,
 	}
+	// Client-encrypted uploads arrive as opaque bytes; the original content
+	// type travels in a form field so the client can restore it after decrypting.
+	if encrypted {
+		input.Encrypted = true
+		input.MimeType = r.FormValue("mime_type")
+	}
 
 	att, err := h.repo.Create(taskID, input)
 	if err != nil {
@@ -187,17 +205,23 @@ func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filePath := filepath.Join(h.attachmentsPath, att.URL)
-	f, err := os.Open(filePath)
-	if err != nil {
+	data, err := atrest.ReadFile(filepath.Join(h.attachmentsPath, att.URL), h.key)
+	if errors.Is(err, os.ErrNotExist) {
 		writeError(w, http.StatusNotFound, "file not found on disk", "NOT_FOUND")
 		return
 	}
-	defer f.Close()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
 
-	if att.MimeType != "" {
+	if att.Encrypted {
+		// Never let the browser interpret ciphertext; the client decrypts it.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Content-Encrypted", "true")
+	} else if att.MimeType != "" {
 		w.Header().Set("Content-Type", att.MimeType)
 	}
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, att.Title))
-	_, _ = io.Copy(w, f)
+	_, _ = w.Write(data)
 }
@@ -0,0 +1,120 @@
+package handler_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
+	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAttachmentUploadEncryptedAtRest(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	attachRepo := repository.NewAttachmentRepository(db, nil)
+	task, err := taskRepo.Create(model.CreateTaskInput{Title: "Taxes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := atrest.GenerateKey()
+	key, _ := atrest.ParseKey(secret)
+	dir := t.TempDir()
+
+	h := handler.NewAttachmentHandler(attachRepo, sse.NewBroker(), dir, 1<<20, key)
+	r := chi.NewRouter()
+	r.Post("/api/tasks/{id}/attachments", h.Create)
+	r.Get("/api/attachments/{id}/file", h.Download)
+	client := testutil.NewTestClient(t, r)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "return.txt")
+	fw.Write([]byte("income: 42"))
+	mw.Close()
+	resp, err := http.Post(client.Server.URL+"/api/tasks/"+task.ID+"/attachments", mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	atts, _ := attachRepo.ListByTask(task.ID)
+	if len(atts) != 1 || atts[0].FileSize != int64(len("income: 42")) {
+		t.Fatalf("expected one attachment with the plaintext size, got %+v", atts)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, atts[0].URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !atrest.IsSealed(raw) {
+		t.Fatal("expected the stored file to be encrypted")
+	}
+
+	dl := client.Get("/api/attachments/" + atts[0].ID + "/file")
+	testutil.AssertStatus(t, dl, http.StatusOK)
+	if string(dl.Body) != "income: 42" {
+		t.Errorf("expected decrypted download, got %q", dl.Body)
+	}
+}
+
+func TestAttachmentUploadClientEncrypted(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	attachRepo := repository.NewAttachmentRepository(db, nil)
+	task, err := taskRepo.Create(model.CreateTaskInput{Title: "Passport"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewAttachmentHandler(attachRepo, sse.NewBroker(), t.TempDir(), 1<<20, nil)
+	r := chi.NewRouter()
+	r.Post("/api/tasks/{id}/attachments", h.Create)
+	client := testutil.NewTestClient(t, r)
+
+	upload := func(content []byte) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("encrypted", "true")
+		mw.WriteField("mime_type", "image/png")
+		fw, _ := mw.CreateFormFile("file", "scan.png")
+		fw.Write(content)
+		mw.Close()
+		resp, err := http.Post(client.Server.URL+"/api/tasks/"+task.ID+"/attachments", mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := upload([]byte("\x89PNG plaintext")); code != http.StatusBadRequest {
+		t.Errorf("plaintext marked encrypted: expected 400, got %d", code)
+	}
+	if atts, _ := attachRepo.ListByTask(task.ID); len(atts) != 0 {
+		t.Fatalf("expected the rejected upload to store nothing, got %+v", atts)
+	}
+
+	key, _ := e2ee.GenerateKey()
+	blob, err := e2ee.EncryptBlob(key, []byte("\x89PNG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := upload(blob); code != http.StatusCreated {
+		t.Fatalf("encrypted blob: expected 201, got %d", code)
+	}
+	atts, _ := attachRepo.ListByTask(task.ID)
+	if len(atts) != 1 || !atts[0].Encrypted || atts[0].MimeType != "image/png" {
+		t.Errorf("expected one encrypted image attachment, got %+v", atts)
+	}
+}
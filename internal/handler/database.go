@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/collinjanssen/thingstodo/internal/database"
+)
+
+type DatabaseHandler struct {
+	db *database.DB
+}
+
+func NewDatabaseHandler(db *database.DB) *DatabaseHandler {
+	return &DatabaseHandler{db: db}
+}
+
+// Stats reports the connections of each database pool and the statements
+// run on it.
+// GET /api/database/stats
+func (h *DatabaseHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pools": h.db.Stats()})
+}
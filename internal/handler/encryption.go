@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
+	mw "github.com/collinjanssen/thingstodo/internal/middleware"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+)
+
+// EncryptionHandler stores the user's wrapped content key. Encryption and
+// decryption happen on the client; the server only keeps the wrapped key so
+// every device can unwrap it with the user's passphrase.
+type EncryptionHandler struct {
+	repo *repository.EncryptionKeyRepository
+}
+
+func NewEncryptionHandler(repo *repository.EncryptionKeyRepository) *EncryptionHandler {
+	return &EncryptionHandler{repo: repo}
+}
+
+// GetKey handles GET /api/encryption/key
+func (h *EncryptionHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	key, err := h.repo.Get(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if key == nil {
+		writeError(w, http.StatusNotFound, "encryption is not set up", "NOT_FOUND")
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// PutKey handles PUT /api/encryption/key
+func (h *EncryptionHandler) PutKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	var input e2ee.WrappedKey
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+	if err := input.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "VALIDATION")
+		return
+	}
+	key, err := h.repo.Put(userID, model.EncryptionKey{
+		Algorithm:     input.Algorithm,
+		KDF:           input.KDF,
+		KDFIterations: input.KDFIterations,
+		Salt:          input.Salt,
+		WrappedKey:    input.WrappedKey,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
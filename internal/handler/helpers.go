@@ -2,7 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
 )
 
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
@@ -34,3 +38,27 @@ func decodeJSONWithRaw(r *http.Request, v interface{}) (map[string]json.RawMessa
 	}
 	return raw, nil
 }
+
+// maxPageLimit caps the limit of a list paginated by cursor.
+const maxPageLimit = 500
+
+// pageParams reads the cursor and limit of a list paginated by cursor. def
+// is the limit when none is given.
+func pageParams(r *http.Request, def int) model.Page {
+	q := r.URL.Query()
+	limit := repository.ParseIntDefault(q.Get("limit"), def)
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return model.Page{Cursor: q.Get("cursor"), Limit: limit}
+}
+
+// writeCursorError reports a cursor that doesn't belong to the list. It
+// returns false if err is not repository.ErrInvalidCursor.
+func writeCursorError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, repository.ErrInvalidCursor) {
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "invalid cursor", "VALIDATION")
+	return true
+}
@@ -0,0 +1,693 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+	maxUndoCount        = 50
+)
+
+// undoableEntities are the change log entity types /api/undo can reverse.
+var undoableEntities = []string{"task", "project", "area", "checklist_item"}
+
+// historyIgnoredFields are snapshot keys left out of revision diffs: timestamps
+// bumped by every write and the nested task lists embedded in project snapshots.
+var historyIgnoredFields = map[string]bool{
+	"updated_at":            true,
+	"headings":              true,
+	"tasks_without_heading": true,
+	"completed_tasks":       true,
+	"task_count":            true,
+	"completed_task_count":  true,
+}
+
+var (
+	errEntityGone       = errors.New("entity no longer exists and cannot be recreated")
+	errNoEarlierVersion = errors.New("no earlier revision to restore")
+)
+
+// HistoryRevision is one change log entry for an entity, with the fields that
+// differ from the revision before it.
+type HistoryRevision struct {
+	Seq       int64           `json:"seq"`
+	Action    string          `json:"action"`
+	Changes   []FieldChange   `json:"changes"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// FieldChange is a single top-level snapshot field that changed in a revision.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// UndoResult describes a change log entry processed by /api/undo.
+type UndoResult struct {
+	Seq      int64  `json:"seq"`
+	Entity   string `json:"entity"`
+	EntityID string `json:"entity_id"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HistoryHandler exposes the change log snapshots as per-entity revision
+// history, and restores earlier revisions for revert and undo. Restores go
+// through the regular repositories, so they are logged and synced like any
+// other change.
+type HistoryHandler struct {
+	changeLog *repository.ChangeLogRepository
+	tasks     *repository.TaskRepository
+	projects  *repository.ProjectRepository
+	areas     *repository.AreaRepository
+	checklist *repository.ChecklistRepository
+	broker    *sse.Broker
+	// pending holds the events of an undo until its transaction commits.
+	pending *[]pendingEvent
+}
+
+type pendingEvent struct {
+	eventType string
+	data      interface{}
+}
+
+func NewHistoryHandler(
+	changeLog *repository.ChangeLogRepository,
+	tasks *repository.TaskRepository,
+	projects *repository.ProjectRepository,
+	areas *repository.AreaRepository,
+	checklist *repository.ChecklistRepository,
+	broker *sse.Broker,
+) *HistoryHandler {
+	return &HistoryHandler{
+		changeLog: changeLog,
+		tasks:     tasks,
+		projects:  projects,
+		areas:     areas,
+		checklist: checklist,
+		broker:    broker,
+	}
+}
+
+func (h *HistoryHandler) TaskHistory(w http.ResponseWriter, r *http.Request) {
+	h.history(w, r, "task")
+}
+
+func (h *HistoryHandler) ProjectHistory(w http.ResponseWriter, r *http.Request) {
+	h.history(w, r, "project")
+}
+
+func (h *HistoryHandler) AreaHistory(w http.ResponseWriter, r *http.Request) {
+	h.history(w, r, "area")
+}
+
+func (h *HistoryHandler) RevertTask(w http.ResponseWriter, r *http.Request) {
+	h.revert(w, r, "task")
+}
+
+func (h *HistoryHandler) RevertProject(w http.ResponseWriter, r *http.Request) {
+	h.revert(w, r, "project")
+}
+
+func (h *HistoryHandler) RevertArea(w http.ResponseWriter, r *http.Request) {
+	h.revert(w, r, "area")
+}
+
+// history handles GET /api/{entity}s/{id}/history?limit=N, newest revision first.
+func (h *HistoryHandler) history(w http.ResponseWriter, r *http.Request, entity string) {
+	id := chi.URLParam(r, "id")
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit", "BAD_REQUEST")
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	// Fetch one extra entry so the oldest returned revision can be diffed too.
+	entries, err := h.changeLog.GetEntityHistory(entity, id, limit+1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+
+	revisions := make([]HistoryRevision, 0, limit)
+	for i, e := range entries {
+		if i == limit {
+			break
+		}
+		rev := HistoryRevision{
+			Seq:       e.Seq,
+			Action:    e.Action,
+			Changes:   []FieldChange{},
+			Snapshot:  json.RawMessage(e.Snapshot),
+			CreatedAt: e.CreatedAt,
+		}
+		if i+1 < len(entries) {
+			rev.Changes = diffSnapshots(entries[i+1].Snapshot, e.Snapshot)
+		}
+		revisions = append(revisions, rev)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": revisions})
+}
+
+// revert handles POST /api/{entity}s/{id}/history/{seq}/revert, restoring the
+// entity to the snapshot recorded at that revision.
+func (h *HistoryHandler) revert(w http.ResponseWriter, r *http.Request, entity string) {
+	id := chi.URLParam(r, "id")
+	seq, err := strconv.ParseInt(chi.URLParam(r, "seq"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid revision", "BAD_REQUEST")
+		return
+	}
+	entry, err := h.changeLog.GetEntry(seq)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if entry == nil || entry.Entity != entity || entry.EntityID != id {
+		writeError(w, http.StatusNotFound, "revision not found", "NOT_FOUND")
+		return
+	}
+	if !isStateSnapshot(entry.Snapshot) {
+		writeError(w, http.StatusBadRequest, "revision has no snapshot to restore", "VALIDATION")
+		return
+	}
+
+	result, err := h.restore(entity, id, json.RawMessage(entry.Snapshot))
+	if err != nil {
+		if errors.Is(err, errEntityGone) {
+			writeError(w, http.StatusConflict, err.Error(), "CONFLICT")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Undo handles POST /api/undo. It reverses the most recent N changes (default
+// 1) by restoring each entity to its revision before that change. Changes
+// written by an undo are never undone themselves, so repeated calls walk
+// further back in time. The app is single-user, so "the caller's changes" are
+// all recorded changes.
+//
+// The batch runs in one transaction: a change with no earlier revision is
+// skipped and stays undoable, and any other failure rolls the batch back.
+func (h *HistoryHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Count int `json:"count"`
+	}{Count: 1}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+			return
+		}
+	}
+	if body.Count < 1 || body.Count > maxUndoCount {
+		writeError(w, http.StatusBadRequest, "count must be between 1 and "+strconv.Itoa(maxUndoCount), "VALIDATION")
+		return
+	}
+
+	tx, err := h.changeLog.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	undo := h.forUndo(tx)
+
+	entries, err := undo.changeLog.ListUndoable(undoableEntities, body.Count)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+
+	undone := []UndoResult{}
+	skipped := []UndoResult{}
+	for _, e := range entries {
+		res := UndoResult{Seq: e.Seq, Entity: e.Entity, EntityID: e.EntityID, Action: e.Action}
+		err := undo.undoEntry(e)
+		if errors.Is(err, errNoEarlierVersion) {
+			res.Error = err.Error()
+			skipped = append(skipped, res)
+			continue
+		}
+		if err != nil {
+			msg := fmt.Sprintf("undo change %d: %v", e.Seq, err)
+			if errors.Is(err, errEntityGone) {
+				writeError(w, http.StatusConflict, msg, "CONFLICT")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, msg, "INTERNAL")
+			return
+		}
+		if err := undo.changeLog.MarkUndone(e.Seq); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+			return
+		}
+		undone = append(undone, res)
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	for _, ev := range *undo.pending {
+		h.broker.BroadcastJSON(ev.eventType, ev.data)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"undone":  undone,
+		"skipped": skipped,
+	})
+}
+
+// forUndo returns a copy of h whose repositories run in tx and mark the
+// changes they log as written by an undo. Its events are queued in pending
+// until tx commits.
+func (h *HistoryHandler) forUndo(tx *sql.Tx) *HistoryHandler {
+	cl := h.changeLog.ForUndo().WithTx(tx)
+	u := *h
+	u.changeLog = cl
+	u.tasks = h.tasks.WithChangeLog(cl).WithTx(tx)
+	u.projects = h.projects.WithChangeLog(cl).WithTx(tx)
+	u.areas = h.areas.WithChangeLog(cl).WithTx(tx)
+	u.checklist = h.checklist.WithChangeLog(cl).WithTx(tx)
+	u.pending = &[]pendingEvent{}
+	return &u
+}
+
+// broadcast sends an SSE event, or queues it while an undo is in progress.
+func (h *HistoryHandler) broadcast(eventType string, data interface{}) {
+	if h.pending != nil {
+		*h.pending = append(*h.pending, pendingEvent{eventType, data})
+		return
+	}
+	h.broker.BroadcastJSON(eventType, data)
+}
+
+func (h *HistoryHandler) undoEntry(e repository.ChangeLogEntry) error {
+	prev, err := h.changeLog.GetPreviousSnapshot(e.Seq)
+	if err != nil {
+		return err
+	}
+	var snap json.RawMessage
+	if prev != nil && isStateSnapshot(*prev) {
+		snap = json.RawMessage(*prev)
+	} else if prev == nil && e.Action != "create" {
+		return errNoEarlierVersion
+	}
+	_, err = h.restore(e.Entity, e.EntityID, snap)
+	return err
+}
+
+// restore brings an entity to the state in snap. A nil snap means the entity
+// did not exist at that point, so it is deleted.
+func (h *HistoryHandler) restore(entity, id string, snap json.RawMessage) (interface{}, error) {
+	switch entity {
+	case "task":
+		return h.restoreTask(id, snap)
+	case "project":
+		return h.restoreProject(id, snap)
+	case "area":
+		return h.restoreArea(id, snap)
+	case "checklist_item":
+		return h.restoreChecklistItem(id, snap)
+	default:
+		return nil, errors.New("unsupported entity type: " + entity)
+	}
+}
+
+func (h *HistoryHandler) restoreTask(id string, snap json.RawMessage) (*model.TaskDetail, error) {
+	current, err := h.tasks.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, errEntityGone
+	}
+	if snap == nil {
+		if current.DeletedAt == nil {
+			if err := h.tasks.Delete(id); err != nil {
+				return nil, err
+			}
+			h.broadcast("task_deleted", map[string]interface{}{"id": id})
+		}
+		return nil, nil
+	}
+	var target model.TaskDetail
+	if err := json.Unmarshal(snap, &target); err != nil {
+		return nil, err
+	}
+
+	if current.DeletedAt != nil && target.DeletedAt == nil {
+		if _, err := h.tasks.Restore(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if current.Status != target.Status {
+		switch target.Status {
+		case "open":
+			_, err = h.tasks.Reopen(id)
+		case "completed":
+			_, err = h.tasks.Complete(id)
+		case "canceled":
+			_, err = h.tasks.Cancel(id)
+		case "wont_do":
+			_, err = h.tasks.WontDo(id)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	input := model.UpdateTaskInput{Raw: map[string]json.RawMessage{}}
+	if current.Title != target.Title {
+		input.Title = &target.Title
+		input.Raw["title"] = mustMarshal(target.Title)
+	}
+	if current.Notes != target.Notes {
+		input.Notes = &target.Notes
+		input.Raw["notes"] = mustMarshal(target.Notes)
+	}
+	if current.HighPriority != target.HighPriority {
+		input.HighPriority = &target.HighPriority
+		input.Raw["high_priority"] = mustMarshal(target.HighPriority)
+	}
+	restoreNullable(input.Raw, "when_date", &input.WhenDate, current.WhenDate, target.WhenDate)
+	restoreNullable(input.Raw, "deadline", &input.Deadline, current.Deadline, target.Deadline)
+	restoreNullable(input.Raw, "project_id", &input.ProjectID, current.ProjectID, target.ProjectID)
+	restoreNullable(input.Raw, "area_id", &input.AreaID, current.AreaID, target.AreaID)
+	restoreNullable(input.Raw, "heading_id", &input.HeadingID, current.HeadingID, target.HeadingID)
+	if ids := tagRefIDs(target.Tags); !equalStrings(tagRefIDs(current.Tags), ids) {
+		input.TagIDs = ids
+	}
+	if len(input.Raw) > 0 || input.TagIDs != nil {
+		if _, err := h.tasks.Update(id, input); err != nil {
+			return nil, err
+		}
+	}
+
+	var reorder []model.ReorderItem
+	if current.SortOrderToday != target.SortOrderToday {
+		reorder = append(reorder, model.ReorderItem{ID: id, SortField: "sort_order_today", SortOrder: target.SortOrderToday})
+	}
+	if current.SortOrderProject != target.SortOrderProject {
+		reorder = append(reorder, model.ReorderItem{ID: id, SortField: "sort_order_project", SortOrder: target.SortOrderProject})
+	}
+	if current.SortOrderHeading != target.SortOrderHeading {
+		reorder = append(reorder, model.ReorderItem{ID: id, SortField: "sort_order_heading", SortOrder: target.SortOrderHeading})
+	}
+	if len(reorder) > 0 {
+		if err := h.tasks.Reorder(reorder); err != nil {
+			return nil, err
+		}
+	}
+
+	if current.DeletedAt == nil && target.DeletedAt != nil {
+		if err := h.tasks.Delete(id); err != nil {
+			return nil, err
+		}
+		h.broadcast("task_deleted", map[string]interface{}{"id": id})
+		return h.tasks.GetByID(id)
+	}
+
+	task, err := h.tasks.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	h.broadcast("task_updated", map[string]interface{}{"id": id, "task": task})
+	return task, nil
+}
+
+func (h *HistoryHandler) restoreProject(id string, snap json.RawMessage) (*model.ProjectDetail, error) {
+	current, err := h.projects.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, errEntityGone
+	}
+	if snap == nil {
+		if err := h.projects.Delete(id); err != nil {
+			return nil, err
+		}
+		h.broadcast("bulk_change", map[string]interface{}{
+			"type": "delete", "entity": "project", "ids": []string{id},
+		})
+		return nil, nil
+	}
+	var target model.ProjectDetail
+	if err := json.Unmarshal(snap, &target); err != nil {
+		return nil, err
+	}
+
+	input := model.UpdateProjectInput{Raw: map[string]json.RawMessage{}}
+	if current.Title != target.Title {
+		input.Title = &target.Title
+		input.Raw["title"] = mustMarshal(target.Title)
+	}
+	if current.Notes != target.Notes {
+		input.Notes = &target.Notes
+		input.Raw["notes"] = mustMarshal(target.Notes)
+	}
+	if current.Status != target.Status {
+		input.Status = &target.Status
+		input.Raw["status"] = mustMarshal(target.Status)
+	}
+	restoreNullable(input.Raw, "area_id", &input.AreaID, current.AreaID, target.AreaID)
+	restoreNullable(input.Raw, "when_date", &input.WhenDate, current.WhenDate, target.WhenDate)
+	restoreNullable(input.Raw, "deadline", &input.Deadline, current.Deadline, target.Deadline)
+	if ids := tagRefIDs(target.Tags); !equalStrings(tagRefIDs(current.Tags), ids) {
+		input.TagIDs = ids
+	}
+	if len(input.Raw) > 0 || input.TagIDs != nil {
+		if _, err := h.projects.Update(id, input); err != nil {
+			return nil, err
+		}
+	}
+	if current.SortOrder != target.SortOrder {
+		if err := h.projects.Reorder([]model.SimpleReorderItem{{ID: id, SortOrder: target.SortOrder}}); err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := h.projects.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	h.broadcast("project_updated", map[string]interface{}{"id": id, "project": project})
+	return project, nil
+}
+
+func (h *HistoryHandler) restoreArea(id string, snap json.RawMessage) (*model.Area, error) {
+	current, err := h.areas.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, errEntityGone
+	}
+	if snap == nil {
+		if err := h.areas.Delete(id); err != nil {
+			return nil, err
+		}
+		h.broadcast("bulk_change", map[string]interface{}{
+			"type": "delete", "entity": "area", "ids": []string{id},
+		})
+		return nil, nil
+	}
+	var target model.Area
+	if err := json.Unmarshal(snap, &target); err != nil {
+		return nil, err
+	}
+
+	var input model.UpdateAreaInput
+	if current.Title != target.Title {
+		input.Title = &target.Title
+	}
+	if current.SortOrder != target.SortOrder {
+		input.SortOrder = &target.SortOrder
+	}
+	area := &current.Area
+	if input.Title != nil || input.SortOrder != nil {
+		area, err = h.areas.Update(id, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.broadcast("area_updated", map[string]interface{}{"id": id, "area": area})
+	return area, nil
+}
+
+// restoreChecklistItem recreates a deleted checklist item under its original
+// ID, or resets a live one to the snapshot.
+func (h *HistoryHandler) restoreChecklistItem(id string, snap json.RawMessage) (*model.ChecklistItem, error) {
+	current, err := h.checklist.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		if current == nil {
+			return nil, nil
+		}
+		if err := h.checklist.Delete(id); err != nil {
+			return nil, err
+		}
+		h.broadcast("task_updated", map[string]interface{}{"id": current.TaskID})
+		return nil, nil
+	}
+	var target model.ChecklistItem
+	if err := json.Unmarshal(snap, &target); err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		task, err := h.tasks.GetByID(target.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if task == nil {
+			return nil, errEntityGone
+		}
+		current, err = h.checklist.Create(target.TaskID, model.CreateChecklistInput{ID: id, Title: target.Title})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var input model.UpdateChecklistInput
+	if current.Title != target.Title {
+		input.Title = &target.Title
+	}
+	if current.Completed != target.Completed {
+		input.Completed = &target.Completed
+	}
+	if current.SortOrder != target.SortOrder {
+		input.SortOrder = &target.SortOrder
+	}
+	item := current
+	if input.Title != nil || input.Completed != nil || input.SortOrder != nil {
+		item, err = h.checklist.Update(id, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.broadcast("task_updated", map[string]interface{}{"id": item.TaskID})
+	return item, nil
+}
+
+// isStateSnapshot reports whether a change log snapshot holds entity state.
+// Hard deletes log only {"id": ...}, which cannot be restored from.
+func isStateSnapshot(snapshot string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(snapshot), &fields); err != nil {
+		return false
+	}
+	return len(fields) > 1
+}
+
+// diffSnapshots compares the top-level fields of two snapshots. Snapshots that
+// carry no state (hard deletes) produce no diff.
+func diffSnapshots(prev, next string) []FieldChange {
+	changes := []FieldChange{}
+	if !isStateSnapshot(prev) || !isStateSnapshot(next) {
+		return changes
+	}
+	var before, after map[string]json.RawMessage
+	if json.Unmarshal([]byte(prev), &before) != nil || json.Unmarshal([]byte(next), &after) != nil {
+		return changes
+	}
+
+	keys := make(map[string]bool, len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	fields := make([]string, 0, len(keys))
+	for k := range keys {
+		if !historyIgnoredFields[k] {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		from, to := before[f], after[f]
+		if bytes.Equal(from, to) {
+			continue
+		}
+		if from == nil {
+			from = json.RawMessage("null")
+		}
+		if to == nil {
+			to = json.RawMessage("null")
+		}
+		changes = append(changes, FieldChange{Field: f, From: from, To: to})
+	}
+	return changes
+}
+
+// restoreNullable marks a nullable update field for restoring when the
+// current and target values differ.
+func restoreNullable(raw map[string]json.RawMessage, key string, dst **string, current, target *string) {
+	if equalStringPtr(current, target) {
+		return
+	}
+	*dst = target
+	raw[key] = mustMarshal(target)
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func tagRefIDs(tags []model.TagRef) []string {
+	ids := make([]string, 0, len(tags))
+	for _, t := range tags {
+		ids = append(ids, t.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,343 @@
+package handler_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+	"github.com/go-chi/chi/v5"
+)
+
+func setupHistoryRouter(t *testing.T) *testutil.TestClient {
+	t.Helper()
+	client, _ := setupHistoryRouterDB(t)
+	return client
+}
+
+func setupHistoryRouterDB(t *testing.T) (*testutil.TestClient, *sql.DB) {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	changeLogRepo := repository.NewChangeLogRepository(db)
+	taskRepo := repository.NewTaskRepository(db, changeLogRepo)
+	projectRepo := repository.NewProjectRepository(db, changeLogRepo)
+	areaRepo := repository.NewAreaRepository(db, changeLogRepo)
+	checklistRepo := repository.NewChecklistRepository(db, changeLogRepo)
+	scheduleRepo := repository.NewScheduleRepository(db, changeLogRepo)
+	reminderRepo := repository.NewReminderRepository(db, changeLogRepo)
+	settingsRepo := repository.NewUserSettingsRepository(db)
+	broker := sse.NewBroker()
+
+	taskH := handler.NewTaskHandler(taskRepo, scheduleRepo, reminderRepo, settingsRepo, broker, nil)
+	projectH := handler.NewProjectHandler(projectRepo, broker, nil)
+	areaH := handler.NewAreaHandler(areaRepo, broker)
+	checklistH := handler.NewChecklistHandler(checklistRepo, broker)
+	historyH := handler.NewHistoryHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, checklistRepo, broker)
+
+	r := chi.NewRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.Post("/tasks", taskH.Create)
+		r.Get("/tasks/{id}", taskH.Get)
+		r.Patch("/tasks/{id}", taskH.Update)
+		r.Delete("/tasks/{id}", taskH.Delete)
+		r.Patch("/tasks/{id}/complete", taskH.Complete)
+		r.Patch("/tasks/{id}/move", taskH.Move)
+		r.Get("/tasks/{id}/history", historyH.TaskHistory)
+		r.Post("/tasks/{id}/history/{seq}/revert", historyH.RevertTask)
+		r.Get("/tasks/{id}/checklist", checklistH.List)
+		r.Post("/tasks/{id}/checklist", checklistH.Create)
+		r.Delete("/checklist/{id}", checklistH.Delete)
+		r.Post("/areas", areaH.Create)
+		r.Post("/projects", projectH.Create)
+		r.Patch("/projects/{id}", projectH.Update)
+		r.Get("/projects/{id}/history", historyH.ProjectHistory)
+		r.Post("/undo", historyH.Undo)
+	})
+	return testutil.NewTestClient(t, r), db
+}
+
+type historyResponse struct {
+	Revisions []struct {
+		Seq     int64  `json:"seq"`
+		Action  string `json:"action"`
+		Changes []struct {
+			Field string      `json:"field"`
+			From  interface{} `json:"from"`
+			To    interface{} `json:"to"`
+		} `json:"changes"`
+	} `json:"revisions"`
+}
+
+func createHistoryTask(t *testing.T, client *testutil.TestClient, title string) string {
+	t.Helper()
+	resp := client.Post("/api/tasks", map[string]interface{}{"title": title})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var task map[string]interface{}
+	resp.JSON(t, &task)
+	return task["id"].(string)
+}
+
+func createHistoryProject(t *testing.T, client *testutil.TestClient, title string) string {
+	t.Helper()
+	resp := client.Post("/api/areas", map[string]interface{}{"title": title + " area"})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var area map[string]interface{}
+	resp.JSON(t, &area)
+	resp = client.Post("/api/projects", map[string]interface{}{"title": title, "area_id": area["id"]})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var project map[string]interface{}
+	resp.JSON(t, &project)
+	return project["id"].(string)
+}
+
+func getHistoryTask(t *testing.T, client *testutil.TestClient, id string) map[string]interface{} {
+	t.Helper()
+	resp := client.Get("/api/tasks/" + id)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var task map[string]interface{}
+	resp.JSON(t, &task)
+	return task
+}
+
+func TestTaskHistoryListsRevisionsWithDiffs(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "Draft")
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "Final"})
+
+	resp := client.Get("/api/tasks/" + id + "/history")
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var body historyResponse
+	resp.JSON(t, &body)
+
+	if len(body.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(body.Revisions))
+	}
+	latest := body.Revisions[0]
+	if latest.Action != "update" {
+		t.Errorf("expected newest revision first, got action %q", latest.Action)
+	}
+	if len(latest.Changes) != 1 || latest.Changes[0].Field != "title" {
+		t.Fatalf("expected a single title change, got %+v", latest.Changes)
+	}
+	if latest.Changes[0].From != "Draft" || latest.Changes[0].To != "Final" {
+		t.Errorf("expected Draft -> Final, got %v -> %v", latest.Changes[0].From, latest.Changes[0].To)
+	}
+	if len(body.Revisions[1].Changes) != 0 {
+		t.Errorf("expected no diff for the create revision, got %+v", body.Revisions[1].Changes)
+	}
+}
+
+func TestTaskRevertToRevision(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "Original")
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "Second", "notes": "added"})
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "Third"})
+
+	var body historyResponse
+	client.Get("/api/tasks/"+id+"/history").JSON(t, &body)
+	createSeq := body.Revisions[len(body.Revisions)-1].Seq
+
+	resp := client.Post(fmt.Sprintf("/api/tasks/%s/history/%d/revert", id, createSeq), nil)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	task := getHistoryTask(t, client, id)
+	if task["title"] != "Original" || task["notes"] != "" {
+		t.Errorf("expected original title and empty notes, got %v / %v", task["title"], task["notes"])
+	}
+}
+
+func TestTaskRevertRejectsForeignRevision(t *testing.T) {
+	client := setupHistoryRouter(t)
+	a := createHistoryTask(t, client, "A")
+	b := createHistoryTask(t, client, "B")
+
+	var body historyResponse
+	client.Get("/api/tasks/"+b+"/history").JSON(t, &body)
+
+	resp := client.Post(fmt.Sprintf("/api/tasks/%s/history/%d/revert", a, body.Revisions[0].Seq), nil)
+	testutil.AssertStatus(t, resp, http.StatusNotFound)
+}
+
+func TestUndoUncompletesTask(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "Finish me")
+	client.Patch("/api/tasks/"+id+"/complete", nil)
+
+	resp := client.Post("/api/undo", nil)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	task := getHistoryTask(t, client, id)
+	if task["status"] != "open" || task["completed_at"] != nil {
+		t.Errorf("expected task reopened, got status %v completed_at %v", task["status"], task["completed_at"])
+	}
+}
+
+func TestUndoUnmovesTask(t *testing.T) {
+	client := setupHistoryRouter(t)
+	projectID := createHistoryProject(t, client, "Project")
+	id := createHistoryTask(t, client, "Wanderer")
+
+	client.Patch("/api/tasks/"+id+"/move", map[string]interface{}{"project_id": projectID})
+	if task := getHistoryTask(t, client, id); task["project_id"] != projectID {
+		t.Fatalf("expected task moved into project, got %v", task["project_id"])
+	}
+
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+	if task := getHistoryTask(t, client, id); task["project_id"] != nil {
+		t.Errorf("expected task moved back out of project, got %v", task["project_id"])
+	}
+}
+
+func TestUndoRestoresDeletedChecklistItem(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "With checklist")
+	resp := client.Post("/api/tasks/"+id+"/checklist", map[string]interface{}{"title": "Step one"})
+	var item map[string]interface{}
+	resp.JSON(t, &item)
+	testutil.AssertStatus(t, client.Delete("/api/checklist/"+item["id"].(string)), http.StatusNoContent)
+
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	client.Get("/api/tasks/"+id+"/checklist").JSON(t, &list)
+	if len(list.Items) != 1 || list.Items[0]["id"] != item["id"] || list.Items[0]["title"] != "Step one" {
+		t.Errorf("expected checklist item restored with its ID, got %+v", list.Items)
+	}
+}
+
+func TestUndoMultipleStepsAndDoesNotRedo(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "v1")
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v2"})
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v3"})
+
+	resp := client.Post("/api/undo", map[string]interface{}{"count": 2})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var body struct {
+		Undone  []map[string]interface{} `json:"undone"`
+		Skipped []map[string]interface{} `json:"skipped"`
+	}
+	resp.JSON(t, &body)
+	if len(body.Undone) != 2 || len(body.Skipped) != 0 {
+		t.Fatalf("expected 2 undone and 0 skipped, got %d / %d", len(body.Undone), len(body.Skipped))
+	}
+	if task := getHistoryTask(t, client, id); task["title"] != "v1" {
+		t.Fatalf("expected title v1 after two undos, got %v", task["title"])
+	}
+
+	// The next undo reverses the create rather than the undo's own writes.
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+	if task := getHistoryTask(t, client, id); task["deleted_at"] == nil {
+		t.Errorf("expected undoing the create to delete the task")
+	}
+}
+
+func TestUndoRollsBackTheBatchOnFailure(t *testing.T) {
+	client, db := setupHistoryRouterDB(t)
+	gone := createHistoryTask(t, client, "Gone")
+	client.Patch("/api/tasks/"+gone, map[string]interface{}{"title": "Gone v2"})
+	id := createHistoryTask(t, client, "v1")
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v2"})
+	if _, err := db.Exec("DELETE FROM tasks WHERE id = ?", gone); err != nil {
+		t.Fatal(err)
+	}
+
+	// The v2 change is undone first, then the vanished task fails the batch.
+	resp := client.Post("/api/undo", map[string]interface{}{"count": 3})
+	testutil.AssertStatus(t, resp, http.StatusConflict)
+	if task := getHistoryTask(t, client, id); task["title"] != "v2" {
+		t.Fatalf("expected the batch rolled back, got title %v", task["title"])
+	}
+
+	// Nothing was marked undone, so the change is still first in line.
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+	if task := getHistoryTask(t, client, id); task["title"] != "v1" {
+		t.Errorf("expected title v1 after undo, got %v", task["title"])
+	}
+}
+
+func TestUndoSkipsChangesWithoutEarlierRevision(t *testing.T) {
+	client, db := setupHistoryRouterDB(t)
+	// A task from before the change log has no revision to go back to.
+	id := "legacy"
+	if _, err := db.Exec("INSERT INTO tasks (id, title) VALUES (?, 'v1')", id); err != nil {
+		t.Fatal(err)
+	}
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v2"})
+
+	for i := 0; i < 2; i++ {
+		resp := client.Post("/api/undo", nil)
+		testutil.AssertStatus(t, resp, http.StatusOK)
+		var body struct {
+			Undone  []map[string]interface{} `json:"undone"`
+			Skipped []map[string]interface{} `json:"skipped"`
+		}
+		resp.JSON(t, &body)
+		// A skipped change is not marked undone and is reported again.
+		if len(body.Undone) != 0 || len(body.Skipped) != 1 {
+			t.Fatalf("undo %d: expected 0 undone and 1 skipped, got %d / %d", i+1, len(body.Undone), len(body.Skipped))
+		}
+	}
+	if task := getHistoryTask(t, client, id); task["title"] != "v2" {
+		t.Errorf("expected title unchanged, got %v", task["title"])
+	}
+}
+
+func TestUndoAfterCompaction(t *testing.T) {
+	client, db := setupHistoryRouterDB(t)
+	id := createHistoryTask(t, client, "v1")
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v2"})
+	client.Patch("/api/tasks/"+id, map[string]interface{}{"title": "v3"})
+	if _, err := db.Exec("UPDATE change_log SET created_at = datetime('now', '-10 days')"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := repository.NewChangeLogRepository(db).CompactOlderThan(7); err != nil || n != 2 {
+		t.Fatalf("CompactOlderThan = %d, %v; want the create and v2 entries removed", n, err)
+	}
+
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+	if task := getHistoryTask(t, client, id); task["title"] != "v2" {
+		t.Errorf("expected title v2 after undo, got %v", task["title"])
+	}
+}
+
+func TestUndoRestoresDeletedTask(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryTask(t, client, "Oops")
+	client.Delete("/api/tasks/" + id)
+
+	testutil.AssertStatus(t, client.Post("/api/undo", nil), http.StatusOK)
+	if task := getHistoryTask(t, client, id); task["deleted_at"] != nil {
+		t.Errorf("expected task restored, got deleted_at %v", task["deleted_at"])
+	}
+}
+
+func TestUndoValidatesCount(t *testing.T) {
+	client := setupHistoryRouter(t)
+	resp := client.Post("/api/undo", map[string]interface{}{"count": 0})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+	resp = client.Post("/api/undo", map[string]interface{}{"count": 500})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestProjectHistory(t *testing.T) {
+	client := setupHistoryRouter(t)
+	id := createHistoryProject(t, client, "Launch")
+	client.Patch("/api/projects/"+id, map[string]interface{}{"notes": "plan"})
+
+	var body historyResponse
+	client.Get("/api/projects/"+id+"/history").JSON(t, &body)
+	if len(body.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(body.Revisions))
+	}
+	if len(body.Revisions[0].Changes) != 1 || body.Revisions[0].Changes[0].Field != "notes" {
+		t.Errorf("expected a single notes change, got %+v", body.Revisions[0].Changes)
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/collinjanssen/thingstodo/internal/recurrence/phrase"
+)
+
+type ParseHandler struct{}
+
+func NewParseHandler() *ParseHandler {
+	return &ParseHandler{}
+}
+
+// Recurrence turns a phrase such as "every other friday" into a recurrence
+// pattern ready for PUT /api/tasks/{id}/repeat.
+// GET /api/parse/recurrence?text=...
+func (h *ParseHandler) Recurrence(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("text"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, "text parameter is required", "VALIDATION")
+		return
+	}
+	pattern, err := phrase.Parse(text)
+	if err == nil {
+		err = validatePattern(&pattern)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "VALIDATION")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"text": text, "pattern": pattern})
+}
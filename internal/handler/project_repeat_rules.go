@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+)
+
+type ProjectRepeatRuleHandler struct {
+	repo         *repository.ProjectRepeatRuleRepository
+	projectRepo  *repository.ProjectRepository
+	settingsRepo *repository.UserSettingsRepository
+	engine       *recurrence.Engine
+	broker       *sse.Broker
+}
+
+func NewProjectRepeatRuleHandler(repo *repository.ProjectRepeatRuleRepository, projectRepo *repository.ProjectRepository, settingsRepo *repository.UserSettingsRepository, engine *recurrence.Engine, broker *sse.Broker) *ProjectRepeatRuleHandler {
+	return &ProjectRepeatRuleHandler{repo: repo, projectRepo: projectRepo, settingsRepo: settingsRepo, engine: engine, broker: broker}
+}
+
+// GET /api/projects/{id}/repeat
+func (h *ProjectRepeatRuleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.repo.GetByProject(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"repeat_rule": rule})
+}
+
+// Upsert sets a project's repeat rule. A project without a when_date is
+// moved to the rule's first occurrence.
+// PUT /api/projects/{id}/repeat
+func (h *ProjectRepeatRuleHandler) Upsert(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	var input model.CreateProjectRepeatRuleInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+	if input.Pattern == nil {
+		writeError(w, http.StatusBadRequest, "pattern is required", "VALIDATION")
+		return
+	}
+	if err := validatePattern(input.Pattern); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "VALIDATION")
+		return
+	}
+	// Projects are scheduled by day.
+	if input.Pattern.Type == model.PatternHourly || input.Pattern.Time != "" {
+		writeError(w, http.StatusBadRequest, "project repeat rules take whole days; hourly patterns and time do not apply", "VALIDATION")
+		return
+	}
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if project == nil {
+		writeError(w, http.StatusNotFound, "project not found", "NOT_FOUND")
+		return
+	}
+
+	if input.Pattern.Occurrence == 0 && project.RepeatRule != nil {
+		input.Pattern.Occurrence = project.RepeatRule.Pattern.Occurrence
+	}
+
+	rule, err := h.repo.Upsert(projectID, input)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+
+	if project.WhenDate == nil {
+		today := time.Now().Format("2006-01-02")
+		if first, calcErr := requestEngine(r, h.settingsRepo, h.engine).FirstOnOrAfter(today, rule.Pattern); calcErr == nil {
+			date, _ := recurrence.SplitDateTime(first)
+			if _, err := h.projectRepo.Update(projectID, model.UpdateProjectInput{
+				WhenDate: &date,
+				Raw:      map[string]json.RawMessage{"when_date": json.RawMessage(`"` + date + `"`)},
+			}); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+				return
+			}
+		}
+	}
+
+	h.broker.BroadcastJSON("project_updated", map[string]interface{}{"id": projectID})
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// DELETE /api/projects/{id}/repeat
+func (h *ProjectRepeatRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	if err := h.repo.DeleteByProject(projectID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	h.broker.BroadcastJSON("project_updated", map[string]interface{}{"id": projectID})
+	w.WriteHeader(http.StatusNoContent)
+}
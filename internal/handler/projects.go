@@ -6,17 +6,19 @@ import (
 
 	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/scheduler"
 	"github.com/collinjanssen/thingstodo/internal/sse"
 	"github.com/go-chi/chi/v5"
 )
 
 type ProjectHandler struct {
-	repo   *repository.ProjectRepository
-	broker *sse.Broker
+	repo      *repository.ProjectRepository
+	broker    *sse.Broker
+	scheduler *scheduler.Scheduler
 }
 
-func NewProjectHandler(repo *repository.ProjectRepository, broker *sse.Broker) *ProjectHandler {
-	return &ProjectHandler{repo: repo, broker: broker}
+func NewProjectHandler(repo *repository.ProjectRepository, broker *sse.Broker, sched *scheduler.Scheduler) *ProjectHandler {
+	return &ProjectHandler{repo: repo, broker: broker, scheduler: sched}
 }
 
 func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -87,6 +89,14 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 	input.Raw = raw
 
+	// Finishing a repeating project creates its next instance, as Complete does.
+	wasOpen := false
+	if input.Status != nil && (*input.Status == "completed" || *input.Status == "canceled") {
+		if existing, err := h.repo.GetByID(id); err == nil && existing != nil {
+			wasOpen = existing.Status == "open"
+		}
+	}
+
 	project, err := h.repo.Update(id, input)
 	if err != nil {
 		if errors.Is(err, repository.ErrDuplicateProjectName) {
@@ -100,6 +110,9 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "project not found", "NOT_FOUND")
 		return
 	}
+	if wasOpen && h.scheduler != nil {
+		h.scheduler.HandleProjectDone(id)
+	}
 	h.broker.BroadcastJSON("project_updated", map[string]interface{}{"id": project.ID, "project": project})
 	writeJSON(w, http.StatusOK, project)
 }
@@ -140,6 +153,15 @@ func (h *ProjectHandler) Reorder(w http.ResponseWriter, r *http.Request) {
 
 func (h *ProjectHandler) Complete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	existing, err := h.repo.GetByID(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "project not found", "NOT_FOUND")
+		return
+	}
 	project, err := h.repo.Complete(id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
@@ -149,6 +171,9 @@ func (h *ProjectHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "project not found", "NOT_FOUND")
 		return
 	}
+	if existing.Status == "open" && h.scheduler != nil {
+		h.scheduler.HandleProjectDone(id)
+	}
 	h.broker.BroadcastJSON("project_updated", map[string]interface{}{"id": project.ID, "project": project})
 	writeJSON(w, http.StatusOK, project)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
 	"github.com/collinjanssen/thingstodo/internal/repository"
 	"github.com/collinjanssen/thingstodo/internal/sse"
 	"github.com/collinjanssen/thingstodo/internal/testutil"
@@ -17,9 +18,11 @@ func setupProjectRouter(t *testing.T) (*testutil.TestClient, *sql.DB) {
 	db := testutil.SetupTestDB(t)
 	broker := sse.NewBroker()
 	projRepo := repository.NewProjectRepository(db, nil)
-	projHandler := handler.NewProjectHandler(projRepo, broker)
+	projHandler := handler.NewProjectHandler(projRepo, broker, nil)
 	areaRepo := repository.NewAreaRepository(db, nil)
 	areaHandler := handler.NewAreaHandler(areaRepo, broker)
+	repeatHandler := handler.NewProjectRepeatRuleHandler(repository.NewProjectRepeatRuleRepository(db, nil), projRepo,
+		repository.NewUserSettingsRepository(db), recurrence.NewEngine(), broker)
 
 	r := chi.NewRouter()
 	r.Route("/api/areas", func(r chi.Router) {
@@ -33,6 +36,9 @@ func setupProjectRouter(t *testing.T) (*testutil.TestClient, *sql.DB) {
 			r.Patch("/", projHandler.Update)
 			r.Delete("/", projHandler.Delete)
 			r.Patch("/complete", projHandler.Complete)
+			r.Get("/repeat", repeatHandler.Get)
+			r.Put("/repeat", repeatHandler.Upsert)
+			r.Delete("/repeat", repeatHandler.Delete)
 		})
 	})
 
@@ -166,3 +172,64 @@ func TestProjectHandlerListEmpty(t *testing.T) {
 		t.Errorf("expected 0 projects, got %d", len(projects))
 	}
 }
+
+func TestProjectRepeatRule(t *testing.T) {
+	client, _ := setupProjectRouter(t)
+	areaID := createTestArea(t, client)
+	resp := client.Post("/api/projects", map[string]string{"title": "Quarterly review", "area_id": areaID})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var project map[string]interface{}
+	resp.JSON(t, &project)
+	id := project["id"].(string)
+
+	resp = client.Put("/api/projects/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{"type": "hourly", "every": 4, "mode": "fixed"},
+	})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Put("/api/projects/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{"type": "rrule", "every": 1, "mode": "fixed", "rrule": "FREQ=MONTHLY;INTERVAL=3"},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	// The project moves to the first occurrence and reports its rule.
+	resp = client.Get("/api/projects/" + id)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var detail struct {
+		WhenDate   *string `json:"when_date"`
+		SeriesID   *string `json:"series_id"`
+		RepeatRule *struct {
+			Pattern struct {
+				RRule string `json:"rrule"`
+			} `json:"pattern"`
+		} `json:"repeat_rule"`
+	}
+	resp.JSON(t, &detail)
+	if detail.WhenDate == nil || detail.SeriesID == nil || *detail.SeriesID != id {
+		t.Errorf("expected when_date and series_id to be set, got %v %v", detail.WhenDate, detail.SeriesID)
+	}
+	if detail.RepeatRule == nil || detail.RepeatRule.Pattern.RRule != "FREQ=MONTHLY;INTERVAL=3" {
+		t.Errorf("expected the repeat rule on the project, got %+v", detail.RepeatRule)
+	}
+
+	testutil.AssertStatus(t, client.Delete("/api/projects/"+id+"/repeat"), http.StatusNoContent)
+	resp = client.Get("/api/projects/" + id + "/repeat")
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	testutil.AssertJSONField(t, resp, "repeat_rule", nil)
+}
+
+func TestProjectTitleUniqueAmongOpenProjects(t *testing.T) {
+	client, _ := setupProjectRouter(t)
+	areaID := createTestArea(t, client)
+	resp := client.Post("/api/projects", map[string]string{"title": "Monthly closing", "area_id": areaID})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var project map[string]interface{}
+	resp.JSON(t, &project)
+
+	resp = client.Post("/api/projects", map[string]string{"title": "Monthly closing", "area_id": areaID})
+	testutil.AssertStatus(t, resp, http.StatusConflict)
+
+	testutil.AssertStatus(t, client.Patch("/api/projects/"+project["id"].(string)+"/complete", nil), http.StatusOK)
+	resp = client.Post("/api/projects", map[string]string{"title": "Monthly closing", "area_id": areaID})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+}
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
@@ -14,14 +16,15 @@ import (
 )
 
 type RepeatRuleHandler struct {
-	repo     *repository.RepeatRuleRepository
-	taskRepo *repository.TaskRepository
-	engine   *recurrence.Engine
-	broker   *sse.Broker
+	repo         *repository.RepeatRuleRepository
+	taskRepo     *repository.TaskRepository
+	settingsRepo *repository.UserSettingsRepository
+	engine       *recurrence.Engine
+	broker       *sse.Broker
 }
 
-func NewRepeatRuleHandler(repo *repository.RepeatRuleRepository, taskRepo *repository.TaskRepository, engine *recurrence.Engine, broker *sse.Broker) *RepeatRuleHandler {
-	return &RepeatRuleHandler{repo: repo, taskRepo: taskRepo, engine: engine, broker: broker}
+func NewRepeatRuleHandler(repo *repository.RepeatRuleRepository, taskRepo *repository.TaskRepository, settingsRepo *repository.UserSettingsRepository, engine *recurrence.Engine, broker *sse.Broker) *RepeatRuleHandler {
+	return &RepeatRuleHandler{repo: repo, taskRepo: taskRepo, settingsRepo: settingsRepo, engine: engine, broker: broker}
 }
 
 func (h *RepeatRuleHandler) Get(w http.ResponseWriter, r *http.Request) {
@@ -42,12 +45,24 @@ func (h *RepeatRuleHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if input.CatchUp != nil && !validCatchUp[*input.CatchUp] {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid catch_up: %s", *input.CatchUp), "VALIDATION")
+		return
+	}
+
 	// Validate: either pattern or legacy fields must be present
 	if input.Pattern != nil {
 		if err := validatePattern(input.Pattern); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error(), "VALIDATION")
 			return
 		}
+		// Editing a rule keeps the task's position in the series unless the
+		// client sends one.
+		if input.Pattern.Occurrence == 0 {
+			if existing, err := h.repo.GetByTask(taskID); err == nil && existing != nil {
+				input.Pattern.Occurrence = existing.Pattern.Occurrence
+			}
+		}
 	} else if input.Frequency == "" || input.Mode == "" {
 		writeError(w, http.StatusBadRequest, "frequency and mode are required", "VALIDATION")
 		return
@@ -66,12 +81,9 @@ func (h *RepeatRuleHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 	// If task has no when_date, set it to the first occurrence
 	if task, taskErr := h.taskRepo.GetByID(taskID); taskErr == nil && task != nil && task.WhenDate == nil {
 		today := time.Now().Format("2006-01-02")
-		if nextDate, calcErr := h.engine.FirstOnOrAfter(today, rule.Pattern); calcErr == nil {
-			if _, updateErr := h.taskRepo.Update(taskID, model.UpdateTaskInput{
-				WhenDate: &nextDate,
-				Raw:      map[string]json.RawMessage{"when_date": json.RawMessage(`"` + nextDate + `"`)},
-			}); updateErr != nil {
-				writeError(w, http.StatusInternalServerError, updateErr.Error(), "INTERNAL")
+		if next, calcErr := requestEngine(r, h.settingsRepo, h.engine).FirstOnOrAfter(today, rule.Pattern); calcErr == nil {
+			if err := h.moveToOccurrence(taskID, next); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 				return
 			}
 		}
@@ -81,6 +93,126 @@ func (h *RepeatRuleHandler) Upsert(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, rule)
 }
 
+// Preview lists the next occurrences of a task's repeat rule after its current
+// date, honouring the rule's end conditions and exceptions.
+// GET /api/tasks/{id}/repeat/preview?count=N
+func (h *RepeatRuleHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	count := 5
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			writeError(w, http.StatusBadRequest, "count must be between 1 and 100", "BAD_REQUEST")
+			return
+		}
+		count = n
+	}
+
+	task, err := h.taskRepo.GetByID(taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if task == nil {
+		writeError(w, http.StatusNotFound, "task not found", "NOT_FOUND")
+		return
+	}
+	rule, err := h.repo.GetByTask(taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if rule == nil {
+		writeError(w, http.StatusNotFound, "task does not repeat", "NOT_FOUND")
+		return
+	}
+
+	from := time.Now().Format("2006-01-02")
+	if task.WhenDate != nil && *task.WhenDate != "someday" {
+		from = recurrence.JoinDateTime(*task.WhenDate, model.FirstStartTime(task.Schedules))
+	}
+	dates, err := requestEngine(r, h.settingsRepo, h.engine).NextOccurrences(from, rule.Pattern, count)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), "VALIDATION")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"from": from, "occurrences": dates})
+}
+
+// Skip moves a repeating task to the occurrence after its current one without
+// completing it. The skipped occurrence still counts towards the series count.
+// PATCH /api/tasks/{id}/skip
+func (h *RepeatRuleHandler) Skip(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+	task, err := h.taskRepo.GetByID(taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if task == nil {
+		writeError(w, http.StatusNotFound, "task not found", "NOT_FOUND")
+		return
+	}
+	rule, err := h.repo.GetByTask(taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if rule == nil {
+		writeError(w, http.StatusBadRequest, "task does not repeat", "VALIDATION")
+		return
+	}
+
+	from := ""
+	if task.WhenDate != nil {
+		from = recurrence.JoinDateTime(*task.WhenDate, model.FirstStartTime(task.Schedules))
+	}
+	next, occurrence, err := requestEngine(r, h.settingsRepo, h.engine).NextInSeries(from, rule.Pattern)
+	if errors.Is(err, recurrence.ErrNoMoreOccurrences) {
+		writeError(w, http.StatusConflict, "the series has no more occurrences", "CONFLICT")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+
+	pattern := rule.Pattern
+	pattern.Occurrence = occurrence
+	if _, err := h.repo.Upsert(taskID, model.CreateRepeatRuleInput{Pattern: &pattern}); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if err := h.moveToOccurrence(taskID, next); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	updated, err := h.taskRepo.GetByID(taskID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+
+	h.broker.BroadcastJSON("task_updated", map[string]interface{}{"id": taskID})
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// moveToOccurrence sets the task's when_date to the occurrence's date and,
+// for a date-time occurrence, the start time of its first schedule entry.
+func (h *RepeatRuleHandler) moveToOccurrence(taskID, occurrence string) error {
+	date, clock := recurrence.SplitDateTime(occurrence)
+	if _, err := h.taskRepo.Update(taskID, model.UpdateTaskInput{
+		WhenDate: &date,
+		Raw:      map[string]json.RawMessage{"when_date": json.RawMessage(`"` + date + `"`)},
+	}); err != nil {
+		return err
+	}
+	if clock == "" {
+		return nil
+	}
+	return h.taskRepo.SetFirstScheduleTime(taskID, clock)
+}
+
 func (h *RepeatRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "id")
 	if err := h.repo.DeleteByTask(taskID); err != nil {
@@ -100,6 +232,12 @@ var validPatternTypes = map[model.PatternType]bool{
 	model.PatternMonthlyWorkday: true,
 	model.PatternYearlyDate:     true,
 	model.PatternYearlyDOW:      true,
+	model.PatternRRule:          true,
+	model.PatternHourly:         true,
+}
+
+var validCatchUp = map[string]bool{
+	model.CatchUpNone: true, model.CatchUpAll: true, model.CatchUpLatest: true, model.CatchUpSkip: true,
 }
 
 var validWeekdays = map[string]bool{
@@ -121,6 +259,31 @@ func validatePattern(p *model.RecurrencePattern) error {
 		return fmt.Errorf("invalid mode: %s", p.Mode)
 	}
 
+	if p.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+	if p.Until != "" {
+		if _, err := time.Parse("2006-01-02", p.Until); err != nil {
+			return fmt.Errorf("until must be a date (YYYY-MM-DD)")
+		}
+	}
+	for _, d := range p.Exceptions {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("invalid exception date: %s", d)
+		}
+	}
+	if p.Roll != "" && p.Roll != model.RollForward && p.Roll != model.RollBackward {
+		return fmt.Errorf("roll must be 'forward' or 'backward'")
+	}
+	if p.Time != "" {
+		if _, err := recurrence.ParseClock(p.Time); err != nil {
+			return fmt.Errorf("time must be HH:MM")
+		}
+	}
+	if p.TimeUntil != "" && p.Type != model.PatternHourly {
+		return fmt.Errorf("time_until only applies to hourly patterns")
+	}
+
 	switch p.Type {
 	case model.PatternWeekly:
 		for _, d := range p.On {
@@ -147,7 +310,8 @@ func validatePattern(p *model.RecurrencePattern) error {
 			return fmt.Errorf("workday_position must be 'first' or 'last'")
 		}
 	case model.PatternYearlyDate:
-		if p.Month < 1 || p.Month > 12 {
+		// Without a month the task's own month is kept.
+		if p.Month < 0 || p.Month > 12 {
 			return fmt.Errorf("month must be between 1 and 12")
 		}
 	case model.PatternYearlyDOW:
@@ -160,6 +324,35 @@ func validatePattern(p *model.RecurrencePattern) error {
 		if p.Weekday != "" && !validWeekdays[p.Weekday] {
 			return fmt.Errorf("invalid weekday: %s", p.Weekday)
 		}
+	case model.PatternRRule:
+		if _, err := recurrence.ParseRRule(p.RRule); err != nil {
+			return fmt.Errorf("invalid rrule: %w", err)
+		}
+	case model.PatternHourly:
+		if p.Every < 1 || p.Every > 23 {
+			return fmt.Errorf("every must be between 1 and 23 hours")
+		}
+		if p.Roll != "" {
+			return fmt.Errorf("roll does not apply to hourly patterns")
+		}
+		for _, d := range p.On {
+			if !validWeekdays[d] {
+				return fmt.Errorf("invalid weekday: %s", d)
+			}
+		}
+		start, end := 0, 23*60+59
+		if p.Time != "" {
+			start, _ = recurrence.ParseClock(p.Time)
+		}
+		if p.TimeUntil != "" {
+			var err error
+			if end, err = recurrence.ParseClock(p.TimeUntil); err != nil {
+				return fmt.Errorf("time_until must be HH:MM")
+			}
+		}
+		if end < start {
+			return fmt.Errorf("time_until must not be before time")
+		}
 	}
 
 	return nil
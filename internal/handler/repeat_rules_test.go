@@ -0,0 +1,345 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+	"github.com/go-chi/chi/v5"
+)
+
+func setupRepeatRouter(t *testing.T) *testutil.TestClient {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	changeLogRepo := repository.NewChangeLogRepository(db)
+	taskRepo := repository.NewTaskRepository(db, changeLogRepo)
+	scheduleRepo := repository.NewScheduleRepository(db, changeLogRepo)
+	reminderRepo := repository.NewReminderRepository(db, changeLogRepo)
+	settingsRepo := repository.NewUserSettingsRepository(db)
+	repeatRepo := repository.NewRepeatRuleRepository(db, changeLogRepo)
+	broker := sse.NewBroker()
+
+	taskH := handler.NewTaskHandler(taskRepo, scheduleRepo, reminderRepo, settingsRepo, broker, nil)
+	repeatH := handler.NewRepeatRuleHandler(repeatRepo, taskRepo, settingsRepo, recurrence.NewEngine(), broker)
+
+	r := chi.NewRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.Post("/tasks", taskH.Create)
+		r.Get("/tasks/{id}", taskH.Get)
+		r.Get("/tasks/{id}/repeat", repeatH.Get)
+		r.Put("/tasks/{id}/repeat", repeatH.Upsert)
+		r.Patch("/tasks/{id}/skip", repeatH.Skip)
+		r.Get("/tasks/{id}/repeat/preview", repeatH.Preview)
+		r.Get("/parse/recurrence", handler.NewParseHandler().Recurrence)
+	})
+	return testutil.NewTestClient(t, r)
+}
+
+func createRepeatTask(t *testing.T, client *testutil.TestClient, whenDate string) string {
+	t.Helper()
+	body := map[string]interface{}{"title": "Recurring"}
+	if whenDate != "" {
+		body["when_date"] = whenDate
+	}
+	resp := client.Post("/api/tasks", body)
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var task map[string]interface{}
+	resp.JSON(t, &task)
+	return task["id"].(string)
+}
+
+func TestRepeatRuleAcceptsRRule(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-11")
+
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "rrule", "every": 1, "mode": "fixed", "rrule": "FREQ=MONTHLY;BYDAY=2TU,4TU",
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var rule struct {
+		Frequency string `json:"frequency"`
+		Pattern   struct {
+			RRule string `json:"rrule"`
+		} `json:"pattern"`
+	}
+	resp.JSON(t, &rule)
+	if rule.Frequency != "monthly" {
+		t.Errorf("expected legacy frequency monthly, got %q", rule.Frequency)
+	}
+	if rule.Pattern.RRule != "FREQ=MONTHLY;BYDAY=2TU,4TU" {
+		t.Errorf("expected rrule kept as given, got %q", rule.Pattern.RRule)
+	}
+}
+
+func TestRepeatRuleRejectsInvalidRRule(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "")
+
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "rrule", "every": 1, "mode": "fixed", "rrule": "FREQ=HOURLY;INTERVAL=2",
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestRepeatRulePinsCountedRRuleToWhenDate(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "rrule", "every": 1, "mode": "fixed", "rrule": "FREQ=WEEKLY;COUNT=4",
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var rule struct {
+		Pattern struct {
+			RRule string `json:"rrule"`
+		} `json:"pattern"`
+	}
+	resp.JSON(t, &rule)
+	want := "DTSTART;VALUE=DATE:20250310\nRRULE:FREQ=WEEKLY;COUNT=4"
+	if rule.Pattern.RRule != want {
+		t.Errorf("expected rrule %q, got %q", want, rule.Pattern.RRule)
+	}
+}
+
+func TestRepeatRuleValidatesEndConditions(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+
+	for _, extra := range []map[string]interface{}{
+		{"count": -1},
+		{"until": "next week"},
+		{"exceptions": []string{"2025-03-17", "someday"}},
+	} {
+		pattern := map[string]interface{}{"type": "daily", "every": 1, "mode": "fixed"}
+		for k, v := range extra {
+			pattern[k] = v
+		}
+		resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+		testutil.AssertStatus(t, resp, http.StatusBadRequest)
+	}
+}
+
+func TestSkipMovesTaskPastExceptions(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "weekly", "every": 1, "mode": "fixed", "on": []string{"mon"},
+			"exceptions": []string{"2025-03-17"},
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	resp = client.Patch("/api/tasks/"+id+"/skip", nil)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var task struct {
+		WhenDate   string `json:"when_date"`
+		RepeatRule struct {
+			Pattern struct {
+				Occurrence int `json:"occurrence"`
+			} `json:"pattern"`
+		} `json:"repeat_rule"`
+	}
+	resp.JSON(t, &task)
+	if task.WhenDate != "2025-03-24" {
+		t.Errorf("expected when_date 2025-03-24, got %s", task.WhenDate)
+	}
+	if task.RepeatRule.Pattern.Occurrence != 3 {
+		t.Errorf("expected occurrence 3 (the exception uses one up), got %d", task.RepeatRule.Pattern.Occurrence)
+	}
+}
+
+func TestSkipHourlyMovesStartTime(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "hourly", "every": 4, "mode": "fixed", "time": "08:00", "time_until": "20:00",
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	for _, want := range []string{"2025-03-10 08:00", "2025-03-10 12:00", "2025-03-10 16:00", "2025-03-10 20:00", "2025-03-11 08:00"} {
+		resp = client.Patch("/api/tasks/"+id+"/skip", nil)
+		testutil.AssertStatus(t, resp, http.StatusOK)
+		var task struct {
+			WhenDate  string `json:"when_date"`
+			Schedules []struct {
+				StartTime string `json:"start_time"`
+			} `json:"schedules"`
+		}
+		resp.JSON(t, &task)
+		if len(task.Schedules) == 0 || task.WhenDate+" "+task.Schedules[0].StartTime != want {
+			t.Fatalf("expected the task at %s, got %+v", want, task)
+		}
+	}
+}
+
+func TestRepeatRuleValidatesTimeOfDay(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	for _, pattern := range []map[string]interface{}{
+		{"type": "daily", "every": 1, "mode": "fixed", "time": "9:30pm"},
+		{"type": "daily", "every": 1, "mode": "fixed", "time": "09:30", "time_until": "12:00"},
+		{"type": "hourly", "every": 24, "mode": "fixed"},
+		{"type": "hourly", "every": 2, "mode": "fixed", "time": "20:00", "time_until": "08:00"},
+	} {
+		resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", pattern, resp.StatusCode)
+		}
+	}
+}
+
+func TestSkipStopsAtEndOfSeries(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{"type": "daily", "every": 1, "mode": "fixed", "until": "2025-03-11"},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	testutil.AssertStatus(t, client.Patch("/api/tasks/"+id+"/skip", nil), http.StatusOK)
+	testutil.AssertStatus(t, client.Patch("/api/tasks/"+id+"/skip", nil), http.StatusConflict)
+}
+
+func TestSkipRequiresRepeatRule(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	testutil.AssertStatus(t, client.Patch("/api/tasks/"+id+"/skip", nil), http.StatusBadRequest)
+}
+
+func TestRepeatPreviewListsNextOccurrences(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": map[string]interface{}{
+			"type": "rrule", "every": 1, "mode": "fixed", "rrule": "FREQ=MONTHLY;BYDAY=2TU,4TU",
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	resp = client.Get("/api/tasks/" + id + "/repeat/preview?count=3")
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var body struct {
+		Occurrences []string `json:"occurrences"`
+	}
+	resp.JSON(t, &body)
+	want := []string{"2025-03-11", "2025-03-25", "2025-04-08"}
+	if len(body.Occurrences) != 3 || body.Occurrences[0] != want[0] || body.Occurrences[2] != want[2] {
+		t.Errorf("expected %v, got %v", want, body.Occurrences)
+	}
+
+	testutil.AssertStatus(t, client.Get("/api/tasks/"+id+"/repeat/preview?count=0"), http.StatusBadRequest)
+}
+
+func TestRepeatPreviewRequiresRule(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	testutil.AssertStatus(t, client.Get("/api/tasks/"+id+"/repeat/preview"), http.StatusNotFound)
+}
+
+func TestRepeatRuleCopyOptionsDefaultAndPersist(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	pattern := map[string]interface{}{"type": "daily", "every": 1, "mode": "fixed"}
+
+	type copyOpts struct {
+		Notes       bool `json:"notes"`
+		Checklist   bool `json:"checklist"`
+		Attachments bool `json:"attachments"`
+	}
+	var rule struct {
+		Copy copyOpts `json:"copy"`
+	}
+
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp.JSON(t, &rule)
+	if rule.Copy != (copyOpts{true, true, true}) {
+		t.Errorf("expected everything copied by default, got %+v", rule.Copy)
+	}
+
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{
+		"pattern": pattern, "copy": map[string]interface{}{"attachments": false},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp.JSON(t, &rule)
+	if rule.Copy != (copyOpts{true, true, false}) {
+		t.Errorf("expected only attachments turned off, got %+v", rule.Copy)
+	}
+
+	// Editing the pattern without copy options keeps the stored ones.
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp.JSON(t, &rule)
+	if rule.Copy.Attachments {
+		t.Errorf("expected copy options kept, got %+v", rule.Copy)
+	}
+}
+
+func TestRepeatRuleCatchUpPolicy(t *testing.T) {
+	client := setupRepeatRouter(t)
+	id := createRepeatTask(t, client, "2025-03-10")
+	pattern := map[string]interface{}{"type": "daily", "every": 1, "mode": "fixed"}
+
+	var rule struct {
+		CatchUp string `json:"catch_up"`
+	}
+	resp := client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp.JSON(t, &rule)
+	if rule.CatchUp != "none" {
+		t.Errorf("expected catch_up none by default, got %q", rule.CatchUp)
+	}
+
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern, "catch_up": "skip"})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	resp.JSON(t, &rule)
+	if rule.CatchUp != "skip" {
+		t.Errorf("expected catch_up kept, got %q", rule.CatchUp)
+	}
+
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": pattern, "catch_up": "sometimes"})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestParseRecurrence(t *testing.T) {
+	client := setupRepeatRouter(t)
+
+	resp := client.Get("/api/parse/recurrence?text=" + url.QueryEscape("every other Friday after completion"))
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var parsed struct {
+		Pattern map[string]interface{} `json:"pattern"`
+	}
+	resp.JSON(t, &parsed)
+	if parsed.Pattern["type"] != "weekly" || parsed.Pattern["every"] != float64(2) || parsed.Pattern["mode"] != "after_completion" {
+		t.Fatalf("unexpected pattern: %v", parsed.Pattern)
+	}
+
+	// The parsed pattern is accepted as a repeat rule as is.
+	id := createRepeatTask(t, client, "2025-03-14")
+	resp = client.Put("/api/tasks/"+id+"/repeat", map[string]interface{}{"pattern": parsed.Pattern})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+
+	resp = client.Get("/api/parse/recurrence?text=" + url.QueryEscape("every book"))
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+	testutil.AssertJSONField(t, resp, "code", "VALIDATION")
+
+	resp = client.Get("/api/parse/recurrence")
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+}
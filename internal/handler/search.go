@@ -1,31 +1,91 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/scheduler"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 )
 
 type SearchHandler struct {
-	repo *repository.SearchRepository
+	repo      *repository.SearchRepository
+	scheduler *scheduler.Scheduler
 }
 
-func NewSearchHandler(repo *repository.SearchRepository) *SearchHandler {
-	return &SearchHandler{repo: repo}
+func NewSearchHandler(repo *repository.SearchRepository, sched *scheduler.Scheduler) *SearchHandler {
+	return &SearchHandler{repo: repo, scheduler: sched}
 }
 
+// Search runs a query such as `invoice tag:work due:<2026-11-01 is:open`
+// over tasks, projects, areas, headings and checklist items. Results are
+// grouped by type, and groups lists each type with its count. A malformed
+// query is rejected with the position of the problem. limit applies per
+// type; next_cursor fetches more of the types that had more.
+// GET /api/search?q=...
 func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("q")
 	if q == "" {
 		writeError(w, http.StatusBadRequest, "q parameter is required", "VALIDATION")
 		return
 	}
-	limit := repository.ParseIntDefault(r.URL.Query().Get("limit"), 20)
 
-	results, err := h.repo.Search(q, limit)
+	ctx, span := tracing.Start(r.Context(), "search.query")
+	results, next, err := h.repo.WithContext(ctx).SearchPaged(q, pageParams(r, 20))
+	span.End(err)
+	if writeQueryError(w, err) || writeCursorError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results, "groups": searchGroups(results), "next_cursor": next})
+}
+
+// writeQueryError reports a malformed search query with the position of the
+// problem. It returns false if err is not a *searchquery.ParseError.
+func writeQueryError(w http.ResponseWriter, err error) bool {
+	var parseErr *searchquery.ParseError
+	if !errors.As(err, &parseErr) {
+		return false
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"error":    parseErr.Error(),
+		"code":     "VALIDATION",
+		"position": parseErr.Pos,
+		"end":      parseErr.End,
+	})
+	return true
+}
+
+// searchGroups counts results per type, in the order the types appear.
+func searchGroups(results []model.SearchResult) []model.SearchGroup {
+	groups := []model.SearchGroup{}
+	for _, result := range results {
+		if n := len(groups); n > 0 && groups[n-1].Type == result.Type {
+			groups[n-1].Count++
+			continue
+		}
+		groups = append(groups, model.SearchGroup{Type: result.Type, Count: 1})
+	}
+	return groups
+}
+
+// Reindex extracts the text of all uploaded files again, in the background.
+// POST /api/search/reindex
+func (h *SearchHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		writeError(w, http.StatusServiceUnavailable, "attachment indexing is not running", "INTERNAL")
+		return
+	}
+	queued, err := h.scheduler.ReindexAttachments()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"queued": queued})
 }
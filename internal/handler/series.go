@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/collinjanssen/thingstodo/internal/repository"
+)
+
+// defaultSeriesDays is the length of the per-day completion history when no
+// from date is given; maxSeriesDays caps a requested range.
+const (
+	defaultSeriesDays = 365
+	maxSeriesDays     = 731
+)
+
+type SeriesHandler struct {
+	repo *repository.SeriesRepository
+	loc  *time.Location
+}
+
+// NewSeriesHandler returns a handler that takes "today" in loc, the
+// configured timezone the scheduler creates instances in.
+func NewSeriesHandler(repo *repository.SeriesRepository, loc *time.Location) *SeriesHandler {
+	return &SeriesHandler{repo: repo, loc: loc}
+}
+
+// seriesRange reads ?from= and ?to=. to defaults to today in the configured
+// timezone and from to a year before to.
+func (h *SeriesHandler) seriesRange(w http.ResponseWriter, r *http.Request) (from, to, today string, ok bool) {
+	today = time.Now().In(h.loc).Format("2006-01-02")
+	end, _ := time.Parse("2006-01-02", today)
+	var err error
+	if v := r.URL.Query().Get("to"); v != "" {
+		if end, err = time.Parse("2006-01-02", v); err != nil {
+			writeError(w, http.StatusBadRequest, "to must be a date (YYYY-MM-DD)", "BAD_REQUEST")
+			return "", "", "", false
+		}
+	}
+	start := end.AddDate(0, 0, -(defaultSeriesDays - 1))
+	if v := r.URL.Query().Get("from"); v != "" {
+		if start, err = time.Parse("2006-01-02", v); err != nil {
+			writeError(w, http.StatusBadRequest, "from must be a date (YYYY-MM-DD)", "BAD_REQUEST")
+			return "", "", "", false
+		}
+	}
+	if start.After(end) {
+		writeError(w, http.StatusBadRequest, "from must not be after to", "BAD_REQUEST")
+		return "", "", "", false
+	}
+	if end.Sub(start) >= maxSeriesDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "date range must not exceed two years", "BAD_REQUEST")
+		return "", "", "", false
+	}
+	return start.Format("2006-01-02"), end.Format("2006-01-02"), today, true
+}
+
+// List returns habit statistics for every repeating series.
+// GET /api/series?from=&to=
+func (h *SeriesHandler) List(w http.ResponseWriter, r *http.Request) {
+	from, to, today, ok := h.seriesRange(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.repo.List(from, to, today)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Stats returns completion rate, streaks, missed occurrences and a per-day
+// completion history for one series.
+// GET /api/series/{id}/stats?from=&to=
+func (h *SeriesHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	from, to, today, ok := h.seriesRange(w, r)
+	if !ok {
+		return
+	}
+	stats, err := h.repo.Stats(chi.URLParam(r, "id"), from, to, today)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if stats == nil {
+		writeError(w, http.StatusNotFound, "series not found", "NOT_FOUND")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,45 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+	"github.com/go-chi/chi/v5"
+)
+
+func setupSeriesRouter(t *testing.T, loc *time.Location) *testutil.TestClient {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+	if _, err := db.Exec(`INSERT INTO tasks (id, title, status, when_date, series_id)
+		VALUES ('t1', 'Stretch', 'open', '2026-03-02', 's1')`); err != nil {
+		t.Fatal(err)
+	}
+	seriesHandler := handler.NewSeriesHandler(repository.NewSeriesRepository(db), loc)
+
+	r := chi.NewRouter()
+	r.Get("/api/series/{id}/stats", seriesHandler.Stats)
+	return testutil.NewTestClient(t, r)
+}
+
+func TestSeriesStatsDefaultsToTodayInConfiguredTimezone(t *testing.T) {
+	// Twenty-six hours apart, so at least one differs from the server's date.
+	for _, loc := range []*time.Location{
+		time.FixedZone("UTC+14", 14*60*60),
+		time.FixedZone("UTC-12", -12*60*60),
+	} {
+		client := setupSeriesRouter(t, loc)
+
+		resp := client.Get("/api/series/s1/stats")
+		testutil.AssertStatus(t, resp, http.StatusOK)
+		var stats model.SeriesStats
+		resp.JSON(t, &stats)
+		if want := time.Now().In(loc).Format("2006-01-02"); stats.To != want {
+			t.Errorf("%s: to = %s, want %s", loc, stats.To, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	mw "github.com/collinjanssen/thingstodo/internal/middleware"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
+)
+
+type SmartListHandler struct {
+	repo   *repository.SmartListRepository
+	broker *sse.Broker
+}
+
+func NewSmartListHandler(repo *repository.SmartListRepository, broker *sse.Broker) *SmartListHandler {
+	return &SmartListHandler{repo: repo, broker: broker}
+}
+
+// validateSmartList checks the fields of a smart list being written and
+// writes the error response if one is wrong.
+func validateSmartList(w http.ResponseWriter, name, query, sort, groupBy *string) bool {
+	if name != nil && strings.TrimSpace(*name) == "" {
+		writeError(w, http.StatusBadRequest, "name is required", "VALIDATION")
+		return false
+	}
+	if query != nil {
+		if strings.TrimSpace(*query) == "" {
+			writeError(w, http.StatusBadRequest, "query is required", "VALIDATION")
+			return false
+		}
+		if _, err := searchquery.Parse(*query); writeQueryError(w, err) {
+			return false
+		}
+	}
+	if sort != nil && *sort != "" && !repository.ValidTaskSort(*sort) {
+		writeError(w, http.StatusBadRequest, "sort must be one of when, deadline, created, updated, title, priority, optionally prefixed with -", "VALIDATION")
+		return false
+	}
+	if groupBy != nil && *groupBy != "" && !repository.ValidSmartListGroupBy(*groupBy) {
+		writeError(w, http.StatusBadRequest, "group_by must be one of none, project, area, tag, when, deadline", "VALIDATION")
+		return false
+	}
+	return true
+}
+
+// List handles GET /api/smart-lists
+func (h *SmartListHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	lists, err := h.repo.List(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"smart_lists": lists})
+}
+
+// Create handles POST /api/smart-lists
+func (h *SmartListHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	var input model.CreateSmartListInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if !validateSmartList(w, &input.Name, &input.Query, &input.Sort, &input.GroupBy) {
+		return
+	}
+	l, err := h.repo.Create(userID, input)
+	if errors.Is(err, repository.ErrDuplicateSmartListName) {
+		writeError(w, http.StatusConflict, "a smart list with this name already exists", "CONFLICT")
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR smart_lists.Create userID=%s: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	h.broker.BroadcastJSON("smart_list_changed", map[string]interface{}{"id": l.ID})
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// Update handles PATCH /api/smart-lists/{id}
+func (h *SmartListHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var input model.UpdateSmartListInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		input.Name = &name
+	}
+	if !validateSmartList(w, input.Name, input.Query, input.Sort, input.GroupBy) {
+		return
+	}
+	if input.Sort != nil && *input.Sort == "" {
+		input.Sort = nil
+	}
+	if input.GroupBy != nil && *input.GroupBy == "" {
+		input.GroupBy = nil
+	}
+	l, err := h.repo.Update(userID, id, input)
+	if errors.Is(err, repository.ErrDuplicateSmartListName) {
+		writeError(w, http.StatusConflict, "a smart list with this name already exists", "CONFLICT")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if l == nil {
+		writeError(w, http.StatusNotFound, "smart list not found", "NOT_FOUND")
+		return
+	}
+	h.broker.BroadcastJSON("smart_list_changed", map[string]interface{}{"id": l.ID})
+	writeJSON(w, http.StatusOK, l)
+}
+
+// Delete handles DELETE /api/smart-lists/{id}
+func (h *SmartListHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if err := h.repo.Delete(userID, id); err != nil {
+		if errors.Is(err, repository.ErrSmartListNotFound) {
+			writeError(w, http.StatusNotFound, "smart list not found", "NOT_FOUND")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	h.broker.BroadcastJSON("smart_list_changed", map[string]interface{}{"id": id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// View evaluates a smart list into its tasks, sorted and grouped. It takes
+// the filter and sort parameters of the other views.
+// GET /api/views/smart/{id}
+func (h *SmartListHandler) View(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	l, err := h.repo.Get(userID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if l == nil {
+		writeError(w, http.StatusNotFound, "smart list not found", "NOT_FOUND")
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "smart_list.view")
+	view, err := h.repo.WithContext(ctx).View(l, f)
+	span.End(err)
+	if writeQueryError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
@@ -1,17 +1,22 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"log"
 
+	"github.com/collinjanssen/thingstodo/internal/metrics"
 	mw "github.com/collinjanssen/thingstodo/internal/middleware"
 	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
 	"github.com/collinjanssen/thingstodo/internal/scheduler"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 )
 
 const (
@@ -19,6 +24,15 @@ const (
 	maxPullLimit     = 1000
 )
 
+var (
+	syncPulled = metrics.NewCounter("thingstodo_sync_pull_changes_total",
+		"Change log entries sent to devices by sync pull.").With()
+	syncPushed = metrics.NewCounter("thingstodo_sync_push_changes_total",
+		"Changes received from devices by sync push, by result status.", "status")
+	syncConflicts = metrics.NewCounter("thingstodo_sync_conflicts_total",
+		"Sync push changes recorded as conflicts, by entity.", "entity")
+)
+
 // SyncHandler handles sync pull and push endpoints.
 type SyncHandler struct {
 	changeLog   *repository.ChangeLogRepository
@@ -32,7 +46,9 @@ type SyncHandler struct {
 	schedules   *repository.ScheduleRepository
 	reminders   *repository.ReminderRepository
 	repeatRules *repository.RepeatRuleRepository
+	conflicts   *repository.SyncConflictRepository
 	scheduler   *scheduler.Scheduler
+	broker      *sse.Broker
 }
 
 // NewSyncHandler creates a new SyncHandler.
@@ -48,7 +64,9 @@ func NewSyncHandler(
 	schedules *repository.ScheduleRepository,
 	reminders *repository.ReminderRepository,
 	repeatRules *repository.RepeatRuleRepository,
+	conflicts *repository.SyncConflictRepository,
 	sched *scheduler.Scheduler,
+	broker *sse.Broker,
 ) *SyncHandler {
 	return &SyncHandler{
 		changeLog:   changeLog,
@@ -62,10 +80,29 @@ func NewSyncHandler(
 		schedules:   schedules,
 		reminders:   reminders,
 		repeatRules: repeatRules,
+		conflicts:   conflicts,
 		scheduler:   sched,
+		broker:      broker,
 	}
 }
 
+// withContext returns a copy of h whose repositories for pushed changes run
+// their statements under ctx, so they are traced within the push.
+func (h *SyncHandler) withContext(ctx context.Context) *SyncHandler {
+	c := *h
+	c.changeLog = h.changeLog.WithContext(ctx)
+	c.tasks = h.tasks.WithContext(ctx)
+	c.projects = h.projects.WithContext(ctx)
+	c.areas = h.areas.WithContext(ctx)
+	c.tags = h.tags.WithContext(ctx)
+	c.checklist = h.checklist.WithContext(ctx)
+	c.headings = h.headings.WithContext(ctx)
+	c.attachments = h.attachments.WithContext(ctx)
+	c.schedules = h.schedules.WithContext(ctx)
+	c.reminders = h.reminders.WithContext(ctx)
+	return &c
+}
+
 // PullResponse is returned by GET /api/sync/pull.
 type PullResponse struct {
 	Changes []repository.ChangeLogEntry `json:"changes"`
@@ -100,23 +137,25 @@ func (h *SyncHandler) Pull(w http.ResponseWriter, r *http.Request) {
 		limit = maxPullLimit
 	}
 
-	// Detect expired cursor: if the client has a cursor but the oldest entry in the
-	// change log is newer than that cursor, the history has been purged and the client
-	// must fall back to a full sync.
+	// Detect expired cursor: compaction keeps the latest change for every entity,
+	// so only retention purging can lose history. If the client's cursor predates
+	// the highest purged seq, it must fall back to a full sync.
 	if since > 0 {
-		oldest, err := h.changeLog.GetOldestSeq()
+		purged, err := h.changeLog.GetPurgedThroughSeq()
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 			return
 		}
-		if oldest > 0 && oldest > since {
+		if since < purged {
 			writeError(w, http.StatusGone, "cursor expired: change log has been purged, perform a full sync", "CURSOR_EXPIRED")
 			return
 		}
 	}
 
 	// Fetch limit+1 to detect has_more
-	entries, err := h.changeLog.GetChangesSince(since, limit+1)
+	ctx, span := tracing.Start(r.Context(), "sync.read_changes")
+	entries, err := h.changeLog.WithContext(ctx).GetChangesSince(since, limit+1)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -134,6 +173,7 @@ func (h *SyncHandler) Pull(w http.ResponseWriter, r *http.Request) {
 		cursor = since
 	}
 
+	syncPulled.Add(float64(len(entries)))
 	writeJSON(w, http.StatusOK, PullResponse{
 		Changes: entries,
 		Cursor:  cursor,
@@ -156,9 +196,25 @@ type FullSyncResponse struct {
 	Cursor      int64       `json:"cursor"`
 }
 
+// FullSyncPage is returned by GET /api/sync/full?entity={name}.
+type FullSyncPage struct {
+	Entity  string      `json:"entity"`
+	Items   interface{} `json:"items"`
+	After   string      `json:"after"`
+	HasMore bool        `json:"has_more"`
+	Cursor  int64       `json:"cursor"`
+}
+
 // Full returns all current entities along with the latest change_log cursor.
+// When the entity query param is set, it returns one page of that entity instead.
 // GET /api/sync/full
+// GET /api/sync/full?entity={name}&after={id}&limit={n}
 func (h *SyncHandler) Full(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("entity") != "" {
+		h.fullPage(w, r)
+		return
+	}
+
 	tasks, err := h.tasks.List(model.TaskFilters{})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to load tasks: "+err.Error(), "INTERNAL")
@@ -240,6 +296,143 @@ func (h *SyncHandler) Full(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// fullPage serves one keyset page of a single entity type. Clients should take
+// the cursor from the first page they request and pull from it once every
+// entity has been paged through, so changes made while paging are not lost.
+func (h *SyncHandler) fullPage(w http.ResponseWriter, r *http.Request) {
+	entity := r.URL.Query().Get("entity")
+	after := r.URL.Query().Get("after")
+
+	limit := defaultPullLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 {
+			writeError(w, http.StatusBadRequest, "invalid 'limit' parameter", "BAD_REQUEST")
+			return
+		}
+		limit = l
+	}
+	if limit > maxPullLimit {
+		limit = maxPullLimit
+	}
+
+	// Capture the cursor before reading so nothing written during the read is skipped.
+	cursor, err := h.changeLog.GetLatestSeq()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get cursor: "+err.Error(), "INTERNAL")
+		return
+	}
+
+	// Fetch limit+1 to detect has_more
+	var items interface{}
+	var ids []string
+	switch entity {
+	case "tasks":
+		page, err := h.tasks.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load tasks: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(t model.TaskListItem) string { return t.ID })
+		items = page
+	case "projects":
+		all, err := h.projects.List(nil, nil)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load projects: "+err.Error(), "INTERNAL")
+			return
+		}
+		var page []model.ProjectListItem
+		page, ids = slicePage(all, after, limit, func(p model.ProjectListItem) string { return p.ID })
+		items = page
+	case "areas":
+		all, err := h.areas.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load areas: "+err.Error(), "INTERNAL")
+			return
+		}
+		var page []model.Area
+		page, ids = slicePage(all, after, limit, func(a model.Area) string { return a.ID })
+		items = page
+	case "tags":
+		all, err := h.tags.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load tags: "+err.Error(), "INTERNAL")
+			return
+		}
+		var page []model.Tag
+		page, ids = slicePage(all, after, limit, func(t model.Tag) string { return t.ID })
+		items = page
+	case "headings":
+		page, err := h.headings.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load headings: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(hd model.Heading) string { return hd.ID })
+		items = page
+	case "checklist":
+		page, err := h.checklist.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load checklist: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(c model.ChecklistItem) string { return c.ID })
+		items = page
+	case "attachments":
+		page, err := h.attachments.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load attachments: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(a model.Attachment) string { return a.ID })
+		items = page
+	case "schedules":
+		page, err := h.schedules.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load schedules: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(sc model.TaskSchedule) string { return sc.ID })
+		items = page
+	case "reminders":
+		page, err := h.reminders.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load reminders: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(rm model.Reminder) string { return rm.ID })
+		items = page
+	case "repeat_rules":
+		page, err := h.repeatRules.ListPage(after, limit+1)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load repeat rules: "+err.Error(), "INTERNAL")
+			return
+		}
+		page, ids = trimPage(page, limit, func(rr model.RepeatRule) string { return rr.ID })
+		items = page
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported entity: "+entity, "BAD_REQUEST")
+		return
+	}
+
+	hasMore := len(ids) > limit
+	if hasMore {
+		ids = ids[:limit]
+	}
+	next := after
+	if len(ids) > 0 {
+		next = ids[len(ids)-1]
+	}
+
+	writeJSON(w, http.StatusOK, FullSyncPage{
+		Entity:  entity,
+		Items:   items,
+		After:   next,
+		HasMore: hasMore,
+		Cursor:  cursor,
+	})
+}
+
 // --- Push types ---
 
 // SyncPushRequest is the body for POST /api/sync/push.
@@ -256,15 +449,27 @@ type SyncChange struct {
 	Data            map[string]interface{} `json:"data"`
 	Fields          []string               `json:"fields"`
 	ClientUpdatedAt string                 `json:"client_updated_at"`
+
+	deviceID string // pushing device, recorded on conflicts
+	force    bool   // skip the LWW check, e.g. when restoring a conflict's server side
 }
 
 // SyncPushResult is the result for a single change in the push response.
 type SyncPushResult struct {
-	Entity   string `json:"entity"`
-	EntityID string `json:"entity_id"`
-	Status   string `json:"status"` // applied, conflict_resolved, error
-	Seq      int64  `json:"seq"`
-	Error    string `json:"error,omitempty"`
+	Entity     string `json:"entity"`
+	EntityID   string `json:"entity_id"`
+	Status     string `json:"status"` // applied, conflict_resolved, error
+	Seq        int64  `json:"seq"`
+	ConflictID string `json:"conflict_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	overwritten *serverVersion // server version a stale change replaced
+}
+
+// serverVersion is an entity as it was before a conflicting change.
+type serverVersion struct {
+	snapshot  interface{}
+	updatedAt string
 }
 
 // SyncPushResponse is returned by POST /api/sync/push.
@@ -288,11 +493,20 @@ func (h *SyncHandler) Push(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, span := tracing.Start(r.Context(), "sync.apply_changes")
+	span.SetAttr("sync.changes", len(req.Changes))
+	traced := h.withContext(ctx)
 	results := make([]SyncPushResult, 0, len(req.Changes))
 	for _, change := range req.Changes {
-		result := h.applyChange(change)
+		change.deviceID = req.DeviceID
+		result := traced.applyChange(change)
+		if result.Status == "conflict_resolved" && result.overwritten != nil {
+			result = traced.recordConflict(result, change)
+		}
+		syncPushed.With(result.Status).Inc()
 		results = append(results, result)
 	}
+	span.End(nil)
 
 	writeJSON(w, http.StatusOK, SyncPushResponse{Results: results})
 }
@@ -416,9 +630,12 @@ func (h *SyncHandler) applyTaskChange(change SyncChange) SyncPushResult {
 			serverTime, _ = time.Parse(time.RFC3339, existing.UpdatedAt)
 		}
 
-		// If server is strictly newer, it's a conflict resolution
-		if serverTime.After(clientTime) {
+		// If server is strictly newer, it's a conflict resolution: the change
+		// still wins, and the server version it replaces goes to the conflict
+		// inbox.
+		if serverTime.After(clientTime) && !change.force {
 			status = "conflict_resolved"
+			result.overwritten = &serverVersion{existing, existing.UpdatedAt}
 		}
 
 		// Build update input only for specified fields
@@ -485,26 +702,26 @@ func (h *SyncHandler) applyTaskChange(change SyncChange) SyncPushResult {
 				if s, ok := val.(string); ok {
 					switch s {
 					case "completed":
-						h.cleanupSchedules(change.EntityID)
+						schedules := h.cleanupSchedules(change.EntityID)
 						if _, cErr := h.tasks.Complete(change.EntityID); cErr != nil {
 							result.Status = "error"
 							result.Error = cErr.Error()
 							return result
 						}
 						if h.scheduler != nil {
-							h.scheduler.HandleTaskDone(change.EntityID)
+							h.scheduler.HandleTaskDone(change.EntityID, schedules)
 						}
 						result.Status = status
 						return result
 					case "canceled":
-						h.cleanupSchedules(change.EntityID)
+						schedules := h.cleanupSchedules(change.EntityID)
 						if _, cErr := h.tasks.Cancel(change.EntityID); cErr != nil {
 							result.Status = "error"
 							result.Error = cErr.Error()
 							return result
 						}
 						if h.scheduler != nil {
-							h.scheduler.HandleTaskDone(change.EntityID)
+							h.scheduler.HandleTaskDone(change.EntityID, schedules)
 						}
 						result.Status = status
 						return result
@@ -517,14 +734,14 @@ func (h *SyncHandler) applyTaskChange(change SyncChange) SyncPushResult {
 						result.Status = status
 						return result
 					case "wont_do":
-						h.cleanupSchedules(change.EntityID)
+						schedules := h.cleanupSchedules(change.EntityID)
 						if _, cErr := h.tasks.WontDo(change.EntityID); cErr != nil {
 							result.Status = "error"
 							result.Error = cErr.Error()
 							return result
 						}
 						if h.scheduler != nil {
-							h.scheduler.HandleTaskDone(change.EntityID)
+							h.scheduler.HandleTaskDone(change.EntityID, schedules)
 						}
 						result.Status = status
 						return result
@@ -621,8 +838,9 @@ func (h *SyncHandler) applyProjectChange(change SyncChange) SyncPushResult {
 
 		status := "applied"
 		clientTime, serverTime := parseTimes(change.ClientUpdatedAt, existing.UpdatedAt)
-		if serverTime.After(clientTime) {
+		if serverTime.After(clientTime) && !change.force {
 			status = "conflict_resolved"
+			result.overwritten = &serverVersion{existing, existing.UpdatedAt}
 		}
 
 		input := model.UpdateProjectInput{
@@ -670,6 +888,9 @@ func (h *SyncHandler) applyProjectChange(change SyncChange) SyncPushResult {
 				result.Error = err.Error()
 				return result
 			}
+			if input.Status != nil && *input.Status != "open" && existing.Status == "open" && h.scheduler != nil {
+				h.scheduler.HandleProjectDone(change.EntityID)
+			}
 		}
 		result.Status = status
 
@@ -718,8 +939,9 @@ func (h *SyncHandler) applyAreaChange(change SyncChange) SyncPushResult {
 
 		status := "applied"
 		clientTime, serverTime := parseTimes(change.ClientUpdatedAt, existing.UpdatedAt)
-		if serverTime.After(clientTime) {
+		if serverTime.After(clientTime) && !change.force {
 			status = "conflict_resolved"
+			result.overwritten = &serverVersion{&existing.Area, existing.UpdatedAt}
 		}
 
 		input := model.UpdateAreaInput{}
@@ -1023,6 +1245,9 @@ func (h *SyncHandler) applyAttachmentChange(change SyncChange) SyncPushResult {
 				input.FileSize = int64(f)
 			}
 		}
+		if v, ok := change.Data["encrypted"].(bool); ok {
+			input.Encrypted = v
+		}
 		_, err := h.attachments.Create(taskID, input)
 		if err != nil {
 			result.Status = "error"
@@ -1176,6 +1401,35 @@ func floatFromData(data map[string]interface{}, key string) float64 {
 	return 0
 }
 
+// trimPage cuts a limit+1 page down to limit items. The returned IDs keep the
+// extra element so the caller can tell whether another page follows.
+func trimPage[T any](page []T, limit int, id func(T) string) ([]T, []string) {
+	ids := make([]string, len(page))
+	for i, item := range page {
+		ids[i] = id(item)
+	}
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, ids
+}
+
+// slicePage pages an in-memory list by ID. It is used for the small tables
+// (projects, areas, tags) whose list queries compute per-row counts.
+func slicePage[T any](all []T, after string, limit int, id func(T) string) ([]T, []string) {
+	sorted := make([]T, 0, len(all))
+	for _, item := range all {
+		if id(item) > after {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return id(sorted[i]) < id(sorted[j]) })
+	if len(sorted) > limit+1 {
+		sorted = sorted[:limit+1]
+	}
+	return trimPage(sorted, limit, id)
+}
+
 func parseTimes(clientUpdatedAt, serverUpdatedAt string) (time.Time, time.Time) {
 	clientTime, err := time.Parse(time.RFC3339, clientUpdatedAt)
 	if err != nil {
@@ -1189,10 +1443,17 @@ func parseTimes(clientUpdatedAt, serverUpdatedAt string) (time.Time, time.Time)
 }
 
 // cleanupSchedules completes past uncompleted schedule entries and deletes
-// today + future entries when a task is completed/canceled/wontdo.
-func (h *SyncHandler) cleanupSchedules(taskID string) {
+// today + future entries when a task is completed/canceled/wontdo. It returns
+// the entries as they were beforehand so a repeat instance can recreate them.
+func (h *SyncHandler) cleanupSchedules(taskID string) []model.TaskSchedule {
+	schedules, err := h.schedules.ListByTask(taskID)
+	if err != nil {
+		log.Printf("schedule snapshot for task %s: %v", taskID, err)
+		schedules = nil
+	}
 	today := time.Now().Format("2006-01-02")
 	if err := h.schedules.CleanupOnTaskDone(taskID, today); err != nil {
 		log.Printf("schedule cleanup for task %s: %v", taskID, err)
 	}
+	return schedules
 }
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// recordConflict stores the server version a stale change overwrote in the
+// conflict inbox, so it can be restored. The change itself has already been
+// applied: last write wins as before, and a failure to record the conflict
+// does not undo it.
+func (h *SyncHandler) recordConflict(result SyncPushResult, change SyncChange) SyncPushResult {
+	snapshot, err := json.Marshal(result.overwritten.snapshot)
+	if err != nil {
+		log.Printf("sync: record conflict for %s %s: %v", change.Entity, change.EntityID, err)
+		return result
+	}
+	clientData, err := json.Marshal(change.Data)
+	if err != nil {
+		log.Printf("sync: record conflict for %s %s: %v", change.Entity, change.EntityID, err)
+		return result
+	}
+
+	conflict, err := h.conflicts.Create(model.SyncConflict{
+		Entity:          change.Entity,
+		EntityID:        change.EntityID,
+		DeviceID:        change.deviceID,
+		Fields:          change.Fields,
+		ClientData:      clientData,
+		ClientUpdatedAt: change.ClientUpdatedAt,
+		ServerSnapshot:  snapshot,
+		ServerUpdatedAt: result.overwritten.updatedAt,
+	})
+	if err != nil {
+		log.Printf("sync: record conflict for %s %s: %v", change.Entity, change.EntityID, err)
+		return result
+	}
+
+	if h.broker != nil {
+		h.broker.BroadcastJSON("sync_conflict", map[string]interface{}{
+			"id": conflict.ID, "entity": conflict.Entity, "entity_id": conflict.EntityID,
+		})
+	}
+	syncConflicts.With(change.Entity).Inc()
+	result.ConflictID = conflict.ID
+	return result
+}
+
+// serverData picks the conflict's fields out of its server snapshot, as the
+// data of a change that restores them. Task snapshots list tags as objects
+// where sync writes tag_ids.
+func serverData(conflict *model.SyncConflict) (map[string]interface{}, error) {
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(conflict.ServerSnapshot, &snapshot); err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{}, len(conflict.Fields))
+	for _, field := range conflict.Fields {
+		if field == "tag_ids" {
+			ids := []interface{}{}
+			tags, _ := snapshot["tags"].([]interface{})
+			for _, t := range tags {
+				if tag, ok := t.(map[string]interface{}); ok {
+					ids = append(ids, tag["id"])
+				}
+			}
+			data[field] = ids
+			continue
+		}
+		data[field] = snapshot[field]
+	}
+	return data, nil
+}
+
+// ListConflicts returns sync conflicts, newest first.
+// GET /api/sync/conflicts?status={open|accepted_client|kept_server|merged|all}
+func (h *SyncHandler) ListConflicts(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "open"
+	case "all":
+		status = ""
+	case "open", "accepted_client", "kept_server", "merged":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status", "BAD_REQUEST")
+		return
+	}
+	conflicts, err := h.conflicts.List(status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conflicts": conflicts})
+}
+
+// GetConflict returns a single sync conflict.
+// GET /api/sync/conflicts/{id}
+func (h *SyncHandler) GetConflict(w http.ResponseWriter, r *http.Request) {
+	conflict, err := h.conflicts.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if conflict == nil {
+		writeError(w, http.StatusNotFound, "conflict not found", "NOT_FOUND")
+		return
+	}
+	writeJSON(w, http.StatusOK, conflict)
+}
+
+// ResolveConflict settles an open conflict. "client" keeps the client change
+// that was applied, "server" restores the overwritten server values of the
+// changed fields, and "merge" applies the field values given in data.
+// POST /api/sync/conflicts/{id}/resolve
+func (h *SyncHandler) ResolveConflict(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var input model.ResolveSyncConflictInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+
+	conflict, err := h.conflicts.GetByID(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if conflict == nil {
+		writeError(w, http.StatusNotFound, "conflict not found", "NOT_FOUND")
+		return
+	}
+	if conflict.Status != "open" {
+		writeError(w, http.StatusConflict, repository.ErrConflictResolved.Error(), "CONFLICT")
+		return
+	}
+
+	var status string
+	var change *SyncChange
+	switch input.Resolution {
+	case "client":
+		status = "accepted_client"
+	case "server":
+		data, err := serverData(conflict)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+			return
+		}
+		status = "kept_server"
+		change = &SyncChange{Entity: conflict.Entity, EntityID: conflict.EntityID, Data: data, Fields: conflict.Fields}
+	case "merge":
+		if len(input.Data) == 0 {
+			writeError(w, http.StatusBadRequest, "data is required for a merge", "VALIDATION")
+			return
+		}
+		fields := make([]string, 0, len(input.Data))
+		for k := range input.Data {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		status = "merged"
+		change = &SyncChange{Entity: conflict.Entity, EntityID: conflict.EntityID, Data: input.Data, Fields: fields}
+	default:
+		writeError(w, http.StatusBadRequest, "resolution must be client, server or merge", "VALIDATION")
+		return
+	}
+
+	if change != nil {
+		change.Action = "update"
+		change.ClientUpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		change.force = true
+		if res := h.applyChange(*change); res.Status == "error" {
+			writeError(w, http.StatusUnprocessableEntity, res.Error, "VALIDATION")
+			return
+		}
+	}
+
+	resolved, err := h.conflicts.Resolve(id, status)
+	if err != nil {
+		if errors.Is(err, repository.ErrConflictResolved) {
+			writeError(w, http.StatusConflict, err.Error(), "CONFLICT")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if h.broker != nil {
+		h.broker.BroadcastJSON("sync_conflict_resolved", map[string]interface{}{
+			"id": resolved.ID, "entity": resolved.Entity, "entity_id": resolved.EntityID, "status": resolved.Status,
+		})
+	}
+	writeJSON(w, http.StatusOK, resolved)
+}
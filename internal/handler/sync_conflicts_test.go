@@ -0,0 +1,173 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+// pushStaleTitle creates a task and pushes a title update older than the
+// server version, returning the task ID and the recorded conflict ID.
+func pushStaleTitle(t *testing.T, client *testutil.TestClient, title string) (string, string) {
+	t.Helper()
+	createResp := client.Post("/api/tasks", map[string]string{"title": "Server title"})
+	testutil.AssertStatus(t, createResp, http.StatusCreated)
+	var created map[string]interface{}
+	createResp.JSON(t, &created)
+	taskID := created["id"].(string)
+
+	resp := client.Post("/api/sync/push", map[string]interface{}{
+		"device_id": "dev-stale",
+		"changes": []map[string]interface{}{
+			{
+				"entity":            "task",
+				"entity_id":         taskID,
+				"action":            "update",
+				"data":              map[string]interface{}{"title": title},
+				"fields":            []string{"title"},
+				"client_updated_at": "2000-01-01T00:00:00Z",
+			},
+		},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var result handler.SyncPushResponse
+	resp.JSON(t, &result)
+	if result.Results[0].Status != "conflict_resolved" || result.Results[0].ConflictID == "" {
+		t.Fatalf("expected conflict_resolved with a conflict_id, got %+v", result.Results[0])
+	}
+	return taskID, result.Results[0].ConflictID
+}
+
+func TestSyncConflictAppliesChangeAndRecordsServerVersion(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+	taskID, conflictID := pushStaleTitle(t, client, "Client title")
+
+	// Last write wins as before: the stale change is still applied.
+	testutil.AssertJSONField(t, client.Get("/api/tasks/"+taskID), "title", "Client title")
+
+	resp := client.Get("/api/sync/conflicts")
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var list struct {
+		Conflicts []model.SyncConflict `json:"conflicts"`
+	}
+	resp.JSON(t, &list)
+	if len(list.Conflicts) != 1 {
+		t.Fatalf("expected 1 open conflict, got %d", len(list.Conflicts))
+	}
+	c := list.Conflicts[0]
+	if c.ID != conflictID || c.DeviceID != "dev-stale" || c.Status != "open" {
+		t.Errorf("unexpected conflict: %+v", c)
+	}
+	if len(c.Fields) != 1 || c.Fields[0] != "title" {
+		t.Errorf("expected fields [title], got %v", c.Fields)
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(c.ServerSnapshot, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot["title"] != "Server title" {
+		t.Errorf("expected the overwritten server title in the snapshot, got %v", snapshot["title"])
+	}
+}
+
+func TestSyncConflictRecordedForAreas(t *testing.T) {
+	client, _, _, db := setupSyncRouterWithDB(t)
+	if _, err := db.Exec(`INSERT INTO areas (id, title) VALUES ('area-1', 'Server area')`); err != nil {
+		t.Fatal(err)
+	}
+	push := func(action, title, at string) handler.SyncPushResult {
+		resp := client.Post("/api/sync/push", map[string]interface{}{
+			"device_id": "dev-area",
+			"changes": []map[string]interface{}{{
+				"entity": "area", "entity_id": "area-1", "action": action,
+				"data": map[string]interface{}{"title": title}, "fields": []string{"title"},
+				"client_updated_at": at,
+			}},
+		})
+		testutil.AssertStatus(t, resp, http.StatusOK)
+		var result handler.SyncPushResponse
+		resp.JSON(t, &result)
+		return result.Results[0]
+	}
+	res := push("update", "Client area", "2000-01-01T00:00:00Z")
+	if res.Status != "conflict_resolved" || res.ConflictID == "" {
+		t.Fatalf("expected conflict_resolved with a conflict_id, got %+v", res)
+	}
+
+	resp := client.Post("/api/sync/conflicts/"+res.ConflictID+"/resolve", map[string]string{"resolution": "server"})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	testutil.AssertJSONField(t, resp, "status", "kept_server")
+	var title string
+	db.QueryRow(`SELECT title FROM areas WHERE id = 'area-1'`).Scan(&title)
+	if title != "Server area" {
+		t.Errorf("expected the server title restored, got %q", title)
+	}
+}
+
+func TestSyncConflictResolveClient(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+	taskID, conflictID := pushStaleTitle(t, client, "Client title")
+
+	resp := client.Post("/api/sync/conflicts/"+conflictID+"/resolve", map[string]string{"resolution": "client"})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	testutil.AssertJSONField(t, resp, "status", "accepted_client")
+	// The applied client change stays.
+	testutil.AssertJSONField(t, client.Get("/api/tasks/"+taskID), "title", "Client title")
+
+	// Already resolved.
+	resp = client.Post("/api/sync/conflicts/"+conflictID+"/resolve", map[string]string{"resolution": "server"})
+	testutil.AssertStatus(t, resp, http.StatusConflict)
+}
+
+func TestSyncConflictResolveServer(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+	taskID, conflictID := pushStaleTitle(t, client, "Client title")
+
+	resp := client.Post("/api/sync/conflicts/"+conflictID+"/resolve", map[string]string{"resolution": "server"})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	testutil.AssertJSONField(t, resp, "status", "kept_server")
+	// The overwritten server title is restored.
+	testutil.AssertJSONField(t, client.Get("/api/tasks/"+taskID), "title", "Server title")
+
+	var list struct {
+		Conflicts []model.SyncConflict `json:"conflicts"`
+	}
+	client.Get("/api/sync/conflicts").JSON(t, &list)
+	if len(list.Conflicts) != 0 {
+		t.Errorf("expected no open conflicts, got %d", len(list.Conflicts))
+	}
+	client.Get("/api/sync/conflicts?status=all").JSON(t, &list)
+	if len(list.Conflicts) != 1 {
+		t.Errorf("expected 1 conflict with status=all, got %d", len(list.Conflicts))
+	}
+}
+
+func TestSyncConflictResolveMerge(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+	taskID, conflictID := pushStaleTitle(t, client, "Client title")
+
+	resp := client.Post("/api/sync/conflicts/"+conflictID+"/resolve", map[string]interface{}{"resolution": "merge"})
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post("/api/sync/conflicts/"+conflictID+"/resolve", map[string]interface{}{
+		"resolution": "merge",
+		"data":       map[string]interface{}{"title": "Merged title", "notes": "from both"},
+	})
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	testutil.AssertJSONField(t, resp, "status", "merged")
+
+	getResp := client.Get("/api/tasks/" + taskID)
+	testutil.AssertJSONField(t, getResp, "title", "Merged title")
+	testutil.AssertJSONField(t, getResp, "notes", "from both")
+}
+
+func TestSyncConflictNotFound(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+	testutil.AssertStatus(t, client.Get("/api/sync/conflicts/nope"), http.StatusNotFound)
+	resp := client.Post("/api/sync/conflicts/nope/resolve", map[string]string{"resolution": "server"})
+	testutil.AssertStatus(t, resp, http.StatusNotFound)
+}
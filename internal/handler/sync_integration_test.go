@@ -265,27 +265,121 @@ func TestSyncCursorExpired(t *testing.T) {
 	client, changeLog, _, db := setupSyncRouterWithDB(t)
 
 	// Insert some old changes to populate the log.
-	seq1, _ := changeLog.AppendChange("task", "old-t1", "create", nil, `{"id":"old-t1"}`, "", "")
-	_, _ = changeLog.AppendChange("task", "old-t2", "create", nil, `{"id":"old-t2"}`, "", "")
+	for _, id := range []string{"old-t1", "old-t2"} {
+		_, err := db.Exec(`INSERT INTO change_log (entity, entity_id, action, snapshot, created_at) VALUES ('task', ?, 'create', '{}', datetime('now', '-10 days'))`, id)
+		if err != nil {
+			t.Fatalf("failed to insert old entry: %v", err)
+		}
+	}
 
 	// Use the first seq as the "old cursor" the client has.
-	oldCursor := seq1
+	oldCursor, _ := changeLog.GetOldestSeq()
 
-	// Delete all current change_log entries to simulate a purge, leaving a gap.
-	_, err := db.Exec(`DELETE FROM change_log`)
-	if err != nil {
+	// Purge the old entries, leaving a gap.
+	if _, err := changeLog.PurgeOlderThan(7); err != nil {
 		t.Fatalf("failed to purge change_log: %v", err)
 	}
-
-	// Insert a fresh change so the table is non-empty (required for cursor expiry detection).
-	// The oldest remaining seq will be higher than oldCursor.
 	_, _ = changeLog.AppendChange("task", "new-t1", "create", nil, `{"id":"new-t1"}`, "", "")
 
-	// Pull with the old cursor — oldest seq is now > oldCursor, so 410 Gone expected.
+	// Pull with the old cursor — it predates the purged range, so 410 Gone expected.
 	pullResp := client.Get("/api/sync/pull?since=" + int64String(oldCursor))
 	testutil.AssertStatus(t, pullResp, http.StatusGone)
 }
 
+// TestSyncCursorSurvivesCompaction tests that compaction keeps old cursors usable
+// and still delivers the latest snapshot of every entity changed after them.
+func TestSyncCursorSurvivesCompaction(t *testing.T) {
+	client, changeLog, _, db := setupSyncRouterWithDB(t)
+
+	insertOld := func(entityID, snapshot string) int64 {
+		res, err := db.Exec(`INSERT INTO change_log (entity, entity_id, action, snapshot, created_at) VALUES ('task', ?, 'update', ?, datetime('now', '-60 days'))`, entityID, snapshot)
+		if err != nil {
+			t.Fatalf("failed to insert old entry: %v", err)
+		}
+		seq, _ := res.LastInsertId()
+		return seq
+	}
+
+	cursor := insertOld("t1", `{"v":1}`)
+	insertOld("t1", `{"v":2}`)
+	insertOld("t1", `{"v":3}`)
+	insertOld("t2", `{"v":1}`)
+
+	compacted, err := changeLog.CompactOlderThan(30)
+	if err != nil {
+		t.Fatalf("CompactOlderThan failed: %v", err)
+	}
+	if compacted != 2 {
+		t.Errorf("expected 2 compacted entries, got %d", compacted)
+	}
+
+	pullResp := client.Get("/api/sync/pull?since=" + int64String(cursor))
+	testutil.AssertStatus(t, pullResp, http.StatusOK)
+
+	var result handler.PullResponse
+	pullResp.JSON(t, &result)
+	if len(result.Changes) != 2 {
+		t.Fatalf("expected 2 changes after compaction, got %d", len(result.Changes))
+	}
+	if result.Changes[0].EntityID != "t1" || result.Changes[0].Snapshot != `{"v":3}` {
+		t.Errorf("expected latest t1 snapshot, got %s %s", result.Changes[0].EntityID, result.Changes[0].Snapshot)
+	}
+}
+
+// TestSyncFullPaged tests paging through a single entity with /api/sync/full?entity=.
+func TestSyncFullPaged(t *testing.T) {
+	client, _, _ := setupSyncRouter(t)
+
+	for i := 0; i < 5; i++ {
+		resp := client.Post("/api/tasks", map[string]string{"title": "Paged task " + int64String(int64(i+1))})
+		testutil.AssertStatus(t, resp, http.StatusCreated)
+	}
+
+	seen := map[string]bool{}
+	after := ""
+	var firstCursor int64
+	for pages := 0; pages < 10; pages++ {
+		resp := client.Get("/api/sync/full?entity=tasks&limit=2&after=" + after)
+		testutil.AssertStatus(t, resp, http.StatusOK)
+
+		var page struct {
+			Entity  string                   `json:"entity"`
+			Items   []map[string]interface{} `json:"items"`
+			After   string                   `json:"after"`
+			HasMore bool                     `json:"has_more"`
+			Cursor  int64                    `json:"cursor"`
+		}
+		resp.JSON(t, &page)
+		if pages == 0 {
+			firstCursor = page.Cursor
+		}
+		if len(page.Items) > 2 {
+			t.Fatalf("expected at most 2 items per page, got %d", len(page.Items))
+		}
+		for _, item := range page.Items {
+			id, _ := item["id"].(string)
+			if seen[id] {
+				t.Errorf("task %s returned twice", id)
+			}
+			seen[id] = true
+		}
+		after = page.After
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if len(seen) != 5 {
+		t.Errorf("expected 5 tasks across pages, got %d", len(seen))
+	}
+	if firstCursor == 0 {
+		t.Error("expected non-zero cursor on first page")
+	}
+
+	resp := client.Get("/api/sync/full?entity=bogus")
+	testutil.AssertStatus(t, resp, http.StatusBadRequest)
+}
+
 // int64String converts an int64 to its decimal string representation.
 func int64String(n int64) string {
 	if n == 0 {
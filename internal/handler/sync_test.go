@@ -36,15 +36,20 @@ func setupSyncRouterWithDB(t *testing.T) (*testutil.TestClient, *repository.Chan
 	repeatRuleRepo := repository.NewRepeatRuleRepository(db, changeLogRepo)
 	settingsRepo := repository.NewUserSettingsRepository(db)
 
-	syncH := handler.NewSyncHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, tagRepo, checklistRepo, headingRepo, attachmentRepo, scheduleRepo, reminderRepo, repeatRuleRepo, nil)
+	conflictRepo := repository.NewSyncConflictRepository(db)
+	broker := sse.NewBroker()
+
+	syncH := handler.NewSyncHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, tagRepo, checklistRepo, headingRepo, attachmentRepo, scheduleRepo, reminderRepo, repeatRuleRepo, conflictRepo, nil, broker)
 
 	r := chi.NewRouter()
 	r.Get("/api/sync/pull", syncH.Pull)
 	r.Post("/api/sync/push", syncH.Push)
 	r.Get("/api/sync/full", syncH.Full)
+	r.Get("/api/sync/conflicts", syncH.ListConflicts)
+	r.Get("/api/sync/conflicts/{id}", syncH.GetConflict)
+	r.Post("/api/sync/conflicts/{id}/resolve", syncH.ResolveConflict)
 
 	// Also mount task endpoints so we can create test data
-	broker := sse.NewBroker()
 	taskH := handler.NewTaskHandler(taskRepo, scheduleRepo, reminderRepo, settingsRepo, broker, nil)
 
 	r.Route("/api/tasks", func(r chi.Router) {
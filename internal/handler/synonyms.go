@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+)
+
+type SynonymHandler struct {
+	repo *repository.SynonymRepository
+}
+
+func NewSynonymHandler(repo *repository.SynonymRepository) *SynonymHandler {
+	return &SynonymHandler{repo: repo}
+}
+
+// GET /api/search/synonyms
+func (h *SynonymHandler) List(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.repo.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"synonyms": groups})
+}
+
+// Create adds a group of words that match each other in search.
+// POST /api/search/synonyms
+func (h *SynonymHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input model.CreateSynonymGroupInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON", "BAD_REQUEST")
+		return
+	}
+	input.Terms = repository.NormalizeSynonyms(input.Terms)
+	if len(input.Terms) < 2 {
+		writeError(w, http.StatusBadRequest, "a synonym group needs at least two different terms", "VALIDATION")
+		return
+	}
+	group, err := h.repo.Create(input)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// DELETE /api/search/synonyms/{id}
+func (h *SynonymHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	err := h.repo.Delete(chi.URLParam(r, "id"))
+	if errors.Is(err, repository.ErrSynonymGroupNotFound) {
+		writeError(w, http.StatusNotFound, "synonym group not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -129,10 +129,13 @@ func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 func (h *TagHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	tasks, err := h.repo.GetTasksByTag(id)
+	tasks, next, err := h.repo.GetTasksByTag(id, pageParams(r, 0))
+	if writeCursorError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "next_cursor": next})
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/collinjanssen/thingstodo/internal/repository"
 	"github.com/collinjanssen/thingstodo/internal/scheduler"
 	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -63,12 +64,17 @@ func (h *TaskHandler) List(w http.ResponseWriter, r *http.Request) {
 		f.Search = &v
 	}
 
-	tasks, err := h.repo.List(f)
+	ctx, span := tracing.Start(r.Context(), "tasks.list")
+	tasks, next, err := h.repo.WithContext(ctx).ListPaged(f, pageParams(r, 0))
+	span.End(err)
+	if writeCursorError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "next_cursor": next})
 }
 
 func (h *TaskHandler) Get(w http.ResponseWriter, r *http.Request) {
@@ -203,7 +209,7 @@ func (h *TaskHandler) Purge(w http.ResponseWriter, r *http.Request) {
 
 func (h *TaskHandler) Complete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	h.cleanupSchedules(id)
+	schedules := h.cleanupSchedules(id)
 	task, err := h.repo.Complete(id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
@@ -214,7 +220,7 @@ func (h *TaskHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if h.scheduler != nil {
-		h.scheduler.HandleTaskDone(id)
+		h.scheduler.HandleTaskDone(id, schedules)
 	}
 	h.broker.BroadcastJSON("task_updated", map[string]interface{}{"id": task.ID, "task": task})
 	writeJSON(w, http.StatusOK, task)
@@ -222,7 +228,7 @@ func (h *TaskHandler) Complete(w http.ResponseWriter, r *http.Request) {
 
 func (h *TaskHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	h.cleanupSchedules(id)
+	schedules := h.cleanupSchedules(id)
 	task, err := h.repo.Cancel(id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
@@ -233,7 +239,7 @@ func (h *TaskHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if h.scheduler != nil {
-		h.scheduler.HandleTaskDone(id)
+		h.scheduler.HandleTaskDone(id, schedules)
 	}
 	h.broker.BroadcastJSON("task_updated", map[string]interface{}{"id": task.ID, "task": task})
 	writeJSON(w, http.StatusOK, task)
@@ -241,7 +247,7 @@ func (h *TaskHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 
 func (h *TaskHandler) WontDo(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	h.cleanupSchedules(id)
+	schedules := h.cleanupSchedules(id)
 	task, err := h.repo.WontDo(id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
@@ -252,7 +258,7 @@ func (h *TaskHandler) WontDo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if h.scheduler != nil {
-		h.scheduler.HandleTaskDone(id)
+		h.scheduler.HandleTaskDone(id, schedules)
 	}
 	h.broker.BroadcastJSON("task_updated", map[string]interface{}{"id": task.ID, "task": task})
 	writeJSON(w, http.StatusOK, task)
@@ -371,9 +377,10 @@ func (h *TaskHandler) BulkAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	schedules := map[string][]model.TaskSchedule{}
 	if input.Action == "complete" || input.Action == "cancel" || input.Action == "wontdo" {
 		for _, id := range input.TaskIDs {
-			h.cleanupSchedules(id)
+			schedules[id] = h.cleanupSchedules(id)
 		}
 	}
 
@@ -385,7 +392,7 @@ func (h *TaskHandler) BulkAction(w http.ResponseWriter, r *http.Request) {
 
 	if h.scheduler != nil && (input.Action == "complete" || input.Action == "cancel" || input.Action == "wontdo") {
 		for _, id := range input.TaskIDs {
-			h.scheduler.HandleTaskDone(id)
+			h.scheduler.HandleTaskDone(id, schedules[id])
 		}
 	}
 
@@ -402,12 +409,19 @@ func (h *TaskHandler) BulkAction(w http.ResponseWriter, r *http.Request) {
 }
 
 // cleanupSchedules completes past uncompleted schedule entries and deletes
-// today + future entries when a task is completed/canceled/wontdo.
-func (h *TaskHandler) cleanupSchedules(taskID string) {
+// today + future entries when a task is completed/canceled/wontdo. It returns
+// the entries as they were beforehand so a repeat instance can recreate them.
+func (h *TaskHandler) cleanupSchedules(taskID string) []model.TaskSchedule {
+	schedules, err := h.scheduleRepo.ListByTask(taskID)
+	if err != nil {
+		log.Printf("schedule snapshot for task %s: %v", taskID, err)
+		schedules = nil
+	}
 	today := time.Now().Format("2006-01-02")
 	if err := h.scheduleRepo.CleanupOnTaskDone(taskID, today); err != nil {
 		log.Printf("schedule cleanup for task %s: %v", taskID, err)
 	}
+	return schedules
 }
 
 // needsDateCrossCheck returns true when only one of when_date/deadline is
@@ -2,11 +2,13 @@ package handler_test
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/push"
 	"github.com/collinjanssen/thingstodo/internal/repository"
 	"github.com/collinjanssen/thingstodo/internal/scheduler"
@@ -264,3 +266,85 @@ func TestTaskHandlerReorder(t *testing.T) {
 	})
 	testutil.AssertStatus(t, resp, http.StatusOK)
 }
+
+func TestTaskHandlerCompleteCarriesDeadlineAndSchedulesToNextInstance(t *testing.T) {
+	client, db := setupTaskRouter(t)
+
+	resp := client.Post("/api/tasks", map[string]interface{}{
+		"title": "Weekly review", "notes": "agenda", "when_date": "2030-01-07",
+		"deadline": "2030-01-09", "high_priority": true,
+	})
+	testutil.AssertStatus(t, resp, http.StatusCreated)
+	var created map[string]interface{}
+	resp.JSON(t, &created)
+	id := created["id"].(string)
+
+	scheduleRepo := repository.NewScheduleRepository(db, nil)
+	schedules, err := scheduleRepo.ListByTask(id)
+	if err != nil || len(schedules) != 1 {
+		t.Fatalf("expected one schedule entry, got %v (%v)", schedules, err)
+	}
+	start, end := "09:00", "09:30"
+	if _, err := scheduleRepo.Update(schedules[0].ID, model.UpdateTaskScheduleInput{
+		StartTime: &start, EndTime: &end,
+		Raw: map[string]json.RawMessage{"start_time": nil, "end_time": nil},
+	}); err != nil {
+		t.Fatalf("update schedule: %v", err)
+	}
+	start2, end2 := "10:00", "11:00"
+	if _, err := scheduleRepo.Create(id, model.CreateTaskScheduleInput{
+		WhenDate: "2030-01-08", StartTime: &start2, EndTime: &end2,
+	}); err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+
+	ruleRepo := repository.NewRepeatRuleRepository(db, nil)
+	if _, err := ruleRepo.Upsert(id, model.CreateRepeatRuleInput{
+		Pattern: &model.RecurrencePattern{Type: model.PatternWeekly, Every: 1, Mode: "fixed", On: []string{"mon"}},
+		Copy:    &model.RepeatCopyOptions{Checklist: true, Attachments: true},
+	}); err != nil {
+		t.Fatalf("upsert rule: %v", err)
+	}
+
+	testutil.AssertStatus(t, client.Patch("/api/tasks/"+id+"/complete", nil), http.StatusOK)
+
+	var nextID string
+	if err := db.QueryRow("SELECT id FROM tasks WHERE id != ? AND title = 'Weekly review'", id).Scan(&nextID); err != nil {
+		t.Fatalf("find next instance: %v", err)
+	}
+	resp = client.Get("/api/tasks/" + nextID)
+	testutil.AssertStatus(t, resp, http.StatusOK)
+	var next model.TaskDetail
+	resp.JSON(t, &next)
+
+	if next.WhenDate == nil || *next.WhenDate != "2030-01-14" {
+		t.Errorf("expected when_date 2030-01-14, got %v", next.WhenDate)
+	}
+	if next.Deadline == nil || *next.Deadline != "2030-01-16" {
+		t.Errorf("expected deadline shifted to 2030-01-16, got %v", next.Deadline)
+	}
+	if !next.HighPriority {
+		t.Error("expected high priority to carry over")
+	}
+	if next.Notes != "" {
+		t.Errorf("expected notes not copied, got %q", next.Notes)
+	}
+	if len(next.Schedules) != 2 {
+		t.Fatalf("expected 2 schedule entries, got %+v", next.Schedules)
+	}
+	first, second := next.Schedules[0], next.Schedules[1]
+	if first.WhenDate != "2030-01-14" || first.StartTime == nil || *first.StartTime != "09:00" || first.EndTime == nil || *first.EndTime != "09:30" {
+		t.Errorf("unexpected first schedule entry %+v", first)
+	}
+	if second.WhenDate != "2030-01-15" || second.StartTime == nil || *second.StartTime != "10:00" {
+		t.Errorf("unexpected second schedule entry %+v", second)
+	}
+
+	rule, err := ruleRepo.GetByTask(nextID)
+	if err != nil || rule == nil {
+		t.Fatalf("expected rule moved to next instance: %v", err)
+	}
+	if rule.Copy.Notes || !rule.Copy.Checklist {
+		t.Errorf("expected copy options carried with the rule, got %+v", rule.Copy)
+	}
+}
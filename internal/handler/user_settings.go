@@ -3,9 +3,12 @@ package handler
 import (
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	mw "github.com/collinjanssen/thingstodo/internal/middleware"
 	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
 	"github.com/collinjanssen/thingstodo/internal/repository"
 )
 
@@ -45,6 +48,24 @@ func (h *UserSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	input.Raw = raw
+	if input.WorkdayCalendar != nil || input.WorkdayCalendarICS != nil {
+		current, err := h.repo.GetOrCreate(userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+			return
+		}
+		id, ics := current.WorkdayCalendar, current.WorkdayCalendarICS
+		if input.WorkdayCalendar != nil {
+			id = *input.WorkdayCalendar
+		}
+		if input.WorkdayCalendarICS != nil {
+			ics = *input.WorkdayCalendarICS
+		}
+		if _, err := recurrence.LoadCalendar(id, ics); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), "VALIDATION")
+			return
+		}
+	}
 	settings, err := h.repo.Update(userID, input)
 	if err != nil {
 		log.Printf("ERROR user_settings.Update userID=%s: %v", userID, err)
@@ -53,3 +74,78 @@ func (h *UserSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, settings)
 }
+
+// Calendars lists the built-in workday calendars that workday_calendar accepts
+// besides "" (Monday to Friday) and "ics".
+// GET /api/user/settings/calendars
+func (h *UserSettingsHandler) Calendars(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"calendars": recurrence.BuiltinCalendars()})
+}
+
+// Holidays lists the holidays of the user's workday calendar in a year.
+// GET /api/user/settings/holidays?year=YYYY
+func (h *UserSettingsHandler) Holidays(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "UNAUTHORIZED")
+		return
+	}
+	year := time.Now().Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 9999 {
+			writeError(w, http.StatusBadRequest, "invalid year", "BAD_REQUEST")
+			return
+		}
+		year = n
+	}
+	settings, err := h.repo.GetOrCreate(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	holidays := []recurrence.Holiday{}
+	cal, err := recurrence.UserCalendar(settings)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+		return
+	}
+	if hc, ok := cal.(*recurrence.HolidayCalendar); ok {
+		holidays = hc.Holidays(year)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"calendar": settings.WorkdayCalendar,
+		"year":     year,
+		"holidays": holidays,
+	})
+}
+
+// requestEngine returns a recurrence engine using the requesting user's
+// workday calendar, or fallback when there is no user or calendar.
+func requestEngine(r *http.Request, settingsRepo *repository.UserSettingsRepository, fallback *recurrence.Engine) *recurrence.Engine {
+	cal := requestCalendar(r, settingsRepo)
+	if cal == nil {
+		return fallback
+	}
+	return recurrence.NewEngineWithCalendar(cal)
+}
+
+// requestCalendar returns the requesting user's workday calendar; nil means
+// Monday to Friday.
+func requestCalendar(r *http.Request, settingsRepo *repository.UserSettingsRepository) recurrence.Calendar {
+	userID, ok := r.Context().Value(mw.UserIDKey).(string)
+	if !ok || userID == "" || settingsRepo == nil {
+		return nil
+	}
+	settings, err := settingsRepo.GetOrCreate(userID)
+	if err != nil {
+		log.Printf("WARN workday calendar userID=%s: %v", userID, err)
+		return nil
+	}
+	cal, err := recurrence.UserCalendar(settings)
+	if err != nil {
+		log.Printf("WARN workday calendar userID=%s: %v", userID, err)
+		return nil
+	}
+	return cal
+}
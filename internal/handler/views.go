@@ -3,18 +3,54 @@ package handler
 import (
 	"log"
 	"net/http"
+	"time"
 
 	mw "github.com/collinjanssen/thingstodo/internal/middleware"
+	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
 )
 
 type ViewHandler struct {
-	repo         *repository.ViewRepository
-	settingsRepo *repository.UserSettingsRepository
+	repo          *repository.ViewRepository
+	settingsRepo  *repository.UserSettingsRepository
+	smartListRepo *repository.SmartListRepository
 }
 
-func NewViewHandler(repo *repository.ViewRepository, settingsRepo *repository.UserSettingsRepository) *ViewHandler {
-	return &ViewHandler{repo: repo, settingsRepo: settingsRepo}
+func NewViewHandler(repo *repository.ViewRepository, settingsRepo *repository.UserSettingsRepository, smartListRepo *repository.SmartListRepository) *ViewHandler {
+	return &ViewHandler{repo: repo, settingsRepo: settingsRepo, smartListRepo: smartListRepo}
+}
+
+// viewFilter reads the filter and sort parameters every view accepts:
+// tag (repeatable), project, area, priority, deadline and sort. It writes
+// the error response and returns false if one is malformed.
+func viewFilter(w http.ResponseWriter, r *http.Request) (model.ViewFilter, bool) {
+	q := r.URL.Query()
+	f := model.ViewFilter{
+		Tags:     q["tag"],
+		Project:  q.Get("project"),
+		Area:     q.Get("area"),
+		Priority: q.Get("priority"),
+		Deadline: q.Get("deadline"),
+		Sort:     q.Get("sort"),
+	}
+	if f.Priority != "" && f.Priority != "high" && f.Priority != "normal" {
+		writeError(w, http.StatusBadRequest, "priority must be high or normal", "VALIDATION")
+		return f, false
+	}
+	switch f.Deadline {
+	case "", "any", "none", "overdue", "today", "tomorrow":
+	default:
+		if _, err := time.Parse("2006-01-02", f.Deadline); err != nil {
+			writeError(w, http.StatusBadRequest, "deadline must be any, none, overdue, today, tomorrow or a date (YYYY-MM-DD)", "VALIDATION")
+			return f, false
+		}
+	}
+	if f.Sort != "" && !repository.ValidTaskSort(f.Sort) {
+		writeError(w, http.StatusBadRequest, "sort must be one of when, deadline, created, updated, title, priority, optionally prefixed with -", "VALIDATION")
+		return f, false
+	}
+	return f, true
 }
 
 func (h *ViewHandler) getReviewSettings(r *http.Request) (*int, bool) {
@@ -31,8 +67,14 @@ func (h *ViewHandler) getReviewSettings(r *http.Request) (*int, bool) {
 }
 
 func (h *ViewHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
 	reviewDays, includeRecurring := h.getReviewSettings(r)
-	view, err := h.repo.Inbox(reviewDays, includeRecurring)
+	ctx, span := tracing.Start(r.Context(), "view.inbox")
+	view, err := h.repo.WithContext(ctx).Inbox(reviewDays, includeRecurring, f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -53,7 +95,13 @@ func (h *ViewHandler) getEveningStartsAt(r *http.Request) string {
 }
 
 func (h *ViewHandler) Today(w http.ResponseWriter, r *http.Request) {
-	view, err := h.repo.Today(h.getEveningStartsAt(r))
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "view.today")
+	view, err := h.repo.WithContext(ctx).Today(h.getEveningStartsAt(r), f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -61,9 +109,48 @@ func (h *ViewHandler) Today(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, view)
 }
 
+// defaultProjectionDays is how far ahead Upcoming projects repeating tasks
+// when ?projected=true is given without projected_until.
+const defaultProjectionDays = 28
+
+// Upcoming returns the Upcoming view. With ?projected=true it also lists
+// future occurrences of repeating tasks up to projected_until (default four
+// weeks after from, at most a year).
+// GET /api/views/upcoming?from=&projected=&projected_until=
 func (h *ViewHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
-	from := r.URL.Query().Get("from")
-	view, err := h.repo.Upcoming(from)
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from := q.Get("from")
+	projectUntil := ""
+	if q.Get("projected") == "true" || q.Get("projected") == "1" {
+		start := time.Now()
+		if from != "" {
+			t, err := time.Parse("2006-01-02", from)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "from must be a date (YYYY-MM-DD)", "BAD_REQUEST")
+				return
+			}
+			start = t
+		}
+		projectUntil = start.AddDate(0, 0, defaultProjectionDays).Format("2006-01-02")
+		if v := q.Get("projected_until"); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "projected_until must be a date (YYYY-MM-DD)", "BAD_REQUEST")
+				return
+			}
+			if limit := start.AddDate(1, 0, 0); t.After(limit) {
+				t = limit
+			}
+			projectUntil = t.Format("2006-01-02")
+		}
+	}
+	ctx, span := tracing.Start(r.Context(), "view.upcoming")
+	view, err := h.repo.WithContext(ctx).Upcoming(from, projectUntil, requestCalendar(r, h.settingsRepo), f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -72,7 +159,13 @@ func (h *ViewHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ViewHandler) Anytime(w http.ResponseWriter, r *http.Request) {
-	view, err := h.repo.Anytime()
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "view.anytime")
+	view, err := h.repo.WithContext(ctx).Anytime(f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -81,7 +174,13 @@ func (h *ViewHandler) Anytime(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ViewHandler) Someday(w http.ResponseWriter, r *http.Request) {
-	view, err := h.repo.Someday()
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "view.someday")
+	view, err := h.repo.WithContext(ctx).Someday(f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -90,10 +189,16 @@ func (h *ViewHandler) Someday(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ViewHandler) Logbook(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	limit := repository.ParseIntDefault(q.Get("limit"), 50)
-	offset := repository.ParseIntDefault(q.Get("offset"), 0)
-	view, err := h.repo.Logbook(limit, offset)
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "view.logbook")
+	view, err := h.repo.WithContext(ctx).Logbook(pageParams(r, 50), f)
+	span.End(err)
+	if writeCursorError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -102,10 +207,16 @@ func (h *ViewHandler) Logbook(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ViewHandler) Trash(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	limit := repository.ParseIntDefault(q.Get("limit"), 50)
-	offset := repository.ParseIntDefault(q.Get("offset"), 0)
-	view, err := h.repo.Trash(limit, offset)
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Start(r.Context(), "view.trash")
+	view, err := h.repo.WithContext(ctx).Trash(pageParams(r, 50), f)
+	span.End(err)
+	if writeCursorError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
@@ -114,11 +225,24 @@ func (h *ViewHandler) Trash(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ViewHandler) Counts(w http.ResponseWriter, r *http.Request) {
+	f, ok := viewFilter(w, r)
+	if !ok {
+		return
+	}
 	reviewDays, includeRecurring := h.getReviewSettings(r)
-	counts, err := h.repo.Counts(reviewDays, includeRecurring)
+	ctx, span := tracing.Start(r.Context(), "view.counts")
+	counts, err := h.repo.WithContext(ctx).Counts(reviewDays, includeRecurring, f)
+	span.End(err)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
 		return
 	}
+	counts.Smart = map[string]int{}
+	if userID, ok := r.Context().Value(mw.UserIDKey).(string); ok && userID != "" {
+		if counts.Smart, err = h.smartListRepo.Counts(userID, f); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "INTERNAL")
+			return
+		}
+	}
 	writeJSON(w, http.StatusOK, counts)
 }
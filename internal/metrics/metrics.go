@@ -0,0 +1,352 @@
+// Package metrics keeps counters, gauges and histograms in a registry and
+// serves them in the Prometheus text exposition format. Metrics are declared
+// as package variables where they are recorded; each name registers once.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are histogram bounds in seconds for request and job durations.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metrics by name.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is one metric family: its HELP and TYPE lines and its samples.
+type metric interface {
+	write(w *bufio.Writer, name string)
+}
+
+// Default is the registry the New functions register in and Handler serves.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: " + name + " registered twice")
+	}
+	r.metrics[name] = m
+}
+
+// WriteText writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for i, m := range metrics {
+		m.write(bw, names[i])
+	}
+	return bw.Flush()
+}
+
+// Handler serves the Default registry.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = Default.WriteText(w)
+	})
+}
+
+// family is what every metric kind shares: its description and label names,
+// and its series by label values.
+type family struct {
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+
+	mu      sync.Mutex
+	value   float64
+	buckets []uint64 // histograms: observations at or below each bound
+	count   uint64
+}
+
+func newFamily(help, kind string, labels []string) *family {
+	return &family{help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+}
+
+func (f *family) with(values []string, buckets int) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(f.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if buckets > 0 {
+			s.buckets = make([]uint64, buckets)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values.
+func (f *family) sorted() []*series {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = f.series[k]
+	}
+	f.mu.Unlock()
+	return out
+}
+
+func (f *family) header(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(f.help), name, f.kind)
+}
+
+func (f *family) write(w *bufio.Writer, name string) {
+	f.header(w, name)
+	for _, s := range f.sorted() {
+		s.mu.Lock()
+		v := s.value
+		s.mu.Unlock()
+		writeSample(w, name, f.labels, s.values, "", "", v)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ f *family }
+
+// Counter is one series of a CounterVec.
+type Counter struct{ s *series }
+
+// NewCounter registers a counter in Default. Names of counters end in _total.
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter registers a counter in r.
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	f := newFamily(help, "counter", labels)
+	r.register(name, f)
+	return &CounterVec{f}
+}
+
+// With returns the series for the label values, in the order of the labels.
+func (c *CounterVec) With(values ...string) Counter {
+	return Counter{c.f.with(values, 0)}
+}
+
+func (c Counter) Inc() { c.Add(1) }
+
+// Add increases the counter by v, which must not be negative.
+func (c Counter) Add(v float64) {
+	c.s.mu.Lock()
+	c.s.value += v
+	c.s.mu.Unlock()
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct{ f *family }
+
+// Gauge is one series of a GaugeVec.
+type Gauge struct{ s *series }
+
+// NewGauge registers a gauge in Default.
+func NewGauge(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge registers a gauge in r.
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	f := newFamily(help, "gauge", labels)
+	r.register(name, f)
+	return &GaugeVec{f}
+}
+
+// With returns the series for the label values, in the order of the labels.
+func (g *GaugeVec) With(values ...string) Gauge {
+	return Gauge{g.f.with(values, 0)}
+}
+
+func (g Gauge) Set(v float64) {
+	g.s.mu.Lock()
+	g.s.value = v
+	g.s.mu.Unlock()
+}
+
+func (g Gauge) Add(v float64) {
+	g.s.mu.Lock()
+	g.s.value += v
+	g.s.mu.Unlock()
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	f       *family
+	buckets []float64
+}
+
+// Histogram is one series of a HistogramVec.
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+// NewHistogram registers a histogram in Default with the given upper bounds,
+// in increasing order.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram registers a histogram in r.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{f: newFamily(help, "histogram", labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// With returns the series for the label values, in the order of the labels.
+func (h *HistogramVec) With(values ...string) Histogram {
+	return Histogram{h.f.with(values, len(h.buckets)), h.buckets}
+}
+
+func (h Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.s.mu.Lock()
+	for ; i < len(h.buckets); i++ {
+		h.s.buckets[i]++
+	}
+	h.s.count++
+	h.s.value += v
+	h.s.mu.Unlock()
+}
+
+func (h *HistogramVec) write(w *bufio.Writer, name string) {
+	h.f.header(w, name)
+	for _, s := range h.f.sorted() {
+		s.mu.Lock()
+		buckets, count, sum := append([]uint64(nil), s.buckets...), s.count, s.value
+		s.mu.Unlock()
+		for i, le := range h.buckets {
+			writeSample(w, name+"_bucket", h.f.labels, s.values, "le", formatFloat(le), float64(buckets[i]))
+		}
+		writeSample(w, name+"_bucket", h.f.labels, s.values, "le", "+Inf", float64(count))
+		writeSample(w, name+"_sum", h.f.labels, s.values, "", "", sum)
+		writeSample(w, name+"_count", h.f.labels, s.values, "", "", float64(count))
+	}
+}
+
+// Emit reports one sample of a func metric, with its label values in the
+// order of the metric's labels.
+type Emit func(value float64, labelValues ...string)
+
+// funcMetric reads its samples when scraped, for values kept elsewhere such
+// as connection pool stats.
+type funcMetric struct {
+	help, kind string
+	labels     []string
+	collect    func(Emit)
+}
+
+// NewGaugeFunc registers a gauge in Default whose samples collect reports at
+// scrape time.
+func NewGaugeFunc(name, help string, labels []string, collect func(Emit)) {
+	Default.NewGaugeFunc(name, help, labels, collect)
+}
+
+// NewGaugeFunc registers a gauge func in r.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(Emit)) {
+	r.register(name, &funcMetric{help: help, kind: "gauge", labels: labels, collect: collect})
+}
+
+// NewCounterFunc registers a counter in Default whose samples collect reports
+// at scrape time.
+func NewCounterFunc(name, help string, labels []string, collect func(Emit)) {
+	Default.NewCounterFunc(name, help, labels, collect)
+}
+
+// NewCounterFunc registers a counter func in r.
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect func(Emit)) {
+	r.register(name, &funcMetric{help: help, kind: "counter", labels: labels, collect: collect})
+}
+
+func (m *funcMetric) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(m.help), name, m.kind)
+	m.collect(func(value float64, labelValues ...string) {
+		writeSample(w, name, m.labels, labelValues, "", "", value)
+	})
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
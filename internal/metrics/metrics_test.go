@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("app_requests_total", "Requests served.", "route")
+	latency := r.NewHistogram("app_latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("app_subscribers", "Open streams.", nil, func(emit Emit) { emit(3) })
+
+	requests.With(`/tasks/{id}`).Inc()
+	requests.With(`/tasks/{id}`).Add(2)
+	requests.With(`/say "hi"`).Inc()
+	latency.With("/tasks").Observe(0.05)
+	latency.With("/tasks").Observe(0.5)
+	latency.With("/tasks").Observe(4)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP app_latency_seconds Request latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{route="/tasks",le="0.1"} 1
+app_latency_seconds_bucket{route="/tasks",le="1"} 2
+app_latency_seconds_bucket{route="/tasks",le="+Inf"} 3
+app_latency_seconds_sum{route="/tasks"} 4.55
+app_latency_seconds_count{route="/tasks"} 3
+# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{route="/say \"hi\""} 1
+app_requests_total{route="/tasks/{id}"} 3
+# HELP app_subscribers Open streams.
+# TYPE app_subscribers gauge
+app_subscribers 3
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("app_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a second registration to panic")
+		}
+	}()
+	r.NewGauge("app_total", "")
+}
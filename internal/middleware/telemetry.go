@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/metrics"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
+	"github.com/go-chi/chi/v5"
+)
+
+var requestDuration = metrics.NewHistogram("thingstodo_http_request_duration_seconds",
+	"Time taken to serve HTTP requests, by route pattern.", metrics.DefBuckets, "method", "route", "status")
+
+// Telemetry records each request's latency by route and wraps it in a
+// server span, continuing the caller's trace when it sends a traceparent.
+// The route is the matched chi pattern, so /api/tasks/{id} is one series
+// rather than one per task.
+func Telemetry(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, span := tracing.StartServer(r, r.Method)
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := strconv.Itoa(rw.status)
+		requestDuration.With(r.Method, route, status).Observe(time.Since(start).Seconds())
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("http.response.status_code", rw.status)
+		var err error
+		if rw.status >= 500 {
+			err = errors.New(http.StatusText(rw.status))
+		}
+		span.End(err)
+	})
+}
@@ -49,6 +49,7 @@ type Project struct {
 	SortOrder float64 `json:"sort_order"`
 	CreatedAt string  `json:"created_at"`
 	UpdatedAt string  `json:"updated_at"`
+	SeriesID  *string `json:"series_id,omitempty"`
 }
 
 type ProjectListItem struct {
@@ -64,6 +65,7 @@ type ProjectDetail struct {
 	Headings             []HeadingWithTasks `json:"headings"`
 	TasksWithoutHeading  []TaskListItem     `json:"tasks_without_heading"`
 	CompletedTasks       []TaskListItem     `json:"completed_tasks"`
+	RepeatRule           *ProjectRepeatRule `json:"repeat_rule"`
 }
 
 type Heading struct {
@@ -97,6 +99,7 @@ type Task struct {
 	DeletedAt         *string `json:"deleted_at"`
 	CreatedAt         string  `json:"created_at"`
 	UpdatedAt         string  `json:"updated_at"`
+	SeriesID          *string `json:"series_id,omitempty"`
 }
 
 type TaskListItem struct {
@@ -121,6 +124,9 @@ type TaskListItem struct {
 	AllTodaySchedulesCompleted   bool     `json:"all_today_schedules_completed,omitempty"`
 	ProjectName              *string  `json:"project_name"`
 	AreaName                 *string  `json:"area_name"`
+	// Projected marks a virtual future occurrence of a repeating task in the
+	// Upcoming view; it has no row of its own and shares the task's ID.
+	Projected bool `json:"projected,omitempty"`
 }
 
 type TaskDetail struct {
@@ -155,6 +161,21 @@ type TaskSchedule struct {
 	CreatedAt string  `json:"created_at,omitempty"`
 }
 
+// FirstStartTime returns the start time of the first of schedules (by sort
+// order), or nil when it has none.
+func FirstStartTime(schedules []TaskSchedule) *string {
+	var first *TaskSchedule
+	for i := range schedules {
+		if first == nil || schedules[i].SortOrder < first.SortOrder {
+			first = &schedules[i]
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return first.StartTime
+}
+
 type Attachment struct {
 	ID        string `json:"id"`
 	TaskID    string `json:"task_id,omitempty"`
@@ -163,6 +184,7 @@ type Attachment struct {
 	URL       string `json:"url"`
 	MimeType  string `json:"mime_type"`
 	FileSize  int64  `json:"file_size"`
+	Encrypted bool   `json:"encrypted"`
 	SortOrder float64 `json:"sort_order"`
 	CreatedAt string `json:"created_at"`
 }
@@ -191,6 +213,8 @@ type RepeatRule struct {
 	ID             string             `json:"id"`
 	TaskID         string             `json:"task_id,omitempty"`
 	Pattern        RecurrencePattern  `json:"pattern"`
+	Copy           RepeatCopyOptions  `json:"copy"`
+	CatchUp        string             `json:"catch_up"`
 	// Deprecated flat fields (kept for backwards compat in responses)
 	Frequency      string             `json:"frequency,omitempty"`
 	IntervalValue  int                `json:"interval_value,omitempty"`
@@ -199,6 +223,53 @@ type RepeatRule struct {
 	CreatedAt      string             `json:"created_at,omitempty"`
 }
 
+// RepeatCopyOptions selects what a new repeat instance copies from the one
+// before it. The deadline offset, schedule time slots, tags and priority are
+// always carried over.
+type RepeatCopyOptions struct {
+	Notes       bool `json:"notes"`
+	Checklist   bool `json:"checklist"`
+	Attachments bool `json:"attachments"`
+}
+
+// DefaultRepeatCopyOptions copies everything.
+func DefaultRepeatCopyOptions() RepeatCopyOptions {
+	return RepeatCopyOptions{Notes: true, Checklist: true, Attachments: true}
+}
+
+// UnmarshalJSON fills fields missing from the input with their defaults, so
+// {"checklist": false} only turns off the checklist.
+func (o *RepeatCopyOptions) UnmarshalJSON(data []byte) error {
+	type plain RepeatCopyOptions
+	p := plain(DefaultRepeatCopyOptions())
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*o = RepeatCopyOptions(p)
+	return nil
+}
+
+// Catch-up policies decide what happens when further occurrences of a
+// fixed-schedule rule come due while its open instance is still waiting.
+// Occurrences that get no instance of their own are logged as missed.
+const (
+	CatchUpNone   = "none"   // leave the overdue instance as it is
+	CatchUpAll    = "all"    // create an instance for every missed occurrence
+	CatchUpLatest = "latest" // create an instance for the latest occurrence only
+	CatchUpSkip   = "skip"   // move the open instance to the latest occurrence
+)
+
+// ProjectRepeatRule repeats a whole project. Finishing the project creates a
+// copy for the next occurrence with its headings and tasks reopened and their
+// dates shifted along with the project's when_date. Copy applies to each task.
+type ProjectRepeatRule struct {
+	ID        string            `json:"id"`
+	ProjectID string            `json:"project_id"`
+	Pattern   RecurrencePattern `json:"pattern"`
+	Copy      RepeatCopyOptions `json:"copy"`
+	CreatedAt string            `json:"created_at,omitempty"`
+}
+
 type User struct {
 	ID           string `json:"id"`
 	Username     string `json:"username"`
@@ -229,6 +300,8 @@ type UserSettings struct {
 	BaseURL                  string `json:"base_url"`
 	PrivacyMode              bool   `json:"privacy_mode"`
 	ReviewIncludeRecurring   bool   `json:"review_include_recurring"`
+	WorkdayCalendar          string `json:"workday_calendar"`
+	WorkdayCalendarICS       string `json:"workday_calendar_ics"`
 }
 
 type UpdateUserSettingsInput struct {
@@ -254,6 +327,8 @@ type UpdateUserSettingsInput struct {
 	BaseURL                  *string `json:"base_url"`
 	PrivacyMode              *bool   `json:"privacy_mode"`
 	ReviewIncludeRecurring   *bool   `json:"review_include_recurring"`
+	WorkdayCalendar          *string `json:"workday_calendar"`
+	WorkdayCalendarICS       *string `json:"workday_calendar_ics"`
 	Raw                      map[string]json.RawMessage `json:"-"`
 }
 
@@ -270,6 +345,7 @@ type CreateTaskInput struct {
 	AreaID      *string  `json:"area_id"`
 	HeadingID   *string  `json:"heading_id"`
 	TagIDs      []string `json:"tag_ids"`
+	SeriesID    *string  `json:"-"` // set by the scheduler for repeat instances
 }
 
 type UpdateTaskInput struct {
@@ -311,6 +387,7 @@ type CreateProjectInput struct {
 	WhenDate *string  `json:"when_date"`
 	Deadline *string  `json:"deadline"`
 	TagIDs   []string `json:"tag_ids"`
+	SeriesID *string  `json:"-"` // set by the scheduler for repeat instances
 }
 
 type UpdateProjectInput struct {
@@ -324,6 +401,12 @@ type UpdateProjectInput struct {
 	Raw      map[string]json.RawMessage `json:"-"`
 }
 
+type CreateProjectRepeatRuleInput struct {
+	Pattern *RecurrencePattern `json:"pattern"`
+	// Copy is optional; when omitted an existing rule keeps its options.
+	Copy *RepeatCopyOptions `json:"copy,omitempty"`
+}
+
 type CreateAreaInput struct {
 	Title string `json:"title"`
 }
@@ -390,6 +473,8 @@ type CreateAttachmentInput struct {
 	URL      string `json:"url"`
 	MimeType string `json:"mime_type"`
 	FileSize int64  `json:"file_size"`
+	// Encrypted marks content encrypted client-side; the server stores it as is.
+	Encrypted bool `json:"encrypted"`
 }
 
 type UpdateAttachmentInput struct {
@@ -399,6 +484,10 @@ type UpdateAttachmentInput struct {
 
 type CreateRepeatRuleInput struct {
 	Pattern        *RecurrencePattern `json:"pattern,omitempty"`
+	// Copy is optional; when omitted an existing rule keeps its options.
+	Copy           *RepeatCopyOptions `json:"copy,omitempty"`
+	// CatchUp is optional; when omitted an existing rule keeps its policy.
+	CatchUp        *string            `json:"catch_up,omitempty"`
 	// Deprecated flat fields (still accepted for backwards compat)
 	Frequency      string             `json:"frequency,omitempty"`
 	IntervalValue  int                `json:"interval_value,omitempty"`
@@ -425,6 +514,14 @@ type TaskFilters struct {
 	Search      *string
 }
 
+// Page asks for one page of a list paginated by cursor: up to Limit items
+// after Cursor, the next_cursor of the page before. The zero Page is the
+// whole list, for lists that allow it.
+type Page struct {
+	Cursor string
+	Limit  int
+}
+
 // --- View response types ---
 
 type InboxView struct {
@@ -446,6 +543,7 @@ type TodaySection struct {
 
 type TaskGroup struct {
 	Project *Ref           `json:"project"`
+	Title   string         `json:"title,omitempty"` // set when grouped by something other than project
 	Tasks   []TaskListItem `json:"tasks"`
 }
 
@@ -482,8 +580,20 @@ type AnytimeNoArea struct {
 }
 
 type LogbookView struct {
-	Groups []DateGroup `json:"groups"`
-	Total  int         `json:"total"`
+	Groups     []DateGroup `json:"groups"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// ViewFilter narrows and orders the tasks of a view. The zero value keeps
+// every task in the view's own order.
+type ViewFilter struct {
+	Tags     []string // IDs or names; a task needs all of them
+	Project  string   // ID or name
+	Area     string   // ID or name; tasks in the area's projects count too
+	Priority string   // "high" or "normal"
+	Deadline string   // "any", "none", "overdue", or a date the deadline is on or before
+	Sort     string   // as SmartList.Sort; "" keeps the view's order
 }
 
 type ViewCounts struct {
@@ -495,13 +605,72 @@ type ViewCounts struct {
 	Someday int `json:"someday"`
 	Logbook int `json:"logbook"`
 	Trash   int `json:"trash"`
-}
 
+	// Smart maps each of the user's smart list IDs to its task count.
+	Smart map[string]int `json:"smart"`
+}
+
+// SeriesStats summarises the history of a repeating task's series as a
+// habit. Past instances count as completed or missed (canceled, won't do, or
+// still open after their date); instances not yet due are left out.
+type SeriesStats struct {
+	SeriesID       string      `json:"series_id"`
+	Title          string      `json:"title"`
+	TaskID         *string     `json:"task_id"` // open instance, if any
+	Occurrences    int         `json:"occurrences"`
+	Completed      int         `json:"completed"`
+	Missed         int         `json:"missed"`
+	CompletionRate float64     `json:"completion_rate"` // 0-1
+	CurrentStreak  int         `json:"current_streak"`
+	LongestStreak  int         `json:"longest_streak"`
+	MissedDates    []string    `json:"missed_dates"`
+	From           string      `json:"from"`
+	To             string      `json:"to"`
+	Days           []SeriesDay `json:"days"`
+}
+
+// SeriesDay is one day of a series' heat map: how many instances were due
+// that day and how many were completed on it.
+type SeriesDay struct {
+	Date      string `json:"date"`
+	Due       int    `json:"due"`
+	Completed int    `json:"completed"`
+}
+
+// SearchResult is one hit of a search. Type is task, project, area, heading
+// or checklist_item. Task is set for tasks and checklist items; Parent is the
+// area of a project, the project of a heading or the task of a checklist item.
 type SearchResult struct {
-	Task         TaskListItem `json:"task"`
-	TitleSnippet string       `json:"title_snippet"`
-	NotesSnippet string       `json:"notes_snippet"`
-	Rank         float64      `json:"rank"`
+	Type         string        `json:"type"`
+	ID           string        `json:"id"`
+	Title        string        `json:"title"`
+	Status       string        `json:"status,omitempty"`
+	Task         *TaskListItem `json:"task,omitempty"`
+	Parent       *Ref          `json:"parent,omitempty"`
+	TitleSnippet string        `json:"title_snippet"`
+	NotesSnippet string        `json:"notes_snippet"`
+	Rank         float64       `json:"rank"`
+
+	// Attachment is the attached file whose text matched, if any.
+	Attachment        *Ref   `json:"attachment,omitempty"`
+	AttachmentSnippet string `json:"attachment_snippet,omitempty"`
+}
+
+// SynonymGroup is a set of words or phrases that match each other in search.
+type SynonymGroup struct {
+	ID        string   `json:"id"`
+	Terms     []string `json:"terms"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type CreateSynonymGroupInput struct {
+	Terms []string `json:"terms"`
+}
+
+// SearchGroup counts the results of one type in a search response.
+type SearchGroup struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
 }
 
 type SavedFilter struct {
@@ -517,3 +686,70 @@ type CreateSavedFilterInput struct {
 	Name   string `json:"name"`
 	Config string `json:"config"`
 }
+
+// SmartList is a named search shown as a view of its own. Query is in the
+// search query language; Sort orders its tasks and GroupBy groups them.
+type SmartList struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Query     string  `json:"query"`
+	Sort      string  `json:"sort"`
+	GroupBy   string  `json:"group_by"`
+	SortOrder float64 `json:"sort_order"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type CreateSmartListInput struct {
+	Name    string `json:"name"`
+	Query   string `json:"query"`
+	Sort    string `json:"sort"`
+	GroupBy string `json:"group_by"`
+}
+
+type UpdateSmartListInput struct {
+	Name      *string  `json:"name"`
+	Query     *string  `json:"query"`
+	Sort      *string  `json:"sort"`
+	GroupBy   *string  `json:"group_by"`
+	SortOrder *float64 `json:"sort_order"`
+}
+
+// SmartListView is a smart list's matching tasks.
+type SmartListView struct {
+	SmartList SmartList   `json:"smart_list"`
+	Groups    []TaskGroup `json:"groups"`
+	Count     int         `json:"count"`
+}
+
+type SyncConflict struct {
+	ID              string          `json:"id"`
+	Entity          string          `json:"entity"`
+	EntityID        string          `json:"entity_id"`
+	DeviceID        string          `json:"device_id"`
+	Fields          []string        `json:"fields"`
+	ClientData      json.RawMessage `json:"client_data"`
+	ClientUpdatedAt string          `json:"client_updated_at"`
+	ServerSnapshot  json.RawMessage `json:"server_snapshot"`
+	ServerUpdatedAt string          `json:"server_updated_at"`
+	Status          string          `json:"status"`
+	CreatedAt       string          `json:"created_at"`
+	ResolvedAt      *string         `json:"resolved_at"`
+}
+
+type ResolveSyncConflictInput struct {
+	Resolution string                 `json:"resolution"` // client, server, merge
+	Data       map[string]interface{} `json:"data"`
+}
+
+// EncryptionKey is the user's content key, wrapped client-side with a
+// passphrase-derived key. The server never sees the unwrapped key.
+type EncryptionKey struct {
+	Algorithm     string `json:"algorithm"`
+	KDF           string `json:"kdf"`
+	KDFIterations int    `json:"kdf_iterations"`
+	Salt          string `json:"salt"`
+	WrappedKey    string `json:"wrapped_key"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
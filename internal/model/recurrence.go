@@ -21,6 +21,14 @@ const (
 	PatternMonthlyWorkday  PatternType = "monthly_workday"
 	PatternYearlyDate      PatternType = "yearly_date"
 	PatternYearlyDOW       PatternType = "yearly_dow"
+	PatternRRule           PatternType = "rrule"
+	PatternHourly          PatternType = "hourly"
+)
+
+// Roll directions for RecurrencePattern.Roll.
+const (
+	RollForward  = "forward"
+	RollBackward = "backward"
 )
 
 // RecurrencePattern is a flat struct with omitempty for unused fields per type.
@@ -46,4 +54,33 @@ type RecurrencePattern struct {
 	// YearlyDate / YearlyDOW: month (1-12) and day (1-31)
 	Month int `json:"month,omitempty"`
 	// Day is reused for YearlyDate (day of month)
+
+	// End conditions (any type): the series ends after Count occurrences or
+	// once the next date would fall after Until (YYYY-MM-DD).
+	Count int    `json:"count,omitempty"`
+	Until string `json:"until,omitempty"`
+
+	// Exceptions are dates (YYYY-MM-DD) the series skips. A skipped date still
+	// uses up one occurrence of Count.
+	Exceptions []string `json:"exceptions,omitempty"`
+
+	// Roll moves a date that falls on a non-workday (weekend or holiday of
+	// the user's workday calendar) to the next ("forward") or previous
+	// ("backward") workday. Empty keeps the date as calculated.
+	Roll string `json:"roll,omitempty"`
+
+	// Time is the time of day ("HH:MM") of each occurrence; a new instance's
+	// first schedule entry starts then. Hourly patterns repeat every Every
+	// hours from Time (default "00:00") up to TimeUntil (default "23:59"),
+	// starting again at Time the next day; On limits them to those weekdays.
+	Time      string `json:"time,omitempty"`
+	TimeUntil string `json:"time_until,omitempty"`
+
+	// Occurrence is the 1-based position of the current task in the series.
+	// It is maintained by the server as instances are created or skipped.
+	Occurrence int `json:"occurrence,omitempty"`
+
+	// RRule: RFC 5545 rule, either bare ("FREQ=MONTHLY;BYDAY=2TU,4TU") or an
+	// iCalendar block with DTSTART, RRULE and EXDATE lines
+	RRule string `json:"rrule,omitempty"`
 }
@@ -31,18 +31,29 @@ func (d *Dispatcher) Enabled() bool {
 	return d.webpush.Enabled() || d.ntfy.Enabled()
 }
 
-func (d *Dispatcher) Send(userID string, payload Payload) error {
+// Backend names the backend Send uses for userID: "webpush", "ntfy" or
+// "none".
+func (d *Dispatcher) Backend(userID string) string {
 	settings, err := d.settingsRepo.GetOrCreate(userID)
 	if err != nil {
 		// Fall back to webpush if settings can't be read
-		return d.webpush.Send(userID, payload)
+		return "webpush"
 	}
 	switch settings.NotificationProvider {
+	case "ntfy", "none":
+		return settings.NotificationProvider
+	default:
+		return "webpush"
+	}
+}
+
+func (d *Dispatcher) Send(userID string, payload Payload) error {
+	switch d.Backend(userID) {
 	case "ntfy":
 		return d.ntfy.Send(userID, payload)
 	case "none":
 		return nil
-	default: // "webpush"
+	default:
 		return d.webpush.Send(userID, payload)
 	}
 }
@@ -7,3 +7,17 @@ type Notifier interface {
 	SendToAll(payload Payload) error
 	Enabled() bool
 }
+
+// Backend names the backend n delivers userID's notifications through, as
+// a metrics label.
+func Backend(n Notifier, userID string) string {
+	switch n := n.(type) {
+	case *Dispatcher:
+		return n.Backend(userID)
+	case *Sender:
+		return "webpush"
+	case *NtfySender:
+		return "ntfy"
+	}
+	return "other"
+}
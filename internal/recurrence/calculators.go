@@ -112,10 +112,13 @@ func (DailyCalculator) CurrentPeriod(from time.Time, _ model.RecurrencePattern)
 	return from, nil
 }
 
-// DailyWeekdayCalculator advances to the next Mon-Fri.
-type DailyWeekdayCalculator struct{}
+// DailyWeekdayCalculator advances to the next workday of its calendar
+// (Mon-Fri when Calendar is nil).
+type DailyWeekdayCalculator struct {
+	Calendar Calendar
+}
 
-func (DailyWeekdayCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+func (c DailyWeekdayCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
 	every := p.Every
 	if every < 1 {
 		every = 1
@@ -123,20 +126,20 @@ func (DailyWeekdayCalculator) Next(from time.Time, p model.RecurrencePattern) (t
 	candidate := from
 	for i := 0; i < every; i++ {
 		candidate = candidate.AddDate(0, 0, 1)
-		for !isWorkday(candidate) {
+		for !isWorkdayIn(c.Calendar, candidate) {
 			candidate = candidate.AddDate(0, 0, 1)
 		}
 	}
 	return candidate, nil
 }
 
-func (DailyWeekdayCalculator) CurrentPeriod(from time.Time, _ model.RecurrencePattern) (time.Time, error) {
-	if isWorkday(from) {
+func (c DailyWeekdayCalculator) CurrentPeriod(from time.Time, _ model.RecurrencePattern) (time.Time, error) {
+	if isWorkdayIn(c.Calendar, from) {
 		return from, nil
 	}
 	// Advance to next weekday
 	candidate := from.AddDate(0, 0, 1)
-	for !isWorkday(candidate) {
+	for !isWorkdayIn(c.Calendar, candidate) {
 		candidate = candidate.AddDate(0, 0, 1)
 	}
 	return candidate, nil
@@ -336,10 +339,13 @@ func (MonthlyDOWCalculator) CurrentPeriod(from time.Time, p model.RecurrencePatt
 	return nthWeekdayInMonth(from.Year(), from.Month(), ordinal, wd), nil
 }
 
-// MonthlyWorkdayCalculator handles first/last workday (Mon-Fri) of month.
-type MonthlyWorkdayCalculator struct{}
+// MonthlyWorkdayCalculator handles first/last workday of month, using its
+// calendar's workdays (Mon-Fri when Calendar is nil).
+type MonthlyWorkdayCalculator struct {
+	Calendar Calendar
+}
 
-func (MonthlyWorkdayCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+func (c MonthlyWorkdayCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
 	every := p.Every
 	if every < 1 {
 		every = 1
@@ -351,13 +357,13 @@ func (MonthlyWorkdayCalculator) Next(from time.Time, p model.RecurrencePattern)
 	switch p.WorkdayPosition {
 	case "first":
 		d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
-		for !isWorkday(d) {
+		for !isWorkdayIn(c.Calendar, d) {
 			d = d.AddDate(0, 0, 1)
 		}
 		return d, nil
 	case "last":
 		d := time.Date(year, month, daysInMonth(year, month), 0, 0, 0, 0, time.UTC)
-		for !isWorkday(d) {
+		for !isWorkdayIn(c.Calendar, d) {
 			d = d.AddDate(0, 0, -1)
 		}
 		return d, nil
@@ -371,13 +377,13 @@ func (c MonthlyWorkdayCalculator) CurrentPeriod(from time.Time, p model.Recurren
 	switch p.WorkdayPosition {
 	case "first":
 		d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
-		for !isWorkday(d) {
+		for !isWorkdayIn(c.Calendar, d) {
 			d = d.AddDate(0, 0, 1)
 		}
 		return d, nil
 	case "last":
 		d := time.Date(year, month, daysInMonth(year, month), 0, 0, 0, 0, time.UTC)
-		for !isWorkday(d) {
+		for !isWorkdayIn(c.Calendar, d) {
 			d = d.AddDate(0, 0, -1)
 		}
 		return d, nil
@@ -477,3 +483,57 @@ func (YearlyDOWCalculator) CurrentPeriod(from time.Time, p model.RecurrencePatte
 	}
 	return nthWeekdayInMonth(from.Year(), month, ordinal, wd), nil
 }
+
+// HourlyCalculator repeats every N hours within a daily window from
+// pattern.Time to pattern.TimeUntil, starting again at Time each day. With
+// On set, only those weekdays have occurrences.
+type HourlyCalculator struct{}
+
+func (HourlyCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+	return nextHourlySlot(from, p, true)
+}
+
+func (HourlyCalculator) CurrentPeriod(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+	return nextHourlySlot(from, p, false)
+}
+
+// nextHourlySlot finds the first slot after from (strict) or on or after it.
+func nextHourlySlot(from time.Time, p model.RecurrencePattern, strict bool) (time.Time, error) {
+	step := max(p.Every, 1) * 60
+	start, end := 0, 23*60+59
+	var err error
+	if p.Time != "" {
+		if start, err = ParseClock(p.Time); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if p.TimeUntil != "" {
+		if end, err = ParseClock(p.TimeUntil); err != nil {
+			return time.Time{}, err
+		}
+	}
+	days := map[time.Weekday]bool{}
+	for _, d := range p.On {
+		if wd, ok := parseWeekday(d); ok {
+			days[wd] = true
+		}
+	}
+
+	day := midnight(from)
+	slot := start
+	if minute := from.Hour()*60 + from.Minute(); minute > start || (strict && minute == start) {
+		n := (minute - start + step - 1) / step
+		if strict && (minute-start)%step == 0 {
+			n++
+		}
+		slot = start + n*step
+	}
+	for i := 0; i < 8; i++ {
+		if slot <= end && (len(days) == 0 || days[day.Weekday()]) {
+			return day.Add(time.Duration(slot) * time.Minute), nil
+		}
+		day = day.AddDate(0, 0, 1)
+		slot = start
+	}
+	return time.Time{}, fmt.Errorf("hourly pattern has no occurrences")
+}
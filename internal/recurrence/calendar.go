@@ -0,0 +1,464 @@
+package recurrence
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// Calendar decides which days count as workdays for the workday patterns
+// (daily_weekday, monthly_workday) and for rolling dates off non-workdays.
+// A nil Calendar means Monday to Friday.
+type Calendar interface {
+	IsWorkday(t time.Time) bool
+}
+
+// WeekdayCalendar treats Monday to Friday as workdays and has no holidays.
+type WeekdayCalendar struct{}
+
+func (WeekdayCalendar) IsWorkday(t time.Time) bool {
+	wd := t.Weekday()
+	return wd >= time.Monday && wd <= time.Friday
+}
+
+// Holiday is a named non-workday.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// HolidayCalendar is a Monday-to-Friday calendar with public holidays, either
+// from a built-in rule set or from an ICS file. Holidays are worked out per
+// year on first use and cached.
+type HolidayCalendar struct {
+	ID   string
+	Name string
+
+	rules  []holidayRule
+	events []icsEvent
+
+	mu    sync.Mutex
+	years map[int]map[string]string
+}
+
+func (c *HolidayCalendar) IsWorkday(t time.Time) bool {
+	if !(WeekdayCalendar{}).IsWorkday(t) {
+		return false
+	}
+	_, holiday := c.holidays(t.Year())[t.Format("2006-01-02")]
+	return !holiday
+}
+
+// Holidays lists the calendar's holidays in the given year in date order.
+func (c *HolidayCalendar) Holidays(year int) []Holiday {
+	days := c.holidays(year)
+	list := make([]Holiday, 0, len(days))
+	for date, name := range days {
+		list = append(list, Holiday{Date: date, Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	return list
+}
+
+func (c *HolidayCalendar) holidays(year int) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if days, ok := c.years[year]; ok {
+		return days
+	}
+	days := map[string]string{}
+	// Observed dates can cross the year boundary (New Year's Day on a
+	// Saturday is observed on December 31), so neighbouring years count too.
+	for y := year - 1; y <= year+1; y++ {
+		taken := map[string]string{}
+		for _, rule := range c.rules {
+			d, ok := rule.date(y)
+			if !ok {
+				continue
+			}
+			d = rule.observe(d, taken)
+			taken[d.Format("2006-01-02")] = rule.name
+			if d.Year() == year {
+				days[d.Format("2006-01-02")] = rule.name
+			}
+		}
+	}
+	for _, ev := range c.events {
+		ev.addTo(year, days)
+	}
+	if c.years == nil {
+		c.years = map[int]map[string]string{}
+	}
+	c.years[year] = days
+	return days
+}
+
+// --- Roll ---
+
+// maxRollDays bounds how far a date is rolled looking for a workday.
+const maxRollDays = 31
+
+// roll moves t to the nearest workday in the given direction. Unknown
+// directions and workdays are returned unchanged.
+func roll(cal Calendar, t time.Time, direction string) time.Time {
+	step := 0
+	switch direction {
+	case model.RollForward:
+		step = 1
+	case model.RollBackward:
+		step = -1
+	default:
+		return t
+	}
+	for i := 0; i < maxRollDays && !isWorkdayIn(cal, t); i++ {
+		t = t.AddDate(0, 0, step)
+	}
+	return t
+}
+
+func isWorkdayIn(cal Calendar, t time.Time) bool {
+	if cal == nil {
+		return WeekdayCalendar{}.IsWorkday(t)
+	}
+	return cal.IsWorkday(t)
+}
+
+// --- Built-in rule sets ---
+
+type holidayRule struct {
+	name    string
+	date    func(year int) (time.Time, bool)
+	observe observance
+}
+
+// observance moves a holiday that falls on a weekend (or on another holiday
+// already placed that year) to the day it is observed.
+type observance func(d time.Time, taken map[string]string) time.Time
+
+// onTheDay keeps the holiday where it falls, even on a weekend.
+func onTheDay(d time.Time, _ map[string]string) time.Time { return d }
+
+// nearestWeekday observes Saturday holidays on Friday and Sunday holidays on
+// Monday (US federal rule).
+func nearestWeekday(d time.Time, _ map[string]string) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// substituteDay observes weekend holidays on the next weekday that is not
+// already a holiday (UK substitute days).
+func substituteDay(d time.Time, taken map[string]string) time.Time {
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday || taken[d.Format("2006-01-02")] != "" {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// saturdayIfSunday moves a Sunday holiday back to Saturday (Dutch King's Day).
+func saturdayIfSunday(d time.Time, _ map[string]string) time.Time {
+	if d.Weekday() == time.Sunday {
+		return d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+func fixed(month time.Month, day int) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+	}
+}
+
+func nth(month time.Month, ordinal string, wd time.Weekday) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		return nthWeekdayInMonth(year, month, ordinal, wd), true
+	}
+}
+
+func easterOffset(days int) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		return easterSunday(year).AddDate(0, 0, days), true
+	}
+}
+
+// everyFifthYear limits a holiday to years divisible by five.
+func everyFifthYear(date func(int) (time.Time, bool)) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		if year%5 != 0 {
+			return time.Time{}, false
+		}
+		return date(year)
+	}
+}
+
+// easterSunday computes Western Easter with the anonymous Gregorian algorithm.
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+type builtinCalendar struct {
+	name  string
+	rules []holidayRule
+}
+
+var builtinCalendars = map[string]builtinCalendar{
+	"us": {"United States (federal)", []holidayRule{
+		{"New Year's Day", fixed(time.January, 1), nearestWeekday},
+		{"Martin Luther King Jr. Day", nth(time.January, "third", time.Monday), onTheDay},
+		{"Washington's Birthday", nth(time.February, "third", time.Monday), onTheDay},
+		{"Memorial Day", nth(time.May, "last", time.Monday), onTheDay},
+		{"Juneteenth", fixed(time.June, 19), nearestWeekday},
+		{"Independence Day", fixed(time.July, 4), nearestWeekday},
+		{"Labor Day", nth(time.September, "first", time.Monday), onTheDay},
+		{"Columbus Day", nth(time.October, "second", time.Monday), onTheDay},
+		{"Veterans Day", fixed(time.November, 11), nearestWeekday},
+		{"Thanksgiving Day", nth(time.November, "fourth", time.Thursday), onTheDay},
+		{"Christmas Day", fixed(time.December, 25), nearestWeekday},
+	}},
+	"gb": {"England and Wales", []holidayRule{
+		{"New Year's Day", fixed(time.January, 1), substituteDay},
+		{"Good Friday", easterOffset(-2), onTheDay},
+		{"Easter Monday", easterOffset(1), onTheDay},
+		{"Early May bank holiday", nth(time.May, "first", time.Monday), onTheDay},
+		{"Spring bank holiday", nth(time.May, "last", time.Monday), onTheDay},
+		{"Summer bank holiday", nth(time.August, "last", time.Monday), onTheDay},
+		{"Christmas Day", fixed(time.December, 25), substituteDay},
+		{"Boxing Day", fixed(time.December, 26), substituteDay},
+	}},
+	"de": {"Germany (nationwide)", []holidayRule{
+		{"Neujahr", fixed(time.January, 1), onTheDay},
+		{"Karfreitag", easterOffset(-2), onTheDay},
+		{"Ostermontag", easterOffset(1), onTheDay},
+		{"Tag der Arbeit", fixed(time.May, 1), onTheDay},
+		{"Christi Himmelfahrt", easterOffset(39), onTheDay},
+		{"Pfingstmontag", easterOffset(50), onTheDay},
+		{"Tag der Deutschen Einheit", fixed(time.October, 3), onTheDay},
+		{"1. Weihnachtstag", fixed(time.December, 25), onTheDay},
+		{"2. Weihnachtstag", fixed(time.December, 26), onTheDay},
+	}},
+	"nl": {"Netherlands", []holidayRule{
+		{"Nieuwjaarsdag", fixed(time.January, 1), onTheDay},
+		{"Tweede Paasdag", easterOffset(1), onTheDay},
+		{"Koningsdag", fixed(time.April, 27), saturdayIfSunday},
+		{"Bevrijdingsdag", everyFifthYear(fixed(time.May, 5)), onTheDay},
+		{"Hemelvaartsdag", easterOffset(39), onTheDay},
+		{"Tweede Pinksterdag", easterOffset(50), onTheDay},
+		{"Eerste Kerstdag", fixed(time.December, 25), onTheDay},
+		{"Tweede Kerstdag", fixed(time.December, 26), onTheDay},
+	}},
+}
+
+// BuiltinCalendar returns the built-in holiday calendar with the given id
+// ("us", "gb", "de", "nl").
+func BuiltinCalendar(id string) (*HolidayCalendar, bool) {
+	b, ok := builtinCalendars[strings.ToLower(id)]
+	if !ok {
+		return nil, false
+	}
+	return &HolidayCalendar{ID: strings.ToLower(id), Name: b.name, rules: b.rules}, true
+}
+
+// CalendarInfo describes a selectable workday calendar.
+type CalendarInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// BuiltinCalendars lists the built-in holiday calendars by id.
+func BuiltinCalendars() []CalendarInfo {
+	list := make([]CalendarInfo, 0, len(builtinCalendars))
+	for id, b := range builtinCalendars {
+		list = append(list, CalendarInfo{ID: id, Name: b.name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// --- ICS ---
+
+// icsEvent is an all-day VEVENT: a single day or range, optionally repeated
+// by an RRULE.
+type icsEvent struct {
+	name  string
+	start time.Time
+	days  int // length in days, at least 1
+	rule  *RRule
+}
+
+func (ev icsEvent) addTo(year int, days map[string]string) {
+	yearStart := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := yearStart.AddDate(1, 0, 0)
+	add := func(start time.Time) {
+		for i := 0; i < ev.days; i++ {
+			d := start.AddDate(0, 0, i)
+			if d.Year() == year {
+				days[d.Format("2006-01-02")] = ev.name
+			}
+		}
+	}
+
+	if ev.rule == nil {
+		add(ev.start)
+		return
+	}
+	// Start early enough to catch ranges that spill over from last year.
+	from := yearStart.AddDate(0, 0, -ev.days)
+	for i := 0; i < 400; i++ {
+		d, err := ev.rule.OnOrAfter(from)
+		if err != nil || !d.Before(yearEnd) {
+			return
+		}
+		add(d)
+		from = d.AddDate(0, 0, 1)
+	}
+}
+
+// ParseICS reads the all-day events of an iCalendar file as holidays. Events
+// may span several days (DTEND is exclusive) and repeat with an RRULE.
+func ParseICS(r io.Reader) (*HolidayCalendar, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &HolidayCalendar{ID: "ics", Name: "Custom (ICS)"}
+	var ev *icsEvent
+	var end time.Time
+	var rrule string
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		prop, _, _ := strings.Cut(strings.ToUpper(name), ";")
+		switch {
+		case prop == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			ev, end, rrule = &icsEvent{}, time.Time{}, ""
+		case ev == nil:
+			if prop == "X-WR-CALNAME" && value != "" {
+				cal.Name = value
+			}
+		case prop == "END" && strings.EqualFold(value, "VEVENT"):
+			if err := finishICSEvent(ev, end, rrule); err != nil {
+				return nil, err
+			}
+			cal.events = append(cal.events, *ev)
+			ev = nil
+		case prop == "SUMMARY":
+			ev.name = unescapeICS(value)
+		case prop == "DTSTART":
+			if ev.start, err = parseICalDate(value); err != nil {
+				return nil, fmt.Errorf("invalid DTSTART %q", value)
+			}
+		case prop == "DTEND":
+			if end, err = parseICalDate(value); err != nil {
+				return nil, fmt.Errorf("invalid DTEND %q", value)
+			}
+		case prop == "RRULE":
+			rrule = value
+		}
+	}
+	if ev != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+	if len(cal.events) == 0 {
+		return nil, fmt.Errorf("calendar has no events")
+	}
+	return cal, nil
+}
+
+func finishICSEvent(ev *icsEvent, end time.Time, rrule string) error {
+	if ev.start.IsZero() {
+		return fmt.Errorf("VEVENT %q has no DTSTART", ev.name)
+	}
+	if ev.name == "" {
+		ev.name = "Holiday"
+	}
+	ev.days = 1
+	if !end.IsZero() && end.After(ev.start) {
+		ev.days = int(end.Sub(ev.start).Hours() / 24)
+	}
+	if rrule != "" {
+		rule, err := ParseRRule("DTSTART:" + ev.start.Format("20060102") + "\nRRULE:" + rrule)
+		if err != nil {
+			return fmt.Errorf("VEVENT %q: %w", ev.name, err)
+		}
+		ev.rule = rule
+	}
+	return nil
+}
+
+// unfoldICS splits an iCalendar stream into logical lines, joining folded
+// continuation lines (RFC 5545 §3.1).
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(s)
+}
+
+// UserCalendar returns the workday calendar chosen in the user's settings:
+// nil (Monday to Friday) when none is set, a built-in rule set by id, or the
+// uploaded ICS file for "ics".
+func UserCalendar(s *model.UserSettings) (Calendar, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return LoadCalendar(s.WorkdayCalendar, s.WorkdayCalendarICS)
+}
+
+// LoadCalendar resolves a workday calendar setting. See UserCalendar.
+func LoadCalendar(id, ics string) (Calendar, error) {
+	switch strings.ToLower(id) {
+	case "", "weekdays":
+		return nil, nil
+	case "ics":
+		if strings.TrimSpace(ics) == "" {
+			return nil, fmt.Errorf("workday calendar is set to ics but no ICS file was provided")
+		}
+		cal, err := ParseICS(strings.NewReader(ics))
+		if err != nil {
+			return nil, err
+		}
+		return cal, nil
+	}
+	if cal, ok := BuiltinCalendar(id); ok {
+		return cal, nil
+	}
+	return nil, fmt.Errorf("unknown workday calendar: %s", id)
+}
@@ -0,0 +1,220 @@
+package recurrence
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestEasterSunday(t *testing.T) {
+	for year, want := range map[int]string{2024: "2024-03-31", 2025: "2025-04-20", 2026: "2026-04-05", 2038: "2038-04-25"} {
+		if got := easterSunday(year).Format("2006-01-02"); got != want {
+			t.Errorf("easterSunday(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func TestBuiltinCalendarHolidays(t *testing.T) {
+	tests := []struct {
+		calendar string
+		day      string
+		workday  bool
+	}{
+		{"us", "2025-11-27", false}, // Thanksgiving
+		{"us", "2025-11-28", true},
+		{"us", "2021-12-31", false}, // New Year's Day 2022 (Saturday) observed
+		{"us", "2026-07-03", false}, // Independence Day (Saturday) observed
+		{"gb", "2022-12-26", false}, // Boxing Day
+		{"gb", "2022-12-27", false}, // Christmas Day (Sunday) substitute
+		{"gb", "2022-12-28", true},
+		{"gb", "2025-04-18", false}, // Good Friday
+		{"de", "2025-05-29", false}, // Christi Himmelfahrt
+		{"nl", "2023-04-27", false}, // Koningsdag
+		{"nl", "2025-05-05", false}, // Bevrijdingsdag in a lustrum year
+		{"nl", "2026-05-05", true},
+	}
+	for _, tt := range tests {
+		cal, ok := BuiltinCalendar(tt.calendar)
+		if !ok {
+			t.Fatalf("BuiltinCalendar(%q) not found", tt.calendar)
+		}
+		if got := cal.IsWorkday(date(tt.day)); got != tt.workday {
+			t.Errorf("%s IsWorkday(%s) = %v, want %v", tt.calendar, tt.day, got, tt.workday)
+		}
+	}
+
+	// Koningsdag 2025 falls on a Sunday and moves to the Saturday before.
+	nl, _ := BuiltinCalendar("nl")
+	found := ""
+	for _, h := range nl.Holidays(2025) {
+		if h.Name == "Koningsdag" {
+			found = h.Date
+		}
+	}
+	if found != "2025-04-26" {
+		t.Errorf("Koningsdag 2025 = %q, want 2025-04-26", found)
+	}
+}
+
+func TestWorkdayPatternsHonourCalendar(t *testing.T) {
+	us, _ := BuiltinCalendar("us")
+	engine := NewEngineWithCalendar(us)
+
+	tests := []struct {
+		name     string
+		from     string
+		pattern  model.RecurrencePattern
+		expected string
+	}{
+		{
+			name:     "daily_weekday skips Thanksgiving",
+			from:     "2025-11-26",
+			pattern:  model.RecurrencePattern{Type: model.PatternDailyWeekday, Every: 1, Mode: "fixed"},
+			expected: "2025-11-28",
+		},
+		{
+			name:     "first workday skips New Year's Day",
+			from:     "2025-12-02",
+			pattern:  model.RecurrencePattern{Type: model.PatternMonthlyWorkday, Every: 1, Mode: "fixed", WorkdayPosition: "first"},
+			expected: "2026-01-02",
+		},
+		{
+			name:     "last workday skips observed New Year's Day",
+			from:     "2027-11-30",
+			pattern:  model.RecurrencePattern{Type: model.PatternMonthlyWorkday, Every: 1, Mode: "fixed", WorkdayPosition: "last"},
+			expected: "2027-12-30", // New Year's Day 2028 is a Saturday, observed Dec 31
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Next(tt.from, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.expected)
+			}
+		})
+	}
+
+	// Without a calendar the holiday is an ordinary workday.
+	got, _ := NewEngine().Next("2025-11-26", model.RecurrencePattern{Type: model.PatternDailyWeekday, Every: 1, Mode: "fixed"})
+	if got != "2025-11-27" {
+		t.Errorf("default engine Next = %s, want 2025-11-27", got)
+	}
+}
+
+func TestRollOffNonWorkdays(t *testing.T) {
+	us, _ := BuiltinCalendar("us")
+	engine := NewEngineWithCalendar(us)
+	day1 := 1
+	monthly := model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: &day1}
+
+	tests := []struct {
+		name     string
+		from     string
+		roll     string
+		expected string
+	}{
+		{"forward off a Sunday", "2025-05-15", model.RollForward, "2025-06-02"},
+		{"backward off a Sunday", "2025-05-15", model.RollBackward, "2025-05-30"},
+		{"backward past the rolled date", "2025-05-30", model.RollBackward, "2025-07-01"},
+		{"forward off a holiday", "2025-12-15", model.RollForward, "2026-01-02"},
+		{"no roll", "2025-05-15", "", "2025-06-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := monthly
+			p.Roll = tt.roll
+			got, err := engine.Next(tt.from, p)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.expected)
+			}
+		})
+	}
+
+	p := monthly
+	p.Roll = model.RollForward
+	got, err := engine.FirstOnOrAfter("2025-06-01", p)
+	if err != nil || got != "2025-06-02" {
+		t.Errorf("FirstOnOrAfter = %s, %v; want 2025-06-02", got, err)
+	}
+}
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:Company holidays\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Founders\\, day\r\n" +
+	"DTSTART;VALUE=DATE:20200612\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Winter shut\r\n" +
+	" down\r\n" +
+	"DTSTART;VALUE=DATE:20251229\r\n" +
+	"DTEND;VALUE=DATE:20260103\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	cal, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Fatalf("ParseICS: %v", err)
+	}
+	if cal.Name != "Company holidays" {
+		t.Errorf("Name = %q", cal.Name)
+	}
+	for day, workday := range map[string]bool{
+		"2025-06-12": false, // yearly event
+		"2026-06-12": false,
+		"2025-12-29": false, // range, spilling into next year
+		"2026-01-02": false,
+		"2026-01-05": true, // DTEND is exclusive; first Monday after
+	} {
+		if got := cal.IsWorkday(date(day)); got != workday {
+			t.Errorf("IsWorkday(%s) = %v, want %v", day, got, workday)
+		}
+	}
+
+	holidays := cal.Holidays(2026)
+	if len(holidays) != 3 || holidays[0].Date != "2026-01-01" || holidays[2].Name != "Founders, day" {
+		t.Errorf("Holidays(2026) = %+v", holidays)
+	}
+
+	engine := NewEngineWithCalendar(cal)
+	got, _ := engine.Next("2025-12-02", model.RecurrencePattern{Type: model.PatternMonthlyWorkday, Every: 1, Mode: "fixed", WorkdayPosition: "first"})
+	if got != "2026-01-05" {
+		t.Errorf("first workday of January = %s, want 2026-01-05", got)
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	if cal, err := LoadCalendar("", ""); err != nil || cal != nil {
+		t.Errorf("LoadCalendar(\"\") = %v, %v; want nil, nil", cal, err)
+	}
+	if cal, err := LoadCalendar("GB", ""); err != nil || cal == nil {
+		t.Errorf("LoadCalendar(GB) = %v, %v", cal, err)
+	}
+	for _, tt := range []struct{ id, ics string }{
+		{"mars", ""},
+		{"ics", ""},
+		{"ics", "BEGIN:VCALENDAR\nEND:VCALENDAR\n"},
+		{"ics", "BEGIN:VEVENT\nSUMMARY:No date\nEND:VEVENT\n"},
+		{"ics", "BEGIN:VEVENT\nDTSTART:20250101\nRRULE:FREQ=HOURLY\nEND:VEVENT\n"},
+	} {
+		if _, err := LoadCalendar(tt.id, tt.ics); err == nil {
+			t.Errorf("LoadCalendar(%q, %q) succeeded, want error", tt.id, tt.ics)
+		}
+	}
+}
@@ -1,12 +1,22 @@
 package recurrence
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
 )
 
+// ErrNoMoreOccurrences is returned when a series has ended: its count or
+// until date (or an RRULE's COUNT or UNTIL) has been reached.
+var ErrNoMoreOccurrences = errors.New("recurrence has no more occurrences")
+
+// maxSkippedExceptions bounds how many consecutive exception dates NextInSeries
+// steps over.
+const maxSkippedExceptions = 1000
+
 // Calculator computes the next occurrence date for a specific pattern type.
 type Calculator interface {
 	Next(from time.Time, pattern model.RecurrencePattern) (time.Time, error)
@@ -15,27 +25,47 @@ type Calculator interface {
 // Engine dispatches to the correct Calculator based on pattern type.
 type Engine struct {
 	calculators map[model.PatternType]Calculator
+	calendar    Calendar
 }
 
-// NewEngine creates an Engine with all calculator implementations registered.
+// NewEngine creates an Engine with all calculator implementations registered,
+// treating Monday to Friday as workdays.
 func NewEngine() *Engine {
+	return NewEngineWithCalendar(nil)
+}
+
+// NewEngineWithCalendar creates an Engine whose workday patterns and rolled
+// dates follow the given calendar. A nil calendar means Monday to Friday.
+func NewEngineWithCalendar(cal Calendar) *Engine {
 	return &Engine{
 		calculators: map[model.PatternType]Calculator{
 			model.PatternDaily:          DailyCalculator{},
-			model.PatternDailyWeekday:   DailyWeekdayCalculator{},
+			model.PatternDailyWeekday:   DailyWeekdayCalculator{Calendar: cal},
 			model.PatternDailyWeekend:   DailyWeekendCalculator{},
 			model.PatternWeekly:         WeeklyCalculator{},
 			model.PatternMonthlyDOM:     MonthlyDOMCalculator{},
 			model.PatternMonthlyDOW:     MonthlyDOWCalculator{},
-			model.PatternMonthlyWorkday: MonthlyWorkdayCalculator{},
+			model.PatternMonthlyWorkday: MonthlyWorkdayCalculator{Calendar: cal},
 			model.PatternYearlyDate:     YearlyDateCalculator{},
 			model.PatternYearlyDOW:      YearlyDOWCalculator{},
+			model.PatternRRule:          RRuleCalculator{},
+			model.PatternHourly:         HourlyCalculator{},
 		},
+		calendar: cal,
 	}
 }
 
-// Next computes the next date from the given date string and pattern.
-// fromDate should be "2006-01-02" format. Falls back to today if empty/invalid.
+// maxRollSteps bounds how many raw occurrences Next steps through when
+// rolling lands a date on or before the date it started from.
+const maxRollSteps = 366
+
+// Next computes the next occurrence from the given date string and pattern.
+// fromDate should be "2006-01-02" or DateTimeLayout format. Falls back to
+// today if empty/invalid. The result is a date, or a date-time for patterns
+// with a time of day (see HasTime); only hourly patterns look at the time of
+// fromDate. With pattern.Roll set, a date on a non-workday moves to the
+// nearest workday in that direction; raw dates whose rolled date is not
+// after fromDate are passed over.
 func (e *Engine) Next(fromDate string, pattern model.RecurrencePattern) (string, error) {
 	base := parseOrNow(fromDate)
 
@@ -43,12 +73,122 @@ func (e *Engine) Next(fromDate string, pattern model.RecurrencePattern) (string,
 	if !ok {
 		return "", fmt.Errorf("unknown pattern type: %s", pattern.Type)
 	}
+	if pattern.Type != model.PatternHourly {
+		base = midnight(base)
+	}
 
 	next, err := calc.Next(base, pattern)
 	if err != nil {
 		return "", err
 	}
-	return next.Format("2006-01-02"), nil
+	if pattern.Roll == "" || pattern.Type == model.PatternHourly {
+		return formatOccurrence(next, pattern), nil
+	}
+	for i := 0; i < maxRollSteps; i++ {
+		if rolled := roll(e.calendar, next, pattern.Roll); rolled.After(base) {
+			return formatOccurrence(rolled, pattern), nil
+		}
+		if next, err = calc.Next(next, pattern); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no workday found after %s", base.Format("2006-01-02"))
+}
+
+// NextInSeries computes the next occurrence of the series after fromDate,
+// applying the pattern's end conditions and skipping its exception dates (all
+// occurrences on such a date). It returns
+// the date together with its 1-based occurrence number; exception dates that
+// were skipped use up occurrences. ErrNoMoreOccurrences means the series has
+// ended.
+func (e *Engine) NextInSeries(fromDate string, pattern model.RecurrencePattern) (string, int, error) {
+	occurrence := max(pattern.Occurrence, 1)
+	count := seriesCount(pattern)
+	from := fromDate
+	for i := 0; i < maxSkippedExceptions; i++ {
+		next, err := e.Next(from, pattern)
+		if err != nil {
+			return "", 0, err
+		}
+		occurrence++
+		if count > 0 && occurrence > count {
+			return "", 0, ErrNoMoreOccurrences
+		}
+		date, _ := SplitDateTime(next)
+		if pattern.Until != "" && date > pattern.Until {
+			return "", 0, ErrNoMoreOccurrences
+		}
+		if !slices.Contains(pattern.Exceptions, date) {
+			return next, occurrence, nil
+		}
+		from = next
+	}
+	return "", 0, fmt.Errorf("too many consecutive exception dates")
+}
+
+// seriesCount returns how many occurrences the series is limited to, or 0.
+// An RRULE's COUNT is included when the rule has no DTSTART: such a rule is
+// anchored at each instance in turn, so only the series can count it.
+func seriesCount(pattern model.RecurrencePattern) int {
+	count := pattern.Count
+	if pattern.Type != model.PatternRRule {
+		return count
+	}
+	r, err := ParseRRule(pattern.RRule)
+	if err != nil || r.Count == 0 || r.DTStart != nil {
+		return count
+	}
+	if count == 0 || r.Count < count {
+		return r.Count
+	}
+	return count
+}
+
+// maxOccurrences caps how many dates Occurrences and NextOccurrences return.
+const maxOccurrences = 1000
+
+// Occurrences lists the occurrences of the series after fromDate up to and
+// including the date toDate, as NextInSeries would produce them one by one.
+// fromDate is normally the current instance, which is not included.
+func (e *Engine) Occurrences(fromDate, toDate string, pattern model.RecurrencePattern) ([]string, error) {
+	return e.walk(fromDate, pattern, func(next string, n int) bool {
+		date, _ := SplitDateTime(next)
+		return date <= toDate && n < maxOccurrences
+	})
+}
+
+// NextOccurrences lists up to n dates of the series after fromDate.
+func (e *Engine) NextOccurrences(fromDate string, pattern model.RecurrencePattern, n int) ([]string, error) {
+	n = min(n, maxOccurrences)
+	return e.walk(fromDate, pattern, func(_ string, count int) bool {
+		return count < n
+	})
+}
+
+// walk steps through the series after fromDate while keep returns true for the
+// next date and the number of dates collected so far. The end of the series
+// stops the walk without an error.
+func (e *Engine) walk(fromDate string, pattern model.RecurrencePattern, keep func(date string, n int) bool) ([]string, error) {
+	dates := []string{}
+	from := fromDate
+	if from == "" {
+		from = parseOrNow("").Format("2006-01-02")
+	}
+	for {
+		next, occurrence, err := e.NextInSeries(from, pattern)
+		if errors.Is(err, ErrNoMoreOccurrences) {
+			return dates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next <= from || !keep(next, len(dates)) {
+			return dates, nil
+		}
+		dates = append(dates, next)
+		from = next
+		pattern.Occurrence = occurrence
+	}
 }
 
 // FirstOnOrAfter finds the earliest occurrence of the pattern that is on or
@@ -62,18 +202,23 @@ func (e *Engine) FirstOnOrAfter(fromDate string, pattern model.RecurrencePattern
 		return "", fmt.Errorf("unknown pattern type: %s", pattern.Type)
 	}
 
+	if pattern.Type != model.PatternHourly {
+		base = midnight(base)
+	}
+
 	if cc, ok := calc.(CurrentPeriodCalculator); ok {
-		if candidate, err := cc.CurrentPeriod(base, pattern); err == nil && !candidate.Before(base) {
-			return candidate.Format("2006-01-02"), nil
+		if candidate, err := cc.CurrentPeriod(base, pattern); err == nil {
+			if pattern.Type != model.PatternHourly {
+				candidate = roll(e.calendar, candidate, pattern.Roll)
+			}
+			if !candidate.Before(base) {
+				return formatOccurrence(candidate, pattern), nil
+			}
 		}
 	}
 
 	// Fall back to Next
-	next, err := calc.Next(base, pattern)
-	if err != nil {
-		return "", err
-	}
-	return next.Format("2006-01-02"), nil
+	return e.Next(base.Format(DateTimeLayout), pattern)
 }
 
 func parseOrNow(s string) time.Time {
@@ -81,6 +226,9 @@ func parseOrNow(s string) time.Time {
 		if t, err := time.Parse("2006-01-02", s); err == nil {
 			return t
 		}
+		if t, err := time.Parse(DateTimeLayout, s); err == nil {
+			return t
+		}
 	}
 	return time.Now()
 }
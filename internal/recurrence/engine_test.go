@@ -1,6 +1,8 @@
 package recurrence
 
 import (
+	"errors"
+	"slices"
 	"testing"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
@@ -270,3 +272,170 @@ func TestNthWeekdayClamp(t *testing.T) {
 		t.Errorf("4th Monday of Feb 2025: got day %d, want 24", result.Day())
 	}
 }
+
+func TestNextInSeries(t *testing.T) {
+	engine := NewEngine()
+	daily := model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: "fixed"}
+
+	tests := []struct {
+		name       string
+		from       string
+		pattern    func(p model.RecurrencePattern) model.RecurrencePattern
+		expected   string
+		occurrence int
+		ended      bool
+	}{
+		{
+			name:       "no end conditions",
+			from:       "2025-03-10",
+			pattern:    func(p model.RecurrencePattern) model.RecurrencePattern { return p },
+			expected:   "2025-03-11",
+			occurrence: 2,
+		},
+		{
+			name: "skips exceptions and counts them",
+			from: "2025-03-10",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Exceptions = []string{"2025-03-11", "2025-03-12"}
+				return p
+			},
+			expected:   "2025-03-13",
+			occurrence: 4,
+		},
+		{
+			name: "count reached",
+			from: "2025-03-10",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Count, p.Occurrence = 3, 3
+				return p
+			},
+			ended: true,
+		},
+		{
+			name: "last occurrence of count",
+			from: "2025-03-10",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Count, p.Occurrence = 3, 2
+				return p
+			},
+			expected:   "2025-03-11",
+			occurrence: 3,
+		},
+		{
+			name: "until is inclusive",
+			from: "2025-03-10",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Until = "2025-03-11"
+				return p
+			},
+			expected:   "2025-03-11",
+			occurrence: 2,
+		},
+		{
+			name: "until passed",
+			from: "2025-03-11",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Until = "2025-03-11"
+				return p
+			},
+			ended: true,
+		},
+		{
+			name: "exception on the last date ends the series",
+			from: "2025-03-10",
+			pattern: func(p model.RecurrencePattern) model.RecurrencePattern {
+				p.Until = "2025-03-11"
+				p.Exceptions = []string{"2025-03-11"}
+				return p
+			},
+			ended: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, occurrence, err := engine.NextInSeries(tt.from, tt.pattern(daily))
+			if tt.ended {
+				if !errors.Is(err, ErrNoMoreOccurrences) {
+					t.Fatalf("expected ErrNoMoreOccurrences, got %s, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected || occurrence != tt.occurrence {
+				t.Errorf("NextInSeries = %s (#%d), want %s (#%d)", got, occurrence, tt.expected, tt.occurrence)
+			}
+		})
+	}
+}
+
+func TestNextInSeriesCountedRRuleWithoutStart(t *testing.T) {
+	engine := NewEngine()
+	p := model.RecurrencePattern{Type: model.PatternRRule, RRule: "FREQ=WEEKLY;BYDAY=MO;COUNT=3"}
+
+	// Each instance re-anchors the rule, so the series counts it.
+	var got []string
+	from := "2025-03-10"
+	for i := 0; i < 5; i++ {
+		next, occurrence, err := engine.NextInSeries(from, p)
+		if errors.Is(err, ErrNoMoreOccurrences) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, next)
+		from, p.Occurrence = next, occurrence
+	}
+	if want := []string{"2025-03-17", "2025-03-24"}; !slices.Equal(got, want) {
+		t.Errorf("series = %v, want %v", got, want)
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	engine := NewEngine()
+	weekly := model.RecurrencePattern{Type: model.PatternWeekly, Every: 1, Mode: "fixed", On: []string{"mon", "thu"}}
+
+	got, err := engine.Occurrences("2025-03-10", "2025-03-24", weekly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"2025-03-13", "2025-03-17", "2025-03-20", "2025-03-24"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Occurrences = %v, want %v", got, want)
+	}
+
+	weekly.Count = 3
+	weekly.Exceptions = []string{"2025-03-13"}
+	got, err = engine.Occurrences("2025-03-10", "2025-03-24", weekly)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2025-03-17"}; !slices.Equal(got, want) {
+		t.Errorf("Occurrences with count and exception = %v, want %v", got, want)
+	}
+}
+
+func TestNextOccurrences(t *testing.T) {
+	engine := NewEngine()
+	p := model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: intPtr(15)}
+
+	got, err := engine.NextOccurrences("2025-01-15", p, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"2025-02-15", "2025-03-15", "2025-04-15"}; !slices.Equal(got, want) {
+		t.Errorf("NextOccurrences = %v, want %v", got, want)
+	}
+
+	p.Until = "2025-03-01"
+	got, err = engine.NextOccurrences("2025-01-15", p, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("NextOccurrences with until = %v, want a single date", got)
+	}
+}
@@ -0,0 +1,658 @@
+// Package phrase turns English recurrence phrases such as "every other
+// friday", "monthly on the last weekday" or "every 3 days after completion"
+// into recurrence patterns.
+package phrase
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// ErrUnrecognized is returned (wrapped) when a phrase does not describe a
+// recurrence.
+var ErrUnrecognized = errors.New("unrecognized recurrence")
+
+const (
+	unitHour  = "hour"
+	unitDay   = "day"
+	unitWeek  = "week"
+	unitMonth = "month"
+	unitYear  = "year"
+)
+
+var unitWords = map[string]string{
+	"hour": unitHour, "hours": unitHour, "hourly": unitHour,
+	"day": unitDay, "days": unitDay, "daily": unitDay,
+	"week": unitWeek, "weeks": unitWeek, "weekly": unitWeek,
+	"month": unitMonth, "months": unitMonth, "monthly": unitMonth,
+	"year": unitYear, "years": unitYear, "yearly": unitYear, "annually": unitYear, "annual": unitYear,
+}
+
+// multiUnitWords are units that carry their own interval.
+var multiUnitWords = map[string]struct {
+	unit  string
+	every int
+}{
+	"biweekly":    {unitWeek, 2},
+	"fortnightly": {unitWeek, 2},
+	"fortnight":   {unitWeek, 2},
+	"quarterly":   {unitMonth, 3},
+	"quarter":     {unitMonth, 3},
+}
+
+var weekdayWords = map[string]string{
+	"monday": "monday", "mon": "monday", "mondays": "monday",
+	"tuesday": "tuesday", "tue": "tuesday", "tues": "tuesday", "tuesdays": "tuesday",
+	"wednesday": "wednesday", "wed": "wednesday", "weds": "wednesday", "wednesdays": "wednesday",
+	"thursday": "thursday", "thu": "thursday", "thur": "thursday", "thurs": "thursday", "thursdays": "thursday",
+	"friday": "friday", "fri": "friday", "fridays": "friday",
+	"saturday": "saturday", "sat": "saturday", "saturdays": "saturday",
+	"sunday": "sunday", "sun": "sunday", "sundays": "sunday",
+}
+
+var monthWords = map[string]int{
+	"january": 1, "jan": 1, "february": 2, "feb": 2, "march": 3, "mar": 3,
+	"april": 4, "apr": 4, "may": 5, "june": 6, "jun": 6, "july": 7, "jul": 7,
+	"august": 8, "aug": 8, "september": 9, "sep": 9, "sept": 9,
+	"october": 10, "oct": 10, "november": 11, "nov": 11, "december": 12, "dec": 12,
+}
+
+var numberWords = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"other": 2,
+}
+
+var ordinalWords = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+	"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+}
+
+var ordinalNames = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth"}
+
+var completionWords = map[string]bool{
+	"completion": true, "completing": true, "complete": true, "completed": true,
+	"done": true, "finishing": true, "finished": true,
+}
+
+// fillers carry no meaning once modifiers have been taken out.
+var fillers = map[string]bool{
+	"every": true, "each": true, "on": true, "the": true, "of": true, "in": true,
+	"and": true, "a": true, "an": true, "at": true, "per": true, "once": true,
+	"repeat": true, "repeats": true, "repeating": true, "starting": true,
+}
+
+// Parse turns a recurrence phrase into a pattern. The result uses fixed
+// mode unless the phrase ends in "after completion"; fields the phrase does
+// not mention (the day of a plain "monthly", the month of a plain "yearly")
+// are left for the engine to take from the task's own date.
+func Parse(input string) (model.RecurrencePattern, error) {
+	toks := tokenize(input)
+	if len(toks) == 0 {
+		return model.RecurrencePattern{}, fmt.Errorf("%w: empty phrase", ErrUnrecognized)
+	}
+	p := parser{input: input, toks: toks, pattern: model.RecurrencePattern{Mode: model.RecurrenceModeFixed}}
+	for _, step := range []func() error{p.takeMode, p.takeWindow, p.takeTime, p.takeUntil, p.takeCount, p.takeSchedule} {
+		if err := step(); err != nil {
+			return model.RecurrencePattern{}, err
+		}
+	}
+	return p.pattern, nil
+}
+
+func tokenize(input string) []string {
+	s := strings.ToLower(strings.TrimSpace(input))
+	s = strings.NewReplacer("a.m.", "am", "p.m.", "pm", "&", " and ").Replace(s)
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ',', ';', '.', '!', '?', '(', ')', '/', '"':
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Fields(s)
+}
+
+type parser struct {
+	input   string
+	toks    []string
+	pattern model.RecurrencePattern
+}
+
+func (p *parser) unrecognized(detail string) error {
+	return fmt.Errorf("%w %q: %s", ErrUnrecognized, p.input, detail)
+}
+
+// cut removes toks[i:j].
+func (p *parser) cut(i, j int) {
+	p.toks = append(p.toks[:i:i], p.toks[j:]...)
+}
+
+// takeMode handles "after completion".
+func (p *parser) takeMode() error {
+	for i := 0; i+1 < len(p.toks); i++ {
+		if p.toks[i] == "after" && completionWords[p.toks[i+1]] {
+			p.pattern.Mode = model.RecurrenceModeAfterCompletion
+			p.cut(i, i+2)
+			return nil
+		}
+	}
+	return nil
+}
+
+// takeWindow handles "from 08:00 to 20:00" and "between 8am and 8pm".
+func (p *parser) takeWindow() error {
+	for i := 0; i < len(p.toks); i++ {
+		var seps map[string]bool
+		switch p.toks[i] {
+		case "from":
+			seps = map[string]bool{"to": true, "until": true, "till": true}
+		case "between":
+			seps = map[string]bool{"and": true}
+		default:
+			continue
+		}
+		start, n, ok := clockAt(p.toks, i+1)
+		if !ok {
+			return p.unrecognized("expected a time after " + p.toks[i])
+		}
+		j := i + 1 + n
+		if j >= len(p.toks) || !seps[p.toks[j]] {
+			return p.unrecognized("expected the end of the time range")
+		}
+		end, m, ok := clockAt(p.toks, j+1)
+		if !ok {
+			return p.unrecognized("expected a time after " + p.toks[j])
+		}
+		p.pattern.Time, p.pattern.TimeUntil = start, end
+		p.cut(i, j+1+m)
+		return nil
+	}
+	return nil
+}
+
+// takeTime handles "at 9:30" and "at 5pm".
+func (p *parser) takeTime() error {
+	for i := 0; i+1 < len(p.toks); i++ {
+		if p.toks[i] != "at" {
+			continue
+		}
+		clock, n, ok := clockAt(p.toks, i+1)
+		if !ok {
+			continue // "at the end of the month"
+		}
+		if p.pattern.Time != "" {
+			return p.unrecognized("more than one time of day")
+		}
+		p.pattern.Time = clock
+		p.cut(i, i+1+n)
+		return nil
+	}
+	return nil
+}
+
+// takeUntil handles "until 2026-12-31".
+func (p *parser) takeUntil() error {
+	for i := 0; i < len(p.toks); i++ {
+		if p.toks[i] != "until" && p.toks[i] != "till" {
+			continue
+		}
+		if i+1 >= len(p.toks) {
+			return p.unrecognized("expected a date after " + p.toks[i])
+		}
+		if _, err := time.Parse("2006-01-02", p.toks[i+1]); err != nil {
+			return p.unrecognized("end dates are written YYYY-MM-DD")
+		}
+		p.pattern.Until = p.toks[i+1]
+		p.cut(i, i+2)
+		return nil
+	}
+	return nil
+}
+
+// takeCount handles "10 times" and "for 10 times".
+func (p *parser) takeCount() error {
+	for i := 1; i < len(p.toks); i++ {
+		if p.toks[i] != "times" && p.toks[i] != "occurrences" {
+			continue
+		}
+		n, ok := number(p.toks[i-1])
+		if !ok || n < 1 {
+			return p.unrecognized("expected a number of times")
+		}
+		if i+1 < len(p.toks) && (p.toks[i+1] == "a" || p.toks[i+1] == "per") {
+			return p.unrecognized("several times per period is not supported")
+		}
+		start := i - 1
+		if start > 0 && p.toks[start-1] == "for" {
+			start--
+		}
+		p.pattern.Count = n
+		p.cut(start, i+1)
+		return nil
+	}
+	return nil
+}
+
+// schedule collects what the rest of the phrase says about the dates.
+type schedule struct {
+	every      int
+	unit       string
+	weekdays   []string
+	ordinal    string
+	ordWeekday string
+	workdayPos string
+	day        *int
+	month      int
+	workdays   bool
+	weekends   bool
+}
+
+func (p *parser) takeSchedule() error {
+	// pos maps each kept token to its place in p.toks, so the fillers around
+	// it can still tell "the 15th of every month" from "every 15th month".
+	var toks []string
+	var pos []int
+	for j, t := range p.toks {
+		if !fillers[t] {
+			toks = append(toks, t)
+			pos = append(pos, j)
+		}
+	}
+	followedByOf := func(i int) bool {
+		return pos[i]+1 < len(p.toks) && p.toks[pos[i]+1] == "of"
+	}
+	afterEvery := func(i int) bool {
+		return pos[i] > 0 && (p.toks[pos[i]-1] == "every" || p.toks[pos[i]-1] == "each")
+	}
+	if len(toks) == 0 {
+		return p.unrecognized("no schedule")
+	}
+
+	var s schedule
+	setUnit := func(unit string) error {
+		if s.unit != "" && s.unit != unit {
+			return p.unrecognized("mixes " + s.unit + "s and " + unit + "s")
+		}
+		s.unit = unit
+		return nil
+	}
+	setDay := func(day int) error {
+		if s.day != nil {
+			return p.unrecognized("more than one day of the month")
+		}
+		s.day = &day
+		return nil
+	}
+
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		next := ""
+		if i+1 < len(toks) {
+			next = toks[i+1]
+		}
+
+		if unit, ok := unitWords[t]; ok {
+			if err := setUnit(unit); err != nil {
+				return err
+			}
+			continue
+		}
+		if m, ok := multiUnitWords[t]; ok {
+			if err := setUnit(m.unit); err != nil {
+				return err
+			}
+			if s.every == 0 {
+				s.every = m.every
+			}
+			continue
+		}
+		if wd, ok := weekdayWords[t]; ok {
+			s.weekdays = append(s.weekdays, wd)
+			continue
+		}
+		if m, ok := monthWords[t]; ok {
+			if s.month != 0 {
+				return p.unrecognized("more than one month")
+			}
+			s.month = m
+			continue
+		}
+
+		switch t {
+		case "weekday", "weekdays", "workday", "workdays":
+			s.workdays = true
+			continue
+		case "business", "working":
+			if next == "day" || next == "days" {
+				s.workdays = true
+				i++
+				continue
+			}
+		case "weekend", "weekends":
+			s.weekends = true
+			if next == "day" || next == "days" {
+				i++
+			}
+			continue
+		case "end":
+			// "at the end of the month"
+			if err := setDay(0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// "every 3 days", "every other friday", "jan 15", "the 15 of each month"
+		if n, ok := number(t); ok {
+			if _, isUnit := unitWords[next]; (isUnit && !followedByOf(i)) || weekdayWords[next] != "" {
+				if s.every != 0 || n < 1 {
+					return p.unrecognized("expected one interval of at least 1")
+				}
+				s.every = n
+				continue
+			}
+			if t == "other" || n < 1 || n > 31 {
+				return p.unrecognized(fmt.Sprintf("unexpected %q", t))
+			}
+			if err := setDay(n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// "second tuesday", "last weekday", "last day", "15th", "every 2nd week"
+		if n, ok := ordinal(t); ok {
+			switch {
+			case next == "day" && (n == 1 || n == -1 || mentionsMonth(toks[i+2:])):
+				day := n
+				if n == -1 {
+					day = 0
+				}
+				if err := setDay(day); err != nil {
+					return err
+				}
+				i++
+			case unitWords[next] != "" && !followedByOf(i):
+				// "every second day", "every 3rd week"; "the 15th of every
+				// month" falls through to a day of the month.
+				if !afterEvery(i) {
+					return p.unrecognized(fmt.Sprintf("expected \"every\" before %q", t))
+				}
+				if s.every != 0 || n < 1 {
+					return p.unrecognized("expected one interval of at least 1")
+				}
+				s.every = n
+			case weekdayWords[next] != "":
+				name, ok := ordinalNames[n]
+				if n == -1 {
+					name, ok = "last", true
+				}
+				if !ok || s.ordinal != "" {
+					return p.unrecognized(fmt.Sprintf("unsupported position %q", t))
+				}
+				s.ordinal, s.ordWeekday = name, weekdayWords[next]
+				i++
+			case next == "weekday" || next == "workday" || next == "business" || next == "working":
+				if n != 1 && n != -1 {
+					return p.unrecognized("only the first or last workday of a month is supported")
+				}
+				s.workdayPos = "first"
+				if n == -1 {
+					s.workdayPos = "last"
+				}
+				i++
+				if (next == "business" || next == "working") && i+1 < len(toks) && toks[i+1] == "day" {
+					i++
+				}
+			default:
+				if n < 1 || n > 31 {
+					return p.unrecognized(fmt.Sprintf("unexpected %q", t))
+				}
+				if err := setDay(n); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		return p.unrecognized(fmt.Sprintf("unexpected %q", t))
+	}
+
+	return p.resolve(s)
+}
+
+// resolve picks the pattern type that fits what the phrase mentioned.
+func (p *parser) resolve(s schedule) error {
+	pat := &p.pattern
+	pat.Every = s.every
+	if pat.Every == 0 {
+		pat.Every = 1
+	}
+	onlyUnits := func(units ...string) error {
+		for _, u := range units {
+			if s.unit == u {
+				return nil
+			}
+		}
+		return p.unrecognized("does not fit a schedule by " + s.unit)
+	}
+	noWeekdays := func() error {
+		if len(s.weekdays) > 0 || s.workdays || s.weekends {
+			return p.unrecognized("weekdays do not fit this schedule")
+		}
+		return nil
+	}
+
+	switch {
+	case s.unit == unitHour:
+		if s.day != nil || s.month != 0 || s.ordinal != "" || s.workdayPos != "" || s.weekends {
+			return p.unrecognized("hourly schedules only take weekdays")
+		}
+		pat.Type = model.PatternHourly
+		switch {
+		case s.workdays:
+			pat.On = []string{"mon", "tue", "wed", "thu", "fri"}
+		default:
+			pat.On = shortWeekdays(s.weekdays)
+		}
+	case s.workdayPos != "":
+		if err := onlyUnits("", unitMonth); err != nil {
+			return err
+		}
+		if err := noWeekdays(); err != nil {
+			return err
+		}
+		pat.Type = model.PatternMonthlyWorkday
+		pat.WorkdayPosition = s.workdayPos
+	case s.month != 0:
+		if err := onlyUnits("", unitYear); err != nil {
+			return err
+		}
+		if err := noWeekdays(); err != nil {
+			return err
+		}
+		if s.ordinal != "" {
+			if s.day != nil {
+				return p.unrecognized("both a weekday and a day of the month")
+			}
+			pat.Type = model.PatternYearlyDOW
+			pat.Ordinal, pat.Weekday, pat.Month = s.ordinal, s.ordWeekday, s.month
+		} else {
+			if s.day != nil && *s.day == 0 {
+				return p.unrecognized("the last day of a month in a year is not supported")
+			}
+			if s.day != nil && *s.day > daysInMonth(s.month) {
+				return p.unrecognized(fmt.Sprintf("%s has no day %d", time.Month(s.month), *s.day))
+			}
+			pat.Type = model.PatternYearlyDate
+			pat.Month, pat.Day = s.month, s.day
+		}
+	case s.ordinal != "":
+		if err := onlyUnits("", unitMonth); err != nil {
+			return err
+		}
+		if err := noWeekdays(); err != nil {
+			return err
+		}
+		if s.day != nil {
+			return p.unrecognized("both a weekday and a day of the month")
+		}
+		pat.Type = model.PatternMonthlyDOW
+		pat.Ordinal, pat.Weekday = s.ordinal, s.ordWeekday
+	case s.day != nil || s.unit == unitMonth:
+		if err := onlyUnits("", unitMonth); err != nil {
+			return err
+		}
+		if err := noWeekdays(); err != nil {
+			return err
+		}
+		pat.Type = model.PatternMonthlyDOM
+		pat.Day = s.day
+	case s.unit == unitYear:
+		if err := noWeekdays(); err != nil {
+			return err
+		}
+		pat.Type = model.PatternYearlyDate
+	case s.workdays || s.weekends:
+		if err := onlyUnits("", unitDay); err != nil {
+			return err
+		}
+		if len(s.weekdays) > 0 || (s.workdays && s.weekends) || pat.Every != 1 {
+			return p.unrecognized("workdays and weekends repeat every day")
+		}
+		pat.Type = model.PatternDailyWeekday
+		if s.weekends {
+			pat.Type = model.PatternDailyWeekend
+		}
+	case len(s.weekdays) > 0 || s.unit == unitWeek:
+		if err := onlyUnits("", unitWeek); err != nil {
+			return err
+		}
+		pat.Type = model.PatternWeekly
+		pat.On = shortWeekdays(s.weekdays)
+	case s.unit == unitDay:
+		pat.Type = model.PatternDaily
+	default:
+		return p.unrecognized("no schedule")
+	}
+
+	if pat.TimeUntil != "" && pat.Type != model.PatternHourly {
+		return p.unrecognized("a time range only fits hourly schedules")
+	}
+	return nil
+}
+
+// shortWeekdays turns weekday names into the "mon".."sun" form used by
+// weekly and hourly patterns, dropping duplicates.
+func shortWeekdays(days []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, d := range days {
+		short := d[:3]
+		if !seen[short] {
+			seen[short] = true
+			out = append(out, short)
+		}
+	}
+	return out
+}
+
+// daysInMonth is the most days month can have, counting February 29.
+func daysInMonth(month int) int {
+	return time.Date(2024, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// mentionsMonth reports whether the tokens name months as a unit or by name,
+// which makes "second day" a day of the month rather than an interval.
+func mentionsMonth(toks []string) bool {
+	for _, t := range toks {
+		if unitWords[t] == unitMonth || monthWords[t] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func number(t string) (int, bool) {
+	if n, ok := numberWords[t]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(t)
+	return n, err == nil
+}
+
+// ordinal parses "first".."tenth", "1st".."31st" and "last" (-1).
+func ordinal(t string) (int, bool) {
+	if t == "last" || t == "final" {
+		return -1, true
+	}
+	if n, ok := ordinalWords[t]; ok {
+		return n, true
+	}
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if digits, ok := strings.CutSuffix(t, suffix); ok {
+			if n, err := strconv.Atoi(digits); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// clockAt parses a time of day starting at toks[i]: "9", "9:30", "17:00",
+// "5pm", "5 pm", "noon" or "midnight". It returns the time as "HH:MM" and
+// the number of tokens used.
+func clockAt(toks []string, i int) (string, int, bool) {
+	if i >= len(toks) {
+		return "", 0, false
+	}
+	t, n := toks[i], 1
+	switch t {
+	case "noon", "midday":
+		return "12:00", 1, true
+	case "midnight":
+		return "00:00", 1, true
+	}
+	meridiem := ""
+	for _, m := range []string{"am", "pm"} {
+		if rest, ok := strings.CutSuffix(t, m); ok && rest != "" {
+			t, meridiem = rest, m
+		}
+	}
+	if meridiem == "" && i+1 < len(toks) && (toks[i+1] == "am" || toks[i+1] == "pm") {
+		meridiem, n = toks[i+1], 2
+	}
+
+	hourText, minuteText, hasMinutes := strings.Cut(t, ":")
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || len(hourText) > 2 {
+		return "", 0, false
+	}
+	minute := 0
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minuteText); err != nil || len(minuteText) != 2 || minute > 59 {
+			return "", 0, false
+		}
+	}
+	switch meridiem {
+	case "":
+		if hour > 23 {
+			return "", 0, false
+		}
+	default:
+		if hour < 1 || hour > 12 {
+			return "", 0, false
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), n, true
+}
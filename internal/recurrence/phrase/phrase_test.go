@@ -0,0 +1,88 @@
+package phrase
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestParse(t *testing.T) {
+	fixed, after := model.RecurrenceModeFixed, model.RecurrenceModeAfterCompletion
+	tests := []struct {
+		input    string
+		expected model.RecurrencePattern
+	}{
+		{"daily", model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: fixed}},
+		{"every day at 7am", model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: fixed, Time: "07:00"}},
+		{"every 3 days after completion", model.RecurrencePattern{Type: model.PatternDaily, Every: 3, Mode: after}},
+		{"every second day", model.RecurrencePattern{Type: model.PatternDaily, Every: 2, Mode: fixed}},
+		{"every weekday", model.RecurrencePattern{Type: model.PatternDailyWeekday, Every: 1, Mode: fixed}},
+		{"weekends at 10:30", model.RecurrencePattern{Type: model.PatternDailyWeekend, Every: 1, Mode: fixed, Time: "10:30"}},
+		{"weekly", model.RecurrencePattern{Type: model.PatternWeekly, Every: 1, Mode: fixed}},
+		{"every other friday", model.RecurrencePattern{Type: model.PatternWeekly, Every: 2, Mode: fixed, On: []string{"fri"}}},
+		{"every Mon, Wed & Fri", model.RecurrencePattern{Type: model.PatternWeekly, Every: 1, Mode: fixed, On: []string{"mon", "wed", "fri"}}},
+		{"biweekly on tuesdays", model.RecurrencePattern{Type: model.PatternWeekly, Every: 2, Mode: fixed, On: []string{"tue"}}},
+		{"monthly", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed}},
+		{"monthly on the 15th", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(15)}},
+		{"every 3 months on the last day", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 3, Mode: fixed, Day: intPtr(0)}},
+		{"on the 15th of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(15)}},
+		{"the 2nd of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(2)}},
+		{"the 31st of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(31)}},
+		{"the 1st of each month at 9am", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(1), Time: "09:00"}},
+		{"the 10 of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(10)}},
+		{"the 15th of every 2 months", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 2, Mode: fixed, Day: intPtr(15)}},
+		{"every 2nd month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 2, Mode: fixed}},
+		{"every 3rd week", model.RecurrencePattern{Type: model.PatternWeekly, Every: 3, Mode: fixed}},
+		{"at the end of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: fixed, Day: intPtr(0)}},
+		{"monthly on the last weekday", model.RecurrencePattern{Type: model.PatternMonthlyWorkday, Every: 1, Mode: fixed, WorkdayPosition: "last"}},
+		{"the first business day of each month", model.RecurrencePattern{Type: model.PatternMonthlyWorkday, Every: 1, Mode: fixed, WorkdayPosition: "first"}},
+		{"the second tuesday of every month", model.RecurrencePattern{Type: model.PatternMonthlyDOW, Every: 1, Mode: fixed, Ordinal: "second", Weekday: "tuesday"}},
+		{"every last friday at 4pm", model.RecurrencePattern{Type: model.PatternMonthlyDOW, Every: 1, Mode: fixed, Ordinal: "last", Weekday: "friday", Time: "16:00"}},
+		{"quarterly", model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 3, Mode: fixed}},
+		{"each Jan 15", model.RecurrencePattern{Type: model.PatternYearlyDate, Every: 1, Mode: fixed, Month: 1, Day: intPtr(15)}},
+		{"every year on 3 march", model.RecurrencePattern{Type: model.PatternYearlyDate, Every: 1, Mode: fixed, Month: 3, Day: intPtr(3)}},
+		{"every feb 29", model.RecurrencePattern{Type: model.PatternYearlyDate, Every: 1, Mode: fixed, Month: 2, Day: intPtr(29)}},
+		{"annually", model.RecurrencePattern{Type: model.PatternYearlyDate, Every: 1, Mode: fixed}},
+		{"the fourth thursday of november", model.RecurrencePattern{Type: model.PatternYearlyDOW, Every: 1, Mode: fixed, Ordinal: "fourth", Weekday: "thursday", Month: 11}},
+		{"every 4 hours between 8am and 8pm", model.RecurrencePattern{Type: model.PatternHourly, Every: 4, Mode: fixed, Time: "08:00", TimeUntil: "20:00"}},
+		{"hourly from 9 to 17 on weekdays", model.RecurrencePattern{Type: model.PatternHourly, Every: 1, Mode: fixed, Time: "09:00", TimeUntil: "17:00", On: []string{"mon", "tue", "wed", "thu", "fri"}}},
+		{"every week for 10 times", model.RecurrencePattern{Type: model.PatternWeekly, Every: 1, Mode: fixed, Count: 10}},
+		{"daily until 2026-12-31", model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: fixed, Until: "2026-12-31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"every book",
+		"monthly report",
+		"every 0 days",
+		"daily on mondays",
+		"the fifth monday of each month",
+		"every weekday from 9 to 5",
+		"3 times a week",
+		"until next year",
+		"every feb 30",
+		"every april 31",
+		"the 2nd month",
+	} {
+		if _, err := Parse(input); !errors.Is(err, ErrUnrecognized) {
+			t.Errorf("Parse(%q) error = %v, want ErrUnrecognized", input, err)
+		}
+	}
+}
@@ -0,0 +1,596 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// maxPeriods bounds how many periods (days, weeks, months or years) are
+// scanned for a matching occurrence, so impossible rules terminate.
+const maxPeriods = 10000
+
+// RRule is a parsed RFC 5545 recurrence rule. Only date-level recurrence is
+// supported: FREQ is DAILY, WEEKLY, MONTHLY or YEARLY, and time components of
+// DTSTART, UNTIL and EXDATE are ignored.
+type RRule struct {
+	Freq       string // DAILY, WEEKLY, MONTHLY, YEARLY
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+	DTStart    *time.Time
+	ExDates    []time.Time
+}
+
+// WeekdayNum is a BYDAY entry: a weekday with an optional ordinal (N=0 means
+// every such weekday in the period, negative counts from the end).
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+var rruleDays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var rruleDayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRRule parses either a bare rule ("FREQ=WEEKLY;BYDAY=MO") or an
+// iCalendar block with DTSTART, RRULE and EXDATE lines.
+func ParseRRule(s string) (*RRule, error) {
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	sawRule := false
+	for _, line := range strings.FieldsFunc(s, func(c rune) bool { return c == '\n' || c == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			name, value = "RRULE", line
+		}
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		switch name {
+		case "RRULE":
+			if sawRule {
+				return nil, errors.New("only one RRULE is supported")
+			}
+			sawRule = true
+			if err := r.parseRule(value); err != nil {
+				return nil, err
+			}
+		case "DTSTART":
+			d, err := parseICalDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %w", err)
+			}
+			r.DTStart = &d
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				d, err := parseICalDate(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid EXDATE: %w", err)
+				}
+				r.ExDates = append(r.ExDates, d)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported property %s", name)
+		}
+	}
+	if !sawRule {
+		return nil, errors.New("missing RRULE")
+	}
+	return r, nil
+}
+
+func (r *RRule) parseRule(rule string) error {
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("must be positive")
+			}
+		case "UNTIL":
+			var d time.Time
+			d, err = parseICalDate(value)
+			r.Until = &d
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, -31, 31)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 1, 12)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, -366, 366)
+		case "WKST":
+			wd, ok := rruleDays[strings.ToUpper(value)]
+			if !ok {
+				err = errors.New("invalid weekday")
+			}
+			r.WeekStart = wd
+		default:
+			return fmt.Errorf("unsupported rule part %s", strings.ToUpper(key))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", strings.ToUpper(key), err)
+		}
+	}
+
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return errors.New("FREQ is required")
+	default:
+		return fmt.Errorf("unsupported FREQ %s", r.Freq)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if r.Freq == "WEEKLY" && len(r.ByMonthDay) > 0 {
+		return errors.New("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	if r.Freq == "DAILY" || r.Freq == "WEEKLY" {
+		for _, d := range r.ByDay {
+			if d.N != 0 {
+				return fmt.Errorf("BYDAY ordinals require FREQ=MONTHLY or YEARLY")
+			}
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return errors.New("BYSETPOS requires another BYxxx rule part")
+	}
+	return nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var out []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		wd, ok := rruleDays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid ordinal in %q", item)
+			}
+		}
+		out = append(out, WeekdayNum{N: n, Day: wd})
+	}
+	return out, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var out []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("value %q out of range", item)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseICalDate accepts DATE (20060102) and DATE-TIME (20060102T150405[Z])
+// values and returns the date at UTC midnight.
+func parseICalDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return time.Parse("20060102", s[:8])
+}
+
+// String formats the rule. Rules without DTSTART or EXDATE are written as a
+// bare rule; otherwise an iCalendar block is produced.
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = rruleDayNames[d.Day]
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+rruleDayNames[r.WeekStart])
+	}
+	rule := strings.Join(parts, ";")
+	if r.DTStart == nil && len(r.ExDates) == 0 {
+		return rule
+	}
+
+	var lines []string
+	if r.DTStart != nil {
+		lines = append(lines, "DTSTART;VALUE=DATE:"+r.DTStart.Format("20060102"))
+	}
+	lines = append(lines, "RRULE:"+rule)
+	if len(r.ExDates) > 0 {
+		dates := make([]string, len(r.ExDates))
+		for i, d := range r.ExDates {
+			dates[i] = d.Format("20060102")
+		}
+		lines = append(lines, "EXDATE;VALUE=DATE:"+strings.Join(dates, ","))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// After returns the first occurrence strictly after from.
+func (r *RRule) After(from time.Time) (time.Time, error) {
+	return r.find(from, false)
+}
+
+// OnOrAfter returns the first occurrence on or after from.
+func (r *RRule) OnOrAfter(from time.Time) (time.Time, error) {
+	return r.find(from, true)
+}
+
+// find walks the rule's periods in order. Without DTSTART the rule is
+// anchored at from, like the other calculators: from supplies the default
+// day, weekday and month, and the interval counts from from's period.
+func (r *RRule) find(from time.Time, inclusive bool) (time.Time, error) {
+	from = truncateDay(from)
+	anchor := from
+	if r.DTStart != nil {
+		anchor = *r.DTStart
+	}
+
+	period := r.periodStart(anchor)
+	// COUNT needs every occurrence from DTSTART; otherwise skip ahead to the
+	// interval-aligned period containing from.
+	if r.DTStart != nil && r.Count == 0 && from.After(anchor) {
+		n := r.periodsBetween(period, r.periodStart(from))
+		period = r.addPeriods(period, n-n%r.Interval)
+	}
+
+	count := 0
+	for i := 0; i < maxPeriods; i++ {
+		for _, c := range r.candidates(period, anchor) {
+			if c.Before(anchor) {
+				continue
+			}
+			if r.Until != nil && c.After(*r.Until) {
+				return time.Time{}, ErrNoMoreOccurrences
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, ErrNoMoreOccurrences
+			}
+			if c.Before(from) || (!inclusive && c.Equal(from)) || r.isExcluded(c) {
+				continue
+			}
+			return c, nil
+		}
+		period = r.addPeriods(period, r.Interval)
+	}
+	return time.Time{}, fmt.Errorf("no occurrence of %s found", r.Freq)
+}
+
+func (r *RRule) isExcluded(t time.Time) bool {
+	for _, d := range r.ExDates {
+		if d.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (r *RRule) periodStart(t time.Time) time.Time {
+	t = truncateDay(t)
+	switch r.Freq {
+	case "WEEKLY":
+		for t.Weekday() != r.WeekStart {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t
+	case "MONTHLY":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "YEARLY":
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t
+	}
+}
+
+func (r *RRule) addPeriods(t time.Time, n int) time.Time {
+	switch r.Freq {
+	case "WEEKLY":
+		return t.AddDate(0, 0, 7*n)
+	case "MONTHLY":
+		return t.AddDate(0, n, 0)
+	case "YEARLY":
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+func (r *RRule) periodsBetween(a, b time.Time) int {
+	switch r.Freq {
+	case "WEEKLY":
+		return int(b.Sub(a).Hours()/24) / 7
+	case "MONTHLY":
+		return (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+	case "YEARLY":
+		return b.Year() - a.Year()
+	default:
+		return int(b.Sub(a).Hours() / 24)
+	}
+}
+
+// candidates expands one period into its sorted occurrences, following the
+// expand/limit table of RFC 5545 section 3.3.10, then applies BYSETPOS.
+func (r *RRule) candidates(period, anchor time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		days = []time.Time{period}
+	case "WEEKLY":
+		for i := 0; i < 7; i++ {
+			d := period.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && d.Weekday() == anchor.Weekday() || r.matchesWeekday(d) {
+				days = append(days, d)
+			}
+		}
+	case "MONTHLY":
+		days = r.expandMonth(period.Year(), period.Month(), anchor.Day())
+	case "YEARLY":
+		days = r.expandYear(period.Year(), anchor)
+	}
+
+	out := days[:0]
+	for _, d := range days {
+		if r.inByMonth(d) && (r.Freq != "DAILY" || r.matchesDay(d)) {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return r.applySetPos(dedupeDays(out))
+}
+
+// expandMonth returns the days of one month selected by BYMONTHDAY/BYDAY,
+// defaulting to the anchor's day of month.
+func (r *RRule) expandMonth(year int, month time.Month, defaultDay int) []time.Time {
+	last := daysInMonth(year, month)
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = last + md + 1
+			}
+			if md < 1 || md > last {
+				continue
+			}
+			d := time.Date(year, month, md, 0, 0, 0, 0, time.UTC)
+			if len(r.ByDay) == 0 || r.matchesOrdinalDay(d, year, month) {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		for md := 1; md <= last; md++ {
+			d := time.Date(year, month, md, 0, 0, 0, 0, time.UTC)
+			if r.matchesOrdinalDay(d, year, month) {
+				days = append(days, d)
+			}
+		}
+	default:
+		if defaultDay <= last {
+			days = append(days, time.Date(year, month, defaultDay, 0, 0, 0, 0, time.UTC))
+		}
+	}
+	return days
+}
+
+func (r *RRule) expandYear(year int, anchor time.Time) []time.Time {
+	months := r.ByMonth
+	if len(months) == 0 && (len(r.ByMonthDay) > 0 || len(r.ByDay) == 0) {
+		if len(r.ByMonthDay) > 0 {
+			months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+		} else {
+			months = []int{int(anchor.Month())}
+		}
+	}
+	if len(months) > 0 {
+		var days []time.Time
+		for _, m := range months {
+			days = append(days, r.expandMonth(year, time.Month(m), anchor.Day())...)
+		}
+		return days
+	}
+
+	// BYDAY without BYMONTH: ordinals count within the whole year.
+	var days []time.Time
+	for d := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		for _, wd := range r.ByDay {
+			if d.Weekday() == wd.Day && (wd.N == 0 || wd.N == weekdayOrdinal(d.YearDay(), daysInYear(year), wd.N)) {
+				days = append(days, d)
+				break
+			}
+		}
+	}
+	return days
+}
+
+// matchesOrdinalDay reports whether d matches a BYDAY entry, with ordinals
+// counted within d's month.
+func (r *RRule) matchesOrdinalDay(d time.Time, year int, month time.Month) bool {
+	for _, wd := range r.ByDay {
+		if d.Weekday() != wd.Day {
+			continue
+		}
+		if wd.N == 0 || wd.N == weekdayOrdinal(d.Day(), daysInMonth(year, month), wd.N) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdayOrdinal returns the ordinal of the day at pos among same-weekday days
+// of a period, counted from the start for positive n and from the end for
+// negative n.
+func weekdayOrdinal(pos, length, n int) int {
+	if n > 0 {
+		return (pos-1)/7 + 1
+	}
+	return -((length-pos)/7 + 1)
+}
+
+func daysInYear(year int) int {
+	if isLeapYear(year) {
+		return 366
+	}
+	return 365
+}
+
+func (r *RRule) matchesWeekday(d time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDay applies BYMONTHDAY and BYDAY as limits (used by DAILY).
+func (r *RRule) matchesDay(d time.Time) bool {
+	if len(r.ByDay) > 0 && !r.matchesWeekday(d) {
+		return false
+	}
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := daysInMonth(d.Year(), d.Month())
+	for _, md := range r.ByMonthDay {
+		if md == d.Day() || md < 0 && last+md+1 == d.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) inByMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var out []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) {
+			out = append(out, days[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupeDays(out)
+}
+
+func dedupeDays(days []time.Time) []time.Time {
+	out := days[:0]
+	for i, d := range days {
+		if i == 0 || !d.Equal(days[i-1]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// RRuleCalculator evaluates PatternRRule patterns.
+type RRuleCalculator struct{}
+
+func (RRuleCalculator) Next(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+	r, err := ParseRRule(p.RRule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.After(from)
+}
+
+func (RRuleCalculator) CurrentPeriod(from time.Time, p model.RecurrencePattern) (time.Time, error) {
+	r, err := ParseRRule(p.RRule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.OnOrAfter(from)
+}
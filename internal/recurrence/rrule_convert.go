@@ -0,0 +1,274 @@
+package recurrence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+var ordinalNames = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", -1: "last"}
+
+// PatternToRRule converts a structured pattern to an equivalent RRULE. It
+// fails for patterns whose dates depend on the date they are computed from
+// (e.g. monthly_dom without a day) and for shapes RRULE cannot express.
+func PatternToRRule(p model.RecurrencePattern) (string, error) {
+	if p.Type == model.PatternRRule {
+		r, err := ParseRRule(p.RRule)
+		if err != nil {
+			return "", err
+		}
+		return r.String(), nil
+	}
+
+	every := p.Every
+	if every < 1 {
+		every = 1
+	}
+	r := &RRule{Interval: every, WeekStart: time.Monday}
+
+	switch p.Type {
+	case model.PatternDaily:
+		r.Freq = "DAILY"
+	case model.PatternDailyWeekday, model.PatternDailyWeekend:
+		if every != 1 {
+			return "", fmt.Errorf("%s with every > 1 has no RRULE equivalent", p.Type)
+		}
+		r.Freq = "WEEKLY"
+		days := []time.Weekday{time.Saturday, time.Sunday}
+		if p.Type == model.PatternDailyWeekday {
+			days = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+		}
+		for _, d := range days {
+			r.ByDay = append(r.ByDay, WeekdayNum{Day: d})
+		}
+	case model.PatternWeekly:
+		r.Freq = "WEEKLY"
+		if len(p.On) == 0 {
+			return "", fmt.Errorf("weekly pattern without days depends on the start date")
+		}
+		for _, s := range p.On {
+			wd, ok := parseWeekday(s)
+			if !ok {
+				return "", fmt.Errorf("invalid weekday: %s", s)
+			}
+			r.ByDay = append(r.ByDay, WeekdayNum{Day: wd})
+		}
+	case model.PatternMonthlyDOM:
+		r.Freq = "MONTHLY"
+		if p.Day == nil {
+			return "", fmt.Errorf("monthly_dom without a day depends on the start date")
+		}
+		r.ByMonthDay, r.BySetPos = monthDayRule(*p.Day)
+	case model.PatternMonthlyDOW:
+		r.Freq = "MONTHLY"
+		wd, err := ordinalWeekday(p.Ordinal, p.Weekday)
+		if err != nil {
+			return "", err
+		}
+		r.ByDay = []WeekdayNum{wd}
+	case model.PatternMonthlyWorkday:
+		r.Freq = "MONTHLY"
+		switch p.WorkdayPosition {
+		case "first":
+			r.BySetPos = []int{1}
+		case "last":
+			r.BySetPos = []int{-1}
+		default:
+			return "", fmt.Errorf("invalid workday_position: %s", p.WorkdayPosition)
+		}
+		for d := time.Monday; d <= time.Friday; d++ {
+			r.ByDay = append(r.ByDay, WeekdayNum{Day: d})
+		}
+	case model.PatternYearlyDate:
+		r.Freq = "YEARLY"
+		if p.Month < 1 || p.Month > 12 || p.Day == nil || *p.Day < 1 || *p.Day > 31 {
+			return "", fmt.Errorf("yearly_date needs a month and day")
+		}
+		r.ByMonth = []int{p.Month}
+		r.ByMonthDay = []int{*p.Day}
+	case model.PatternYearlyDOW:
+		r.Freq = "YEARLY"
+		if p.Month < 1 || p.Month > 12 {
+			return "", fmt.Errorf("yearly_dow needs a month")
+		}
+		wd, err := ordinalWeekday(p.Ordinal, p.Weekday)
+		if err != nil {
+			return "", err
+		}
+		r.ByMonth = []int{p.Month}
+		r.ByDay = []WeekdayNum{wd}
+	default:
+		return "", fmt.Errorf("unknown pattern type: %s", p.Type)
+	}
+	return r.String(), nil
+}
+
+// monthDayRule maps a monthly_dom day to BYMONTHDAY/BYSETPOS. Days past the
+// 28th clamp to the last day of shorter months, which RRULE expresses as the
+// earlier of that day and the last day.
+func monthDayRule(day int) (byMonthDay, bySetPos []int) {
+	switch {
+	case day == 0:
+		return []int{-1}, nil
+	case day < 0:
+		// monthly_dom counts back from the last day: -1 is the day before it.
+		return []int{day - 1}, nil
+	case day > 28:
+		return []int{day, -1}, []int{1}
+	default:
+		return []int{day}, nil
+	}
+}
+
+func ordinalWeekday(ordinal, weekday string) (WeekdayNum, error) {
+	wd, ok := parseWeekday(weekday)
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday: %s", weekday)
+	}
+	if ordinal == "" {
+		ordinal = "first"
+	}
+	n := ordinalToInt(ordinal)
+	if strings.EqualFold(ordinal, "last") {
+		n = -1
+	}
+	return WeekdayNum{N: n, Day: wd}, nil
+}
+
+// RRuleToPattern converts a simple RRULE back to a structured pattern with
+// the given mode. It reports false when the rule has no structured equivalent
+// (e.g. it uses COUNT, UNTIL, DTSTART, EXDATE or several ordinals).
+func RRuleToPattern(rule string, mode model.RecurrenceMode) (model.RecurrencePattern, bool) {
+	r, err := ParseRRule(rule)
+	if err != nil || r.Count > 0 || r.Until != nil || r.DTStart != nil || len(r.ExDates) > 0 || r.WeekStart != time.Monday {
+		return model.RecurrencePattern{}, false
+	}
+	p := model.RecurrencePattern{Every: r.Interval, Mode: mode}
+
+	switch r.Freq {
+	case "DAILY":
+		if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 || len(r.ByMonth) > 0 || len(r.BySetPos) > 0 {
+			return p, false
+		}
+		p.Type = model.PatternDaily
+		return p, true
+
+	case "WEEKLY":
+		if len(r.ByDay) == 0 || len(r.ByMonth) > 0 || len(r.BySetPos) > 0 {
+			return p, false
+		}
+		set := weekdaySet(r.ByDay)
+		switch {
+		case r.Interval == 1 && set == "MO,TU,WE,TH,FR":
+			p.Type = model.PatternDailyWeekday
+		case r.Interval == 1 && set == "SU,SA":
+			p.Type = model.PatternDailyWeekend
+		default:
+			p.Type = model.PatternWeekly
+			for _, d := range r.ByDay {
+				p.On = append(p.On, strings.ToLower(d.Day.String()[:3]))
+			}
+		}
+		return p, true
+
+	case "MONTHLY":
+		if len(r.ByMonth) > 0 {
+			return p, false
+		}
+		switch {
+		case len(r.ByDay) == 0 && len(r.BySetPos) == 0 && len(r.ByMonthDay) == 1:
+			day, ok := structuredMonthDay(r.ByMonthDay[0])
+			if !ok {
+				return p, false
+			}
+			p.Type = model.PatternMonthlyDOM
+			p.Day = &day
+		case len(r.ByDay) == 0 && len(r.ByMonthDay) == 2 && r.ByMonthDay[0] > 28 && r.ByMonthDay[1] == -1 &&
+			len(r.BySetPos) == 1 && r.BySetPos[0] == 1:
+			day := r.ByMonthDay[0]
+			p.Type = model.PatternMonthlyDOM
+			p.Day = &day
+		case len(r.ByMonthDay) == 0 && len(r.BySetPos) == 0 && len(r.ByDay) == 1:
+			ordinal, ok := ordinalNames[r.ByDay[0].N]
+			if !ok {
+				return p, false
+			}
+			p.Type = model.PatternMonthlyDOW
+			p.Ordinal = ordinal
+			p.Weekday = strings.ToLower(r.ByDay[0].Day.String())
+		case len(r.ByMonthDay) == 0 && len(r.BySetPos) == 1 && weekdaySet(r.ByDay) == "MO,TU,WE,TH,FR":
+			switch r.BySetPos[0] {
+			case 1:
+				p.WorkdayPosition = "first"
+			case -1:
+				p.WorkdayPosition = "last"
+			default:
+				return p, false
+			}
+			p.Type = model.PatternMonthlyWorkday
+		default:
+			return p, false
+		}
+		return p, true
+
+	case "YEARLY":
+		if len(r.ByMonth) != 1 || len(r.BySetPos) > 0 {
+			return p, false
+		}
+		p.Month = r.ByMonth[0]
+		switch {
+		case len(r.ByDay) == 0 && len(r.ByMonthDay) == 1 && r.ByMonthDay[0] > 0:
+			day := r.ByMonthDay[0]
+			p.Type = model.PatternYearlyDate
+			p.Day = &day
+		case len(r.ByMonthDay) == 0 && len(r.ByDay) == 1:
+			ordinal, ok := ordinalNames[r.ByDay[0].N]
+			if !ok {
+				return p, false
+			}
+			p.Type = model.PatternYearlyDOW
+			p.Ordinal = ordinal
+			p.Weekday = strings.ToLower(r.ByDay[0].Day.String())
+		default:
+			return p, false
+		}
+		return p, true
+	}
+	return p, false
+}
+
+// structuredMonthDay is the inverse of monthDayRule for single-day rules.
+// Only days every month has (1-28) map back exactly.
+func structuredMonthDay(md int) (int, bool) {
+	switch {
+	case md == -1:
+		return 0, true
+	case md < -1:
+		return md + 1, true
+	case md <= 28:
+		return md, true
+	default:
+		return 0, false
+	}
+}
+
+// weekdaySet returns the plain BYDAY weekdays in week order (Sunday first),
+// or "" when any entry has an ordinal.
+func weekdaySet(days []WeekdayNum) string {
+	var seen [7]bool
+	for _, d := range days {
+		if d.N != 0 {
+			return ""
+		}
+		seen[d.Day] = true
+	}
+	var names []string
+	for i, ok := range seen {
+		if ok {
+			names = append(names, rruleDayNames[i])
+		}
+	}
+	return strings.Join(names, ",")
+}
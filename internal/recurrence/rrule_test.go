@@ -0,0 +1,267 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+func rrulePattern(rule string) model.RecurrencePattern {
+	return model.RecurrencePattern{Type: model.PatternRRule, Every: 1, Mode: "fixed", RRule: rule}
+}
+
+func TestRRuleNext(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name     string
+		from     string
+		rule     string
+		expected string
+	}{
+		{
+			name:     "2nd and 4th Tuesday → 2nd",
+			from:     "2025-03-10",
+			rule:     "FREQ=MONTHLY;BYDAY=2TU,4TU",
+			expected: "2025-03-11",
+		},
+		{
+			name:     "2nd and 4th Tuesday → 4th",
+			from:     "2025-03-11",
+			rule:     "FREQ=MONTHLY;BYDAY=2TU,4TU",
+			expected: "2025-03-25",
+		},
+		{
+			name:     "2nd and 4th Tuesday → next month",
+			from:     "2025-03-25",
+			rule:     "FREQ=MONTHLY;BYDAY=2TU,4TU",
+			expected: "2025-04-08",
+		},
+		{
+			name:     "last weekday of the quarter",
+			from:     "2025-04-01",
+			rule:     "DTSTART:20250301\nRRULE:FREQ=MONTHLY;INTERVAL=3;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			expected: "2025-06-30",
+		},
+		{
+			name:     "last weekday of the quarter → following quarter",
+			from:     "2025-06-30",
+			rule:     "DTSTART:20250301\nRRULE:FREQ=MONTHLY;INTERVAL=3;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			expected: "2025-09-30",
+		},
+		{
+			name:     "every 3 weeks Mon/Thu → Thursday",
+			from:     "2025-03-10",
+			rule:     "DTSTART:20250310\nRRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH;UNTIL=20251231",
+			expected: "2025-03-13",
+		},
+		{
+			name:     "every 3 weeks Mon/Thu → skips two weeks",
+			from:     "2025-03-13",
+			rule:     "DTSTART:20250310\nRRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH;UNTIL=20251231",
+			expected: "2025-03-31",
+		},
+		{
+			name:     "every 3 weeks Mon/Thu → last before UNTIL",
+			from:     "2025-12-20",
+			rule:     "DTSTART:20250310\nRRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH;UNTIL=20251231",
+			expected: "2025-12-29",
+		},
+		{
+			name:     "last day of month",
+			from:     "2025-02-10",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1",
+			expected: "2025-02-28",
+		},
+		{
+			name:     "Feb 29 skips to leap year",
+			from:     "2025-03-01",
+			rule:     "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+			expected: "2028-02-29",
+		},
+		{
+			name:     "EXDATE skips an occurrence",
+			from:     "2025-03-10",
+			rule:     "DTSTART:20250310\nRRULE:FREQ=WEEKLY;BYDAY=MO\nEXDATE:20250317",
+			expected: "2025-03-24",
+		},
+		{
+			name:     "COUNT within range",
+			from:     "2025-03-11",
+			rule:     "DTSTART:20250310\nRRULE:FREQ=DAILY;COUNT=3",
+			expected: "2025-03-12",
+		},
+		{
+			name:     "yearly Thanksgiving",
+			from:     "2025-03-10",
+			rule:     "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			expected: "2025-11-27",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Next(tt.from, rrulePattern(tt.rule))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Next(%s, %q) = %s, want %s", tt.from, tt.rule, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRRuleExhausted(t *testing.T) {
+	engine := NewEngine()
+
+	tests := []struct {
+		name string
+		from string
+		rule string
+	}{
+		{"COUNT reached", "2025-03-12", "DTSTART:20250310\nRRULE:FREQ=DAILY;COUNT=3"},
+		{"UNTIL passed", "2025-12-29", "DTSTART:20250310\nRRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH;UNTIL=20251231"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.Next(tt.from, rrulePattern(tt.rule))
+			if !errors.Is(err, ErrNoMoreOccurrences) {
+				t.Errorf("expected ErrNoMoreOccurrences, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRRuleFirstOnOrAfter(t *testing.T) {
+	engine := NewEngine()
+	got, err := engine.FirstOnOrAfter("2025-03-11", rrulePattern("FREQ=MONTHLY;BYDAY=2TU,4TU"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "2025-03-11" {
+		t.Errorf("FirstOnOrAfter = %s, want 2025-03-11", got)
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYWEEKNO=20",
+		"FREQ=DAILY;INTERVAL=0",
+	} {
+		if _, err := ParseRRule(rule); err == nil {
+			t.Errorf("ParseRRule(%q) succeeded, want error", rule)
+		}
+	}
+}
+
+func TestRRuleString(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"freq=monthly;byday=2tu,4tu", "FREQ=MONTHLY;BYDAY=2TU,4TU"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=1;BYDAY=MO", "FREQ=WEEKLY;BYDAY=MO"},
+		{
+			"DTSTART;VALUE=DATE:20250310\nRRULE:FREQ=DAILY;COUNT=3\nEXDATE:20250311T090000Z",
+			"DTSTART;VALUE=DATE:20250310\nRRULE:FREQ=DAILY;COUNT=3\nEXDATE;VALUE=DATE:20250311",
+		},
+	}
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.in)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", tt.in, err)
+		}
+		if got := r.String(); got != tt.out {
+			t.Errorf("String() = %q, want %q", got, tt.out)
+		}
+	}
+}
+
+func TestRRuleConversionRoundTrip(t *testing.T) {
+	engine := NewEngine()
+
+	patterns := []model.RecurrencePattern{
+		{Type: model.PatternDaily, Every: 2, Mode: "fixed"},
+		{Type: model.PatternDailyWeekday, Every: 1, Mode: "fixed"},
+		{Type: model.PatternDailyWeekend, Every: 1, Mode: "fixed"},
+		{Type: model.PatternWeekly, Every: 1, Mode: "fixed", On: []string{"mon", "thu"}},
+		{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: intPtr(15)},
+		{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: intPtr(0)},
+		{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: intPtr(-2)},
+		{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed", Day: intPtr(31)},
+		{Type: model.PatternMonthlyDOW, Every: 1, Mode: "fixed", Ordinal: "second", Weekday: "tuesday"},
+		{Type: model.PatternMonthlyDOW, Every: 1, Mode: "fixed", Ordinal: "last", Weekday: "friday"},
+		{Type: model.PatternMonthlyWorkday, Every: 1, Mode: "fixed", WorkdayPosition: "first"},
+		{Type: model.PatternMonthlyWorkday, Every: 1, Mode: "fixed", WorkdayPosition: "last"},
+		{Type: model.PatternYearlyDate, Every: 1, Mode: "fixed", Month: 3, Day: intPtr(15)},
+		{Type: model.PatternYearlyDOW, Every: 1, Mode: "fixed", Month: 11, Ordinal: "fourth", Weekday: "thursday"},
+	}
+
+	for _, p := range patterns {
+		t.Run(string(p.Type), func(t *testing.T) {
+			rule, err := PatternToRRule(p)
+			if err != nil {
+				t.Fatalf("PatternToRRule: %v", err)
+			}
+			back, ok := RRuleToPattern(rule, p.Mode)
+			if !ok {
+				t.Fatalf("RRuleToPattern(%q) not convertible", rule)
+			}
+			if !reflect.DeepEqual(back, p) {
+				t.Errorf("round trip via %q = %+v, want %+v", rule, back, p)
+			}
+
+			// Both forms must find the same first occurrence throughout the year.
+			for m := 1; m <= 12; m++ {
+				from := fmt.Sprintf("2025-%02d-01", m)
+				want, err := engine.FirstOnOrAfter(from, p)
+				if err != nil {
+					t.Fatalf("FirstOnOrAfter(%s): %v", from, err)
+				}
+				got, err := engine.FirstOnOrAfter(from, rrulePattern(rule))
+				if err != nil {
+					t.Fatalf("FirstOnOrAfter(%s) via %q: %v", from, rule, err)
+				}
+				if got != want {
+					t.Errorf("FirstOnOrAfter(%s) via %q = %s, want %s", from, rule, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRRuleToPatternRejectsComplexRules(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=MONTHLY;BYDAY=2TU,4TU",
+		"FREQ=DAILY;COUNT=5",
+		"FREQ=MONTHLY;BYMONTHDAY=31",
+		"DTSTART:20250101\nRRULE:FREQ=WEEKLY;BYDAY=MO",
+	} {
+		if p, ok := RRuleToPattern(rule, "fixed"); ok {
+			t.Errorf("RRuleToPattern(%q) = %+v, want not convertible", rule, p)
+		}
+	}
+}
+
+func TestPatternToRRuleRejectsDateDependentPatterns(t *testing.T) {
+	for _, p := range []model.RecurrencePattern{
+		{Type: model.PatternMonthlyDOM, Every: 1, Mode: "fixed"},
+		{Type: model.PatternWeekly, Every: 1, Mode: "fixed"},
+		{Type: model.PatternDailyWeekday, Every: 2, Mode: "fixed"},
+	} {
+		if rule, err := PatternToRRule(p); err == nil {
+			t.Errorf("PatternToRRule(%+v) = %q, want error", p, rule)
+		}
+	}
+}
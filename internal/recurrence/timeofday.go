@@ -0,0 +1,63 @@
+package recurrence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// DateTimeLayout is the layout of an occurrence that has a time of day. The
+// Engine returns occurrences in this layout for patterns with a Time and for
+// hourly patterns, and plain dates ("2006-01-02") otherwise. Because a date
+// sorts before every time on that day, both layouts compare as strings.
+const DateTimeLayout = "2006-01-02T15:04"
+
+// HasTime reports whether the pattern's occurrences carry a time of day.
+func HasTime(p model.RecurrencePattern) bool {
+	return p.Time != "" || p.Type == model.PatternHourly
+}
+
+// SplitDateTime splits an occurrence into its date and its time of day ("HH:MM",
+// empty for a plain date).
+func SplitDateTime(s string) (date, clock string) {
+	if d, c, ok := strings.Cut(s, "T"); ok {
+		return d, c
+	}
+	return s, ""
+}
+
+// JoinDateTime builds an occurrence from a date and an optional time of day,
+// e.g. a task's when_date and the start time of its first schedule entry.
+func JoinDateTime(date string, clock *string) string {
+	if date == "" || clock == nil || *clock == "" {
+		return date
+	}
+	return date + "T" + (*clock)[:min(len(*clock), 5)]
+}
+
+// ParseClock parses a time of day ("HH:MM") into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// midnight truncates t to the start of its day.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// formatOccurrence renders t in the layout the pattern calls for.
+func formatOccurrence(t time.Time, p model.RecurrencePattern) string {
+	switch {
+	case p.Type == model.PatternHourly:
+		return t.Format(DateTimeLayout)
+	case p.Time != "":
+		return t.Format("2006-01-02") + "T" + p.Time
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,74 @@
+package recurrence
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+func TestTimeOfDayPatterns(t *testing.T) {
+	engine := NewEngine()
+	medication := model.RecurrencePattern{Type: model.PatternHourly, Every: 4, Mode: "fixed", Time: "08:00", TimeUntil: "20:00"}
+
+	tests := []struct {
+		name     string
+		from     string
+		pattern  model.RecurrencePattern
+		expected string
+	}{
+		{"weekday at a time", "2025-03-14", model.RecurrencePattern{Type: model.PatternDailyWeekday, Every: 1, Mode: "fixed", Time: "09:30"}, "2025-03-17T09:30"},
+		{"time of from is ignored by date patterns", "2025-03-14T23:00", model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: "fixed", Time: "09:30"}, "2025-03-15T09:30"},
+		{"hourly from a plain date", "2025-03-10", medication, "2025-03-10T08:00"},
+		{"hourly next slot", "2025-03-10T08:00", medication, "2025-03-10T12:00"},
+		{"hourly between slots", "2025-03-10T13:15", medication, "2025-03-10T16:00"},
+		{"hourly last slot of the window", "2025-03-10T16:00", medication, "2025-03-10T20:00"},
+		{"hourly wraps to the next day", "2025-03-10T20:00", medication, "2025-03-11T08:00"},
+		{"hourly without a window", "2025-03-10T22:00", model.RecurrencePattern{Type: model.PatternHourly, Every: 6, Mode: "fixed"}, "2025-03-11T00:00"},
+		{"hourly on weekdays only", "2025-03-14T20:00", model.RecurrencePattern{Type: model.PatternHourly, Every: 4, Mode: "fixed", Time: "08:00", TimeUntil: "20:00", On: []string{"mon", "tue", "wed", "thu", "fri"}}, "2025-03-17T08:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Next(tt.from, tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.expected)
+			}
+		})
+	}
+
+	got, err := engine.Occurrences("2025-03-10T16:00", "2025-03-11", medication)
+	want := []string{"2025-03-10T20:00", "2025-03-11T08:00", "2025-03-11T12:00", "2025-03-11T16:00", "2025-03-11T20:00"}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("Occurrences = %v, %v; want %v", got, err, want)
+	}
+
+	// An exception date skips every slot on that day.
+	skipping := medication
+	skipping.Exceptions = []string{"2025-03-11"}
+	if next, _, _ := engine.NextInSeries("2025-03-10T20:00", skipping); next != "2025-03-12T08:00" {
+		t.Errorf("NextInSeries over exception = %s, want 2025-03-12T08:00", next)
+	}
+
+	if first, _ := engine.FirstOnOrAfter("2025-03-10", medication); first != "2025-03-10T08:00" {
+		t.Errorf("FirstOnOrAfter = %s, want 2025-03-10T08:00", first)
+	}
+}
+
+func TestSplitAndJoinDateTime(t *testing.T) {
+	if d, c := SplitDateTime("2025-03-10T08:00"); d != "2025-03-10" || c != "08:00" {
+		t.Errorf("SplitDateTime = %q, %q", d, c)
+	}
+	if d, c := SplitDateTime("2025-03-10"); d != "2025-03-10" || c != "" {
+		t.Errorf("SplitDateTime(date) = %q, %q", d, c)
+	}
+	clock := "09:30:00"
+	if got := JoinDateTime("2025-03-10", &clock); got != "2025-03-10T09:30" {
+		t.Errorf("JoinDateTime = %q", got)
+	}
+	if got := JoinDateTime("2025-03-10", nil); got != "2025-03-10" {
+		t.Errorf("JoinDateTime(nil) = %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -10,16 +11,43 @@ import (
 )
 
 type AreaRepository struct {
-	db        *sql.DB
+	db querier
+	reader
 	changeLog *ChangeLogRepository
 }
 
 func NewAreaRepository(db *sql.DB, changeLog *ChangeLogRepository) *AreaRepository {
-	return &AreaRepository{db: db, changeLog: changeLog}
+	return &AreaRepository{db: db, reader: reader{db}, changeLog: changeLog}
+}
+
+// WithChangeLog returns a copy of the repository that records its changes in cl.
+func (r *AreaRepository) WithChangeLog(cl *ChangeLogRepository) *AreaRepository {
+	c := *r
+	c.changeLog = cl
+	return &c
+}
+
+// WithTx returns a copy of the repository whose statements, reads included,
+// run in tx.
+func (r *AreaRepository) WithTx(tx *sql.Tx) *AreaRepository {
+	c := *r
+	c.db, c.read = tx, tx
+	return &c
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *AreaRepository) WithContext(ctx context.Context) *AreaRepository {
+	c := *r
+	c.db, c.read = withContext(r.db, ctx), withContext(r.read, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
 }
 
 func (r *AreaRepository) List() ([]model.Area, error) {
-	rows, err := r.db.Query(`
+	rows, err := r.read.Query(`
 		SELECT a.id, a.title, a.sort_order, a.created_at, a.updated_at,
 			COALESCE((SELECT COUNT(*) FROM projects WHERE area_id = a.id), 0),
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE area_id = a.id AND deleted_at IS NULL), 0),
@@ -46,7 +74,7 @@ func (r *AreaRepository) List() ([]model.Area, error) {
 
 func (r *AreaRepository) GetByID(id string) (*model.AreaDetail, error) {
 	var a model.AreaDetail
-	err := r.db.QueryRow(
+	err := r.read.QueryRow(
 		"SELECT id, title, sort_order, created_at, updated_at FROM areas WHERE id = ?", id,
 	).Scan(&a.ID, &a.Title, &a.SortOrder, &a.CreatedAt, &a.UpdatedAt)
 	if err == sql.ErrNoRows {
@@ -57,7 +85,7 @@ func (r *AreaRepository) GetByID(id string) (*model.AreaDetail, error) {
 	}
 
 	// Projects in this area
-	projRows, err := r.db.Query(`
+	projRows, err := r.read.Query(`
 		SELECT p.id, p.title, p.notes, p.area_id, p.status, p.when_date, p.deadline,
 			p.sort_order, p.created_at, p.updated_at,
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE project_id = p.id AND deleted_at IS NULL), 0),
@@ -75,16 +103,18 @@ func (r *AreaRepository) GetByID(id string) (*model.AreaDetail, error) {
 			&p.SortOrder, &p.CreatedAt, &p.UpdatedAt, &p.TaskCount, &p.CompletedTaskCount); err != nil {
 			return nil, fmt.Errorf("scan project: %w", err)
 		}
-		p.Tags = getProjectTags(r.db, p.ID)
 		projects = append(projects, p)
 	}
+	for i := range projects {
+		projects[i].Tags = getProjectTags(r.read, projects[i].ID)
+	}
 	if projects == nil {
 		projects = []model.ProjectListItem{}
 	}
 	a.Projects = projects
 
 	// Standalone tasks in this area (no project)
-	taskRows, err := r.db.Query(`
+	taskRows, err := r.read.Query(`
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
@@ -107,12 +137,12 @@ func (r *AreaRepository) GetByID(id string) (*model.AreaDetail, error) {
 		return nil, err
 	}
 	defer taskRows.Close()
-	a.Tasks = scanTaskListItems(r.db, taskRows)
-	populateActionableScheduleFlags(r.db, a.Tasks)
+	a.Tasks = scanTaskListItems(r.read, taskRows)
+	populateActionableScheduleFlags(r.read, a.Tasks)
 
 	// Completed standalone tasks (today only)
 	today := time.Now().Format("2006-01-02")
-	completedRows, err := r.db.Query(`
+	completedRows, err := r.read.Query(`
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
@@ -139,7 +169,7 @@ func (r *AreaRepository) GetByID(id string) (*model.AreaDetail, error) {
 		return nil, err
 	}
 	defer completedRows.Close()
-	a.CompletedTasks = scanTaskListItems(r.db, completedRows)
+	a.CompletedTasks = scanTaskListItems(r.read, completedRows)
 
 	return &a, nil
 }
@@ -201,7 +231,7 @@ func (r *AreaRepository) Delete(id string) error {
 }
 
 func (r *AreaRepository) Reorder(items []model.SimpleReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -250,7 +280,7 @@ func (r *AreaRepository) DeleteWithTasks(id string) error {
 	}
 	rows.Close()
 
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
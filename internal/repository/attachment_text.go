@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// Outcomes of reading an attachment's text.
+const (
+	AttachmentTextIndexed     = "indexed"
+	AttachmentTextUnsupported = "unsupported"
+	AttachmentTextFailed      = "failed"
+)
+
+// AttachmentTextRepository stores text extracted from uploaded files for
+// search.
+type AttachmentTextRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentTextRepository(db *sql.DB) *AttachmentTextRepository {
+	return &AttachmentTextRepository{db: db}
+}
+
+// ListPending returns file attachments whose text hasn't been extracted yet,
+// oldest first. Client-encrypted files are left out; the server can't read them.
+func (r *AttachmentTextRepository) ListPending(limit int) ([]model.Attachment, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.task_id, a.type, a.title, a.url, a.mime_type, a.file_size, a.encrypted, a.sort_order, a.created_at
+		FROM attachments a
+		WHERE a.type = 'file' AND a.encrypted = 0
+			AND NOT EXISTS (SELECT 1 FROM attachment_text x WHERE x.attachment_id = a.id)
+		ORDER BY a.created_at, a.id
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.Attachment
+	for rows.Next() {
+		var a model.Attachment
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// Save records the outcome for an attachment, replacing any earlier text.
+// text is indexed only when status is AttachmentTextIndexed.
+func (r *AttachmentTextRepository) Save(attachmentID, status, text, errMsg string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM attachment_text WHERE attachment_id = ?", attachmentID); err != nil {
+		return fmt.Errorf("clear attachment text: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO attachment_text (attachment_id, status, error) VALUES (?, ?, ?)",
+		attachmentID, status, errMsg); err != nil {
+		return fmt.Errorf("save attachment text: %w", err)
+	}
+	if status == AttachmentTextIndexed && text != "" {
+		if _, err := tx.Exec("INSERT INTO attachment_text_fts (attachment_id, body) VALUES (?, ?)", attachmentID, text); err != nil {
+			return fmt.Errorf("index attachment text: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Status returns the outcome recorded for an attachment, or "" if it hasn't
+// been read yet.
+func (r *AttachmentTextRepository) Status(attachmentID string) (string, error) {
+	var status string
+	err := r.db.QueryRow("SELECT status FROM attachment_text WHERE attachment_id = ?", attachmentID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// Reset forgets all extracted text so every file is read again. It returns
+// how many attachments are now waiting.
+func (r *AttachmentTextRepository) Reset() (int, error) {
+	if _, err := r.db.Exec("DELETE FROM attachment_text"); err != nil {
+		return 0, fmt.Errorf("reset attachment text: %w", err)
+	}
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM attachments WHERE type = 'file' AND encrypted = 0").Scan(&n)
+	return n, err
+}
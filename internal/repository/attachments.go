@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type AttachmentRepository struct {
-	db        *sql.DB
+	db        querier
 	changeLog *ChangeLogRepository
 }
 
@@ -16,9 +17,20 @@ func NewAttachmentRepository(db *sql.DB, changeLog *ChangeLogRepository) *Attach
 	return &AttachmentRepository{db: db, changeLog: changeLog}
 }
 
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *AttachmentRepository) WithContext(ctx context.Context) *AttachmentRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
 func (r *AttachmentRepository) ListByTask(taskID string) ([]model.Attachment, error) {
 	rows, err := r.db.Query(
-		"SELECT id, type, title, url, mime_type, file_size, sort_order, created_at FROM attachments WHERE task_id = ? ORDER BY sort_order", taskID)
+		"SELECT id, type, title, url, mime_type, file_size, encrypted, sort_order, created_at FROM attachments WHERE task_id = ? ORDER BY sort_order", taskID)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +39,7 @@ func (r *AttachmentRepository) ListByTask(taskID string) ([]model.Attachment, er
 	var items []model.Attachment
 	for rows.Next() {
 		var a model.Attachment
-		if err := rows.Scan(&a.ID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.SortOrder, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan attachment: %w", err)
 		}
 		items = append(items, a)
@@ -41,7 +53,30 @@ func (r *AttachmentRepository) ListByTask(taskID string) ([]model.Attachment, er
 // ListAll returns all attachments across all tasks.
 func (r *AttachmentRepository) ListAll() ([]model.Attachment, error) {
 	rows, err := r.db.Query(
-		"SELECT id, task_id, type, title, url, mime_type, file_size, sort_order, created_at FROM attachments ORDER BY sort_order")
+		"SELECT id, task_id, type, title, url, mime_type, file_size, encrypted, sort_order, created_at FROM attachments ORDER BY sort_order")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.Attachment
+	for rows.Next() {
+		var a model.Attachment
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan attachment: %w", err)
+		}
+		items = append(items, a)
+	}
+	if items == nil {
+		items = []model.Attachment{}
+	}
+	return items, rows.Err()
+}
+
+// ListPage returns attachments ordered by ID, starting after afterID.
+func (r *AttachmentRepository) ListPage(afterID string, limit int) ([]model.Attachment, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, type, title, url, mime_type, file_size, encrypted, sort_order, created_at FROM attachments WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +85,7 @@ func (r *AttachmentRepository) ListAll() ([]model.Attachment, error) {
 	var items []model.Attachment
 	for rows.Next() {
 		var a model.Attachment
-		if err := rows.Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.SortOrder, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan attachment: %w", err)
 		}
 		items = append(items, a)
@@ -64,8 +99,8 @@ func (r *AttachmentRepository) ListAll() ([]model.Attachment, error) {
 func (r *AttachmentRepository) GetByID(id string) (*model.Attachment, error) {
 	var a model.Attachment
 	err := r.db.QueryRow(
-		"SELECT id, task_id, type, title, url, mime_type, file_size, sort_order, created_at FROM attachments WHERE id = ?", id,
-	).Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.SortOrder, &a.CreatedAt)
+		"SELECT id, task_id, type, title, url, mime_type, file_size, encrypted, sort_order, created_at FROM attachments WHERE id = ?", id,
+	).Scan(&a.ID, &a.TaskID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -83,9 +118,9 @@ func (r *AttachmentRepository) Create(taskID string, input model.CreateAttachmen
 	var maxSort float64
 	_ = r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) FROM attachments WHERE task_id = ?", taskID).Scan(&maxSort)
 
-	_, err := r.db.Exec(`INSERT INTO attachments (id, task_id, type, title, url, mime_type, file_size, sort_order)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		id, taskID, input.Type, input.Title, input.URL, input.MimeType, input.FileSize, maxSort+1024)
+	_, err := r.db.Exec(`INSERT INTO attachments (id, task_id, type, title, url, mime_type, file_size, encrypted, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, taskID, input.Type, input.Title, input.URL, input.MimeType, input.FileSize, boolToInt(input.Encrypted), maxSort+1024)
 	if err != nil {
 		return nil, fmt.Errorf("create attachment: %w", err)
 	}
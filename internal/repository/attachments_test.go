@@ -148,3 +148,30 @@ func TestAttachmentEmptyList(t *testing.T) {
 		t.Errorf("expected 0 items, got %d", len(items))
 	}
 }
+
+func TestAttachmentCreateEncryptedFile(t *testing.T) {
+	repo, taskID := setupTaskForAttachments(t)
+
+	att, err := repo.Create(taskID, model.CreateAttachmentInput{
+		Type:      "file",
+		Title:     "scan.pdf",
+		URL:       "/attachments/abc123.bin",
+		MimeType:  "application/pdf",
+		FileSize:  2048,
+		Encrypted: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if !att.Encrypted {
+		t.Error("expected encrypted=true")
+	}
+
+	list, err := repo.ListByTask(taskID)
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	if len(list) != 1 || !list[0].Encrypted {
+		t.Errorf("expected encrypted flag in list, got %+v", list)
+	}
+}
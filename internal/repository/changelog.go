@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"strings"
 )
 
 // ChangeLogEntry represents a single entry in the change_log table.
@@ -19,7 +21,9 @@ type ChangeLogEntry struct {
 
 // ChangeLogRepository provides access to the change_log table.
 type ChangeLogRepository struct {
-	db *sql.DB
+	db querier
+	// undo marks each appended entry as written by an undo.
+	undo bool
 }
 
 // NewChangeLogRepository creates a new ChangeLogRepository.
@@ -27,22 +31,67 @@ func NewChangeLogRepository(db *sql.DB) *ChangeLogRepository {
 	return &ChangeLogRepository{db: db}
 }
 
+// ForUndo returns a ChangeLogRepository that marks every entry it appends as
+// written by an undo, so a later undo skips it instead of redoing the reversed
+// change. Changes logged elsewhere at the same time stay undoable.
+func (r *ChangeLogRepository) ForUndo() *ChangeLogRepository {
+	return &ChangeLogRepository{db: r.db, undo: true}
+}
+
+// WithTx returns a copy of the repository whose statements run in tx.
+func (r *ChangeLogRepository) WithTx(tx *sql.Tx) *ChangeLogRepository {
+	c := *r
+	c.db = tx
+	return &c
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ChangeLogRepository) WithContext(ctx context.Context) *ChangeLogRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	return &c
+}
+
+// Begin starts a transaction for changes that must be applied and logged
+// together. Bind repositories to it with WithTx.
+func (r *ChangeLogRepository) Begin() (*sql.Tx, error) {
+	return beginTx(r.db)
+}
+
 // AppendChange inserts a new entry into the change log and returns its seq.
 func (r *ChangeLogRepository) AppendChange(entity, entityID, action string, fields *string, snapshot, userID, deviceID string) (int64, error) {
-	result, err := r.db.Exec(
-		`INSERT INTO change_log (entity, entity_id, action, fields, snapshot, user_id, device_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		entity, entityID, action, fields, snapshot, nullableString(userID), nullableString(deviceID),
+	tx, err := begin(r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The entity's latest snapshot is the state this change replaces.
+	result, err := tx.Exec(
+		`INSERT INTO change_log (entity, entity_id, action, fields, snapshot, user_id, device_id, previous_snapshot)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, (SELECT snapshot FROM change_log WHERE entity = ? AND entity_id = ? ORDER BY seq DESC LIMIT 1))`,
+		entity, entityID, action, fields, snapshot, nullableString(userID), nullableString(deviceID), entity, entityID,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if r.undo {
+		if _, err := tx.Exec(`INSERT INTO change_log_undo (seq, kind) VALUES (?, 'undo')`, seq); err != nil {
+			return 0, err
+		}
+	}
+	return seq, tx.Commit()
 }
 
 // GetChangesSince returns entries with seq > sinceSeq, ordered by seq ASC, up to limit entries.
 func (r *ChangeLogRepository) GetChangesSince(sinceSeq int64, limit int) ([]ChangeLogEntry, error) {
 	rows, err := r.db.Query(
-		`SELECT seq, entity, entity_id, action, fields, snapshot, COALESCE(user_id, ''), COALESCE(device_id, ''), created_at
+		`SELECT `+changeLogColumns+`
 		 FROM change_log
 		 WHERE seq > ?
 		 ORDER BY seq ASC
@@ -53,22 +102,7 @@ func (r *ChangeLogRepository) GetChangesSince(sinceSeq int64, limit int) ([]Chan
 		return nil, err
 	}
 	defer rows.Close()
-
-	var entries []ChangeLogEntry
-	for rows.Next() {
-		var e ChangeLogEntry
-		if err := rows.Scan(&e.Seq, &e.Entity, &e.EntityID, &e.Action, &e.Fields, &e.Snapshot, &e.UserID, &e.DeviceID, &e.CreatedAt); err != nil {
-			return nil, err
-		}
-		entries = append(entries, e)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	if entries == nil {
-		entries = []ChangeLogEntry{}
-	}
-	return entries, nil
+	return scanChangeLogEntries(rows)
 }
 
 // GetLatestSeq returns the highest seq in the change log, or 0 if the table is empty.
@@ -97,18 +131,178 @@ func (r *ChangeLogRepository) GetOldestSeq() (int64, error) {
 	return seq.Int64, nil
 }
 
+// GetPurgedThroughSeq returns the highest seq removed by PurgeOlderThan, or 0 if
+// nothing has been purged. Cursors below this value can no longer be served.
+func (r *ChangeLogRepository) GetPurgedThroughSeq() (int64, error) {
+	var seq int64
+	err := r.db.QueryRow(`SELECT purged_through_seq FROM change_log_state WHERE id = 1`).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
 // PurgeOlderThan deletes entries older than the given number of days and returns the count deleted.
+// The highest deleted seq is recorded so pulls with an older cursor are reported as expired.
 func (r *ChangeLogRepository) PurgeOlderThan(days int) (int64, error) {
-	result, err := r.db.Exec(
+	tx, err := begin(r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var maxSeq sql.NullInt64
+	if err := tx.QueryRow(
+		`SELECT MAX(seq) FROM change_log WHERE created_at < datetime('now', ? || ' days')`,
+		-days,
+	).Scan(&maxSeq); err != nil {
+		return 0, err
+	}
+	if !maxSeq.Valid {
+		return 0, nil
+	}
+
+	result, err := tx.Exec(
 		`DELETE FROM change_log WHERE created_at < datetime('now', ? || ' days')`,
 		-days,
 	)
 	if err != nil {
 		return 0, err
 	}
+	if _, err := tx.Exec(
+		`UPDATE change_log_state SET purged_through_seq = MAX(purged_through_seq, ?) WHERE id = 1`,
+		maxSeq.Int64,
+	); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 	return result.RowsAffected()
 }
 
+// CompactOlderThan removes entries older than the given number of days that are
+// superseded by a later entry for the same (entity, entity_id), and returns the
+// count deleted. Every snapshot is a full entity state, so a client pulling from
+// any cursor still converges on the current state; it just skips intermediate
+// revisions. Compaction therefore never expires cursors, and undo keeps
+// working since each entry records the snapshot it replaced.
+func (r *ChangeLogRepository) CompactOlderThan(days int) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM change_log
+		 WHERE created_at < datetime('now', ? || ' days')
+		   AND seq < (SELECT MAX(c2.seq) FROM change_log c2
+		              WHERE c2.entity = change_log.entity AND c2.entity_id = change_log.entity_id)`,
+		-days,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const changeLogColumns = `seq, entity, entity_id, action, fields, snapshot, COALESCE(user_id, ''), COALESCE(device_id, ''), created_at`
+
+func scanChangeLogEntries(rows *sql.Rows) ([]ChangeLogEntry, error) {
+	var entries []ChangeLogEntry
+	for rows.Next() {
+		var e ChangeLogEntry
+		if err := rows.Scan(&e.Seq, &e.Entity, &e.EntityID, &e.Action, &e.Fields, &e.Snapshot, &e.UserID, &e.DeviceID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []ChangeLogEntry{}
+	}
+	return entries, nil
+}
+
+// GetEntityHistory returns the entries for one entity, newest first, up to limit entries.
+func (r *ChangeLogRepository) GetEntityHistory(entity, entityID string, limit int) ([]ChangeLogEntry, error) {
+	rows, err := r.db.Query(
+		`SELECT `+changeLogColumns+`
+		 FROM change_log
+		 WHERE entity = ? AND entity_id = ?
+		 ORDER BY seq DESC
+		 LIMIT ?`,
+		entity, entityID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanChangeLogEntries(rows)
+}
+
+// GetEntry returns the entry with the given seq, or nil if it does not exist.
+func (r *ChangeLogRepository) GetEntry(seq int64) (*ChangeLogEntry, error) {
+	rows, err := r.db.Query(`SELECT `+changeLogColumns+` FROM change_log WHERE seq = ?`, seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries, err := scanChangeLogEntries(rows)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// GetPreviousSnapshot returns the snapshot the entity had before the entry
+// with the given seq, as recorded with the entry, or nil if none was logged
+// (the entity did not exist yet, or its history had been purged).
+func (r *ChangeLogRepository) GetPreviousSnapshot(seq int64) (*string, error) {
+	var snap sql.NullString
+	err := r.db.QueryRow(`SELECT previous_snapshot FROM change_log WHERE seq = ?`, seq).Scan(&snap)
+	if err == sql.ErrNoRows || !snap.Valid {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snap.String, nil
+}
+
+// ListUndoable returns the most recent entries for the given entity types that
+// have not been undone and were not written by an undo, newest first.
+func (r *ChangeLogRepository) ListUndoable(entities []string, limit int) ([]ChangeLogEntry, error) {
+	if len(entities) == 0 {
+		return []ChangeLogEntry{}, nil
+	}
+	placeholders := strings.Repeat("?,", len(entities))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(entities)+1)
+	for _, e := range entities {
+		args = append(args, e)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(
+		`SELECT `+changeLogColumns+`
+		 FROM change_log
+		 WHERE entity IN (`+placeholders+`)
+		   AND seq NOT IN (SELECT seq FROM change_log_undo)
+		 ORDER BY seq DESC
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanChangeLogEntries(rows)
+}
+
+// MarkUndone records that the entry with the given seq has been reversed.
+func (r *ChangeLogRepository) MarkUndone(seq int64) error {
+	_, err := r.db.Exec(`INSERT OR IGNORE INTO change_log_undo (seq, kind) VALUES (?, 'undone')`, seq)
+	return err
+}
+
 // nullableString converts an empty string to nil for nullable SQL columns.
 func nullableString(s string) interface{} {
 	if s == "" {
@@ -182,3 +182,136 @@ func TestChangeLogRepository_PurgeOlderThan_NothingToDelete(t *testing.T) {
 		t.Errorf("expected 0 deleted, got %d", deleted)
 	}
 }
+
+func TestChangeLogRepository_PurgeOlderThan_RecordsWatermark(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewChangeLogRepository(db)
+
+	res, err := db.Exec(`INSERT INTO change_log (entity, entity_id, action, snapshot, created_at) VALUES ('task', 'old1', 'create', '{}', datetime('now', '-10 days'))`)
+	if err != nil {
+		t.Fatalf("failed to insert old entry: %v", err)
+	}
+	oldSeq, _ := res.LastInsertId()
+	repo.AppendChange("task", "new1", "create", nil, `{}`, "", "") //nolint:errcheck
+
+	if _, err := repo.PurgeOlderThan(7); err != nil {
+		t.Fatalf("PurgeOlderThan failed: %v", err)
+	}
+
+	purged, err := repo.GetPurgedThroughSeq()
+	if err != nil {
+		t.Fatalf("GetPurgedThroughSeq failed: %v", err)
+	}
+	if purged != oldSeq {
+		t.Errorf("expected purged-through seq %d, got %d", oldSeq, purged)
+	}
+}
+
+func TestChangeLogRepository_CompactOlderThan(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewChangeLogRepository(db)
+
+	for _, id := range []string{"a", "a", "b"} {
+		_, err := db.Exec(`INSERT INTO change_log (entity, entity_id, action, snapshot, created_at) VALUES ('task', ?, 'update', '{}', datetime('now', '-10 days'))`, id)
+		if err != nil {
+			t.Fatalf("failed to insert old entry: %v", err)
+		}
+	}
+	// A recent change supersedes the old "b" entry but must itself survive.
+	repo.AppendChange("task", "b", "update", nil, `{}`, "", "") //nolint:errcheck
+	// Same entity ID under a different entity type is tracked separately.
+	repo.AppendChange("project", "a", "update", nil, `{}`, "", "") //nolint:errcheck
+
+	deleted, err := repo.CompactOlderThan(7)
+	if err != nil {
+		t.Fatalf("CompactOlderThan failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 compacted, got %d", deleted)
+	}
+
+	entries, _ := repo.GetChangesSince(0, 100)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 remaining entries, got %d", len(entries))
+	}
+
+	purged, _ := repo.GetPurgedThroughSeq()
+	if purged != 0 {
+		t.Errorf("compaction must not advance the purge watermark, got %d", purged)
+	}
+}
+
+func TestChangeLogRepository_GetEntityHistory(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewChangeLogRepository(db)
+
+	first, _ := repo.AppendChange("task", "a", "create", nil, `{"id":"a"}`, "", "")
+	repo.AppendChange("task", "b", "create", nil, `{"id":"b"}`, "", "") //nolint:errcheck
+	second, _ := repo.AppendChange("task", "a", "update", nil, `{"id":"a"}`, "", "")
+
+	entries, err := repo.GetEntityHistory("task", "a", 10)
+	if err != nil {
+		t.Fatalf("GetEntityHistory failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Seq != second || entries[1].Seq != first {
+		t.Fatalf("expected seqs [%d %d] newest first, got %+v", second, first, entries)
+	}
+
+}
+
+func TestChangeLogRepository_GetPreviousSnapshot(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewChangeLogRepository(db)
+
+	first, _ := repo.AppendChange("task", "a", "create", nil, `{"title":"v1"}`, "", "")
+	repo.AppendChange("task", "b", "create", nil, `{"title":"other"}`, "", "") //nolint:errcheck
+	second, _ := repo.AppendChange("task", "a", "update", nil, `{"title":"v2"}`, "", "")
+	if prev, _ := repo.GetPreviousSnapshot(first); prev != nil {
+		t.Errorf("expected no snapshot before the create, got %s", *prev)
+	}
+	// The recorded snapshot survives compaction of the entry it came from.
+	if _, err := db.Exec(`UPDATE change_log SET created_at = datetime('now', '-10 days')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CompactOlderThan(7); err != nil {
+		t.Fatal(err)
+	}
+
+	prev, err := repo.GetPreviousSnapshot(second)
+	if err != nil {
+		t.Fatalf("GetPreviousSnapshot failed: %v", err)
+	}
+	if prev == nil || *prev != `{"title":"v1"}` {
+		t.Errorf("expected the v1 snapshot, got %v", prev)
+	}
+}
+
+func TestChangeLogRepository_ListUndoable(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewChangeLogRepository(db)
+
+	first, _ := repo.AppendChange("task", "a", "create", nil, `{}`, "", "")
+	repo.AppendChange("tag", "t", "create", nil, `{}`, "", "") //nolint:errcheck
+	second, _ := repo.AppendChange("task", "a", "update", nil, `{}`, "", "")
+
+	entities := []string{"task"}
+	entries, _ := repo.ListUndoable(entities, 10)
+	if len(entries) != 2 || entries[0].Seq != second {
+		t.Fatalf("expected 2 task entries newest first, got %+v", entries)
+	}
+
+	// Undo the update; the change it writes must not become undoable, but a
+	// change logged elsewhere while the undo runs must.
+	if err := repo.MarkUndone(second); err != nil {
+		t.Fatalf("MarkUndone failed: %v", err)
+	}
+	if _, err := repo.ForUndo().AppendChange("task", "a", "update", nil, `{}`, "", ""); err != nil {
+		t.Fatalf("AppendChange failed: %v", err)
+	}
+	concurrent, _ := repo.AppendChange("task", "b", "update", nil, `{}`, "", "")
+
+	entries, _ = repo.ListUndoable(entities, 10)
+	if len(entries) != 2 || entries[0].Seq != concurrent || entries[1].Seq != first {
+		t.Errorf("expected the concurrent change and the create to remain undoable, got %+v", entries)
+	}
+}
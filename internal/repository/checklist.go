@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type ChecklistRepository struct {
-	db        *sql.DB
+	db        querier
 	changeLog *ChangeLogRepository
 }
 
@@ -16,6 +17,31 @@ func NewChecklistRepository(db *sql.DB, changeLog *ChangeLogRepository) *Checkli
 	return &ChecklistRepository{db: db, changeLog: changeLog}
 }
 
+// WithChangeLog returns a copy of the repository that records its changes in cl.
+func (r *ChecklistRepository) WithChangeLog(cl *ChangeLogRepository) *ChecklistRepository {
+	c := *r
+	c.changeLog = cl
+	return &c
+}
+
+// WithTx returns a copy of the repository whose statements run in tx.
+func (r *ChecklistRepository) WithTx(tx *sql.Tx) *ChecklistRepository {
+	c := *r
+	c.db = tx
+	return &c
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ChecklistRepository) WithContext(ctx context.Context) *ChecklistRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
 func (r *ChecklistRepository) ListByTask(taskID string) ([]model.ChecklistItem, error) {
 	rows, err := r.db.Query(
 		"SELECT id, title, completed, sort_order FROM checklist_items WHERE task_id = ? ORDER BY sort_order", taskID)
@@ -65,6 +91,46 @@ func (r *ChecklistRepository) ListAll() ([]model.ChecklistItem, error) {
 	return items, rows.Err()
 }
 
+// ListPage returns checklist items ordered by ID, starting after afterID.
+func (r *ChecklistRepository) ListPage(afterID string, limit int) ([]model.ChecklistItem, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, title, completed, sort_order FROM checklist_items WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.ChecklistItem
+	for rows.Next() {
+		var c model.ChecklistItem
+		var completed int
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &completed, &c.SortOrder); err != nil {
+			return nil, fmt.Errorf("scan checklist item: %w", err)
+		}
+		c.Completed = completed == 1
+		items = append(items, c)
+	}
+	if items == nil {
+		items = []model.ChecklistItem{}
+	}
+	return items, rows.Err()
+}
+
+func (r *ChecklistRepository) GetByID(id string) (*model.ChecklistItem, error) {
+	var c model.ChecklistItem
+	var completed int
+	err := r.db.QueryRow("SELECT id, task_id, title, completed, sort_order FROM checklist_items WHERE id = ?", id).
+		Scan(&c.ID, &c.TaskID, &c.Title, &completed, &c.SortOrder)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Completed = completed == 1
+	return &c, nil
+}
+
 func (r *ChecklistRepository) Create(taskID string, input model.CreateChecklistInput) (*model.ChecklistItem, error) {
 	id := input.ID
 	if id == "" {
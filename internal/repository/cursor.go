@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// defaultPageLimit is the page size of a list that is paged with a cursor
+// but no limit.
+const defaultPageLimit = 100
+
+// cursorPayload is what a cursor holds: the list it belongs to and the sort
+// key of the last row of the page before.
+type cursorPayload struct {
+	Kind string          `json:"k"`
+	Key  json.RawMessage `json:"v"`
+}
+
+// encodeCursor packs the sort key of the last row of a page into an opaque
+// cursor for the next page of the kind of list.
+func encodeCursor(kind string, key interface{}) string {
+	raw, _ := json.Marshal(key)
+	b, _ := json.Marshal(cursorPayload{Kind: kind, Key: raw})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor unpacks a cursor made by encodeCursor for the same kind of
+// list into key. Anything else is ErrInvalidCursor.
+func decodeCursor(kind, cursor string, key interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var p cursorPayload
+	if err := json.Unmarshal(b, &p); err != nil || p.Kind != kind {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(p.Key, key); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// keyTerm is one term of an ORDER BY clause.
+type keyTerm struct {
+	expr string
+	desc bool
+}
+
+// keyset pages through rows in the order of an ORDER BY clause: each page
+// starts after the sort key of the last row of the one before, so pages stay
+// cheap and stable while rows are added or removed.
+type keyset struct {
+	kind  string
+	terms []keyTerm
+}
+
+// newKeyset splits an ORDER BY clause over tasks aliased t into its terms,
+// adding t.id to make the order total.
+func newKeyset(kind, order string) keyset {
+	k := keyset{kind: kind}
+	depth, start := 0, 0
+	for i := 0; i <= len(order); i++ {
+		if i < len(order) {
+			switch order[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if order[i] != ',' || depth > 0 {
+				continue
+			}
+		}
+		term := keyTerm{expr: strings.TrimSpace(order[start:i])}
+		switch upper := strings.ToUpper(term.expr); {
+		case strings.HasSuffix(upper, " DESC"):
+			term.expr, term.desc = strings.TrimSpace(term.expr[:len(term.expr)-5]), true
+		case strings.HasSuffix(upper, " ASC"):
+			term.expr = strings.TrimSpace(term.expr[:len(term.expr)-4])
+		}
+		k.terms = append(k.terms, term)
+		start = i + 1
+	}
+	if k.terms[len(k.terms)-1].expr != "t.id" {
+		k.terms = append(k.terms, keyTerm{expr: "t.id"})
+	}
+	return k
+}
+
+// orderBy returns the ORDER BY clause of the keyset.
+func (k keyset) orderBy() string {
+	order := make([]string, len(k.terms))
+	for i, term := range k.terms {
+		order[i] = term.expr
+		if term.desc {
+			order[i] += " DESC"
+		}
+	}
+	return strings.Join(order, ", ")
+}
+
+// after returns the condition, preceded by AND, that keeps the tasks after
+// the cursor, or "" for the first page. NULLs sort first, as in SQLite.
+func (k keyset) after(cursor string) (string, []interface{}, error) {
+	if cursor == "" {
+		return "", nil, nil
+	}
+	var values []interface{}
+	if err := decodeCursor(k.kind, cursor, &values); err != nil {
+		return "", nil, err
+	}
+	if len(values) != len(k.terms) {
+		return "", nil, ErrInvalidCursor
+	}
+	cond, args := k.condition(values)
+	return " AND " + cond, args, nil
+}
+
+// condition returns the condition that keeps the rows whose sort key comes
+// after values.
+func (k keyset) condition(values []interface{}) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for i, term := range k.terms {
+		var conds []string
+		for j := 0; j < i; j++ {
+			conds = append(conds, k.terms[j].expr+" IS ?")
+			args = append(args, values[j])
+		}
+		if term.desc {
+			conds = append(conds, "("+term.expr+" < ? OR ("+term.expr+" IS NULL AND ? IS NOT NULL))")
+		} else {
+			conds = append(conds, "("+term.expr+" > ? OR ("+term.expr+" IS NOT NULL AND ? IS NULL))")
+		}
+		args = append(args, values[i], values[i])
+		alternatives = append(alternatives, "("+strings.Join(conds, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// next returns the cursor of the page after the one ending with task
+// lastID.
+func (k keyset) next(db querier, lastID string) (string, error) {
+	exprs := make([]string, len(k.terms))
+	values := make([]interface{}, len(k.terms))
+	dest := make([]interface{}, len(k.terms))
+	for i, term := range k.terms {
+		exprs[i] = term.expr
+		dest[i] = &values[i]
+	}
+	err := db.QueryRow("SELECT "+strings.Join(exprs, ", ")+" FROM tasks t WHERE t.id = ?", lastID).Scan(dest...)
+	if err != nil {
+		return "", err
+	}
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+	return encodeCursor(k.kind, values), nil
+}
+
+// pageSize is the number of rows a page holds, or 0 for the whole list.
+func pageSize(page model.Page) int {
+	if page.Limit <= 0 && page.Cursor != "" {
+		return defaultPageLimit
+	}
+	return page.Limit
+}
+
+// limitClause returns the LIMIT clause for a page of size rows. It fetches
+// one row more, to tell whether another page follows.
+func limitClause(size int) string {
+	if size <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", size+1)
+}
+
+// trimPage cuts the extra row limitClause fetched off tasks and returns the
+// cursor of the page after them, or "" if they are the last.
+func (k keyset) trimPage(db querier, tasks []model.TaskListItem, size int) ([]model.TaskListItem, string, error) {
+	if size <= 0 || len(tasks) <= size {
+		return tasks, "", nil
+	}
+	tasks = tasks[:size]
+	next, err := k.next(db, tasks[size-1].ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("page cursor: %w", err)
+	}
+	return tasks, next, nil
+}
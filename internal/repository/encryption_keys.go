@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+type EncryptionKeyRepository struct {
+	db *sql.DB
+}
+
+func NewEncryptionKeyRepository(db *sql.DB) *EncryptionKeyRepository {
+	return &EncryptionKeyRepository{db: db}
+}
+
+// Get returns the user's wrapped key, or nil if encryption is not set up.
+func (r *EncryptionKeyRepository) Get(userID string) (*model.EncryptionKey, error) {
+	var k model.EncryptionKey
+	err := r.db.QueryRow(
+		`SELECT algorithm, kdf, kdf_iterations, salt, wrapped_key, created_at, updated_at
+		 FROM encryption_keys WHERE user_id = ?`, userID,
+	).Scan(&k.Algorithm, &k.KDF, &k.KDFIterations, &k.Salt, &k.WrappedKey, &k.CreatedAt, &k.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get encryption key: %w", err)
+	}
+	return &k, nil
+}
+
+// Put stores the user's wrapped key, replacing any previous one. Re-wrapping
+// the same data key under a new passphrase goes through here too.
+func (r *EncryptionKeyRepository) Put(userID string, k model.EncryptionKey) (*model.EncryptionKey, error) {
+	_, err := r.db.Exec(
+		`INSERT INTO encryption_keys (user_id, algorithm, kdf, kdf_iterations, salt, wrapped_key)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(user_id) DO UPDATE SET
+		   algorithm = excluded.algorithm,
+		   kdf = excluded.kdf,
+		   kdf_iterations = excluded.kdf_iterations,
+		   salt = excluded.salt,
+		   wrapped_key = excluded.wrapped_key,
+		   updated_at = datetime('now')`,
+		userID, k.Algorithm, k.KDF, k.KDFIterations, k.Salt, k.WrappedKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("put encryption key: %w", err)
+	}
+	return r.Get(userID)
+}
@@ -0,0 +1,40 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+func TestEncryptionKeyPutAndGet(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	user, err := repository.NewUserRepository(db).Create("admin", "hash")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	repo := repository.NewEncryptionKeyRepository(db)
+
+	key, err := repo.Get(user.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if key != nil {
+		t.Fatalf("expected no key before setup, got %+v", key)
+	}
+
+	first := model.EncryptionKey{Algorithm: "aes-256-gcm", KDF: "pbkdf2-sha256", KDFIterations: 600000, Salt: "c2FsdA==", WrappedKey: "a2V5MQ=="}
+	if _, err := repo.Put(user.ID, first); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	second := first
+	second.WrappedKey = "a2V5Mg=="
+	key, err = repo.Put(user.ID, second)
+	if err != nil {
+		t.Fatalf("second put failed: %v", err)
+	}
+	if key.WrappedKey != "a2V5Mg==" {
+		t.Errorf("expected wrapped key replaced, got %q", key.WrappedKey)
+	}
+}
@@ -6,3 +6,7 @@ var ErrDuplicateProjectName = fmt.Errorf("duplicate project name")
 var ErrDuplicateAreaName = fmt.Errorf("duplicate area name")
 var ErrDuplicateTagName = fmt.Errorf("duplicate tag name")
 var ErrSavedFilterLimitReached = fmt.Errorf("saved filter limit reached")
+var ErrSynonymGroupNotFound = fmt.Errorf("synonym group not found")
+var ErrDuplicateSmartListName = fmt.Errorf("duplicate smart list name")
+var ErrSmartListNotFound = fmt.Errorf("smart list not found")
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
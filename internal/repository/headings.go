@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type HeadingRepository struct {
-	db        *sql.DB
+	db        querier
 	changeLog *ChangeLogRepository
 }
 
@@ -16,6 +17,17 @@ func NewHeadingRepository(db *sql.DB, changeLog *ChangeLogRepository) *HeadingRe
 	return &HeadingRepository{db: db, changeLog: changeLog}
 }
 
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *HeadingRepository) WithContext(ctx context.Context) *HeadingRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
 func (r *HeadingRepository) ListByProject(projectID string) ([]model.Heading, error) {
 	rows, err := r.db.Query(
 		"SELECT id, title, project_id, sort_order FROM headings WHERE project_id = ? ORDER BY sort_order", projectID)
@@ -61,6 +73,29 @@ func (r *HeadingRepository) ListAll() ([]model.Heading, error) {
 	return headings, rows.Err()
 }
 
+// ListPage returns headings ordered by ID, starting after afterID.
+func (r *HeadingRepository) ListPage(afterID string, limit int) ([]model.Heading, error) {
+	rows, err := r.db.Query(
+		"SELECT id, title, project_id, sort_order FROM headings WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var headings []model.Heading
+	for rows.Next() {
+		var h model.Heading
+		if err := rows.Scan(&h.ID, &h.Title, &h.ProjectID, &h.SortOrder); err != nil {
+			return nil, fmt.Errorf("scan heading: %w", err)
+		}
+		headings = append(headings, h)
+	}
+	if headings == nil {
+		headings = []model.Heading{}
+	}
+	return headings, rows.Err()
+}
+
 func (r *HeadingRepository) Create(projectID string, input model.CreateHeadingInput) (*model.Heading, error) {
 	id := input.ID
 	if id == "" {
@@ -115,7 +150,7 @@ func (r *HeadingRepository) Delete(id string) error {
 }
 
 func (r *HeadingRepository) Reorder(items []model.SimpleReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+type ProjectRepeatRuleRepository struct {
+	db        *sql.DB
+	changeLog *ChangeLogRepository
+}
+
+func NewProjectRepeatRuleRepository(db *sql.DB, changeLog *ChangeLogRepository) *ProjectRepeatRuleRepository {
+	return &ProjectRepeatRuleRepository{db: db, changeLog: changeLog}
+}
+
+func (r *ProjectRepeatRuleRepository) GetByProject(projectID string) (*model.ProjectRepeatRule, error) {
+	return getProjectRepeatRule(r.db, projectID)
+}
+
+// Upsert sets the project's repeat rule. Without copy options in the input a
+// new rule copies everything and an existing rule keeps its own.
+func (r *ProjectRepeatRuleRepository) Upsert(projectID string, input model.CreateProjectRepeatRuleInput) (*model.ProjectRepeatRule, error) {
+	pattern := resolvePattern(model.CreateRepeatRuleInput{Pattern: input.Pattern})
+	pinRRuleStart(&pattern, seriesStart(r.db, "projects", projectID))
+	patternJSON, err := json.Marshal(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("marshal pattern: %w", err)
+	}
+	copyJSON := "{}"
+	if input.Copy != nil {
+		b, err := json.Marshal(input.Copy)
+		if err != nil {
+			return nil, fmt.Errorf("marshal copy options: %w", err)
+		}
+		copyJSON = string(b)
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO project_repeat_rules (id, project_id, pattern, copy_options)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(project_id) DO UPDATE SET
+			pattern = excluded.pattern,
+			copy_options = CASE WHEN ? THEN excluded.copy_options ELSE copy_options END`,
+		model.NewID(), projectID, string(patternJSON), copyJSON, input.Copy != nil)
+	if err != nil {
+		return nil, fmt.Errorf("upsert project repeat rule: %w", err)
+	}
+	// A project that starts repeating starts a series of its own.
+	if _, err := r.db.Exec("UPDATE projects SET series_id = id WHERE id = ? AND series_id IS NULL", projectID); err != nil {
+		return nil, fmt.Errorf("start project series: %w", err)
+	}
+	rule, err := r.GetByProject(projectID)
+	if err == nil && rule != nil {
+		logChange(r.changeLog, "project_repeat_rule", rule.ID, "upsert", nil, rule, "", "")
+	}
+	return rule, err
+}
+
+func (r *ProjectRepeatRuleRepository) DeleteByProject(projectID string) error {
+	var ruleID string
+	_ = r.db.QueryRow("SELECT id FROM project_repeat_rules WHERE project_id = ?", projectID).Scan(&ruleID)
+	_, err := r.db.Exec("DELETE FROM project_repeat_rules WHERE project_id = ?", projectID)
+	if err == nil && ruleID != "" {
+		logChange(r.changeLog, "project_repeat_rule", ruleID, "delete", nil, map[string]string{"id": ruleID, "project_id": projectID}, "", "")
+	}
+	return err
+}
+
+func (r *ProjectRepeatRuleRepository) ListAll() ([]model.ProjectRepeatRule, error) {
+	rows, err := r.db.Query("SELECT id, project_id, pattern, copy_options, created_at FROM project_repeat_rules ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []model.ProjectRepeatRule
+	for rows.Next() {
+		var rr model.ProjectRepeatRule
+		var patternJSON, copyJSON string
+		if err := rows.Scan(&rr.ID, &rr.ProjectID, &patternJSON, &copyJSON, &rr.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan project repeat rule: %w", err)
+		}
+		_ = json.Unmarshal([]byte(patternJSON), &rr.Pattern)
+		rr.Copy = decodeCopyOptions(copyJSON)
+		rules = append(rules, rr)
+	}
+	return rules, rows.Err()
+}
+
+func getProjectRepeatRule(db querier, projectID string) (*model.ProjectRepeatRule, error) {
+	var rr model.ProjectRepeatRule
+	var patternJSON, copyJSON string
+	err := db.QueryRow(
+		"SELECT id, project_id, pattern, copy_options, created_at FROM project_repeat_rules WHERE project_id = ?", projectID,
+	).Scan(&rr.ID, &rr.ProjectID, &patternJSON, &copyJSON, &rr.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(patternJSON), &rr.Pattern); err != nil {
+		return nil, fmt.Errorf("unmarshal pattern: %w", err)
+	}
+	rr.Copy = decodeCopyOptions(copyJSON)
+	return &rr, nil
+}
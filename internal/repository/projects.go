@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -13,18 +14,45 @@ func isUniqueConstraintError(err error) bool {
 }
 
 type ProjectRepository struct {
-	db        *sql.DB
+	db querier
+	reader
 	changeLog *ChangeLogRepository
 }
 
 func NewProjectRepository(db *sql.DB, changeLog *ChangeLogRepository) *ProjectRepository {
-	return &ProjectRepository{db: db, changeLog: changeLog}
+	return &ProjectRepository{db: db, reader: reader{db}, changeLog: changeLog}
+}
+
+// WithChangeLog returns a copy of the repository that records its changes in cl.
+func (r *ProjectRepository) WithChangeLog(cl *ChangeLogRepository) *ProjectRepository {
+	c := *r
+	c.changeLog = cl
+	return &c
+}
+
+// WithTx returns a copy of the repository whose statements, reads included,
+// run in tx.
+func (r *ProjectRepository) WithTx(tx *sql.Tx) *ProjectRepository {
+	c := *r
+	c.db, c.read = tx, tx
+	return &c
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ProjectRepository) WithContext(ctx context.Context) *ProjectRepository {
+	c := *r
+	c.db, c.read = withContext(r.db, ctx), withContext(r.read, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
 }
 
 func (r *ProjectRepository) List(areaID, status *string) ([]model.ProjectListItem, error) {
 	query := `
 		SELECT p.id, p.title, p.notes, p.area_id, p.status, p.when_date, p.deadline,
-			p.sort_order, p.created_at, p.updated_at,
+			p.sort_order, p.created_at, p.updated_at, p.series_id,
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE project_id = p.id AND deleted_at IS NULL), 0),
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE project_id = p.id AND status = 'completed' AND deleted_at IS NULL), 0)
 		FROM projects p`
@@ -44,7 +72,7 @@ func (r *ProjectRepository) List(areaID, status *string) ([]model.ProjectListIte
 	}
 	query += " ORDER BY p.sort_order ASC"
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.read.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list projects: %w", err)
 	}
@@ -55,33 +83,40 @@ func (r *ProjectRepository) List(areaID, status *string) ([]model.ProjectListIte
 		var p model.ProjectListItem
 		if err := rows.Scan(
 			&p.ID, &p.Title, &p.Notes, &p.AreaID, &p.Status, &p.WhenDate, &p.Deadline,
-			&p.SortOrder, &p.CreatedAt, &p.UpdatedAt,
+			&p.SortOrder, &p.CreatedAt, &p.UpdatedAt, &p.SeriesID,
 			&p.TaskCount, &p.CompletedTaskCount,
 		); err != nil {
 			return nil, err
 		}
-		if p.AreaID != nil {
-			p.Area = getRef(r.db, "areas", *p.AreaID)
-		}
-		p.Tags = getProjectTags(r.db, p.ID)
 		projects = append(projects, p)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	if projects == nil {
 		projects = []model.ProjectListItem{}
 	}
-	return projects, rows.Err()
+	// Look up areas and tags once the rows are done, so the connection is free.
+	for i := range projects {
+		p := &projects[i]
+		if p.AreaID != nil {
+			p.Area = getRef(r.read, "areas", *p.AreaID)
+		}
+		p.Tags = getProjectTags(r.read, p.ID)
+	}
+	return projects, nil
 }
 
 func (r *ProjectRepository) GetByID(id string) (*model.ProjectDetail, error) {
 	var p model.ProjectDetail
-	err := r.db.QueryRow(`
+	err := r.read.QueryRow(`
 		SELECT p.id, p.title, p.notes, p.area_id, p.status, p.when_date, p.deadline,
-			p.sort_order, p.created_at, p.updated_at,
+			p.sort_order, p.created_at, p.updated_at, p.series_id,
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE project_id = p.id AND deleted_at IS NULL), 0),
 			COALESCE((SELECT COUNT(*) FROM tasks WHERE project_id = p.id AND status = 'completed' AND deleted_at IS NULL), 0)
 		FROM projects p WHERE p.id = ?`, id).Scan(
 		&p.ID, &p.Title, &p.Notes, &p.AreaID, &p.Status, &p.WhenDate, &p.Deadline,
-		&p.SortOrder, &p.CreatedAt, &p.UpdatedAt,
+		&p.SortOrder, &p.CreatedAt, &p.UpdatedAt, &p.SeriesID,
 		&p.TaskCount, &p.CompletedTaskCount,
 	)
 	if err == sql.ErrNoRows {
@@ -92,12 +127,15 @@ func (r *ProjectRepository) GetByID(id string) (*model.ProjectDetail, error) {
 	}
 
 	if p.AreaID != nil {
-		p.Area = getRef(r.db, "areas", *p.AreaID)
+		p.Area = getRef(r.read, "areas", *p.AreaID)
+	}
+	p.Tags = getProjectTags(r.read, id)
+	if p.RepeatRule, err = getProjectRepeatRule(r.read, id); err != nil {
+		return nil, err
 	}
-	p.Tags = getProjectTags(r.db, id)
 
 	// Load headings with tasks
-	headingRows, err := r.db.Query(
+	headingRows, err := r.read.Query(
 		"SELECT id, title, project_id, sort_order FROM headings WHERE project_id = ? ORDER BY sort_order", id)
 	if err != nil {
 		return nil, err
@@ -110,19 +148,21 @@ func (r *ProjectRepository) GetByID(id string) (*model.ProjectDetail, error) {
 		if err := headingRows.Scan(&h.ID, &h.Title, &h.ProjectID, &h.SortOrder); err != nil {
 			return nil, fmt.Errorf("scan heading: %w", err)
 		}
-		h.Tasks = getTaskListItems(r.db, "heading_id", h.ID)
 		headings = append(headings, h)
 	}
+	for i := range headings {
+		headings[i].Tasks = getTaskListItems(r.read, "heading_id", headings[i].ID)
+	}
 	if headings == nil {
 		headings = []model.HeadingWithTasks{}
 	}
 	p.Headings = headings
 
 	// Tasks without heading
-	p.TasksWithoutHeading = getTaskListItemsNoHeading(r.db, id)
+	p.TasksWithoutHeading = getTaskListItemsNoHeading(r.read, id)
 
 	// Completed tasks (all time)
-	completedRows, err := r.db.Query(`
+	completedRows, err := r.read.Query(`
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
@@ -148,7 +188,7 @@ func (r *ProjectRepository) GetByID(id string) (*model.ProjectDetail, error) {
 		return nil, err
 	}
 	defer completedRows.Close()
-	p.CompletedTasks = scanTaskListItems(r.db, completedRows)
+	p.CompletedTasks = scanTaskListItems(r.read, completedRows)
 
 	return &p, nil
 }
@@ -159,9 +199,9 @@ func (r *ProjectRepository) Create(input model.CreateProjectInput) (*model.Proje
 	_ = r.db.QueryRow("SELECT COALESCE(MAX(sort_order), 0) FROM projects").Scan(&maxSort)
 
 	_, err := r.db.Exec(`
-		INSERT INTO projects (id, title, notes, area_id, when_date, deadline, sort_order)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		id, input.Title, input.Notes, input.AreaID, input.WhenDate, input.Deadline, maxSort+1024)
+		INSERT INTO projects (id, title, notes, area_id, when_date, deadline, sort_order, series_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, input.Title, input.Notes, input.AreaID, input.WhenDate, input.Deadline, maxSort+1024, input.SeriesID)
 	if err != nil {
 		if isUniqueConstraintError(err) {
 			return nil, ErrDuplicateProjectName
@@ -261,7 +301,7 @@ func (r *ProjectRepository) DeleteWithTasks(id string) error {
 	}
 	rows.Close()
 
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -298,7 +338,7 @@ func (r *ProjectRepository) Complete(id string) (*model.ProjectDetail, error) {
 }
 
 func (r *ProjectRepository) Reorder(items []model.SimpleReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -327,7 +367,7 @@ func (r *ProjectRepository) Reorder(items []model.SimpleReorderItem) error {
 
 var validRefTables = map[string]bool{"projects": true, "areas": true, "headings": true}
 
-func getRef(db *sql.DB, table, id string) *model.Ref {
+func getRef(db querier, table, id string) *model.Ref {
 	if !validRefTables[table] {
 		return nil
 	}
@@ -339,7 +379,7 @@ func getRef(db *sql.DB, table, id string) *model.Ref {
 	return &ref
 }
 
-func getProjectTags(db *sql.DB, projectID string) []model.TagRef {
+func getProjectTags(db querier, projectID string) []model.TagRef {
 	rows, err := db.Query(
 		"SELECT t.id, t.title, t.color FROM tags t JOIN project_tags pt ON t.id = pt.tag_id WHERE pt.project_id = ? ORDER BY t.sort_order", projectID)
 	if err != nil {
@@ -358,8 +398,8 @@ func getProjectTags(db *sql.DB, projectID string) []model.TagRef {
 	return tags
 }
 
-func setProjectTags(db *sql.DB, projectID string, tagIDs []string) error {
-	tx, err := db.Begin()
+func setProjectTags(db querier, projectID string, tagIDs []string) error {
+	tx, err := begin(db)
 	if err != nil {
 		return err
 	}
@@ -378,7 +418,7 @@ func setProjectTags(db *sql.DB, projectID string, tagIDs []string) error {
 
 var validFilterCols = map[string]bool{"heading_id": true, "project_id": true, "area_id": true}
 
-func getTaskListItems(db *sql.DB, filterCol, filterVal string) []model.TaskListItem {
+func getTaskListItems(db querier, filterCol, filterVal string) []model.TaskListItem {
 	if !validFilterCols[filterCol] {
 		return []model.TaskListItem{}
 	}
@@ -410,7 +450,7 @@ func getTaskListItems(db *sql.DB, filterCol, filterVal string) []model.TaskListI
 	return tasks
 }
 
-func getTaskListItemsNoHeading(db *sql.DB, projectID string) []model.TaskListItem {
+func getTaskListItemsNoHeading(db querier, projectID string) []model.TaskListItem {
 	rows, err := db.Query(`
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
@@ -439,7 +479,7 @@ func getTaskListItemsNoHeading(db *sql.DB, projectID string) []model.TaskListIte
 	return tasks
 }
 
-func scanTaskListItems(db *sql.DB, rows *sql.Rows) []model.TaskListItem {
+func scanTaskListItems(db querier, rows *sql.Rows) []model.TaskListItem {
 	var tasks []model.TaskListItem
 	for rows.Next() {
 		var t model.TaskListItem
@@ -461,41 +501,13 @@ func scanTaskListItems(db *sql.DB, rows *sql.Rows) []model.TaskListItem {
 		t.HasFiles = hasFiles == 1
 		t.HasRepeatRule = hasRepeat == 1
 		t.HasReminders = hasReminders == 1
-		// Get tags
-		tagRows, _ := db.Query(
-			"SELECT t.id, t.title, t.color FROM tags t JOIN task_tags tt ON t.id = tt.tag_id WHERE tt.task_id = ?", t.ID)
-		if tagRows != nil {
-			var tags []model.TagRef
-			for tagRows.Next() {
-				var tag model.TagRef
-				_ = tagRows.Scan(&tag.ID, &tag.Title, &tag.Color)
-				tags = append(tags, tag)
-			}
-			tagRows.Close()
-			if tags == nil {
-				tags = []model.TagRef{}
-			}
-			t.Tags = tags
-		} else {
-			t.Tags = []model.TagRef{}
-		}
-		// Resolve project/area names
-		if t.ProjectID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM projects WHERE id = ?", *t.ProjectID).Scan(&name); err == nil {
-				t.ProjectName = &name
-			}
-		}
-		if t.AreaID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM areas WHERE id = ?", *t.AreaID).Scan(&name); err == nil {
-				t.AreaName = &name
-			}
-		}
 		tasks = append(tasks, t)
 	}
 	if tasks == nil {
-		tasks = []model.TaskListItem{}
+		return []model.TaskListItem{}
 	}
+	index := newTaskIndex(tasks)
+	_ = loadTaskTags(db, tasks, index, index.ids())
+	_ = loadTaskNames(db, tasks)
 	return tasks
 }
@@ -295,3 +295,26 @@ func TestProjectTaskProgress(t *testing.T) {
 		t.Errorf("expected completed_task_count=1, got %d", projects[0].CompletedTaskCount)
 	}
 }
+
+func TestProjectRepeatRuleUpsertPinsCountedRRule(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	projects := repository.NewProjectRepository(db, nil)
+	rules := repository.NewProjectRepeatRuleRepository(db, nil)
+	areaID := createArea(t, db)
+	when := "2025-03-10"
+	p, err := projects.Create(model.CreateProjectInput{Title: "Sprint", AreaID: &areaID, WhenDate: &when})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := rules.Upsert(p.ID, model.CreateProjectRepeatRuleInput{
+		Pattern: &model.RecurrencePattern{Type: model.PatternRRule, RRule: "FREQ=WEEKLY;COUNT=4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DTSTART;VALUE=DATE:20250310\nRRULE:FREQ=WEEKLY;COUNT=4"
+	if rule.Pattern.RRule != want {
+		t.Errorf("rrule = %q, want %q", rule.Pattern.RRule, want)
+	}
+}
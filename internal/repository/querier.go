@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier runs statements on a *sql.DB, or on a *sql.Tx for a repository
+// bound to a transaction with WithTx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ctxQuerier runs statements on db under ctx, so the driver traces them as
+// part of the span in ctx.
+type ctxQuerier struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (q ctxQuerier) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return q.db.ExecContext(q.ctx, query, args...)
+}
+
+func (q ctxQuerier) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return q.db.QueryContext(q.ctx, query, args...)
+}
+
+func (q ctxQuerier) QueryRow(query string, args ...interface{}) *sql.Row {
+	return q.db.QueryRowContext(q.ctx, query, args...)
+}
+
+// withContext returns q with its statements run under ctx. A transaction is
+// returned as is; it keeps the context it was begun with.
+func withContext(q querier, ctx context.Context) querier {
+	switch q := q.(type) {
+	case *sql.DB:
+		return ctxQuerier{db: q, ctx: ctx}
+	case ctxQuerier:
+		return ctxQuerier{db: q.db, ctx: ctx}
+	}
+	return q
+}
+
+// txn is a transaction started by begin.
+type txn interface {
+	querier
+	Commit() error
+	Rollback() error
+}
+
+// begin starts a transaction on q. When q is a transaction already, the
+// statements join it and committing or rolling back is left to its owner.
+func begin(q querier) (txn, error) {
+	if tx, ok := q.(*sql.Tx); ok {
+		return joinedTx{tx}, nil
+	}
+	return beginTx(q)
+}
+
+// beginTx starts a new transaction on the database behind q.
+func beginTx(q querier) (*sql.Tx, error) {
+	if q, ok := q.(ctxQuerier); ok {
+		return q.db.BeginTx(q.ctx, nil)
+	}
+	return q.(*sql.DB).Begin()
+}
+
+// joinedTx runs statements in an enclosing transaction.
+type joinedTx struct {
+	*sql.Tx
+}
+
+func (joinedTx) Commit() error   { return nil }
+func (joinedTx) Rollback() error { return nil }
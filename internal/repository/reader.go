@@ -0,0 +1,15 @@
+package repository
+
+import "database/sql"
+
+// reader is embedded by repositories whose list reads can run on the reader
+// pool of a database.DB. It starts out as the repository's own db.
+type reader struct {
+	read querier
+}
+
+// SetReader routes the repository's list and detail reads to read. Writes,
+// and reads that must see them within a call, stay on the writer.
+func (r *reader) SetReader(read *sql.DB) {
+	r.read = read
+}
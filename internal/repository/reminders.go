@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type ReminderRepository struct {
-	db        *sql.DB
+	db        querier
 	changeLog *ChangeLogRepository
 }
 
@@ -16,6 +17,17 @@ func NewReminderRepository(db *sql.DB, changeLog *ChangeLogRepository) *Reminder
 	return &ReminderRepository{db: db, changeLog: changeLog}
 }
 
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ReminderRepository) WithContext(ctx context.Context) *ReminderRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
 func (r *ReminderRepository) ListByTask(taskID string) ([]model.Reminder, error) {
 	rows, err := r.db.Query(
 		"SELECT id, type, value, exact_at, created_at FROM reminders WHERE task_id = ? ORDER BY created_at", taskID)
@@ -61,6 +73,29 @@ func (r *ReminderRepository) ListAll() ([]model.Reminder, error) {
 	return items, rows.Err()
 }
 
+// ListPage returns reminders ordered by ID, starting after afterID.
+func (r *ReminderRepository) ListPage(afterID string, limit int) ([]model.Reminder, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, type, value, exact_at, created_at FROM reminders WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.Reminder
+	for rows.Next() {
+		var rm model.Reminder
+		if err := rows.Scan(&rm.ID, &rm.TaskID, &rm.Type, &rm.Value, &rm.ExactAt, &rm.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan reminder: %w", err)
+		}
+		items = append(items, rm)
+	}
+	if items == nil {
+		items = []model.Reminder{}
+	}
+	return items, rows.Err()
+}
+
 func (r *ReminderRepository) Create(taskID string, input model.CreateReminderInput) (*model.Reminder, error) {
 	id := input.ID
 	if id == "" {
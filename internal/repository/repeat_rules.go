@@ -4,8 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
 )
 
 type RepeatRuleRepository struct {
@@ -19,10 +22,10 @@ func NewRepeatRuleRepository(db *sql.DB, changeLog *ChangeLogRepository) *Repeat
 
 func (r *RepeatRuleRepository) GetByTask(taskID string) (*model.RepeatRule, error) {
 	var rr model.RepeatRule
-	var patternJSON string
+	var patternJSON, copyJSON string
 	err := r.db.QueryRow(
-		"SELECT id, task_id, pattern FROM repeat_rules WHERE task_id = ?", taskID,
-	).Scan(&rr.ID, &rr.TaskID, &patternJSON)
+		"SELECT id, task_id, pattern, copy_options, catch_up FROM repeat_rules WHERE task_id = ?", taskID,
+	).Scan(&rr.ID, &rr.TaskID, &patternJSON, &copyJSON, &rr.CatchUp)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -34,6 +37,7 @@ func (r *RepeatRuleRepository) GetByTask(taskID string) (*model.RepeatRule, erro
 			return nil, fmt.Errorf("unmarshal pattern: %w", err)
 		}
 	}
+	rr.Copy = decodeCopyOptions(copyJSON)
 	populateFlatFields(&rr)
 	return &rr, nil
 }
@@ -41,6 +45,7 @@ func (r *RepeatRuleRepository) GetByTask(taskID string) (*model.RepeatRule, erro
 func (r *RepeatRuleRepository) Upsert(taskID string, input model.CreateRepeatRuleInput) (*model.RepeatRule, error) {
 	id := model.NewID()
 	pattern := resolvePattern(input)
+	pinRRuleStart(&pattern, seriesStart(r.db, "tasks", taskID))
 	patternJSON, err := json.Marshal(pattern)
 	if err != nil {
 		return nil, fmt.Errorf("marshal pattern: %w", err)
@@ -49,19 +54,41 @@ func (r *RepeatRuleRepository) Upsert(taskID string, input model.CreateRepeatRul
 	// Also write flat columns for backwards compat with older code
 	frequency, intervalValue, mode, constraintsJSON := flatFieldsFromPattern(pattern)
 
+	// Without copy options or a catch-up policy in the input, a new rule gets
+	// the defaults and an existing rule keeps its own.
+	copyJSON := "{}"
+	if input.Copy != nil {
+		b, err := json.Marshal(input.Copy)
+		if err != nil {
+			return nil, fmt.Errorf("marshal copy options: %w", err)
+		}
+		copyJSON = string(b)
+	}
+	catchUp := model.CatchUpNone
+	if input.CatchUp != nil {
+		catchUp = *input.CatchUp
+	}
+
 	_, err = r.db.Exec(`
-		INSERT INTO repeat_rules (id, task_id, pattern, frequency, interval_value, mode, day_constraints)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO repeat_rules (id, task_id, pattern, frequency, interval_value, mode, day_constraints, copy_options, catch_up)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(task_id) DO UPDATE SET
 			pattern = excluded.pattern,
 			frequency = excluded.frequency,
 			interval_value = excluded.interval_value,
 			mode = excluded.mode,
-			day_constraints = excluded.day_constraints`,
-		id, taskID, string(patternJSON), frequency, intervalValue, mode, constraintsJSON)
+			day_constraints = excluded.day_constraints,
+			copy_options = CASE WHEN ? THEN excluded.copy_options ELSE copy_options END,
+			catch_up = CASE WHEN ? THEN excluded.catch_up ELSE catch_up END`,
+		id, taskID, string(patternJSON), frequency, intervalValue, mode, constraintsJSON, copyJSON, catchUp,
+		input.Copy != nil, input.CatchUp != nil)
 	if err != nil {
 		return nil, fmt.Errorf("upsert repeat rule: %w", err)
 	}
+	// A task that starts repeating starts a series of its own.
+	if _, err := r.db.Exec("UPDATE tasks SET series_id = id WHERE id = ? AND series_id IS NULL", taskID); err != nil {
+		return nil, fmt.Errorf("start task series: %w", err)
+	}
 	rule, err := r.GetByTask(taskID)
 	if err == nil && rule != nil {
 		logChange(r.changeLog, "repeat_rule", rule.ID, "upsert", nil, rule, "", "")
@@ -81,7 +108,7 @@ func (r *RepeatRuleRepository) DeleteByTask(taskID string) error {
 }
 
 func (r *RepeatRuleRepository) ListAll() ([]model.RepeatRule, error) {
-	rows, err := r.db.Query("SELECT id, task_id, pattern FROM repeat_rules")
+	rows, err := r.db.Query("SELECT id, task_id, pattern, copy_options, catch_up FROM repeat_rules")
 	if err != nil {
 		return nil, err
 	}
@@ -90,11 +117,37 @@ func (r *RepeatRuleRepository) ListAll() ([]model.RepeatRule, error) {
 	var rules []model.RepeatRule
 	for rows.Next() {
 		var rr model.RepeatRule
-		var patternJSON string
-		_ = rows.Scan(&rr.ID, &rr.TaskID, &patternJSON)
+		var patternJSON, copyJSON string
+		_ = rows.Scan(&rr.ID, &rr.TaskID, &patternJSON, &copyJSON, &rr.CatchUp)
+		if patternJSON != "" {
+			_ = json.Unmarshal([]byte(patternJSON), &rr.Pattern)
+		}
+		rr.Copy = decodeCopyOptions(copyJSON)
+		populateFlatFields(&rr)
+		rules = append(rules, rr)
+	}
+	return rules, rows.Err()
+}
+
+// ListPage returns repeat rules ordered by ID, starting after afterID.
+func (r *RepeatRuleRepository) ListPage(afterID string, limit int) ([]model.RepeatRule, error) {
+	rows, err := r.db.Query("SELECT id, task_id, pattern, copy_options, catch_up FROM repeat_rules WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []model.RepeatRule{}
+	for rows.Next() {
+		var rr model.RepeatRule
+		var patternJSON, copyJSON string
+		if err := rows.Scan(&rr.ID, &rr.TaskID, &patternJSON, &copyJSON, &rr.CatchUp); err != nil {
+			return nil, fmt.Errorf("scan repeat rule: %w", err)
+		}
 		if patternJSON != "" {
 			_ = json.Unmarshal([]byte(patternJSON), &rr.Pattern)
 		}
+		rr.Copy = decodeCopyOptions(copyJSON)
 		populateFlatFields(&rr)
 		rules = append(rules, rr)
 	}
@@ -103,6 +156,33 @@ func (r *RepeatRuleRepository) ListAll() ([]model.RepeatRule, error) {
 
 // resolvePattern converts a CreateRepeatRuleInput to a RecurrencePattern.
 // If input.Pattern is set, uses it directly. Otherwise, converts from legacy flat fields.
+// seriesStart returns the when_date of the task or project in table, or ""
+// when it has none.
+func seriesStart(db querier, table, id string) string {
+	var when sql.NullString
+	_ = db.QueryRow("SELECT when_date FROM "+table+" WHERE id = ?", id).Scan(&when)
+	return when.String
+}
+
+// pinRRuleStart anchors a counted RRULE without DTSTART at start, or today
+// when start is not a date, so COUNT keeps counting from the same date as
+// each instance is generated.
+func pinRRuleStart(p *model.RecurrencePattern, start string) {
+	if p.Type != model.PatternRRule {
+		return
+	}
+	rule, err := recurrence.ParseRRule(p.RRule)
+	if err != nil || rule.Count == 0 || rule.DTStart != nil {
+		return
+	}
+	t, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		t, _ = time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	}
+	rule.DTStart = &t
+	p.RRule = rule.String()
+}
+
 func resolvePattern(input model.CreateRepeatRuleInput) model.RecurrencePattern {
 	if input.Pattern != nil {
 		p := *input.Pattern
@@ -166,6 +246,8 @@ func populateFlatFields(rr *model.RepeatRule) {
 		rr.Frequency = "monthly"
 	case model.PatternYearlyDate, model.PatternYearlyDOW:
 		rr.Frequency = "yearly"
+	case model.PatternRRule:
+		rr.Frequency = rruleFrequency(rr.Pattern.RRule)
 	}
 
 	if rr.DayConstraints == nil {
@@ -194,8 +276,29 @@ func flatFieldsFromPattern(p model.RecurrencePattern) (frequency string, interva
 		frequency = "monthly"
 	case model.PatternYearlyDate, model.PatternYearlyDOW:
 		frequency = "yearly"
+	case model.PatternRRule:
+		frequency = rruleFrequency(p.RRule)
 	default:
 		frequency = "daily"
 	}
 	return
 }
+
+// decodeCopyOptions reads the copy_options column, falling back to the
+// defaults for missing fields or unreadable JSON.
+func decodeCopyOptions(s string) model.RepeatCopyOptions {
+	opts := model.DefaultRepeatCopyOptions()
+	if s != "" {
+		_ = json.Unmarshal([]byte(s), &opts)
+	}
+	return opts
+}
+
+// rruleFrequency maps an RRULE's FREQ to the legacy frequency column, which
+// only allows daily, weekly, monthly and yearly.
+func rruleFrequency(rule string) string {
+	if r, err := recurrence.ParseRRule(rule); err == nil {
+		return strings.ToLower(r.Freq)
+	}
+	return "daily"
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type ScheduleRepository struct {
-	db        *sql.DB
+	db        querier
 	changeLog *ChangeLogRepository
 }
 
@@ -16,6 +17,17 @@ func NewScheduleRepository(db *sql.DB, changeLog *ChangeLogRepository) *Schedule
 	return &ScheduleRepository{db: db, changeLog: changeLog}
 }
 
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ScheduleRepository) WithContext(ctx context.Context) *ScheduleRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
 func (r *ScheduleRepository) ListByTask(taskID string) ([]model.TaskSchedule, error) {
 	rows, err := r.db.Query(
 		"SELECT id, task_id, when_date, start_time, end_time, completed, sort_order FROM task_schedules WHERE task_id = ? ORDER BY sort_order", taskID)
@@ -61,6 +73,29 @@ func (r *ScheduleRepository) ListAll() ([]model.TaskSchedule, error) {
 	return items, rows.Err()
 }
 
+// ListPage returns task schedules ordered by ID, starting after afterID.
+func (r *ScheduleRepository) ListPage(afterID string, limit int) ([]model.TaskSchedule, error) {
+	rows, err := r.db.Query(
+		"SELECT id, task_id, when_date, start_time, end_time, completed, sort_order FROM task_schedules WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.TaskSchedule
+	for rows.Next() {
+		var s model.TaskSchedule
+		if err := rows.Scan(&s.ID, &s.TaskID, &s.WhenDate, &s.StartTime, &s.EndTime, &s.Completed, &s.SortOrder); err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		items = append(items, s)
+	}
+	if items == nil {
+		items = []model.TaskSchedule{}
+	}
+	return items, rows.Err()
+}
+
 func (r *ScheduleRepository) Create(taskID string, input model.CreateTaskScheduleInput) (*model.TaskSchedule, error) {
 	id := input.ID
 	if id == "" {
@@ -135,7 +170,7 @@ func (r *ScheduleRepository) Delete(id string) error {
 }
 
 func (r *ScheduleRepository) Reorder(items []model.SimpleReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -253,7 +288,7 @@ func (r *ScheduleRepository) CreateFirstEntry(taskID, date string) error {
 // and deletes future + someday uncompleted entries for a task. Called when a
 // task is completed, canceled, or marked won't do.
 func (r *ScheduleRepository) CleanupOnTaskDone(taskID, today string) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -304,13 +339,17 @@ func (r *ScheduleRepository) CleanupOnTaskDone(taskID, today string) error {
 		taskID, today,
 	)
 	if err == nil {
-		defer completedRows.Close()
+		var completed []model.TaskSchedule
 		for completedRows.Next() {
 			var s model.TaskSchedule
 			if err := completedRows.Scan(&s.ID, &s.TaskID, &s.WhenDate, &s.StartTime, &s.EndTime, &s.Completed, &s.SortOrder); err != nil {
 				break
 			}
-			logChange(r.changeLog, "schedule", s.ID, "update", []string{"completed"}, &s, "", "")
+			completed = append(completed, s)
+		}
+		completedRows.Close()
+		for i := range completed {
+			logChange(r.changeLog, "schedule", completed[i].ID, "update", []string{"completed"}, &completed[i], "", "")
 		}
 	}
 
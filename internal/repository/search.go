@@ -1,44 +1,290 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/collinjanssen/thingstodo/internal/e2ee"
 	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
 )
 
 type SearchRepository struct {
-	db *sql.DB
+	db querier
 }
 
 func NewSearchRepository(db *sql.DB) *SearchRepository {
 	return &SearchRepository{db: db}
 }
 
-// ftsPrefix converts a user query into an FTS5 prefix query.
-// e.g. "inbox task" → "inbox* task*" so partial words match.
-func ftsPrefix(query string) string {
-	words := strings.Fields(query)
-	for i, w := range words {
-		// Strip any existing trailing * to avoid double-star
-		w = strings.TrimRight(w, "*")
-		if w != "" {
-			words[i] = w + "*"
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *SearchRepository) WithContext(ctx context.Context) *SearchRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	return &c
+}
+
+// ftsTerm quotes a search term for FTS5. Bare words match as prefixes, e.g.
+// "inbox" matches "inboxes"; phrases match exactly.
+func ftsTerm(t searchquery.Term) string {
+	text := strings.TrimRight(t.Text, "*")
+	quoted := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	if !t.Phrase {
+		quoted += "*"
+	}
+	return quoted
+}
+
+// indexable reports whether a term has anything for FTS5 to match on.
+func indexable(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// ftsAny joins alternative FTS5 terms into one.
+func ftsAny(alts []string) string {
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	return "(" + strings.Join(alts, " OR ") + ")"
+}
+
+// searchTypes are the result types in the order their groups are returned.
+var searchTypes = []string{"task", "project", "area", "heading", "checklist_item"}
+
+// searchPlan is a parsed query split up for the per-type searches.
+type searchPlan struct {
+	terms   []string // FTS5 terms every result must match
+	negated []string // FTS5 terms no result may match
+	filters []searchquery.Filter
+	types   map[string]bool
+	today   time.Time
+
+	// words are the query terms behind terms, and alts the FTS5 terms each
+	// one may match: itself, its synonyms and, failing all else, near
+	// spellings. terms[i] is alts[i] joined with OR.
+	words []searchquery.Term
+	alts  [][]string
+}
+
+func newSearchPlan(q *searchquery.Query, synonyms map[string][]string) searchPlan {
+	plan := searchPlan{types: map[string]bool{}, today: time.Now()}
+	for _, term := range q.Terms {
+		if !indexable(term.Text) {
+			continue
+		}
+		alts := []string{ftsTerm(term)}
+		for _, synonym := range synonyms[strings.ToLower(term.Text)] {
+			alts = append(alts, ftsTerm(searchquery.Term{Text: synonym, Phrase: term.Phrase}))
+		}
+		if term.Negated {
+			plan.negated = append(plan.negated, ftsAny(alts))
+		} else {
+			plan.words = append(plan.words, term)
+			plan.alts = append(plan.alts, alts)
+			plan.terms = append(plan.terms, ftsAny(alts))
 		}
 	}
-	return strings.Join(words, " ")
+
+	var only, without []string
+	for _, f := range q.Filters {
+		switch {
+		case f.Key != "type":
+			plan.filters = append(plan.filters, f)
+		case f.Negated:
+			without = append(without, f.Value)
+		default:
+			only = append(only, f.Value)
+		}
+	}
+	switch {
+	case len(only) > 0:
+		for _, t := range only {
+			plan.types[t] = true
+		}
+	case len(plan.terms) == 0:
+		// Without text only tasks are worth listing.
+		plan.types["task"] = true
+	case len(plan.filters) > 0:
+		// Filters describe tasks; checklist items go by their task.
+		plan.types["task"], plan.types["checklist_item"] = true, true
+	default:
+		for _, t := range searchTypes {
+			plan.types[t] = true
+		}
+	}
+	for _, t := range without {
+		delete(plan.types, t)
+	}
+	return plan
+}
+
+// taskConditions returns the conditions a task aliased t must meet to match
+// the plan: every term and filter, none of the negated terms, and not deleted.
+func (p searchPlan) taskConditions() ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range p.terms {
+		conditions = append(conditions, taskTermMatch)
+		args = append(args, term, term, term, term)
+	}
+	for _, term := range p.negated {
+		conditions = append(conditions, "NOT "+taskTermMatch)
+		args = append(args, term, term, term, term)
+	}
+	filterConds, filterArgs := p.filterConditions()
+	conditions = append(conditions, filterConds...)
+	args = append(args, filterArgs...)
+	return append(conditions, "t.deleted_at IS NULL"), args
 }
 
+// filterConditions returns the conditions of the plan's task filters on
+// tasks aliased t.
+func (p searchPlan) filterConditions() ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, f := range p.filters {
+		cond, filterArgs := searchFilterSQL(f, p.today)
+		if f.Negated {
+			cond = "NOT COALESCE((" + cond + "), 0)"
+		}
+		conditions = append(conditions, cond)
+		args = append(args, filterArgs...)
+	}
+	return conditions, args
+}
+
+// fuzzyMinResults is the result count below which a search retries with
+// near spellings of its words.
+const fuzzyMinResults = 3
+
+// Search runs a query in the searchquery language over tasks, projects,
+// areas, headings and checklist items. Tasks also match on the names of
+// their tags, the titles and URLs of their attachments and the text of
+// attached files. Words also match their synonyms, and a search with fewer
+// than fuzzyMinResults results is retried with near spellings of its words.
+// Results come grouped by type in searchTypes order, best match first within
+// a group, with up to limit results per group. A malformed query returns a
+// *searchquery.ParseError.
 func (r *SearchRepository) Search(query string, limit int) ([]model.SearchResult, error) {
+	results, _, err := r.SearchPaged(query, model.Page{Limit: limit})
+	return results, err
+}
+
+// searchCursor is where the next page of a search picks up: the time the
+// first page ranked and filtered at, whether it fell back to near
+// spellings, and the sort key of the last result of each type with more.
+type searchCursor struct {
+	Now   time.Time                `json:"now"`
+	Fuzzy bool                     `json:"fuzzy,omitempty"`
+	After map[string][]interface{} `json:"after"`
+}
+
+// SearchPaged returns one page of the results of Search, up to page.Limit
+// per type, with the cursor of the next page, or "" if it is the last. The
+// next page only holds the types that had more results.
+func (r *SearchRepository) SearchPaged(query string, page model.Page) ([]model.SearchResult, string, error) {
+	limit := page.Limit
 	if limit <= 0 {
 		limit = 20
 	}
+	q, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, "", err
+	}
+	synonyms, err := r.synonymMap()
+	if err != nil {
+		return nil, "", err
+	}
+	plan := newSearchPlan(q, synonyms)
 
-	ftsQuery := ftsPrefix(query)
+	var cur searchCursor
+	if page.Cursor != "" {
+		if err := decodeCursor("search", page.Cursor, &cur); err != nil {
+			return nil, "", err
+		}
+		plan.today = cur.Now
+		if cur.Fuzzy {
+			if _, err := r.addNearSpellings(&plan); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	cur.Now = plan.today
 
-	rows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
+	results, more, err := r.run(plan, limit, cur.After)
+	if err == nil && page.Cursor == "" && len(results) < fuzzyMinResults {
+		var retry bool
+		retry, err = r.addNearSpellings(&plan)
+		if err == nil && retry {
+			cur.Fuzzy = true
+			results, more, err = r.run(plan, limit, nil)
+		}
+	}
+	if err != nil || len(more) == 0 {
+		return results, "", err
+	}
+	cur.After = more
+	return results, encodeCursor("search", cur), nil
+}
+
+// searchKeysets are the orders of the results of each type: by rank, then
+// by title when there are no words to rank by.
+var searchKeysets = map[string]keyset{
+	"task":   {terms: []keyTerm{{expr: "rank_value"}, {expr: "t.updated_at", desc: true}, {expr: "t.id"}}},
+	"ranked": {terms: []keyTerm{{expr: "rank"}, {expr: "e.id"}}},
+	"titled": {terms: []keyTerm{{expr: "e.title"}, {expr: "e.id"}}},
+}
+
+// run searches each type of the plan, or with after each type in it, for up
+// to limit results after the sort key after holds for the type. It returns
+// the sort key of the last result of each type with more.
+func (r *SearchRepository) run(plan searchPlan, limit int, after map[string][]interface{}) ([]model.SearchResult, map[string][]interface{}, error) {
+	results := []model.SearchResult{}
+	more := map[string][]interface{}{}
+	for _, typ := range searchTypes {
+		if !plan.types[typ] {
+			continue
+		}
+		key, ok := after[typ]
+		if after != nil && !ok {
+			continue
+		}
+		var found []model.SearchResult
+		var err error
+		if typ == "task" {
+			found, err = r.searchTasks(plan, limit+1, key)
+		} else {
+			found, err = r.searchEntities(plan, entitySearches[typ], limit+1, key)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(found) > limit {
+			found = found[:limit]
+			last := found[limit-1]
+			switch {
+			case typ == "task":
+				more[typ] = []interface{}{last.Rank, last.Task.UpdatedAt, last.ID}
+			case len(plan.terms) > 0:
+				more[typ] = []interface{}{last.Rank, last.ID}
+			default:
+				more[typ] = []interface{}{last.Title, last.ID}
+			}
+		}
+		results = append(results, found...)
+	}
+	return results, more, nil
+}
+
+// searchTaskColumns selects a task list item from tasks aliased t.
+const searchTaskColumns = `t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
 			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
@@ -52,55 +298,382 @@ func (r *SearchRepository) Search(query string, limit int) ([]model.SearchResult
 			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
 			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
 			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			snippet(tasks_fts, 0, '<mark>', '</mark>', '...', 32),
-			snippet(tasks_fts, 1, '<mark>', '</mark>', '...', 32),
-			rank
-		FROM tasks_fts
-		JOIN tasks t ON t.rowid = tasks_fts.rowid
-		WHERE tasks_fts MATCH ? AND t.deleted_at IS NULL
-		ORDER BY rank
-		LIMIT ?`, ftsQuery, limit)
+			pr.title, ar.title`
+
+// searchTaskJoins brings in the project and area names for searchTaskColumns
+// and taskListColumns.
+const searchTaskJoins = `LEFT JOIN projects pr ON pr.id = t.project_id
+		LEFT JOIN areas ar ON ar.id = t.area_id`
+
+// taskBoost scales the rank of a task: open, high-priority and recently
+// updated tasks come first among equally good matches. It binds the time
+// the search runs at, so every page of a search ranks alike. FTS5 ranks are
+// negative, lower being better, so a larger boost moves a task up.
+const taskBoost = `(1.0
+			+ CASE WHEN t.status = 'open' THEN 0.5 ELSE 0 END
+			+ CASE WHEN t.high_priority = 1 THEN 0.3 ELSE 0 END
+			+ 0.5 * MAX(0, 1 - (julianday(?) - julianday(t.updated_at)) / 30.0))`
+
+// taskTermMatch matches tasks aliased t whose title or notes, tag names,
+// attachment titles and URLs, or the text of attached files match one FTS5
+// term (bound four times).
+const taskTermMatch = `(t.rowid IN (SELECT rowid FROM tasks_fts WHERE tasks_fts MATCH ?)
+			OR t.id IN (SELECT tt.task_id FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+				WHERE g.rowid IN (SELECT rowid FROM tags_fts WHERE tags_fts MATCH ?))
+			OR t.id IN (SELECT a.task_id FROM attachments a
+				WHERE a.rowid IN (SELECT rowid FROM attachments_fts WHERE attachments_fts MATCH ?))
+			OR t.id IN (SELECT a.task_id FROM attachments a
+				WHERE a.id IN (SELECT attachment_id FROM attachment_text_fts WHERE attachment_text_fts MATCH ?)))`
+
+func (r *SearchRepository) searchTasks(plan searchPlan, limit int, after []interface{}) ([]model.SearchResult, error) {
+	var conditions []string
+	var args []interface{}
+
+	snippets := "'', '', 0"
+	if len(plan.terms) > 0 {
+		// Snippets and rank come from title and notes; a task found only
+		// through a tag or attachment ranks after those.
+		anyTerm := strings.Join(plan.terms, " OR ")
+		snippets = `(SELECT snippet(tasks_fts, 0, '<mark>', '</mark>', '...', 32) FROM tasks_fts WHERE tasks_fts MATCH ? AND rowid = t.rowid),
+			(SELECT snippet(tasks_fts, 1, '<mark>', '</mark>', '...', 32) FROM tasks_fts WHERE tasks_fts MATCH ? AND rowid = t.rowid),
+			COALESCE((SELECT rank FROM tasks_fts WHERE tasks_fts MATCH ? AND rowid = t.rowid), 0) * ` + taskBoost
+		args = append(args, anyTerm, anyTerm, anyTerm, plan.today.UTC().Format("2006-01-02 15:04:05"))
+	}
+	taskConds, taskArgs := plan.taskConditions()
+	conditions = append(conditions, taskConds...)
+	args = append(args, taskArgs...)
+	keys := searchKeysets["task"]
+	if after != nil {
+		if len(after) != len(keys.terms) {
+			return nil, ErrInvalidCursor
+		}
+		cond, afterArgs := keys.condition(after)
+		conditions = append(conditions, cond)
+		args = append(args, afterArgs...)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(`
+		SELECT `+searchTaskColumns+`,
+			`+snippets+` AS rank_value
+		FROM tasks t
+		`+searchTaskJoins+`
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+keys.orderBy()+`
+		LIMIT ?`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("search: %w", err)
+		return nil, fmt.Errorf("search tasks: %w", err)
 	}
 	defer rows.Close()
 
 	var results []model.SearchResult
 	for rows.Next() {
-		var sr model.SearchResult
-		var t model.TaskListItem
-		var whenEvening, highPriority, hasNotes, hasLinks, hasFiles, hasRepeat, hasReminders int
-		if err := rows.Scan(
-			&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &whenEvening, &highPriority,
-			&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
-			&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
-			&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
-			&t.ChecklistCount, &t.ChecklistDone,
-			&hasNotes, &hasLinks, &hasFiles, &hasRepeat, &hasReminders,
-			&t.FirstReminderType, &t.FirstReminderValue, &t.FirstReminderExactAt,
-			&sr.TitleSnippet, &sr.NotesSnippet, &sr.Rank,
-		); err != nil {
+		var titleSnippet, notesSnippet sql.NullString
+		sr := model.SearchResult{Type: "task"}
+		t, err := scanSearchTask(rows, &titleSnippet, &notesSnippet, &sr.Rank)
+		if err != nil {
 			return nil, err
 		}
-		_ = whenEvening // column retained in DB but no longer exposed
-		t.HighPriority = highPriority == 1
-		t.HasNotes = hasNotes == 1
-		t.HasLinks = hasLinks == 1
-		t.HasFiles = hasFiles == 1
-		t.HasRepeatRule = hasRepeat == 1
-		t.HasReminders = hasReminders == 1
-		t.Tags = []model.TagRef{}
-		if t.ProjectID != nil {
-			_ = r.db.QueryRow("SELECT title FROM projects WHERE id = ?", *t.ProjectID).Scan(&t.ProjectName)
-		}
-		if t.AreaID != nil {
-			_ = r.db.QueryRow("SELECT title FROM areas WHERE id = ?", *t.AreaID).Scan(&t.AreaName)
+		sr.ID, sr.Title, sr.Status = t.ID, t.Title, t.Status
+		sr.TitleSnippet, sr.NotesSnippet = titleSnippet.String, notesSnippet.String
+		if e2ee.IsEncrypted(t.Notes) {
+			// Encrypted notes are not indexed; don't leak ciphertext into snippets.
+			sr.NotesSnippet = ""
 		}
 		sr.Task = t
 		results = append(results, sr)
 	}
-	if results == nil {
-		results = []model.SearchResult{}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(plan.terms) > 0 {
+		for i := range results {
+			if results[i].Task.HasFiles {
+				r.attachmentSnippet(&results[i], strings.Join(plan.terms, " OR "))
+			}
+		}
+	}
+	return results, nil
+}
+
+// attachmentSnippet points a task result at the attached file that best
+// matches the query, if any.
+func (r *SearchRepository) attachmentSnippet(sr *model.SearchResult, match string) {
+	var ref model.Ref
+	var snippet string
+	err := r.db.QueryRow(`
+		SELECT a.id, a.title, snippet(attachment_text_fts, 1, '<mark>', '</mark>', '...', 32)
+		FROM attachment_text_fts
+		JOIN attachments a ON a.id = attachment_text_fts.attachment_id
+		WHERE attachment_text_fts MATCH ? AND a.task_id = ?
+		ORDER BY rank
+		LIMIT 1`, match, sr.ID).Scan(&ref.ID, &ref.Title, &snippet)
+	if err != nil {
+		return
+	}
+	sr.Attachment = &ref
+	sr.AttachmentSnippet = snippet
+}
+
+func scanSearchTask(rows *sql.Rows, extra ...interface{}) (*model.TaskListItem, error) {
+	var t model.TaskListItem
+	var whenEvening, highPriority, hasNotes, hasLinks, hasFiles, hasRepeat, hasReminders int
+	dest := []interface{}{
+		&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &whenEvening, &highPriority,
+		&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
+		&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
+		&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
+		&t.ChecklistCount, &t.ChecklistDone,
+		&hasNotes, &hasLinks, &hasFiles, &hasRepeat, &hasReminders,
+		&t.FirstReminderType, &t.FirstReminderValue, &t.FirstReminderExactAt,
+		&t.ProjectName, &t.AreaName,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, fmt.Errorf("scan search task: %w", err)
+	}
+	_ = whenEvening // column retained in DB but no longer exposed
+	t.HighPriority = highPriority == 1
+	t.HasNotes = hasNotes == 1
+	t.HasLinks = hasLinks == 1
+	t.HasFiles = hasFiles == 1
+	t.HasRepeatRule = hasRepeat == 1
+	t.HasReminders = hasReminders == 1
+	t.Tags = []model.TagRef{}
+	return &t, nil
+}
+
+// entitySearch describes how to search one non-task type. Each expression
+// refers to the table aliased e.
+type entitySearch struct {
+	typ    string
+	table  string
+	fts    string
+	notes  string // second indexed column, or "" if there is none
+	status string
+	parent string // parent id and title
+	join   string // extra joins, e.g. to the task of a checklist item
+	where  string // extra condition
+}
+
+var entitySearches = map[string]entitySearch{
+	"project": {
+		typ: "project", table: "projects", fts: "projects_fts", notes: "notes", status: "e.status",
+		parent: "e.area_id, (SELECT title FROM areas WHERE id = e.area_id)",
+	},
+	"area": {
+		typ: "area", table: "areas", fts: "areas_fts", status: "''",
+		parent: "NULL, NULL",
+	},
+	"heading": {
+		typ: "heading", table: "headings", fts: "headings_fts", status: "''",
+		parent: "e.project_id, (SELECT title FROM projects WHERE id = e.project_id)",
+	},
+	"checklist_item": {
+		typ: "checklist_item", table: "checklist_items", fts: "checklist_items_fts",
+		status: "CASE WHEN e.completed = 1 THEN 'completed' ELSE 'open' END",
+		parent: "t.id, t.title",
+		join:   "JOIN tasks t ON t.id = e.task_id",
+		where:  "t.deleted_at IS NULL",
+	},
+}
+
+func (r *SearchRepository) searchEntities(plan searchPlan, es entitySearch, limit int, after []interface{}) ([]model.SearchResult, error) {
+	var conditions []string
+	var args []interface{}
+
+	notes := "''"
+	if es.notes != "" {
+		notes = "e." + es.notes
+	}
+	columns := "'', '', 0"
+	from := es.table + " e"
+	keys := searchKeysets["titled"]
+	if len(plan.terms) > 0 {
+		notesSnippet := "''"
+		if es.notes != "" {
+			notesSnippet = "snippet(" + es.fts + ", 1, '<mark>', '</mark>', '...', 32)"
+		}
+		columns = "snippet(" + es.fts + ", 0, '<mark>', '</mark>', '...', 32), " + notesSnippet + ", rank"
+		from = es.fts + " JOIN " + es.table + " e ON e.rowid = " + es.fts + ".rowid"
+		keys = searchKeysets["ranked"]
+		conditions = append(conditions, es.fts+" MATCH ?")
+		args = append(args, strings.Join(plan.terms, " AND "))
+	}
+	for _, term := range plan.negated {
+		conditions = append(conditions, "e.rowid NOT IN (SELECT rowid FROM "+es.fts+" WHERE "+es.fts+" MATCH ?)")
+		args = append(args, term)
+	}
+	if es.where != "" {
+		conditions = append(conditions, es.where)
+	}
+	if es.typ == "checklist_item" {
+		filterConds, filterArgs := plan.filterConditions()
+		conditions = append(conditions, filterConds...)
+		args = append(args, filterArgs...)
+	}
+	if after != nil {
+		if len(after) != len(keys.terms) {
+			return nil, ErrInvalidCursor
+		}
+		cond, afterArgs := keys.condition(after)
+		conditions = append(conditions, cond)
+		args = append(args, afterArgs...)
+	}
+	if len(conditions) == 0 {
+		conditions = append(conditions, "1")
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(`
+		SELECT e.id, e.title, `+notes+`, `+es.status+`, `+es.parent+`, `+columns+`
+		FROM `+from+` `+es.join+`
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+keys.orderBy()+`
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search %ss: %w", es.typ, err)
+	}
+	defer rows.Close()
+
+	var results []model.SearchResult
+	var taskIDs []string
+	for rows.Next() {
+		sr := model.SearchResult{Type: es.typ}
+		var notesValue string
+		var parentID, parentTitle sql.NullString
+		if err := rows.Scan(&sr.ID, &sr.Title, &notesValue, &sr.Status, &parentID, &parentTitle,
+			&sr.TitleSnippet, &sr.NotesSnippet, &sr.Rank); err != nil {
+			return nil, fmt.Errorf("scan %s result: %w", es.typ, err)
+		}
+		if e2ee.IsEncrypted(notesValue) {
+			sr.NotesSnippet = ""
+		}
+		if e2ee.IsEncrypted(sr.Title) {
+			sr.TitleSnippet = ""
+		}
+		if parentID.Valid {
+			sr.Parent = &model.Ref{ID: parentID.String, Title: parentTitle.String}
+			if es.typ == "checklist_item" {
+				taskIDs = append(taskIDs, parentID.String)
+			}
+		}
+		results = append(results, sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// A checklist item carries its task, so clients can show where it lives.
+	if len(taskIDs) > 0 {
+		tasks, err := r.tasksByID(taskIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range results {
+			results[i].Task = tasks[results[i].Parent.ID]
+		}
+	}
+	return results, nil
+}
+
+func (r *SearchRepository) tasksByID(ids []string) (map[string]*model.TaskListItem, error) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := r.db.Query(`SELECT `+searchTaskColumns+` FROM tasks t `+searchTaskJoins+` WHERE t.id IN (`+strings.Join(placeholders, ",")+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("load search tasks: %w", err)
+	}
+	defer rows.Close()
+	tasks := make(map[string]*model.TaskListItem, len(ids))
+	for rows.Next() {
+		t, err := scanSearchTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks[t.ID] = t
+	}
+	return tasks, rows.Err()
+}
+
+// searchFilterSQL returns the condition for one filter of a search query.
+func searchFilterSQL(f searchquery.Filter, today time.Time) (string, []interface{}) {
+	date := searchquery.ResolveDate(f.Value, today)
+	switch f.Key {
+	case "tag":
+		return `t.id IN (SELECT tt.task_id FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+			WHERE g.id = ? OR g.title = ? COLLATE NOCASE)`, []interface{}{f.Value, f.Value}
+	case "project":
+		return "t.project_id IN (SELECT id FROM projects WHERE id = ? OR title = ? COLLATE NOCASE)", []interface{}{f.Value, f.Value}
+	case "area":
+		// Tasks in a project belong to the project's area.
+		return `(t.area_id IN (SELECT id FROM areas WHERE id = ? OR title = ? COLLATE NOCASE)
+			OR t.project_id IN (SELECT p.id FROM projects p JOIN areas a ON a.id = p.area_id WHERE a.id = ? OR a.title = ? COLLATE NOCASE))`,
+			[]interface{}{f.Value, f.Value, f.Value, f.Value}
+	case "heading":
+		return "t.heading_id IN (SELECT id FROM headings WHERE id = ? OR title = ? COLLATE NOCASE)", []interface{}{f.Value, f.Value}
+	case "priority":
+		if f.Value == "high" {
+			return "t.high_priority = 1", nil
+		}
+		return "t.high_priority = 0", nil
+	case "is":
+		switch f.Value {
+		case "someday":
+			return "t.when_date = 'someday'", nil
+		case "inbox":
+			return "(t.project_id IS NULL AND t.area_id IS NULL AND t.when_date IS NULL AND t.status = 'open')", nil
+		case "repeating":
+			return "EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id)", nil
+		case "overdue":
+			return "(t.status = 'open' AND t.deadline < ?)", []interface{}{today.Format("2006-01-02")}
+		}
+		return "t.status = ?", []interface{}{f.Value}
+	case "has":
+		switch f.Value {
+		case "attachment":
+			return "EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id)", nil
+		case "file", "link":
+			return "EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = ?)", []interface{}{f.Value}
+		case "notes":
+			return "t.notes != ''", nil
+		case "deadline":
+			return "t.deadline IS NOT NULL", nil
+		case "checklist":
+			return "EXISTS(SELECT 1 FROM checklist_items WHERE task_id = t.id)", nil
+		case "reminder":
+			return "EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id)", nil
+		case "repeat":
+			return "EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id)", nil
+		case "tag":
+			return "EXISTS(SELECT 1 FROM task_tags WHERE task_id = t.id)", nil
+		}
+	case "due":
+		if f.Value == "none" {
+			return "t.deadline IS NULL", nil
+		}
+		return "t.deadline " + f.Op + " ?", []interface{}{date}
+	case "when":
+		switch f.Value {
+		case "none":
+			return "t.when_date IS NULL", nil
+		case "someday":
+			return "t.when_date = 'someday'", nil
+		}
+		return "(t.when_date " + f.Op + " ? AND t.when_date != 'someday')", []interface{}{date}
+	case "created":
+		return "date(t.created_at) " + f.Op + " ?", []interface{}{date}
+	case "completed":
+		if f.Value == "none" {
+			return "t.completed_at IS NULL", nil
+		}
+		return "date(t.completed_at) " + f.Op + " ?", []interface{}{date}
 	}
-	return results, rows.Err()
+	return "1", nil
 }
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
+)
+
+// Near spellings are words from the search vocabularies whose trigrams
+// overlap a query word's by at least minSimilarity. Up to maxNearSpellings
+// of the closest are tried per word.
+const (
+	minSimilarity    = 0.3
+	maxNearSpellings = 3
+	minFuzzyLength   = 3
+)
+
+// searchVocab lists every indexed word, limited by length with the
+// arguments repeated per table.
+const searchVocab = `
+	SELECT term FROM tasks_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM projects_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM areas_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM headings_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM checklist_items_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM tags_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM attachments_fts_vocab WHERE length(term) BETWEEN ? AND ?
+	UNION SELECT term FROM attachment_text_fts_vocab WHERE length(term) BETWEEN ? AND ?`
+
+// synonymMap maps each word of a synonym group to the group's other words.
+func (r *SearchRepository) synonymMap() (map[string][]string, error) {
+	groups, err := listSynonymGroups(r.db)
+	if err != nil {
+		return nil, err
+	}
+	synonyms := map[string][]string{}
+	for _, g := range groups {
+		for _, term := range g.Terms {
+			for _, other := range g.Terms {
+				if other != term {
+					synonyms[term] = append(synonyms[term], other)
+				}
+			}
+		}
+	}
+	return synonyms, nil
+}
+
+// addNearSpellings lets each plain word of the plan also match indexed words
+// spelled like it. It reports whether any were found.
+func (r *SearchRepository) addNearSpellings(plan *searchPlan) (bool, error) {
+	added := false
+	for i, word := range plan.words {
+		text := strings.ToLower(word.Text)
+		n := utf8.RuneCountInString(text)
+		if word.Phrase || n < minFuzzyLength || strings.ContainsAny(text, " \t") {
+			continue
+		}
+		var args []interface{}
+		for range 8 {
+			args = append(args, n-2, n+2)
+		}
+		rows, err := r.db.Query(searchVocab, args...)
+		if err != nil {
+			return false, err
+		}
+		near, err := closestWords(rows, text)
+		if err != nil {
+			return false, err
+		}
+		for _, w := range near {
+			plan.alts[i] = append(plan.alts[i], ftsTerm(searchquery.Term{Text: w, Phrase: true}))
+			added = true
+		}
+		plan.terms[i] = ftsAny(plan.alts[i])
+	}
+	return added, nil
+}
+
+// closestWords returns the vocabulary words most similar to word, leaving
+// out those word already matches as a prefix.
+func closestWords(rows *sql.Rows, word string) ([]string, error) {
+	defer rows.Close()
+	target := trigrams(word)
+	type candidate struct {
+		word  string
+		score float64
+	}
+	var found []candidate
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(term, word) {
+			continue
+		}
+		if score := similarity(target, trigrams(term)); score >= minSimilarity {
+			found = append(found, candidate{term, score})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score > found[j].score
+		}
+		return found[i].word < found[j].word
+	})
+	var words []string
+	for i := 0; i < len(found) && i < maxNearSpellings; i++ {
+		words = append(words, found[i].word)
+	}
+	return words, nil
+}
+
+// trigrams returns the set of three-letter runs of a word padded like
+// pg_trgm, so that the start of a word weighs more than its end.
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// similarity is the share of trigrams two words have in common.
+func similarity(a, b map[string]bool) float64 {
+	shared := 0
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
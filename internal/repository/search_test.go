@@ -1,10 +1,14 @@
 package repository_test
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
 	"github.com/collinjanssen/thingstodo/internal/testutil"
 )
 
@@ -97,3 +101,351 @@ func TestSearchLimit(t *testing.T) {
 		t.Errorf("expected at most 3 results, got %d", len(results))
 	}
 }
+
+func TestSearchPaged(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	areaRepo := repository.NewAreaRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	for i := 0; i < 5; i++ {
+		_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Test task for search"})
+	}
+	for i := 0; i < 3; i++ {
+		_, _ = areaRepo.Create(model.CreateAreaInput{Title: fmt.Sprintf("Test area %d", i)})
+	}
+
+	seen := map[string]bool{}
+	var pages []map[string]int
+	page := model.Page{Limit: 2}
+	for {
+		results, next, err := searchRepo.SearchPaged("test", page)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		counts := map[string]int{}
+		for _, r := range results {
+			if seen[r.ID] {
+				t.Errorf("result %s came up twice", r.ID)
+			}
+			seen[r.ID] = true
+			counts[r.Type]++
+		}
+		pages = append(pages, counts)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	want := []map[string]int{{"task": 2, "area": 2}, {"task": 2, "area": 1}, {"task": 1}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("expected pages %v, got %v", want, pages)
+	}
+}
+
+func TestSearchSkipsEncryptedNotes(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	task, _ := taskRepo.Create(model.CreateTaskInput{Title: "Bank details", Notes: "account number"})
+	encrypted := "ttd:enc:v1:c2VjcmV0IGNpcGhlcnRleHQ="
+	if _, err := taskRepo.Update(task.ID, model.UpdateTaskInput{Notes: &encrypted}); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	for _, q := range []string{"account", "enc"} {
+		results, err := searchRepo.Search(q, 20)
+		if err != nil {
+			t.Fatalf("search %q failed: %v", q, err)
+		}
+		if len(results) != 0 {
+			t.Errorf("expected no results for %q, got %d", q, len(results))
+		}
+	}
+
+	results, err := searchRepo.Search("bank", 20)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected title to stay searchable, got %d results", len(results))
+	}
+	if results[0].NotesSnippet != "" {
+		t.Errorf("expected no notes snippet for encrypted notes, got %q", results[0].NotesSnippet)
+	}
+}
+
+func TestSearchQueryFilters(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	areaRepo := repository.NewAreaRepository(db, nil)
+	projRepo := repository.NewProjectRepository(db, nil)
+	tagRepo := repository.NewTagRepository(db, nil)
+	attachRepo := repository.NewAttachmentRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	area, _ := areaRepo.Create(model.CreateAreaInput{Title: "Finance"})
+	project, _ := projRepo.Create(model.CreateProjectInput{Title: "Q3 close", AreaID: &area.ID})
+	work, _ := tagRepo.Create(model.CreateTagInput{Title: "Work"})
+	early, late := "2026-10-20", "2026-11-15"
+
+	target, _ := taskRepo.Create(model.CreateTaskInput{
+		Title: "Send invoice", ProjectID: &project.ID, TagIDs: []string{work.ID},
+		Deadline: &early, HighPriority: true,
+	})
+	if _, err := attachRepo.Create(target.ID, model.CreateAttachmentInput{Type: "link", Title: "Portal", URL: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Check invoice", ProjectID: &project.ID, TagIDs: []string{work.ID}, Deadline: &late})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Invoice template", TagIDs: []string{work.ID}, Deadline: &early})
+	done, _ := taskRepo.Create(model.CreateTaskInput{Title: "Paid invoice", ProjectID: &project.ID, Deadline: &early})
+	if _, err := taskRepo.Complete(done.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		`invoice tag:work project:"Q3 close" due:<2026-11-01 is:open has:attachment priority:high`: {"Send invoice"},
+		`invoice tag:work due:<2026-11-01`:               {"Send invoice", "Invoice template"},
+		`project:"q3 close" is:completed`:                {"Paid invoice"},
+		`area:Finance -is:completed due:>=2026-11-01`:    {"Check invoice"},
+		`invoice -tag:work`:                              {"Paid invoice"},
+		`"invoice template"`:                             {"Invoice template"},
+		`invoice -template has:tag -project:"Q3 close"`:  {},
+		`priority:normal has:deadline is:open -tag:work`: {},
+	}
+	for query, want := range cases {
+		results, err := searchRepo.Search(query, 20)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		got := map[string]bool{}
+		for _, r := range results {
+			got[r.Task.Title] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+			continue
+		}
+		for _, title := range want {
+			if !got[title] {
+				t.Errorf("%s: got %v, want %v", query, got, want)
+			}
+		}
+	}
+}
+
+func TestSearchQueryParseError(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	searchRepo := repository.NewSearchRepository(db)
+
+	_, err := searchRepo.Search("invoice due:<soon", 20)
+	var parseErr *searchquery.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+	if parseErr.Pos != 13 || parseErr.End != 17 {
+		t.Errorf("expected the error at 13-17, got %d-%d", parseErr.Pos, parseErr.End)
+	}
+}
+
+func TestSearchAcrossTypes(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	areaRepo := repository.NewAreaRepository(db, nil)
+	projRepo := repository.NewProjectRepository(db, nil)
+	headingRepo := repository.NewHeadingRepository(db, nil)
+	checklistRepo := repository.NewChecklistRepository(db, nil)
+	attachRepo := repository.NewAttachmentRepository(db, nil)
+	tagRepo := repository.NewTagRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	area, _ := areaRepo.Create(model.CreateAreaInput{Title: "Garden"})
+	project, _ := projRepo.Create(model.CreateProjectInput{Title: "Spring prep", Notes: "order compost early", AreaID: &area.ID})
+	if _, err := headingRepo.Create(project.ID, model.CreateHeadingInput{Title: "Compost bins"}); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := taskRepo.Create(model.CreateTaskInput{Title: "Weekend chores", ProjectID: &project.ID})
+	if _, err := checklistRepo.Create(task.ID, model.CreateChecklistInput{Title: "Turn the compost"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attachRepo.Create(task.ID, model.CreateAttachmentInput{Type: "link", Title: "Guide", URL: "https://seeds.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	errand, _ := tagRepo.Create(model.CreateTagInput{Title: "Errand"})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Pick up mulch", TagIDs: []string{errand.ID}})
+
+	results, err := searchRepo.Search("compost", 20)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	var types []string
+	for _, r := range results {
+		types = append(types, r.Type)
+	}
+	if want := []string{"project", "heading", "checklist_item"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("expected types %v, got %v", want, types)
+	}
+	if results[0].NotesSnippet == "" || results[0].Parent == nil || results[0].Parent.Title != "Garden" {
+		t.Errorf("expected the project with a notes snippet and its area, got %+v", results[0])
+	}
+	if results[1].Parent == nil || results[1].Parent.ID != project.ID {
+		t.Errorf("expected the heading's project as parent, got %+v", results[1].Parent)
+	}
+	if results[2].Task == nil || results[2].Task.ID != task.ID || results[2].Status != "open" {
+		t.Errorf("expected the checklist item with its task, got %+v", results[2])
+	}
+
+	results, _ = searchRepo.Search("garden", 20)
+	if len(results) != 1 || results[0].Type != "area" {
+		t.Errorf("expected the area, got %+v", results)
+	}
+
+	// Tasks also match on their link attachments and tag names.
+	for query, title := range map[string]string{"seeds": "Weekend chores", "errand": "Pick up mulch"} {
+		results, _ = searchRepo.Search(query, 20)
+		if len(results) != 1 || results[0].Type != "task" || results[0].Task.Title != title {
+			t.Errorf("%s: expected task %q, got %+v", query, title, results)
+		}
+	}
+}
+
+func TestSearchTypeFilter(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	areaRepo := repository.NewAreaRepository(db, nil)
+	projRepo := repository.NewProjectRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	area, _ := areaRepo.Create(model.CreateAreaInput{Title: "Work"})
+	_, _ = projRepo.Create(model.CreateProjectInput{Title: "Launch website", AreaID: &area.ID})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Launch checklist"})
+
+	cases := map[string][]string{
+		"launch":                {"task", "project"},
+		"launch type:project":   {"project"},
+		"launch -type:project":  {"task"},
+		"launch is:open":        {"task"},
+		"type:project":          {"project"},
+		"launch type:heading":   nil,
+		"launch type:checklist": nil,
+	}
+	for query, want := range cases {
+		results, err := searchRepo.Search(query, 20)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Type)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestSearchNearSpellings(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Buy groceries"})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Schedule dentist appointment"})
+
+	for query, want := range map[string]string{
+		"grocreies":          "Buy groceries",
+		"dentsit":            "Schedule dentist appointment",
+		"apointment dentist": "Schedule dentist appointment",
+	} {
+		results, err := searchRepo.Search(query, 20)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(results) != 1 || results[0].Title != want {
+			t.Errorf("%s: expected %q, got %+v", query, want, results)
+		}
+	}
+
+	// Phrases are taken literally and unrelated words find nothing.
+	for _, query := range []string{`"grocreies"`, "xylophone"} {
+		results, _ := searchRepo.Search(query, 20)
+		if len(results) != 0 {
+			t.Errorf("%s: expected no results, got %+v", query, results)
+		}
+	}
+}
+
+func TestSearchSynonyms(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	synonymRepo := repository.NewSynonymRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+
+	terms := repository.NormalizeSynonyms([]string{" Car ", "automobile", "car", "", "motor  vehicle"})
+	if want := []string{"car", "automobile", "motor vehicle"}; !reflect.DeepEqual(terms, want) {
+		t.Fatalf("expected %v, got %v", want, terms)
+	}
+	group, err := synonymRepo.Create(model.CreateSynonymGroupInput{Terms: terms})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Service the automobile"})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Register motor vehicle"})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Car wash"})
+
+	results, err := searchRepo.Search("car", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected all three tasks, got %+v", results)
+	}
+	results, _ = searchRepo.Search("automobile -wash", 20)
+	if len(results) != 2 {
+		t.Errorf("expected two tasks, got %+v", results)
+	}
+
+	if err := synonymRepo.Delete(group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := synonymRepo.Delete(group.ID); !errors.Is(err, repository.ErrSynonymGroupNotFound) {
+		t.Errorf("expected not found on second delete, got %v", err)
+	}
+	results, _ = searchRepo.Search("automobile", 20)
+	if len(results) != 1 {
+		t.Errorf("expected only the literal match once the group is gone, got %+v", results)
+	}
+}
+
+func TestSearchRanksOpenPriorityTasksFirst(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	areaRepo := repository.NewAreaRepository(db, nil)
+	projRepo := repository.NewProjectRepository(db, nil)
+	searchRepo := repository.NewSearchRepository(db)
+
+	area, _ := areaRepo.Create(model.CreateAreaInput{Title: "Office"})
+	project, _ := projRepo.Create(model.CreateProjectInput{Title: "Finance", AreaID: &area.ID})
+	done, _ := taskRepo.Create(model.CreateTaskInput{Title: "Quarterly report"})
+	if _, err := taskRepo.Complete(done.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Quarterly report", ProjectID: &project.ID})
+	urgent, _ := taskRepo.Create(model.CreateTaskInput{Title: "Quarterly report", HighPriority: true})
+
+	results, err := searchRepo.Search("quarterly type:task", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[0].ID != urgent.ID || results[2].ID != done.ID {
+		t.Errorf("expected high priority first and completed last, got %s, %s, %s",
+			results[0].Status, results[1].Status, results[2].Status)
+	}
+	if name := results[1].Task.ProjectName; name == nil || *name != "Finance" {
+		t.Errorf("expected the project name, got %v", name)
+	}
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// SeriesRepository reads the instances of repeating tasks grouped by their
+// series_id to report habit statistics.
+type SeriesRepository struct {
+	db *sql.DB
+}
+
+func NewSeriesRepository(db *sql.DB) *SeriesRepository {
+	return &SeriesRepository{db: db}
+}
+
+type seriesInstance struct {
+	id          string
+	title       string
+	status      string
+	date        string // occurrence date; "" when the instance has none
+	completedOn string
+}
+
+// Stats returns the statistics of one series with a per-day heat map from
+// from to to (inclusive). today decides which open instances are missed. It
+// returns nil when no instance outside the trash belongs to the series.
+func (r *SeriesRepository) Stats(seriesID, from, to, today string) (*model.SeriesStats, error) {
+	instances, err := r.instances("series_id = ?", seriesID)
+	if err != nil {
+		return nil, err
+	}
+	list := instances[seriesID]
+	if len(list) == 0 {
+		return nil, nil
+	}
+	stats := buildSeriesStats(seriesID, list, from, to, today)
+	return &stats, nil
+}
+
+// List returns the statistics of every series, ordered by title.
+func (r *SeriesRepository) List(from, to, today string) ([]model.SeriesStats, error) {
+	instances, err := r.instances("series_id IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]model.SeriesStats, 0, len(instances))
+	for id, series := range instances {
+		list = append(list, buildSeriesStats(id, series, from, to, today))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Title != list[j].Title {
+			return list[i].Title < list[j].Title
+		}
+		return list[i].SeriesID < list[j].SeriesID
+	})
+	return list, nil
+}
+
+// instances loads the non-trashed tasks matching where, grouped by series and
+// ordered by occurrence date.
+func (r *SeriesRepository) instances(where string, args ...interface{}) (map[string][]seriesInstance, error) {
+	rows, err := r.db.Query(`
+		SELECT series_id, id, title, status, COALESCE(when_date, ''),
+			COALESCE(substr(completed_at, 1, 10), ''), created_at
+		FROM tasks
+		WHERE `+where+` AND deleted_at IS NULL
+		ORDER BY series_id, created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list series instances: %w", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		seriesInstance
+		createdAt string
+	}
+	grouped := map[string][]row{}
+	for rows.Next() {
+		var seriesID string
+		var in row
+		if err := rows.Scan(&seriesID, &in.id, &in.title, &in.status, &in.date, &in.completedOn, &in.createdAt); err != nil {
+			return nil, fmt.Errorf("scan series instance: %w", err)
+		}
+		if _, err := time.Parse("2006-01-02", in.date); err != nil {
+			in.date = "" // someday or unscheduled
+		}
+		grouped[seriesID] = append(grouped[seriesID], in)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]seriesInstance, len(grouped))
+	for id, list := range grouped {
+		// Undated instances sort by when they were completed or created.
+		key := func(in row) string {
+			switch {
+			case in.date != "":
+				return in.date
+			case in.completedOn != "":
+				return in.completedOn
+			}
+			return in.createdAt
+		}
+		sort.SliceStable(list, func(i, j int) bool { return key(list[i]) < key(list[j]) })
+		for _, in := range list {
+			result[id] = append(result[id], in.seriesInstance)
+		}
+	}
+	return result, nil
+}
+
+// buildSeriesStats works out the statistics of a series from its instances
+// in occurrence order.
+func buildSeriesStats(seriesID string, instances []seriesInstance, from, to, today string) model.SeriesStats {
+	stats := model.SeriesStats{
+		SeriesID:    seriesID,
+		MissedDates: []string{},
+		From:        from,
+		To:          to,
+		Days:        []model.SeriesDay{},
+	}
+
+	due := map[string]int{}
+	completed := map[string]int{}
+	streak := 0
+	for _, in := range instances {
+		stats.Title = in.title
+		if in.date != "" {
+			due[in.date]++
+		}
+
+		switch {
+		case in.status == "completed":
+			stats.Completed++
+			streak++
+			stats.LongestStreak = max(stats.LongestStreak, streak)
+			day := in.completedOn
+			if day == "" {
+				day = in.date
+			}
+			completed[day]++
+		case in.status == "canceled" || in.status == "wont_do" || (in.status == "open" && in.date != "" && in.date < today):
+			stats.Missed++
+			streak = 0
+			if in.date != "" {
+				stats.MissedDates = append(stats.MissedDates, in.date)
+			}
+		}
+		if in.status == "open" {
+			id := in.id
+			stats.TaskID = &id
+		}
+	}
+	stats.CurrentStreak = streak
+	stats.Occurrences = stats.Completed + stats.Missed
+	if stats.Occurrences > 0 {
+		stats.CompletionRate = float64(stats.Completed) / float64(stats.Occurrences)
+	}
+
+	start, err1 := time.Parse("2006-01-02", from)
+	end, err2 := time.Parse("2006-01-02", to)
+	if err1 == nil && err2 == nil {
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			date := d.Format("2006-01-02")
+			stats.Days = append(stats.Days, model.SeriesDay{Date: date, Due: due[date], Completed: completed[date]})
+		}
+	}
+	return stats
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+func TestSeriesStatsStreaksAndMissedOccurrences(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewSeriesRepository(db)
+
+	for _, row := range []struct {
+		id, status, when, completed string
+	}{
+		{"t1", "completed", "2026-03-02", "2026-03-02 08:00:00"},
+		{"t2", "completed", "2026-03-03", "2026-03-04 09:00:00"},
+		{"t3", "canceled", "2026-03-04", ""},
+		{"t4", "completed", "2026-03-05", "2026-03-05 07:00:00"},
+		{"t5", "completed", "2026-03-06", "2026-03-06 07:00:00"},
+		{"t6", "completed", "2026-03-07", "2026-03-07 07:00:00"},
+		{"t7", "open", "2026-03-08", ""},
+	} {
+		var completedAt interface{}
+		if row.completed != "" {
+			completedAt = row.completed
+		}
+		if _, err := db.Exec(`INSERT INTO tasks (id, title, status, when_date, completed_at, series_id)
+			VALUES (?, 'Stretch', ?, ?, ?, 's1')`, row.id, row.status, row.when, completedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = db.Exec(`INSERT INTO tasks (id, title, status, when_date, series_id, deleted_at)
+		VALUES ('t0', 'Stretch', 'open', '2026-03-01', 's1', datetime('now'))`)
+
+	stats, err := repo.Stats("s1", "2026-03-01", "2026-03-09", "2026-03-08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats == nil {
+		t.Fatal("expected stats")
+	}
+	if stats.Completed != 5 || stats.Missed != 1 || stats.Occurrences != 6 {
+		t.Errorf("counts = %d completed, %d missed, %d occurrences", stats.Completed, stats.Missed, stats.Occurrences)
+	}
+	if stats.CurrentStreak != 3 || stats.LongestStreak != 3 {
+		t.Errorf("streaks = %d current, %d longest", stats.CurrentStreak, stats.LongestStreak)
+	}
+	if len(stats.MissedDates) != 1 || stats.MissedDates[0] != "2026-03-04" {
+		t.Errorf("missed dates = %v", stats.MissedDates)
+	}
+	if stats.TaskID == nil || *stats.TaskID != "t7" {
+		t.Errorf("task id = %v, want t7", stats.TaskID)
+	}
+	if len(stats.Days) != 9 {
+		t.Fatalf("days = %d, want 9", len(stats.Days))
+	}
+	// t2 was due on the 3rd but completed on the 4th.
+	if d := stats.Days[2]; d.Date != "2026-03-03" || d.Due != 1 || d.Completed != 0 {
+		t.Errorf("day 3 = %+v", d)
+	}
+	if d := stats.Days[3]; d.Due != 1 || d.Completed != 1 {
+		t.Errorf("day 4 = %+v", d)
+	}
+
+	// Once the open instance is overdue it counts as missed and breaks the streak.
+	stats, _ = repo.Stats("s1", "2026-03-01", "2026-03-09", "2026-03-09")
+	if stats.Missed != 2 || stats.CurrentStreak != 0 || stats.LongestStreak != 3 {
+		t.Errorf("after overdue: missed %d, current %d, longest %d", stats.Missed, stats.CurrentStreak, stats.LongestStreak)
+	}
+
+	if stats, err := repo.Stats("missing", "2026-03-01", "2026-03-09", "2026-03-09"); err != nil || stats != nil {
+		t.Errorf("Stats(missing) = %v, %v; want nil, nil", stats, err)
+	}
+	list, err := repo.List("2026-03-01", "2026-03-09", "2026-03-09")
+	if err != nil || len(list) != 1 || list[0].Title != "Stretch" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+}
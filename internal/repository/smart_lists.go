@@ -0,0 +1,376 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
+)
+
+// smartListLimit caps the tasks a smart list view returns.
+const smartListLimit = 500
+
+// smartListGroupings are the ways a smart list can group its tasks.
+var smartListGroupings = map[string]bool{
+	"none": true, "project": true, "area": true, "tag": true, "when": true, "deadline": true,
+}
+
+// ValidSmartListGroupBy reports whether g is a grouping a smart list can use.
+func ValidSmartListGroupBy(g string) bool {
+	return smartListGroupings[g]
+}
+
+type SmartListRepository struct {
+	db querier
+	reader
+	search *SearchRepository
+}
+
+func NewSmartListRepository(db *sql.DB) *SmartListRepository {
+	return &SmartListRepository{db: db, reader: reader{db}, search: NewSearchRepository(db)}
+}
+
+// SetReader routes the views and counts of smart lists, and the searches
+// they run, to read.
+func (r *SmartListRepository) SetReader(read *sql.DB) {
+	r.read = read
+	r.search = NewSearchRepository(read)
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *SmartListRepository) WithContext(ctx context.Context) *SmartListRepository {
+	c := *r
+	c.db, c.read = withContext(r.db, ctx), withContext(r.read, ctx)
+	c.search = r.search.WithContext(ctx)
+	return &c
+}
+
+const smartListColumns = `id, name, query, sort, group_by, sort_order, created_at, updated_at`
+
+func scanSmartList(row interface{ Scan(...interface{}) error }) (*model.SmartList, error) {
+	var l model.SmartList
+	err := row.Scan(&l.ID, &l.Name, &l.Query, &l.Sort, &l.GroupBy, &l.SortOrder, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// List returns a user's smart lists in their sidebar order.
+func (r *SmartListRepository) List(userID string) ([]model.SmartList, error) {
+	rows, err := r.db.Query(`SELECT `+smartListColumns+` FROM smart_lists
+		WHERE user_id = ? ORDER BY sort_order, name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list smart lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []model.SmartList{}
+	for rows.Next() {
+		l, err := scanSmartList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan smart list: %w", err)
+		}
+		lists = append(lists, *l)
+	}
+	return lists, rows.Err()
+}
+
+// Get returns one of a user's smart lists, or nil if there is none with id.
+func (r *SmartListRepository) Get(userID, id string) (*model.SmartList, error) {
+	l, err := scanSmartList(r.db.QueryRow(`SELECT `+smartListColumns+` FROM smart_lists
+		WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+// Create adds a smart list after the user's others. An empty sort or
+// grouping defaults to "when" and "none".
+func (r *SmartListRepository) Create(userID string, input model.CreateSmartListInput) (*model.SmartList, error) {
+	if input.Sort == "" {
+		input.Sort = "when"
+	}
+	if input.GroupBy == "" {
+		input.GroupBy = "none"
+	}
+	id := model.NewID()
+	_, err := r.db.Exec(`
+		INSERT INTO smart_lists (id, user_id, name, query, sort, group_by, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM smart_lists WHERE user_id = ?))`,
+		id, userID, input.Name, input.Query, input.Sort, input.GroupBy, userID)
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return nil, ErrDuplicateSmartListName
+		}
+		return nil, fmt.Errorf("create smart list: %w", err)
+	}
+	return r.Get(userID, id)
+}
+
+// Update changes the given fields of a smart list. It returns nil if the
+// user has no smart list with id.
+func (r *SmartListRepository) Update(userID, id string, input model.UpdateSmartListInput) (*model.SmartList, error) {
+	sets := []string{"updated_at = datetime('now')"}
+	var args []interface{}
+	if input.Name != nil {
+		sets, args = append(sets, "name = ?"), append(args, *input.Name)
+	}
+	if input.Query != nil {
+		sets, args = append(sets, "query = ?"), append(args, *input.Query)
+	}
+	if input.Sort != nil {
+		sets, args = append(sets, "sort = ?"), append(args, *input.Sort)
+	}
+	if input.GroupBy != nil {
+		sets, args = append(sets, "group_by = ?"), append(args, *input.GroupBy)
+	}
+	if input.SortOrder != nil {
+		sets, args = append(sets, "sort_order = ?"), append(args, *input.SortOrder)
+	}
+	args = append(args, id, userID)
+	if _, err := r.db.Exec("UPDATE smart_lists SET "+strings.Join(sets, ", ")+" WHERE id = ? AND user_id = ?", args...); err != nil {
+		if isUniqueConstraintError(err) {
+			return nil, ErrDuplicateSmartListName
+		}
+		return nil, fmt.Errorf("update smart list: %w", err)
+	}
+	return r.Get(userID, id)
+}
+
+// Delete removes one of a user's smart lists.
+func (r *SmartListRepository) Delete(userID, id string) error {
+	res, err := r.db.Exec("DELETE FROM smart_lists WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("delete smart list: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSmartListNotFound
+	}
+	return nil
+}
+
+// statusValues are the is: filter values that pick tasks by status.
+var statusValues = map[string]bool{"open": true, "completed": true, "canceled": true, "wont_do": true}
+
+// conditions returns the conditions on tasks aliased t for a smart list
+// query narrowed by f. Unless the query picks a status with is:, only open
+// tasks match.
+func (r *SmartListRepository) conditions(query string, f model.ViewFilter) ([]string, []interface{}, error) {
+	q, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	synonyms, err := r.search.synonymMap()
+	if err != nil {
+		return nil, nil, err
+	}
+	conditions, args := newSearchPlan(q, synonyms).taskConditions()
+	filterConds, filterArgs := viewFilterConditions(f)
+	conditions, args = append(conditions, filterConds...), append(args, filterArgs...)
+	for _, filter := range q.Filters {
+		if filter.Key == "is" && statusValues[filter.Value] {
+			return conditions, args, nil
+		}
+	}
+	return append(conditions, "t.status = 'open'"), args, nil
+}
+
+// View evaluates a smart list into its tasks, sorted and grouped as the list
+// says. f narrows the tasks further, and its sort, if any, replaces the
+// list's. At most smartListLimit tasks are returned; Count is the full number.
+func (r *SmartListRepository) View(list *model.SmartList, f model.ViewFilter) (*model.SmartListView, error) {
+	conditions, args, err := r.conditions(list.Query, f)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.read.Query(`
+		SELECT `+searchTaskColumns+`,
+			COALESCE(t.area_id, pr.area_id),
+			(SELECT title FROM areas WHERE id = COALESCE(t.area_id, pr.area_id))
+		FROM tasks t
+		`+searchTaskJoins+`
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+viewOrder(f, taskOrder(list.Sort))+`
+		LIMIT ?`, append(args, smartListLimit)...)
+	if err != nil {
+		return nil, fmt.Errorf("smart list tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []model.TaskListItem
+	areas := map[string]*model.Ref{} // by task ID, counting a project's area
+	for rows.Next() {
+		var areaID, areaTitle sql.NullString
+		t, err := scanSearchTask(rows, &areaID, &areaTitle)
+		if err != nil {
+			return nil, err
+		}
+		if areaID.Valid {
+			areas[t.ID] = &model.Ref{ID: areaID.String, Title: areaTitle.String}
+		}
+		tasks = append(tasks, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadListTags(r.read, tasks); err != nil {
+		return nil, err
+	}
+	view := &model.SmartListView{SmartList: *list, Groups: r.group(list.GroupBy, tasks, areas), Count: len(tasks)}
+	if len(tasks) == smartListLimit {
+		if view.Count, err = r.count(conditions, args); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
+}
+
+func (r *SmartListRepository) count(conditions []string, args []interface{}) (int, error) {
+	var n int
+	err := r.read.QueryRow(`SELECT COUNT(*) FROM tasks t `+searchTaskJoins+`
+		WHERE `+strings.Join(conditions, " AND "), args...).Scan(&n)
+	return n, err
+}
+
+// Counts returns the number of tasks in each of a user's smart lists, by ID,
+// narrowed by f.
+func (r *SmartListRepository) Counts(userID string, f model.ViewFilter) (map[string]int, error) {
+	lists, err := r.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(lists))
+	for _, l := range lists {
+		conditions, args, err := r.conditions(l.Query, f)
+		if err != nil {
+			// Saved queries are checked when written; skip one that no
+			// longer parses rather than failing every count.
+			continue
+		}
+		if counts[l.ID], err = r.count(conditions, args); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// loadListTags fills in the tags of a list of tasks in one query.
+func loadListTags(db querier, tasks []model.TaskListItem) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(tasks))
+	placeholders := make([]string, len(tasks))
+	args := make([]interface{}, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+		placeholders[i] = "?"
+		args[i] = t.ID
+	}
+	rows, err := db.Query(`
+		SELECT tt.task_id, g.id, g.title, g.color
+		FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.task_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY g.sort_order`, args...)
+	if err != nil {
+		return fmt.Errorf("load task tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID string
+		var tag model.TagRef
+		if err := rows.Scan(&taskID, &tag.ID, &tag.Title, &tag.Color); err != nil {
+			return fmt.Errorf("scan task tag: %w", err)
+		}
+		t := &tasks[index[taskID]]
+		t.Tags = append(t.Tags, tag)
+	}
+	return rows.Err()
+}
+
+// group splits sorted tasks into groups. Groups come in the order their
+// first task does, except that dates go in date order, and tasks without a
+// project, area, tag or date come last.
+func (r *SmartListRepository) group(by string, tasks []model.TaskListItem, areas map[string]*model.Ref) []model.TaskGroup {
+	if len(tasks) == 0 {
+		return []model.TaskGroup{}
+	}
+	switch by {
+	case "project":
+		return groupByProject(r.read, tasks)
+	case "none", "":
+		return []model.TaskGroup{{Tasks: tasks}}
+	}
+
+	type bucket struct {
+		key, title string
+		tasks      []model.TaskListItem
+	}
+	var buckets []*bucket
+	byKey := map[string]*bucket{}
+	add := func(key, title string, t model.TaskListItem) {
+		b := byKey[key]
+		if b == nil {
+			b = &bucket{key: key, title: title}
+			byKey[key] = b
+			buckets = append(buckets, b)
+		}
+		b.tasks = append(b.tasks, t)
+	}
+	for _, t := range tasks {
+		switch by {
+		case "area":
+			if a := areas[t.ID]; a != nil {
+				add(a.ID, a.Title, t)
+			} else {
+				add("", "No Area", t)
+			}
+		case "tag":
+			for _, tag := range t.Tags {
+				add(tag.ID, tag.Title, t)
+			}
+			if len(t.Tags) == 0 {
+				add("", "No Tags", t)
+			}
+		case "when":
+			switch {
+			case t.WhenDate == nil:
+				add("", "No Date", t)
+			case *t.WhenDate == "someday":
+				add("someday", "Someday", t)
+			default:
+				add(*t.WhenDate, *t.WhenDate, t)
+			}
+		case "deadline":
+			if t.Deadline == nil {
+				add("", "No Deadline", t)
+			} else {
+				add(*t.Deadline, *t.Deadline, t)
+			}
+		}
+	}
+
+	byDate := by == "when" || by == "deadline"
+	sort.SliceStable(buckets, func(i, j int) bool {
+		a, b := buckets[i].key, buckets[j].key
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		// Dates sort as text, and "someday" after all of them.
+		return byDate && a < b
+	})
+	groups := make([]model.TaskGroup, len(buckets))
+	for i, b := range buckets {
+		groups[i] = model.TaskGroup{Title: b.title, Tasks: b.tasks}
+	}
+	return groups
+}
@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+func TestSmartListView(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	user, err := repository.NewUserRepository(db).Create("admin", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskRepo := repository.NewTaskRepository(db, nil)
+	tagRepo := repository.NewTagRepository(db, nil)
+	repo := repository.NewSmartListRepository(db)
+
+	work, _ := tagRepo.Create(model.CreateTagInput{Title: "work"})
+	home, _ := tagRepo.Create(model.CreateTagInput{Title: "home"})
+	report, _ := taskRepo.Create(model.CreateTaskInput{Title: "Write report", Deadline: strPtr("2026-05-02"), TagIDs: []string{work.ID}})
+	slides, _ := taskRepo.Create(model.CreateTaskInput{Title: "Review slides", Deadline: strPtr("2026-05-01"), TagIDs: []string{work.ID, home.ID}})
+	done, _ := taskRepo.Create(model.CreateTaskInput{Title: "Expense report", TagIDs: []string{work.ID}})
+	if _, err := taskRepo.Complete(done.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Water plants", TagIDs: []string{home.ID}})
+
+	list, err := repo.Create(user.ID, model.CreateSmartListInput{Name: "Work", Query: "tag:work", Sort: "deadline", GroupBy: "tag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(user.ID, model.CreateSmartListInput{Name: "work", Query: "is:open"}); !errors.Is(err, repository.ErrDuplicateSmartListName) {
+		t.Fatalf("expected a duplicate name error, got %v", err)
+	}
+
+	// Completed tasks stay out unless the query asks for a status.
+	view, err := repo.View(list, model.ViewFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Count != 2 || len(view.Groups) != 2 {
+		t.Fatalf("expected 2 tasks in 2 groups, got %+v", view)
+	}
+	if g := view.Groups[0]; g.Title != "work" || len(g.Tasks) != 2 || g.Tasks[0].ID != slides.ID || g.Tasks[1].ID != report.ID {
+		t.Errorf("expected work tasks by deadline, got %+v", g)
+	}
+	if g := view.Groups[1]; g.Title != "home" || len(g.Tasks) != 1 || g.Tasks[0].ID != slides.ID {
+		t.Errorf("expected slides under home, got %+v", g)
+	}
+
+	query, sort, groupBy := "tag:work is:completed", "-title", "none"
+	list, err = repo.Update(user.ID, list.ID, model.UpdateSmartListInput{Query: &query, Sort: &sort, GroupBy: &groupBy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, _ = repo.View(list, model.ViewFilter{})
+	if view.Count != 1 || view.Groups[0].Tasks[0].ID != done.ID {
+		t.Errorf("expected the completed task, got %+v", view)
+	}
+
+	counts, err := repo.Counts(user.ID, model.ViewFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[list.ID] != 1 {
+		t.Errorf("expected a count of 1, got %v", counts)
+	}
+
+	if err := repo.Delete(user.ID, list.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(user.ID, list.ID); !errors.Is(err, repository.ErrSmartListNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
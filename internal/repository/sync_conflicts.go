@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+var ErrConflictResolved = fmt.Errorf("conflict already resolved")
+
+type SyncConflictRepository struct {
+	db *sql.DB
+}
+
+func NewSyncConflictRepository(db *sql.DB) *SyncConflictRepository {
+	return &SyncConflictRepository{db: db}
+}
+
+const syncConflictColumns = `id, entity, entity_id, device_id, fields, client_data, client_updated_at,
+	server_snapshot, server_updated_at, status, created_at, resolved_at`
+
+func scanSyncConflict(scan func(dest ...interface{}) error) (*model.SyncConflict, error) {
+	var c model.SyncConflict
+	var fields, clientData, serverSnapshot string
+	if err := scan(&c.ID, &c.Entity, &c.EntityID, &c.DeviceID, &fields, &clientData, &c.ClientUpdatedAt,
+		&serverSnapshot, &c.ServerUpdatedAt, &c.Status, &c.CreatedAt, &c.ResolvedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields), &c.Fields); err != nil {
+		return nil, fmt.Errorf("decode conflict fields: %w", err)
+	}
+	c.ClientData = json.RawMessage(clientData)
+	c.ServerSnapshot = json.RawMessage(serverSnapshot)
+	return &c, nil
+}
+
+// Create records a sync conflict: a stale client change and the server
+// version it overwrote.
+func (r *SyncConflictRepository) Create(c model.SyncConflict) (*model.SyncConflict, error) {
+	id := model.NewID()
+	if c.Fields == nil {
+		c.Fields = []string{}
+	}
+	fields, err := json.Marshal(c.Fields)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.db.Exec(
+		`INSERT INTO sync_conflicts (id, entity, entity_id, device_id, fields, client_data, client_updated_at, server_snapshot, server_updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, c.Entity, c.EntityID, c.DeviceID, string(fields), string(c.ClientData), c.ClientUpdatedAt,
+		string(c.ServerSnapshot), c.ServerUpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create sync conflict: %w", err)
+	}
+	return r.GetByID(id)
+}
+
+// List returns conflicts with the given status, newest first. An empty status
+// returns every conflict.
+func (r *SyncConflictRepository) List(status string) ([]model.SyncConflict, error) {
+	query := `SELECT ` + syncConflictColumns + ` FROM sync_conflicts`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, rowid DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list sync conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := []model.SyncConflict{}
+	for rows.Next() {
+		c, err := scanSyncConflict(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, rows.Err()
+}
+
+func (r *SyncConflictRepository) GetByID(id string) (*model.SyncConflict, error) {
+	c, err := scanSyncConflict(r.db.QueryRow(
+		`SELECT `+syncConflictColumns+` FROM sync_conflicts WHERE id = ?`, id,
+	).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// Resolve marks an open conflict with the given resolution status.
+func (r *SyncConflictRepository) Resolve(id, status string) (*model.SyncConflict, error) {
+	res, err := r.db.Exec(
+		`UPDATE sync_conflicts SET status = ?, resolved_at = datetime('now') WHERE id = ? AND status = 'open'`,
+		status, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("resolve sync conflict: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		existing, err := r.GetByID(id)
+		if err != nil || existing == nil {
+			return nil, err
+		}
+		return nil, ErrConflictResolved
+	}
+	return r.GetByID(id)
+}
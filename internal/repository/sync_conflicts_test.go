@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+func TestSyncConflictRepository_CreateListResolve(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewSyncConflictRepository(db)
+
+	c, err := repo.Create(model.SyncConflict{
+		Entity:          "task",
+		EntityID:        "t1",
+		DeviceID:        "dev1",
+		Fields:          []string{"title"},
+		ClientData:      json.RawMessage(`{"title":"client"}`),
+		ClientUpdatedAt: "2026-01-01T00:00:00Z",
+		ServerSnapshot:  json.RawMessage(`{"id":"t1","title":"server"}`),
+		ServerUpdatedAt: "2026-01-02 00:00:00",
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if c.Status != "open" || c.ResolvedAt != nil {
+		t.Errorf("expected open unresolved conflict, got %+v", c)
+	}
+
+	open, _ := repo.List("open")
+	if len(open) != 1 || open[0].ID != c.ID {
+		t.Fatalf("expected 1 open conflict, got %+v", open)
+	}
+
+	resolved, err := repo.Resolve(c.ID, "kept_server")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolved.Status != "kept_server" || resolved.ResolvedAt == nil {
+		t.Errorf("expected kept_server with resolved_at, got %+v", resolved)
+	}
+	if _, err := repo.Resolve(c.ID, "merged"); !errors.Is(err, repository.ErrConflictResolved) {
+		t.Errorf("expected ErrConflictResolved, got %v", err)
+	}
+	if missing, err := repo.Resolve("missing", "merged"); missing != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing conflict, got %v, %v", missing, err)
+	}
+
+	open, _ = repo.List("open")
+	all, _ := repo.List("")
+	if len(open) != 0 || len(all) != 1 {
+		t.Errorf("expected 0 open and 1 total, got %d and %d", len(open), len(all))
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+type SynonymRepository struct {
+	db *sql.DB
+}
+
+func NewSynonymRepository(db *sql.DB) *SynonymRepository {
+	return &SynonymRepository{db: db}
+}
+
+// NormalizeSynonyms lower-cases and trims terms, collapses inner spaces and
+// drops blanks and duplicates.
+func NormalizeSynonyms(terms []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, term := range terms {
+		term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+		if term == "" || seen[term] {
+			continue
+		}
+		seen[term] = true
+		out = append(out, term)
+	}
+	return out
+}
+
+func (r *SynonymRepository) List() ([]model.SynonymGroup, error) {
+	return listSynonymGroups(r.db)
+}
+
+// Create stores a synonym group. Terms should already be normalized.
+func (r *SynonymRepository) Create(input model.CreateSynonymGroupInput) (*model.SynonymGroup, error) {
+	data, err := json.Marshal(input.Terms)
+	if err != nil {
+		return nil, err
+	}
+	id := model.NewID()
+	if _, err := r.db.Exec("INSERT INTO search_synonyms (id, terms) VALUES (?, ?)", id, string(data)); err != nil {
+		return nil, fmt.Errorf("create synonym group: %w", err)
+	}
+	g := model.SynonymGroup{ID: id, Terms: input.Terms}
+	if err := r.db.QueryRow("SELECT created_at FROM search_synonyms WHERE id = ?", id).Scan(&g.CreatedAt); err != nil {
+		return nil, fmt.Errorf("fetch synonym group: %w", err)
+	}
+	return &g, nil
+}
+
+func (r *SynonymRepository) Delete(id string) error {
+	res, err := r.db.Exec("DELETE FROM search_synonyms WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete synonym group: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSynonymGroupNotFound
+	}
+	return nil
+}
+
+func listSynonymGroups(db querier) ([]model.SynonymGroup, error) {
+	rows, err := db.Query("SELECT id, terms, created_at FROM search_synonyms ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("list synonym groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []model.SynonymGroup{}
+	for rows.Next() {
+		var g model.SynonymGroup
+		var terms string
+		if err := rows.Scan(&g.ID, &terms, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan synonym group: %w", err)
+		}
+		if err := json.Unmarshal([]byte(terms), &g.Terms); err != nil {
+			return nil, fmt.Errorf("decode synonym group %s: %w", g.ID, err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
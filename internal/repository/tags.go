@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -9,16 +10,28 @@ import (
 )
 
 type TagRepository struct {
-	db        *sql.DB
+	db querier
+	reader
 	changeLog *ChangeLogRepository
 }
 
 func NewTagRepository(db *sql.DB, changeLog *ChangeLogRepository) *TagRepository {
-	return &TagRepository{db: db, changeLog: changeLog}
+	return &TagRepository{db: db, reader: reader{db}, changeLog: changeLog}
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *TagRepository) WithContext(ctx context.Context) *TagRepository {
+	c := *r
+	c.db, c.read = withContext(r.db, ctx), withContext(r.read, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
 }
 
 func (r *TagRepository) List() ([]model.Tag, error) {
-	rows, err := r.db.Query(`
+	rows, err := r.read.Query(`
 		SELECT t.id, t.title, t.color, t.parent_tag_id, t.sort_order,
 			COALESCE((SELECT COUNT(*) FROM task_tags tt2 JOIN tasks tk ON tk.id = tt2.task_id WHERE tt2.tag_id = t.id AND tk.deleted_at IS NULL), 0)
 		FROM tags t ORDER BY t.sort_order ASC`)
@@ -99,7 +112,7 @@ func (r *TagRepository) Update(id string, input model.UpdateTagInput) (*model.Ta
 }
 
 func (r *TagRepository) Reorder(items []model.SimpleReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -134,8 +147,18 @@ func (r *TagRepository) Delete(id string) error {
 	return err
 }
 
-func (r *TagRepository) GetTasksByTag(tagID string) ([]model.TaskListItem, error) {
-	rows, err := r.db.Query(`
+// tagTasksKeyset is the order of GetTasksByTag.
+var tagTasksKeyset = newKeyset("tag_tasks", "t.sort_order_today, t.created_at")
+
+// GetTasksByTag returns a page of the open tasks with a tag, with the cursor
+// of the next page, or "" if it is the last.
+func (r *TagRepository) GetTasksByTag(tagID string, page model.Page) ([]model.TaskListItem, string, error) {
+	after, afterArgs, err := tagTasksKeyset.after(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	size := pageSize(page)
+	rows, err := r.read.Query(`
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
@@ -154,13 +177,18 @@ func (r *TagRepository) GetTasksByTag(tagID string) ([]model.TaskListItem, error
 			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
 		FROM tasks t
 		JOIN task_tags tt ON t.id = tt.task_id
-		WHERE tt.tag_id = ? AND t.deleted_at IS NULL AND t.status = 'open'
-		ORDER BY t.sort_order_today`, tagID)
+		WHERE tt.tag_id = ? AND t.deleted_at IS NULL AND t.status = 'open'`+after+`
+		ORDER BY `+tagTasksKeyset.orderBy()+limitClause(size), append([]interface{}{tagID}, afterArgs...)...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
-	tasks := scanTaskListItems(r.db, rows)
-	populateActionableScheduleFlags(r.db, tasks)
-	return tasks, nil
+	tasks := scanTaskListItems(r.read, rows)
+	rows.Close()
+	tasks, next, err := tagTasksKeyset.trimPage(r.read, tasks, size)
+	if err != nil {
+		return nil, "", err
+	}
+	populateActionableScheduleFlags(r.read, tasks)
+	return tasks, next, nil
 }
@@ -136,7 +136,7 @@ func TestTagGetTasksByTag(t *testing.T) {
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Work task", TagIDs: []string{tag.ID}})
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Other task"})
 
-	tasks, err := tagRepo.GetTasksByTag(tag.ID)
+	tasks, _, err := tagRepo.GetTasksByTag(tag.ID, model.Page{})
 	if err != nil {
 		t.Fatalf("failed to get tasks by tag: %v", err)
 	}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+)
+
+// taskListColumns are the columns of a list item that come from the task row
+// itself, with the names of its project and area from searchTaskJoins. The
+// rest, from checklists, attachments, repeat rules, reminders, schedules and
+// tags, is loaded for the whole list at once by loadTaskListDetails, which
+// keeps the cost of a list to a handful of queries however long it is.
+const taskListColumns = `t.id, t.title, t.notes, t.status, t.when_date, t.high_priority,
+			t.deadline, t.project_id, t.area_id, t.heading_id,
+			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
+			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
+			pr.title, ar.title`
+
+// scheduleSource says which schedule entry gives a list item its times.
+type scheduleSource int
+
+const (
+	// firstSchedule is the task's first entry by sort order.
+	firstSchedule scheduleSource = iota
+	// scannedSchedule is an entry the query joined, scanned with the row.
+	scannedSchedule
+	// pastSchedule is the first open entry before listDetails.before, as
+	// the Earlier lists show; PastScheduleCount counts those entries.
+	pastSchedule
+)
+
+// listDetails says how loadTaskListDetails fills in the schedule fields of
+// a list.
+type listDetails struct {
+	schedule scheduleSource
+	before   string
+	// actionable sets HasActionableSchedules, AllTodaySchedulesCompleted
+	// and FirstScheduleCompleted, for the lists a task can be completed from.
+	actionable bool
+}
+
+// listTasks runs a query selecting taskListColumns, followed by the columns
+// extra returns destinations for, if any, and loads the details of the
+// tasks.
+func listTasks(db querier, d listDetails, extra func(t *model.TaskListItem) []interface{}, query string, args ...interface{}) ([]model.TaskListItem, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tasks := []model.TaskListItem{}
+	for rows.Next() {
+		var t model.TaskListItem
+		var highPriority int
+		dest := []interface{}{
+			&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &highPriority,
+			&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
+			&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
+			&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
+			&t.ProjectName, &t.AreaName,
+		}
+		if extra != nil {
+			dest = append(dest, extra(&t)...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan task: %w", err)
+		}
+		t.HighPriority = highPriority == 1
+		t.HasNotes = t.Notes != ""
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := loadTaskListDetails(db, tasks, d); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// taskIndex maps the IDs of a list's tasks to their positions in it. A task
+// can be in a list more than once, once per schedule entry.
+type taskIndex map[string][]int
+
+func newTaskIndex(tasks []model.TaskListItem) taskIndex {
+	index := make(taskIndex, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = append(index[t.ID], i)
+	}
+	return index
+}
+
+// ids returns the task IDs as a JSON array. Queries bind it to json_each to
+// select the rows of every task in the list at once, however many there are.
+func (x taskIndex) ids() string {
+	ids := make([]string, 0, len(x))
+	for id := range x {
+		ids = append(ids, id)
+	}
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
+
+// loadTaskListDetails fills in the fields of list items that come from
+// related tables, with one query per table.
+func loadTaskListDetails(db querier, tasks []model.TaskListItem, d listDetails) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	index := newTaskIndex(tasks)
+	ids := index.ids()
+	if err := loadTaskTags(db, tasks, index, ids); err != nil {
+		return err
+	}
+
+	var total, done int
+	rows, err := db.Query(`
+		SELECT task_id, COUNT(*), SUM(completed = 1) FROM checklist_items
+		WHERE task_id IN (SELECT value FROM json_each(?))
+		GROUP BY task_id`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				tasks[i].ChecklistCount, tasks[i].ChecklistDone = total, done
+			}
+		}, &total, &done)
+	}
+	if err != nil {
+		return fmt.Errorf("load checklist counts: %w", err)
+	}
+
+	var links, files int
+	rows, err = db.Query(`
+		SELECT task_id, MAX(type = 'link'), MAX(type = 'file') FROM attachments
+		WHERE task_id IN (SELECT value FROM json_each(?))
+		GROUP BY task_id`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				tasks[i].HasLinks, tasks[i].HasFiles = links == 1, files == 1
+			}
+		}, &links, &files)
+	}
+	if err != nil {
+		return fmt.Errorf("load attachment flags: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT task_id FROM repeat_rules WHERE task_id IN (SELECT value FROM json_each(?))`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				tasks[i].HasRepeatRule = true
+			}
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("load repeat rules: %w", err)
+	}
+
+	// A task's first reminder comes first among its rows.
+	var reminderType, exactAt *string
+	var value *int
+	rows, err = db.Query(`
+		SELECT task_id, type, value, exact_at FROM reminders
+		WHERE task_id IN (SELECT value FROM json_each(?))
+		ORDER BY task_id, created_at, rowid`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				if !tasks[i].HasReminders {
+					tasks[i].HasReminders = true
+					tasks[i].FirstReminderType, tasks[i].FirstReminderValue, tasks[i].FirstReminderExactAt = reminderType, value, exactAt
+				}
+			}
+		}, &reminderType, &value, &exactAt)
+	}
+	if err != nil {
+		return fmt.Errorf("load reminders: %w", err)
+	}
+
+	return loadTaskSchedules(db, tasks, index, ids, d)
+}
+
+// eachTaskRow scans each row of rows, a task ID followed by columns for
+// dest, and calls fn with the positions of the task in the list. It closes
+// rows.
+func eachTaskRow(rows *sql.Rows, index taskIndex, fn func(at []int), dest ...interface{}) error {
+	defer rows.Close()
+	var taskID string
+	dest = append([]interface{}{&taskID}, dest...)
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fn(index[taskID])
+	}
+	return rows.Err()
+}
+
+// loadTaskTags fills in the tags of list items.
+func loadTaskTags(db querier, tasks []model.TaskListItem, index taskIndex, ids string) error {
+	for i := range tasks {
+		tasks[i].Tags = []model.TagRef{}
+	}
+	var tag model.TagRef
+	rows, err := db.Query(`
+		SELECT tt.task_id, g.id, g.title, g.color
+		FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.task_id IN (SELECT value FROM json_each(?))
+		ORDER BY tt.task_id, tt.tag_id`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				tasks[i].Tags = append(tasks[i].Tags, tag)
+			}
+		}, &tag.ID, &tag.Title, &tag.Color)
+	}
+	if err != nil {
+		return fmt.Errorf("load task tags: %w", err)
+	}
+	return nil
+}
+
+// loadTaskNames fills in the project and area names of list items.
+func loadTaskNames(db querier, tasks []model.TaskListItem) error {
+	names := map[string]map[string]*string{"projects": {}, "areas": {}}
+	for _, t := range tasks {
+		if t.ProjectID != nil {
+			names["projects"][*t.ProjectID] = nil
+		}
+		if t.AreaID != nil {
+			names["areas"][*t.AreaID] = nil
+		}
+	}
+	for table, byID := range names {
+		if len(byID) == 0 {
+			continue
+		}
+		ids := make([]string, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+		b, _ := json.Marshal(ids)
+		rows, err := db.Query("SELECT id, title FROM "+table+" WHERE id IN (SELECT value FROM json_each(?))", string(b))
+		if err != nil {
+			return fmt.Errorf("load %s names: %w", table, err)
+		}
+		for rows.Next() {
+			var id, title string
+			if err := rows.Scan(&id, &title); err != nil {
+				rows.Close()
+				return fmt.Errorf("load %s names: %w", table, err)
+			}
+			byID[id] = &title
+		}
+		rows.Close()
+	}
+	for i, t := range tasks {
+		if t.ProjectID != nil {
+			tasks[i].ProjectName = names["projects"][*t.ProjectID]
+		}
+		if t.AreaID != nil {
+			tasks[i].AreaName = names["areas"][*t.AreaID]
+		}
+	}
+	return nil
+}
+
+// taskSchedule is the part of a schedule entry list items are built from.
+type taskSchedule struct {
+	id, whenDate string
+	start, end   *string
+	completed    bool
+}
+
+// loadTaskSchedules fills in the schedule fields of list items as d says,
+// from the schedule entries of all the tasks, loaded in one query.
+func loadTaskSchedules(db querier, tasks []model.TaskListItem, index taskIndex, ids string, d listDetails) error {
+	if d.schedule == scannedSchedule && !d.actionable {
+		return nil
+	}
+	schedules := make([][]taskSchedule, len(tasks))
+	var s taskSchedule
+	rows, err := db.Query(`
+		SELECT task_id, id, when_date, start_time, end_time, completed FROM task_schedules
+		WHERE task_id IN (SELECT value FROM json_each(?))
+		ORDER BY task_id, sort_order, rowid`, ids)
+	if err == nil {
+		err = eachTaskRow(rows, index, func(at []int) {
+			for _, i := range at {
+				schedules[i] = append(schedules[i], s)
+			}
+		}, &s.id, &s.whenDate, &s.start, &s.end, &s.completed)
+	}
+	if err != nil {
+		return fmt.Errorf("load schedules: %w", err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	for i := range tasks {
+		t := &tasks[i]
+		entries := schedules[i]
+		switch d.schedule {
+		case firstSchedule:
+			if len(entries) > 0 {
+				t.FirstScheduleTime, t.FirstScheduleEndTime = entries[0].start, entries[0].end
+			}
+		case pastSchedule:
+			for _, e := range entries {
+				if e.completed || e.whenDate >= d.before || e.whenDate == "someday" {
+					continue
+				}
+				if t.PastScheduleCount == 0 {
+					id := e.id
+					t.FirstScheduleTime, t.FirstScheduleEndTime, t.ScheduleEntryID = e.start, e.end, &id
+				}
+				t.PastScheduleCount++
+			}
+		}
+		if !d.actionable {
+			continue
+		}
+
+		completed := 0
+		for _, e := range entries {
+			if e.completed {
+				completed++
+			} else if e.whenDate != today {
+				t.HasActionableSchedules = true
+			}
+		}
+		t.AllTodaySchedulesCompleted = len(entries) > 0 && completed == len(entries)
+		if t.FirstScheduleTime == nil {
+			continue
+		}
+		// The entry shown is the one scanned with the row, if any.
+		for j, e := range entries {
+			if t.ScheduleEntryID == nil && j == 0 || t.ScheduleEntryID != nil && e.id == *t.ScheduleEntryID {
+				t.FirstScheduleCompleted = e.completed
+			}
+		}
+	}
+	return nil
+}
+
+// populateActionableScheduleFlags sets HasActionableSchedules on each task
+// that has uncompleted schedule entries requiring confirmation — i.e. past
+// entries (will be auto-completed) or future/someday entries (will be deleted).
+// If the only uncompleted entries are for today, no flag is set because
+// completing today's entry is the normal expected behavior. It also sets
+// AllTodaySchedulesCompleted and FirstScheduleCompleted, for tasks whose
+// schedule times were scanned with them.
+func populateActionableScheduleFlags(db querier, tasks []model.TaskListItem) {
+	if len(tasks) == 0 {
+		return
+	}
+	index := newTaskIndex(tasks)
+	_ = loadTaskSchedules(db, tasks, index, index.ids(), listDetails{schedule: scannedSchedule, actionable: true})
+}
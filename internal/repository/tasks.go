@@ -1,25 +1,18 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
 )
 
-type TaskRepository struct {
-	db        *sql.DB
-	changeLog *ChangeLogRepository
-}
-
-func NewTaskRepository(db *sql.DB, changeLog *ChangeLogRepository) *TaskRepository {
-	return &TaskRepository{db: db, changeLog: changeLog}
-}
-
-func (r *TaskRepository) List(f model.TaskFilters) ([]model.TaskListItem, error) {
-	query := `
+// taskListItemSelect selects the columns scanned by scanTaskListItems.
+const taskListItemSelect = `
 		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
 			t.deadline, t.project_id, t.area_id, t.heading_id,
 			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
@@ -38,6 +31,55 @@ func (r *TaskRepository) List(f model.TaskFilters) ([]model.TaskListItem, error)
 			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
 		FROM tasks t`
 
+type TaskRepository struct {
+	db querier
+	reader
+	changeLog *ChangeLogRepository
+}
+
+func NewTaskRepository(db *sql.DB, changeLog *ChangeLogRepository) *TaskRepository {
+	return &TaskRepository{db: db, reader: reader{db}, changeLog: changeLog}
+}
+
+// WithChangeLog returns a copy of the repository that records its changes in cl.
+func (r *TaskRepository) WithChangeLog(cl *ChangeLogRepository) *TaskRepository {
+	c := *r
+	c.changeLog = cl
+	return &c
+}
+
+// WithTx returns a copy of the repository whose statements, reads included,
+// run in tx.
+func (r *TaskRepository) WithTx(tx *sql.Tx) *TaskRepository {
+	c := *r
+	c.db, c.read = tx, tx
+	return &c
+}
+
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *TaskRepository) WithContext(ctx context.Context) *TaskRepository {
+	c := *r
+	c.db, c.read = withContext(r.db, ctx), withContext(r.read, ctx)
+	if r.changeLog != nil {
+		c.changeLog = r.changeLog.WithContext(ctx)
+	}
+	return &c
+}
+
+func (r *TaskRepository) List(f model.TaskFilters) ([]model.TaskListItem, error) {
+	tasks, _, err := r.ListPaged(f, model.Page{})
+	return tasks, err
+}
+
+// taskListKeyset is the order of List.
+var taskListKeyset = newKeyset("tasks", "t.sort_order_today ASC, t.created_at ASC")
+
+// ListPaged returns one page of the tasks List returns, with the cursor of
+// the next page, or "" if it is the last.
+func (r *TaskRepository) ListPaged(f model.TaskFilters, page model.Page) ([]model.TaskListItem, string, error) {
+	query := taskListItemSelect
+
 	var conditions []string
 	var args []interface{}
 
@@ -93,17 +135,43 @@ func (r *TaskRepository) List(f model.TaskFilters) ([]model.TaskListItem, error)
 
 	conditions = append(conditions, "t.deleted_at IS NULL")
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	after, afterArgs, err := taskListKeyset.after(page.Cursor)
+	if err != nil {
+		return nil, "", err
 	}
-	query += " ORDER BY t.sort_order_today ASC, t.created_at ASC"
+	size := pageSize(page)
+	query += " WHERE " + strings.Join(conditions, " AND ") + after +
+		" ORDER BY " + taskListKeyset.orderBy() + limitClause(size)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.read.Query(query, append(args, afterArgs...)...)
 	if err != nil {
-		return nil, fmt.Errorf("list tasks: %w", err)
+		return nil, "", fmt.Errorf("list tasks: %w", err)
 	}
 	defer rows.Close()
 
+	tasks, err := scanTaskRows(r.read, rows)
+	if err != nil {
+		return nil, "", err
+	}
+	rows.Close()
+	return taskListKeyset.trimPage(r.read, tasks, size)
+}
+
+// ListPage returns non-deleted tasks ordered by ID, starting after afterID.
+// Keyset paging keeps each page cheap and stable while rows are added or removed.
+func (r *TaskRepository) ListPage(afterID string, limit int) ([]model.TaskListItem, error) {
+	rows, err := r.db.Query(taskListItemSelect+`
+		WHERE t.deleted_at IS NULL AND t.id > ?
+		ORDER BY t.id ASC
+		LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list task page: %w", err)
+	}
+	defer rows.Close()
+	return scanTaskRows(r.db, rows)
+}
+
+func scanTaskRows(db querier, rows *sql.Rows) ([]model.TaskListItem, error) {
 	var tasks []model.TaskListItem
 	for rows.Next() {
 		var t model.TaskListItem
@@ -127,13 +195,18 @@ func (r *TaskRepository) List(f model.TaskFilters) ([]model.TaskListItem, error)
 		t.HasFiles = hasFiles == 1
 		t.HasRepeatRule = hasRepeat == 1
 		t.HasReminders = hasReminders == 1
-		t.Tags, _ = r.getTaskTags(t.ID)
 		tasks = append(tasks, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	if tasks == nil {
 		tasks = []model.TaskListItem{}
 	}
-	return tasks, rows.Err()
+	for i := range tasks {
+		tasks[i].Tags, _ = getTaskTags(db, tasks[i].ID)
+	}
+	return tasks, nil
 }
 
 func (r *TaskRepository) GetByID(id string) (*model.TaskDetail, error) {
@@ -143,12 +216,12 @@ func (r *TaskRepository) GetByID(id string) (*model.TaskDetail, error) {
 		SELECT id, title, notes, status, when_date, when_evening, high_priority,
 			deadline, project_id, area_id, heading_id,
 			sort_order_today, sort_order_project, sort_order_heading,
-			completed_at, canceled_at, deleted_at, created_at, updated_at
+			completed_at, canceled_at, deleted_at, created_at, updated_at, series_id
 		FROM tasks WHERE id = ?`, id).Scan(
 		&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &whenEvening, &highPriority,
 		&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
 		&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
-		&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
+		&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt, &t.SeriesID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -170,7 +243,7 @@ func (r *TaskRepository) GetByID(id string) (*model.TaskDetail, error) {
 		t.HeadingRef = r.getRef("headings", *t.HeadingID)
 	}
 
-	t.Tags, _ = r.getTaskTags(id)
+	t.Tags, _ = getTaskTags(r.db, id)
 	t.Checklist, _ = r.getChecklist(id)
 	t.Attachments, _ = r.getAttachments(id)
 	t.RepeatRule, _ = r.getRepeatRule(id)
@@ -191,11 +264,11 @@ func (r *TaskRepository) Create(input model.CreateTaskInput) (*model.TaskDetail,
 
 	_, err := r.db.Exec(`
 		INSERT INTO tasks (id, title, notes, when_date, high_priority, deadline,
-			project_id, area_id, heading_id, sort_order_today, sort_order_project, sort_order_heading)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			project_id, area_id, heading_id, sort_order_today, sort_order_project, sort_order_heading, series_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, input.Title, input.Notes, input.WhenDate,
 		boolToInt(input.HighPriority), input.Deadline, input.ProjectID, input.AreaID, input.HeadingID,
-		maxSort+1024, maxSort+1024, maxSort+1024,
+		maxSort+1024, maxSort+1024, maxSort+1024, input.SeriesID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("create task: %w", err)
@@ -426,7 +499,7 @@ func (r *TaskRepository) MarkReviewed(id string) (*model.TaskDetail, error) {
 }
 
 func (r *TaskRepository) Reorder(items []model.ReorderItem) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -457,7 +530,7 @@ func (r *TaskRepository) Reorder(items []model.ReorderItem) error {
 }
 
 func (r *TaskRepository) BulkAction(input model.BulkActionInput) (int, error) {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return 0, err
 	}
@@ -597,8 +670,8 @@ func bulkNilIfEmpty(s string) interface{} {
 
 // --- helpers ---
 
-func (r *TaskRepository) getTaskTags(taskID string) ([]model.TagRef, error) {
-	rows, err := r.db.Query(
+func getTaskTags(db querier, taskID string) ([]model.TagRef, error) {
+	rows, err := db.Query(
 		"SELECT t.id, t.title, t.color FROM tags t JOIN task_tags tt ON t.id = tt.tag_id WHERE tt.task_id = ? ORDER BY t.sort_order", taskID)
 	if err != nil {
 		return nil, err
@@ -617,7 +690,7 @@ func (r *TaskRepository) getTaskTags(taskID string) ([]model.TagRef, error) {
 }
 
 func (r *TaskRepository) setTaskTags(taskID string, tagIDs []string) error {
-	tx, err := r.db.Begin()
+	tx, err := begin(r.db)
 	if err != nil {
 		return err
 	}
@@ -676,7 +749,7 @@ func (r *TaskRepository) getReminders(taskID string) ([]model.Reminder, error) {
 
 func (r *TaskRepository) getAttachments(taskID string) ([]model.Attachment, error) {
 	rows, err := r.db.Query(
-		"SELECT id, type, title, url, mime_type, file_size, sort_order, created_at FROM attachments WHERE task_id = ? ORDER BY sort_order", taskID)
+		"SELECT id, type, title, url, mime_type, file_size, encrypted, sort_order, created_at FROM attachments WHERE task_id = ? ORDER BY sort_order", taskID)
 	if err != nil {
 		return nil, err
 	}
@@ -684,7 +757,7 @@ func (r *TaskRepository) getAttachments(taskID string) ([]model.Attachment, erro
 	var items []model.Attachment
 	for rows.Next() {
 		var a model.Attachment
-		_ = rows.Scan(&a.ID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.SortOrder, &a.CreatedAt)
+		_ = rows.Scan(&a.ID, &a.Type, &a.Title, &a.URL, &a.MimeType, &a.FileSize, &a.Encrypted, &a.SortOrder, &a.CreatedAt)
 		items = append(items, a)
 	}
 	if items == nil {
@@ -695,10 +768,10 @@ func (r *TaskRepository) getAttachments(taskID string) ([]model.Attachment, erro
 
 func (r *TaskRepository) getRepeatRule(taskID string) (*model.RepeatRule, error) {
 	var rr model.RepeatRule
-	var patternJSON string
+	var patternJSON, copyJSON string
 	err := r.db.QueryRow(
-		"SELECT id, pattern FROM repeat_rules WHERE task_id = ?", taskID,
-	).Scan(&rr.ID, &patternJSON)
+		"SELECT id, pattern, copy_options, catch_up FROM repeat_rules WHERE task_id = ?", taskID,
+	).Scan(&rr.ID, &patternJSON, &copyJSON, &rr.CatchUp)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -710,10 +783,14 @@ func (r *TaskRepository) getRepeatRule(taskID string) (*model.RepeatRule, error)
 			return nil, fmt.Errorf("unmarshal repeat pattern: %w", err)
 		}
 	}
+	rr.Copy = decodeCopyOptions(copyJSON)
 	// Populate deprecated flat fields for backwards compat
 	rr.Mode = string(rr.Pattern.Mode)
 	rr.IntervalValue = rr.Pattern.Every
 	rr.Frequency = patternToFrequency(rr.Pattern.Type)
+	if rr.Pattern.Type == model.PatternRRule {
+		rr.Frequency = rruleFrequency(rr.Pattern.RRule)
+	}
 	if rr.Pattern.Type == model.PatternWeekly {
 		rr.DayConstraints = rr.Pattern.On
 	}
@@ -731,14 +808,18 @@ func (r *TaskRepository) getRepeatRule(taskID string) (*model.RepeatRule, error)
 func (r *TaskRepository) syncFirstScheduleDate(taskID string, whenDate *string) error {
 	if whenDate == nil {
 		// Log deletes for each schedule entry before removing them
+		var sids []string
 		rows, _ := r.db.Query("SELECT id FROM task_schedules WHERE task_id = ?", taskID)
 		if rows != nil {
-			defer rows.Close()
 			for rows.Next() {
 				var sid string
 				_ = rows.Scan(&sid)
-				logChange(r.changeLog, "schedule", sid, "delete", nil, map[string]string{"id": sid}, "", "")
+				sids = append(sids, sid)
 			}
+			rows.Close()
+		}
+		for _, sid := range sids {
+			logChange(r.changeLog, "schedule", sid, "delete", nil, map[string]string{"id": sid}, "", "")
 		}
 		// Clear all schedule entries
 		_, err := r.db.Exec("DELETE FROM task_schedules WHERE task_id = ?", taskID)
@@ -789,6 +870,51 @@ func (r *TaskRepository) syncFirstScheduleDate(taskID string, whenDate *string)
 	return err
 }
 
+// defaultSlotMinutes is the length of the time slot SetFirstScheduleTime
+// gives an entry that has none to keep.
+const defaultSlotMinutes = 30
+
+// SetFirstScheduleTime makes the task's first schedule entry start at start
+// ("HH:MM"), keeping the length of its current time slot or using
+// defaultSlotMinutes. The end time stops at 23:59.
+func (r *TaskRepository) SetFirstScheduleTime(taskID, start string) error {
+	startMin, err := recurrence.ParseClock(start)
+	if err != nil {
+		return err
+	}
+	var id string
+	var curStart, curEnd sql.NullString
+	err = r.db.QueryRow(
+		"SELECT id, start_time, end_time FROM task_schedules WHERE task_id = ? ORDER BY sort_order ASC LIMIT 1", taskID,
+	).Scan(&id, &curStart, &curEnd)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	length := defaultSlotMinutes
+	if curStart.Valid && curEnd.Valid {
+		from, err1 := recurrence.ParseClock(curStart.String[:min(len(curStart.String), 5)])
+		to, err2 := recurrence.ParseClock(curEnd.String[:min(len(curEnd.String), 5)])
+		if err1 == nil && err2 == nil && to > from {
+			length = to - from
+		}
+	}
+	endMin := min(startMin+length, 23*60+59)
+	end := fmt.Sprintf("%02d:%02d", endMin/60, endMin%60)
+
+	if _, err := r.db.Exec("UPDATE task_schedules SET start_time = ?, end_time = ? WHERE id = ?", start, end, id); err != nil {
+		return fmt.Errorf("set schedule time: %w", err)
+	}
+	var sched model.TaskSchedule
+	_ = r.db.QueryRow("SELECT id, task_id, when_date, start_time, end_time, completed, sort_order FROM task_schedules WHERE id = ?", id).
+		Scan(&sched.ID, &sched.TaskID, &sched.WhenDate, &sched.StartTime, &sched.EndTime, &sched.Completed, &sched.SortOrder)
+	logChange(r.changeLog, "schedule", id, "update", []string{"start_time", "end_time"}, &sched, "", "")
+	return nil
+}
+
 func (r *TaskRepository) getSchedules(taskID string) ([]model.TaskSchedule, error) {
 	rows, err := r.db.Query(
 		"SELECT id, when_date, start_time, end_time, completed, sort_order FROM task_schedules WHERE task_id = ? ORDER BY sort_order", taskID)
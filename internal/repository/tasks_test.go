@@ -1,6 +1,8 @@
 package repository_test
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
@@ -286,6 +288,45 @@ func TestTaskListAll(t *testing.T) {
 	}
 }
 
+func TestTaskListPaged(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	repo := repository.NewTaskRepository(db, nil)
+
+	for i := 0; i < 5; i++ {
+		_, _ = repo.Create(model.CreateTaskInput{Title: fmt.Sprintf("Task %d", i)})
+	}
+	all, _ := repo.List(model.TaskFilters{})
+
+	var paged []model.TaskListItem
+	page := model.Page{Limit: 2}
+	for {
+		tasks, next, err := repo.ListPaged(model.TaskFilters{}, page)
+		if err != nil {
+			t.Fatalf("failed to list page: %v", err)
+		}
+		if len(tasks) > 2 {
+			t.Fatalf("expected at most 2 tasks a page, got %d", len(tasks))
+		}
+		paged = append(paged, tasks...)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("expected %d tasks over all pages, got %d", len(all), len(paged))
+	}
+	for i := range all {
+		if paged[i].ID != all[i].ID {
+			t.Errorf("task %d: expected %s, got %s", i, all[i].ID, paged[i].ID)
+		}
+	}
+
+	if _, _, err := repo.ListPaged(model.TaskFilters{}, model.Page{Cursor: "bogus"}); !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("expected an invalid cursor error, got %v", err)
+	}
+}
+
 func TestTaskListFilterByStatus(t *testing.T) {
 	db := testutil.SetupTestDB(t)
 	repo := repository.NewTaskRepository(db, nil)
@@ -475,3 +516,21 @@ func TestTaskListItemMetadata(t *testing.T) {
 		t.Errorf("expected checklist_count=1, got %d", task.ChecklistCount)
 	}
 }
+
+func TestTaskListOnReaderPool(t *testing.T) {
+	db := testutil.SetupTestPools(t)
+	repo := repository.NewTaskRepository(db.Write, nil)
+	repo.SetReader(db.Read)
+
+	task, err := repo.Create(model.CreateTaskInput{Title: "Read back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := repo.List(model.TaskFilters{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Fatalf("expected the created task from the reader pool, got %+v", tasks)
+	}
+}
@@ -19,9 +19,9 @@ func NewUserSettingsRepository(db *sql.DB) *UserSettingsRepository {
 func (r *UserSettingsRepository) GetOrCreate(userID string) (*model.UserSettings, error) {
 	var s model.UserSettings
 	err := r.db.QueryRow(
-		"SELECT play_complete_sound, show_count_main, show_count_projects, show_count_tags, review_after_days, sort_areas, sort_tags, evening_starts_at, default_time_gap, show_time_badge, time_format, font_size, default_reminder_type, default_reminder_value, copy_reminders_to_recurring, notification_provider, ntfy_server_url, ntfy_topic, ntfy_access_token, base_url, privacy_mode, review_include_recurring FROM user_settings WHERE user_id = ?",
+		"SELECT play_complete_sound, show_count_main, show_count_projects, show_count_tags, review_after_days, sort_areas, sort_tags, evening_starts_at, default_time_gap, show_time_badge, time_format, font_size, default_reminder_type, default_reminder_value, copy_reminders_to_recurring, notification_provider, ntfy_server_url, ntfy_topic, ntfy_access_token, base_url, privacy_mode, review_include_recurring, workday_calendar, workday_calendar_ics FROM user_settings WHERE user_id = ?",
 		userID,
-	).Scan(&s.PlayCompleteSound, &s.ShowCountMain, &s.ShowCountProjects, &s.ShowCountTags, &s.ReviewAfterDays, &s.SortAreas, &s.SortTags, &s.EveningStartsAt, &s.DefaultTimeGap, &s.ShowTimeBadge, &s.TimeFormat, &s.FontSize, &s.DefaultReminderType, &s.DefaultReminderValue, &s.CopyRemindersToRecurring, &s.NotificationProvider, &s.NtfyServerURL, &s.NtfyTopic, &s.NtfyAccessToken, &s.BaseURL, &s.PrivacyMode, &s.ReviewIncludeRecurring)
+	).Scan(&s.PlayCompleteSound, &s.ShowCountMain, &s.ShowCountProjects, &s.ShowCountTags, &s.ReviewAfterDays, &s.SortAreas, &s.SortTags, &s.EveningStartsAt, &s.DefaultTimeGap, &s.ShowTimeBadge, &s.TimeFormat, &s.FontSize, &s.DefaultReminderType, &s.DefaultReminderValue, &s.CopyRemindersToRecurring, &s.NotificationProvider, &s.NtfyServerURL, &s.NtfyTopic, &s.NtfyAccessToken, &s.BaseURL, &s.PrivacyMode, &s.ReviewIncludeRecurring, &s.WorkdayCalendar, &s.WorkdayCalendarICS)
 	if err == sql.ErrNoRows {
 		_, err = r.db.Exec(
 			"INSERT INTO user_settings (user_id) VALUES (?)", userID,
@@ -158,6 +158,14 @@ func (r *UserSettingsRepository) Update(userID string, input model.UpdateUserSet
 		setClauses = append(setClauses, "review_include_recurring = ?")
 		args = append(args, boolToInt(*input.ReviewIncludeRecurring))
 	}
+	if input.WorkdayCalendar != nil {
+		setClauses = append(setClauses, "workday_calendar = ?")
+		args = append(args, *input.WorkdayCalendar)
+	}
+	if input.WorkdayCalendarICS != nil {
+		setClauses = append(setClauses, "workday_calendar_ics = ?")
+		args = append(args, *input.WorkdayCalendarICS)
+	}
 
 	if len(setClauses) > 0 {
 		setClauses = append(setClauses, "updated_at = datetime('now')")
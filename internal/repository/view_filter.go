@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/searchquery"
+)
+
+// taskSorts are the orders views and smart lists can sort tasks aliased t
+// by, as ORDER BY terms in ascending order. A sort prefixed with "-"
+// reverses them.
+var taskSorts = map[string][]string{
+	"when":     {"t.when_date IS NULL", "t.when_date = 'someday'", "t.when_date"},
+	"deadline": {"t.deadline IS NULL", "t.deadline"},
+	"created":  {"t.created_at"},
+	"updated":  {"t.updated_at"},
+	"title":    {"t.title COLLATE NOCASE"},
+	"priority": {"t.high_priority = 0", "t.deadline IS NULL", "t.deadline"},
+}
+
+// ValidTaskSort reports whether s is a sort views and smart lists can use.
+func ValidTaskSort(s string) bool {
+	return taskSorts[strings.TrimPrefix(s, "-")] != nil
+}
+
+// taskOrder returns the ORDER BY clause for a task sort, falling back to
+// "when" for one it doesn't know.
+func taskOrder(s string) string {
+	dir := ""
+	if strings.HasPrefix(s, "-") {
+		s, dir = s[1:], " DESC"
+	}
+	terms := taskSorts[s]
+	if terms == nil {
+		terms = taskSorts["when"]
+	}
+	var order []string
+	for _, term := range terms {
+		order = append(order, term+dir)
+	}
+	return strings.Join(append(order, "t.created_at", "t.id"), ", ")
+}
+
+// viewOrder returns the ORDER BY clause of a view query: def, or the
+// filter's sort after any leading terms the view groups by.
+func viewOrder(f model.ViewFilter, def string, groupTerms ...string) string {
+	if f.Sort == "" {
+		return def
+	}
+	return strings.Join(append(groupTerms, taskOrder(f.Sort)), ", ")
+}
+
+// viewFilterSQL returns the filter's conditions on tasks aliased t, each
+// preceded by AND, ready to append to a WHERE clause.
+func viewFilterSQL(f model.ViewFilter) (string, []interface{}) {
+	conditions, args := viewFilterConditions(f)
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+// viewFilterConditions returns the filter's conditions on tasks aliased t.
+func viewFilterConditions(f model.ViewFilter) ([]string, []interface{}) {
+	var filters []searchquery.Filter
+	for _, tag := range f.Tags {
+		filters = append(filters, searchquery.Filter{Key: "tag", Value: tag})
+	}
+	if f.Project != "" {
+		filters = append(filters, searchquery.Filter{Key: "project", Value: f.Project})
+	}
+	if f.Area != "" {
+		filters = append(filters, searchquery.Filter{Key: "area", Value: f.Area})
+	}
+	if f.Priority != "" {
+		filters = append(filters, searchquery.Filter{Key: "priority", Value: f.Priority})
+	}
+	switch f.Deadline {
+	case "":
+	case "any":
+		filters = append(filters, searchquery.Filter{Key: "has", Value: "deadline"})
+	case "none":
+		filters = append(filters, searchquery.Filter{Key: "due", Value: "none"})
+	case "overdue":
+		filters = append(filters, searchquery.Filter{Key: "is", Value: "overdue"})
+	default:
+		filters = append(filters, searchquery.Filter{Key: "due", Op: "<=", Value: f.Deadline})
+	}
+
+	var conditions []string
+	var args []interface{}
+	today := time.Now()
+	for _, filter := range filters {
+		cond, condArgs := searchFilterSQL(filter, today)
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+	return conditions, args
+}
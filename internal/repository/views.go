@@ -1,49 +1,46 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
 )
 
 type ViewRepository struct {
-	db *sql.DB
+	db querier
 }
 
 func NewViewRepository(db *sql.DB) *ViewRepository {
 	return &ViewRepository{db: db}
 }
 
-func (r *ViewRepository) Inbox(reviewAfterDays *int, includeRecurring bool) (*model.InboxView, error) {
-	rows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+// WithContext returns a copy of the repository whose statements run under
+// ctx, so they are traced as part of the span in ctx.
+func (r *ViewRepository) WithContext(ctx context.Context) *ViewRepository {
+	c := *r
+	c.db = withContext(r.db, ctx)
+	return &c
+}
+
+func (r *ViewRepository) Inbox(reviewAfterDays *int, includeRecurring bool, f model.ViewFilter) (*model.InboxView, error) {
+	where, whereArgs := viewFilterSQL(f)
+	inboxTasks, err := listTasks(r.db, listDetails{actionable: true}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
+		`+searchTaskJoins+`
 		WHERE t.project_id IS NULL AND t.area_id IS NULL
-			AND t.status = 'open' AND t.when_date IS NULL AND t.deleted_at IS NULL
-		ORDER BY t.sort_order_today ASC`)
+			AND t.status = 'open' AND t.when_date IS NULL AND t.deleted_at IS NULL`+where+`
+		ORDER BY `+viewOrder(f, "t.sort_order_today ASC"), whereArgs...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	inboxTasks := scanTaskListItems(r.db, rows)
-	populateActionableScheduleFlags(r.db, inboxTasks)
 
 	// Collect inbox task IDs to exclude from review
 	inboxIDs := make(map[string]bool, len(inboxTasks))
@@ -53,33 +50,16 @@ func (r *ViewRepository) Inbox(reviewAfterDays *int, includeRecurring bool) (*mo
 
 	var reviewTasks []model.TaskListItem
 	if reviewAfterDays != nil && *reviewAfterDays > 0 {
-		reviewRows, err := r.db.Query(`
-			SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-				t.deadline, t.project_id, t.area_id, t.heading_id,
-				t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-				t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-				COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-				COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-				CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-				CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-				CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-				CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-				(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-				(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-				(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-				(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+		allReview, err := listTasks(r.db, listDetails{actionable: true}, nil, `
+			SELECT `+taskListColumns+`
 			FROM tasks t
+			`+searchTaskJoins+`
 			WHERE t.status = 'open' AND t.deleted_at IS NULL
-				AND date(t.updated_at) < date('now', '-' || ? || ' days')
-			ORDER BY t.updated_at ASC`, *reviewAfterDays)
+				AND date(t.updated_at) < date('now', '-' || ? || ' days')`+where+`
+			ORDER BY `+viewOrder(f, "t.updated_at ASC"), append([]interface{}{*reviewAfterDays}, whereArgs...)...)
 		if err != nil {
 			return nil, err
 		}
-		defer reviewRows.Close()
-		allReview := scanTaskListItems(r.db, reviewRows)
-		populateActionableScheduleFlags(r.db, allReview)
 
 		// Exclude tasks already in inbox, and optionally exclude recurring tasks
 		for _, t := range allReview {
@@ -99,32 +79,19 @@ func (r *ViewRepository) Inbox(reviewAfterDays *int, includeRecurring bool) (*mo
 	return &model.InboxView{Tasks: inboxTasks, Review: reviewTasks}, nil
 }
 
-func (r *ViewRepository) Today(eveningStartsAt string) (*model.TodayView, error) {
+func (r *ViewRepository) Today(eveningStartsAt string, f model.ViewFilter) (*model.TodayView, error) {
 	today := time.Now().Format("2006-01-02")
+	where, whereArgs := viewFilterSQL(f)
 
 	// Today tasks: JOIN task_schedules so multi-schedule entries for today each appear.
 	// Includes tasks where ANY schedule entry matches today (not just when_date).
 	// Excludes evening entries (start_time >= eveningStartsAt).
 	// Tasks without any schedule entry for today (e.g. deadline-only) use LEFT JOIN.
-	todayRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			ts.start_time,
-			ts.end_time,
-			ts.id AS schedule_entry_id
+	todayTasks, err := listTasks(r.db, listDetails{schedule: scannedSchedule, actionable: true}, scannedScheduleTimes, `
+		SELECT `+taskListColumns+`,
+			ts.start_time, ts.end_time, ts.id
 		FROM tasks t
+		`+searchTaskJoins+`
 		LEFT JOIN task_schedules ts ON ts.task_id = t.id AND ts.when_date = ?
 		WHERE t.status = 'open'
 			AND (
@@ -134,35 +101,19 @@ func (r *ViewRepository) Today(eveningStartsAt string) (*model.TodayView, error)
 				OR t.deadline = ?
 				OR EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date = ? AND completed = 0))
 			AND t.deleted_at IS NULL
-			AND (ts.start_time IS NULL OR ts.start_time < ?)
-		ORDER BY t.sort_order_today ASC, ts.start_time ASC`, today, today, today, today, today, today, eveningStartsAt)
+			AND (ts.start_time IS NULL OR ts.start_time < ?)`+where+`
+		ORDER BY `+viewOrder(f, "t.sort_order_today ASC, ts.start_time ASC"),
+		append([]interface{}{today, today, today, today, today, today, eveningStartsAt}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer todayRows.Close()
-	todayTasks := scanTodayTaskListItems(r.db, todayRows)
-	populateActionableScheduleFlags(r.db, todayTasks)
 
 	// Evening tasks: schedule entry's start_time >= eveningStartsAt
-	eveningRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			ts.start_time,
-			ts.end_time,
-			ts.id AS schedule_entry_id
+	eveningTasks, err := listTasks(r.db, listDetails{schedule: scannedSchedule, actionable: true}, scannedScheduleTimes, `
+		SELECT `+taskListColumns+`,
+			ts.start_time, ts.end_time, ts.id
 		FROM tasks t
+		`+searchTaskJoins+`
 		LEFT JOIN task_schedules ts ON ts.task_id = t.id AND ts.when_date = ?
 		WHERE t.status = 'open'
 			AND (
@@ -172,106 +123,53 @@ func (r *ViewRepository) Today(eveningStartsAt string) (*model.TodayView, error)
 				OR t.deadline = ?
 				OR EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date = ? AND completed = 0))
 			AND t.deleted_at IS NULL
-			AND ts.start_time IS NOT NULL AND ts.start_time >= ?
-		ORDER BY t.sort_order_today ASC, ts.start_time ASC`, today, today, today, today, today, today, eveningStartsAt)
+			AND ts.start_time IS NOT NULL AND ts.start_time >= ?`+where+`
+		ORDER BY `+viewOrder(f, "t.sort_order_today ASC, ts.start_time ASC"),
+		append([]interface{}{today, today, today, today, today, today, eveningStartsAt}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer eveningRows.Close()
-	eveningTasks := scanTodayTaskListItems(r.db, eveningRows)
-	populateActionableScheduleFlags(r.db, eveningTasks)
 
 	// Overdue
-	overdueRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+	overdueTasks, err := listTasks(r.db, listDetails{actionable: true}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
-		WHERE t.status = 'open' AND t.deadline < ? AND t.deleted_at IS NULL
-		ORDER BY t.deadline ASC`, today)
+		`+searchTaskJoins+`
+		WHERE t.status = 'open' AND t.deadline < ? AND t.deleted_at IS NULL`+where+`
+		ORDER BY `+viewOrder(f, "t.deadline ASC"), append([]interface{}{today}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer overdueRows.Close()
-	overdueTasks := scanTaskListItems(r.db, overdueRows)
-	populateActionableScheduleFlags(r.db, overdueTasks)
 
 	// Earlier: tasks with when_date before today, but not overdue (no overdue deadline)
 	// Only include tasks that have at least one uncompleted past schedule entry
-	earlierRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1),
-			(SELECT id FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1)
+	earlierTasks, err := listTasks(r.db, listDetails{schedule: pastSchedule, before: today, actionable: true}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
+		`+searchTaskJoins+`
 		WHERE t.status = 'open'
 			AND t.when_date < ? AND t.when_date != 'someday'
 			AND (t.deadline IS NULL OR t.deadline >= ?)
 			AND t.deleted_at IS NULL
-			AND EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0)
-		ORDER BY t.when_date ASC, t.sort_order_today ASC`, today, today, today, today, today, today)
+			AND EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0)`+where+`
+		ORDER BY `+viewOrder(f, "t.when_date ASC, t.sort_order_today ASC"),
+		append([]interface{}{today, today, today}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer earlierRows.Close()
-	earlierTasks := scanTodayTaskListItems(r.db, earlierRows)
-	populatePastScheduleCounts(r.db, earlierTasks, today)
-	populateActionableScheduleFlags(r.db, earlierTasks)
 
 	// Completed today
-	completedRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+	completedTasks, err := listTasks(r.db, listDetails{}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
+		`+searchTaskJoins+`
 		WHERE t.status IN ('completed', 'canceled', 'wont_do')
 			AND COALESCE(t.completed_at, t.canceled_at, t.updated_at) >= ?
-			AND t.deleted_at IS NULL
-		ORDER BY COALESCE(t.completed_at, t.canceled_at, t.updated_at) DESC`, today)
+			AND t.deleted_at IS NULL`+where+`
+		ORDER BY `+viewOrder(f, "COALESCE(t.completed_at, t.canceled_at, t.updated_at) DESC"), append([]interface{}{today}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer completedRows.Close()
-	completedTasks := scanTaskListItems(r.db, completedRows)
 
 	return &model.TodayView{
 		Sections: []model.TodaySection{
@@ -284,56 +182,61 @@ func (r *ViewRepository) Today(eveningStartsAt string) (*model.TodayView, error)
 	}, nil
 }
 
-func (r *ViewRepository) Upcoming(from string) (*model.UpcomingView, error) {
+// Upcoming lists open tasks by date from the given date onwards. When
+// projectUntil is set, future occurrences of repeating tasks up to that date
+// are added as projected items without creating rows.
+func (r *ViewRepository) Upcoming(from, projectUntil string, cal recurrence.Calendar, f model.ViewFilter) (*model.UpcomingView, error) {
 	if from == "" {
 		from = time.Now().Format("2006-01-02")
 	}
+	where, whereArgs := viewFilterSQL(f)
 	// JOIN task_schedules so a task with multiple schedule dates appears once per date
-	rows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			ts.start_time,
-			ts.end_time,
-			ts.id AS schedule_entry_id,
-			ts.when_date AS schedule_date
+	var scheduleDates []*string
+	tasks, err := listTasks(r.db, listDetails{schedule: scannedSchedule, actionable: true}, func(t *model.TaskListItem) []interface{} {
+		date := new(string)
+		scheduleDates = append(scheduleDates, date)
+		return append(scannedScheduleTimes(t), date)
+	}, `
+		SELECT `+taskListColumns+`,
+			ts.start_time, ts.end_time, ts.id, ts.when_date
 		FROM tasks t
+		`+searchTaskJoins+`
 		JOIN task_schedules ts ON ts.task_id = t.id AND ts.completed = 0
-		WHERE t.status = 'open' AND ts.when_date >= ? AND ts.when_date != 'someday' AND t.deleted_at IS NULL
-		ORDER BY ts.when_date ASC, ts.start_time ASC, t.sort_order_today ASC`, from)
+		WHERE t.status = 'open' AND ts.when_date >= ? AND ts.when_date != 'someday' AND t.deleted_at IS NULL`+where+`
+		ORDER BY `+viewOrder(f, "ts.when_date ASC, ts.start_time ASC, t.sort_order_today ASC", "ts.when_date ASC"),
+		append([]interface{}{from}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	tasks := scanUpcomingTaskListItems(r.db, rows)
 
 	// Group by schedule_date (which may differ from t.when_date for multi-date tasks)
 	dateMap := make(map[string][]model.TaskListItem)
 	var dateOrder []string
-	for _, item := range tasks {
-		d := item.date
+	for i, task := range tasks {
+		d := *scheduleDates[i]
 		if _, ok := dateMap[d]; !ok {
 			dateOrder = append(dateOrder, d)
 		}
-		dateMap[d] = append(dateMap[d], item.task)
+		dateMap[d] = append(dateMap[d], task)
+	}
+
+	var projected map[string][]model.TaskListItem
+	if projectUntil != "" {
+		projected, err = r.projectedOccurrences(from, projectUntil, cal, f)
+		if err != nil {
+			return nil, err
+		}
+		for d := range projected {
+			if _, ok := dateMap[d]; !ok {
+				dateOrder = append(dateOrder, d)
+			}
+		}
+		sort.Strings(dateOrder)
 	}
 
 	var dates []model.DateGroup
 	for _, d := range dateOrder {
-		group := dateMap[d]
-		populateActionableScheduleFlags(r.db, group)
+		group := append(dateMap[d], projected[d]...)
 		dates = append(dates, model.DateGroup{Date: d, Tasks: group})
 	}
 	if dates == nil {
@@ -341,84 +244,173 @@ func (r *ViewRepository) Upcoming(from string) (*model.UpcomingView, error) {
 	}
 
 	// Overdue: tasks with deadline before today
-	overdueRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+	overdueTasks, err := listTasks(r.db, listDetails{actionable: true}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
-		WHERE t.status = 'open' AND t.deadline < ? AND t.deleted_at IS NULL
-		ORDER BY t.deadline ASC`, from)
+		`+searchTaskJoins+`
+		WHERE t.status = 'open' AND t.deadline < ? AND t.deleted_at IS NULL`+where+`
+		ORDER BY `+viewOrder(f, "t.deadline ASC"), append([]interface{}{from}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer overdueRows.Close()
-	overdueTasks := scanTaskListItems(r.db, overdueRows)
-	populateActionableScheduleFlags(r.db, overdueTasks)
 
 	// Earlier: tasks with when_date before the from date, not someday, not overdue
 	// Only include tasks that have at least one uncompleted past schedule entry
-	earlierRows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1),
-			(SELECT id FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0 ORDER BY sort_order ASC LIMIT 1)
+	earlierTasks, err := listTasks(r.db, listDetails{schedule: pastSchedule, before: from, actionable: true}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
+		`+searchTaskJoins+`
 		WHERE t.status = 'open'
 			AND t.when_date < ? AND t.when_date != 'someday'
 			AND (t.deadline IS NULL OR t.deadline >= ?)
 			AND t.deleted_at IS NULL
-			AND EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0)
-		ORDER BY t.when_date ASC, t.sort_order_today ASC`, from, from, from, from, from, from)
+			AND EXISTS(SELECT 1 FROM task_schedules WHERE task_id = t.id AND when_date < ? AND when_date != 'someday' AND completed = 0)`+where+`
+		ORDER BY `+viewOrder(f, "t.when_date ASC, t.sort_order_today ASC"),
+		append([]interface{}{from, from, from}, whereArgs...)...)
 	if err != nil {
 		return nil, err
 	}
-	defer earlierRows.Close()
-	earlierTasks := scanTodayTaskListItems(r.db, earlierRows)
-	populatePastScheduleCounts(r.db, earlierTasks, from)
-	populateActionableScheduleFlags(r.db, earlierTasks)
 
 	return &model.UpcomingView{Overdue: overdueTasks, Dates: dates, Earlier: earlierTasks}, nil
 }
 
-func (r *ViewRepository) Anytime() (*model.AnytimeView, error) {
-	return r.buildAnytimeView(false)
+// projectedOccurrences returns virtual future occurrences of open repeating
+// tasks between from and until, keyed by date. Each is a copy of the task's
+// list item with its date moved and Projected set.
+func (r *ViewRepository) projectedOccurrences(from, until string, cal recurrence.Calendar, f model.ViewFilter) (map[string][]model.TaskListItem, error) {
+	where, whereArgs := viewFilterSQL(f)
+	tasks, err := listTasks(r.db, listDetails{}, nil, `
+		SELECT `+taskListColumns+`
+		FROM tasks t
+		`+searchTaskJoins+`
+		WHERE t.status = 'open' AND t.deleted_at IS NULL
+			AND t.when_date IS NOT NULL AND t.when_date != 'someday'
+			AND EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id)`+where, whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	patterns := make(map[string]string, len(tasks))
+	index := newTaskIndex(tasks)
+	rows, err := r.db.Query("SELECT task_id, pattern FROM repeat_rules WHERE task_id IN (SELECT value FROM json_each(?))", index.ids())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var taskID, pattern string
+		if err := rows.Scan(&taskID, &pattern); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		patterns[taskID] = pattern
+	}
+	rows.Close()
+
+	engine := recurrence.NewEngineWithCalendar(cal)
+	projected := make(map[string][]model.TaskListItem)
+	for _, task := range tasks {
+		// An occurrence to come starts with its checklist unchecked.
+		task.ChecklistDone = 0
+		var pattern model.RecurrencePattern
+		if err := json.Unmarshal([]byte(patterns[task.ID]), &pattern); err != nil {
+			continue
+		}
+		start := recurrence.JoinDateTime(*task.WhenDate, task.FirstScheduleTime)
+		dates, err := engine.Occurrences(start, until, pattern)
+		if err != nil {
+			continue
+		}
+		for _, d := range dates {
+			date, clock := recurrence.SplitDateTime(d)
+			if date < from {
+				continue
+			}
+			item := task
+			item.WhenDate = &date
+			if clock != "" {
+				item.FirstScheduleTime = &clock
+				item.FirstScheduleEndTime = nil
+			}
+			item.Deadline = nil
+			item.Projected = true
+			projected[date] = append(projected[date], item)
+		}
+	}
+	return projected, nil
 }
 
-func (r *ViewRepository) Someday() (*model.AnytimeView, error) {
-	return r.buildAnytimeView(true)
+func (r *ViewRepository) Anytime(f model.ViewFilter) (*model.AnytimeView, error) {
+	return r.buildAnytimeView(false, f)
 }
 
-func (r *ViewRepository) buildAnytimeView(somedayOnly bool) (*model.AnytimeView, error) {
+func (r *ViewRepository) Someday(f model.ViewFilter) (*model.AnytimeView, error) {
+	return r.buildAnytimeView(true, f)
+}
+
+func (r *ViewRepository) buildAnytimeView(somedayOnly bool, f model.ViewFilter) (*model.AnytimeView, error) {
 	// Anytime: open tasks with no when_date (not scheduled for a specific day, not someday).
 	// Someday: open tasks explicitly marked when_date = 'someday'.
+	// Tasks with no project or area show in Anytime only with a deadline.
+	when := "t.when_date IS NULL AND (t.project_id IS NOT NULL OR t.area_id IS NOT NULL OR t.deadline IS NOT NULL)"
+	if somedayOnly {
+		when = "t.when_date = 'someday'"
+	}
+	where, whereArgs := viewFilterSQL(f)
+	tasks, err := listTasks(r.db, listDetails{actionable: true}, nil, `
+		SELECT `+taskListColumns+`
+		FROM tasks t
+		`+searchTaskJoins+`
+		WHERE t.status = 'open' AND t.deleted_at IS NULL AND `+when+where+`
+		ORDER BY `+viewOrder(f, "t.sort_order_today ASC"), whereArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split the tasks, still in order, between their project, their area,
+	// or neither.
+	projectTasks := make(map[string][]model.TaskListItem)
+	areaTasks := make(map[string][]model.TaskListItem)
+	noAreaTasks := []model.TaskListItem{}
+	for _, t := range tasks {
+		switch {
+		case t.ProjectID != nil:
+			projectTasks[*t.ProjectID] = append(projectTasks[*t.ProjectID], t)
+		case t.AreaID != nil:
+			areaTasks[*t.AreaID] = append(areaTasks[*t.AreaID], t)
+		default:
+			noAreaTasks = append(noAreaTasks, t)
+		}
+	}
+
+	// Open projects with tasks, by area
+	areaProjects := make(map[string][]model.AnytimeProject)
+	var noAreaProjects []model.AnytimeProject
+	projRows, err := r.db.Query("SELECT id, title, area_id FROM projects WHERE status = 'open' ORDER BY sort_order")
+	if err != nil {
+		return nil, err
+	}
+	for projRows.Next() {
+		var projRef model.Ref
+		var areaID *string
+		if err := projRows.Scan(&projRef.ID, &projRef.Title, &areaID); err != nil {
+			projRows.Close()
+			return nil, err
+		}
+		tasks := projectTasks[projRef.ID]
+		if len(tasks) == 0 {
+			continue
+		}
+		p := model.AnytimeProject{Project: projRef, Tasks: tasks}
+		if areaID == nil {
+			noAreaProjects = append(noAreaProjects, p)
+		} else {
+			areaProjects[*areaID] = append(areaProjects[*areaID], p)
+		}
+	}
+	projRows.Close()
 
-	// Get all areas
 	areaRows, err := r.db.Query("SELECT id, title FROM areas ORDER BY sort_order")
 	if err != nil {
 		return nil, err
@@ -428,35 +420,18 @@ func (r *ViewRepository) buildAnytimeView(somedayOnly bool) (*model.AnytimeView,
 	var view model.AnytimeView
 	for areaRows.Next() {
 		var areaRef model.Ref
-		_ = areaRows.Scan(&areaRef.ID, &areaRef.Title)
-
-		aa := model.AnytimeArea{Area: areaRef}
-
-		// Projects in area
-		projRows, err := r.db.Query(
-			"SELECT id, title FROM projects WHERE area_id = ? AND status = 'open' ORDER BY sort_order", areaRef.ID)
-		if err != nil {
-			continue
+		if err := areaRows.Scan(&areaRef.ID, &areaRef.Title); err != nil {
+			return nil, err
 		}
-		for projRows.Next() {
-			var projRef model.Ref
-			_ = projRows.Scan(&projRef.ID, &projRef.Title)
-			tasks := r.getAnytimeTasks(&projRef.ID, &areaRef.ID, true, somedayOnly)
-			if len(tasks) == 0 {
-				continue
-			}
-			aa.Projects = append(aa.Projects, model.AnytimeProject{Project: projRef, Tasks: tasks})
+		aa := model.AnytimeArea{Area: areaRef, Projects: areaProjects[areaRef.ID], StandaloneTasks: areaTasks[areaRef.ID]}
+		if len(aa.Projects) == 0 && len(aa.StandaloneTasks) == 0 {
+			continue
 		}
-		projRows.Close()
 		if aa.Projects == nil {
 			aa.Projects = []model.AnytimeProject{}
 		}
-
-		// Standalone tasks in area
-		aa.StandaloneTasks = r.getAnytimeTasks(nil, &areaRef.ID, false, somedayOnly)
-
-		if len(aa.Projects) == 0 && len(aa.StandaloneTasks) == 0 {
-			continue
+		if aa.StandaloneTasks == nil {
+			aa.StandaloneTasks = []model.TaskListItem{}
 		}
 		view.Areas = append(view.Areas, aa)
 	}
@@ -464,158 +439,45 @@ func (r *ViewRepository) buildAnytimeView(somedayOnly bool) (*model.AnytimeView,
 		view.Areas = []model.AnytimeArea{}
 	}
 
-	// No-area projects
-	noAreaProjRows, err := r.db.Query(
-		"SELECT id, title FROM projects WHERE area_id IS NULL AND status = 'open' ORDER BY sort_order")
-	if err == nil {
-		for noAreaProjRows.Next() {
-			var projRef model.Ref
-			_ = noAreaProjRows.Scan(&projRef.ID, &projRef.Title)
-			tasks := r.getAnytimeTasks(&projRef.ID, nil, true, somedayOnly)
-			if len(tasks) == 0 {
-				continue
-			}
-			view.NoArea.Projects = append(view.NoArea.Projects, model.AnytimeProject{Project: projRef, Tasks: tasks})
-		}
-		noAreaProjRows.Close()
-	}
+	view.NoArea.Projects = noAreaProjects
 	if view.NoArea.Projects == nil {
 		view.NoArea.Projects = []model.AnytimeProject{}
 	}
-
-	// Standalone tasks with no area, no project
-	view.NoArea.StandaloneTasks = r.getAnytimeStandaloneNoArea(somedayOnly)
+	view.NoArea.StandaloneTasks = noAreaTasks
 
 	return &view, nil
 }
 
-func (r *ViewRepository) getAnytimeTasks(projectID, areaID *string, byProject, somedayOnly bool) []model.TaskListItem {
-	var query string
-	var args []interface{}
-
-	query = `
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
-		FROM tasks t WHERE t.status = 'open' AND t.deleted_at IS NULL`
-
-	if byProject && projectID != nil {
-		query += " AND t.project_id = ?"
-		args = append(args, *projectID)
-	} else if !byProject {
-		query += " AND t.project_id IS NULL"
-		if areaID != nil {
-			query += " AND t.area_id = ?"
-			args = append(args, *areaID)
-		}
+// Logbook returns a page of finished tasks, most recent first, grouped by the
+// day they were finished. A day can continue on the next page.
+func (r *ViewRepository) Logbook(page model.Page, f model.ViewFilter) (*model.LogbookView, error) {
+	if page.Limit <= 0 {
+		page.Limit = 50
 	}
-
-	if somedayOnly {
-		query += " AND t.when_date = 'someday'"
-	} else {
-		query += " AND t.when_date IS NULL"
-	}
-
-	query += " ORDER BY t.sort_order_today ASC"
-
-	rows, err := r.db.Query(query, args...)
+	where, whereArgs := viewFilterSQL(f)
+	keys := newKeyset("logbook"+f.Sort, viewOrder(f, "COALESCE(t.completed_at, t.canceled_at, t.updated_at) DESC",
+		"date(COALESCE(t.completed_at, t.canceled_at, t.updated_at)) DESC"))
+	after, afterArgs, err := keys.after(page.Cursor)
 	if err != nil {
-		return []model.TaskListItem{}
-	}
-	defer rows.Close()
-	tasks := scanTaskListItems(r.db, rows)
-	populateActionableScheduleFlags(r.db, tasks)
-	return tasks
-}
-
-func (r *ViewRepository) getAnytimeStandaloneNoArea(somedayOnly bool) []model.TaskListItem {
-	var query string
-	query = `
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
-		FROM tasks t
-		WHERE t.status = 'open' AND t.project_id IS NULL AND t.area_id IS NULL AND t.deleted_at IS NULL`
-
-	if somedayOnly {
-		query += " AND t.when_date = 'someday'"
-	} else {
-		query += " AND t.when_date IS NULL AND t.deadline IS NOT NULL"
+		return nil, err
 	}
 
-	query += " ORDER BY t.sort_order_today ASC"
+	var total int
+	_ = r.db.QueryRow("SELECT COUNT(*) FROM tasks t WHERE t.status IN ('completed', 'canceled', 'wont_do') AND t.deleted_at IS NULL"+where, whereArgs...).Scan(&total)
 
-	rows, err := r.db.Query(query)
+	tasks, err := listTasks(r.db, listDetails{}, nil, `
+		SELECT `+taskListColumns+`
+		FROM tasks t
+		`+searchTaskJoins+`
+		WHERE t.status IN ('completed', 'canceled', 'wont_do') AND t.deleted_at IS NULL`+where+after+`
+		ORDER BY `+keys.orderBy()+limitClause(page.Limit), append(whereArgs, afterArgs...)...)
 	if err != nil {
-		return []model.TaskListItem{}
-	}
-	defer rows.Close()
-	tasks := scanTaskListItems(r.db, rows)
-	populateActionableScheduleFlags(r.db, tasks)
-	return tasks
-}
-
-func (r *ViewRepository) Logbook(limit, offset int) (*model.LogbookView, error) {
-	if limit <= 0 {
-		limit = 50
+		return nil, err
 	}
-
-	var total int
-	_ = r.db.QueryRow("SELECT COUNT(*) FROM tasks WHERE status IN ('completed', 'canceled', 'wont_do') AND deleted_at IS NULL").Scan(&total)
-
-	rows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
-		FROM tasks t
-		WHERE t.status IN ('completed', 'canceled', 'wont_do') AND t.deleted_at IS NULL
-		ORDER BY COALESCE(t.completed_at, t.canceled_at, t.updated_at) DESC
-		LIMIT ? OFFSET ?`, limit, offset)
+	tasks, next, err := keys.trimPage(r.db, tasks, page.Limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	tasks := scanTaskListItems(r.db, rows)
 
 	// Group by completion date
 	dateMap := make(map[string][]model.TaskListItem)
@@ -643,44 +505,38 @@ func (r *ViewRepository) Logbook(limit, offset int) (*model.LogbookView, error)
 		groups = []model.DateGroup{}
 	}
 
-	return &model.LogbookView{Groups: groups, Total: total}, nil
+	return &model.LogbookView{Groups: groups, Total: total, NextCursor: next}, nil
 }
 
-func (r *ViewRepository) Trash(limit, offset int) (*model.LogbookView, error) {
-	if limit <= 0 {
-		limit = 50
+// Trash returns a page of deleted tasks, most recently deleted first, grouped
+// by the day they were deleted. A day can continue on the next page.
+func (r *ViewRepository) Trash(page model.Page, f model.ViewFilter) (*model.LogbookView, error) {
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+	where, whereArgs := viewFilterSQL(f)
+	keys := newKeyset("trash"+f.Sort, viewOrder(f, "t.deleted_at DESC", "date(t.deleted_at) DESC"))
+	after, afterArgs, err := keys.after(page.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var total int
-	_ = r.db.QueryRow("SELECT COUNT(*) FROM tasks WHERE deleted_at IS NOT NULL").Scan(&total)
-
-	rows, err := r.db.Query(`
-		SELECT t.id, t.title, t.notes, t.status, t.when_date, t.when_evening, t.high_priority,
-			t.deadline, t.project_id, t.area_id, t.heading_id,
-			t.sort_order_today, t.sort_order_project, t.sort_order_heading,
-			t.completed_at, t.canceled_at, t.deleted_at, t.created_at, t.updated_at,
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id), 0),
-			COALESCE((SELECT COUNT(*) FROM checklist_items WHERE task_id = t.id AND completed = 1), 0),
-			CASE WHEN t.notes != '' THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'link') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM attachments WHERE task_id = t.id AND type = 'file') THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			CASE WHEN EXISTS(SELECT 1 FROM reminders WHERE task_id = t.id) THEN 1 ELSE 0 END,
-			(SELECT type FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT value FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT exact_at FROM reminders WHERE task_id = t.id ORDER BY created_at LIMIT 1),
-			(SELECT start_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1),
-			(SELECT end_time FROM task_schedules WHERE task_id = t.id ORDER BY sort_order ASC LIMIT 1)
+	_ = r.db.QueryRow("SELECT COUNT(*) FROM tasks t WHERE t.deleted_at IS NOT NULL"+where, whereArgs...).Scan(&total)
+
+	tasks, err := listTasks(r.db, listDetails{}, nil, `
+		SELECT `+taskListColumns+`
 		FROM tasks t
-		WHERE t.deleted_at IS NOT NULL
-		ORDER BY t.deleted_at DESC
-		LIMIT ? OFFSET ?`, limit, offset)
+		`+searchTaskJoins+`
+		WHERE t.deleted_at IS NOT NULL`+where+after+`
+		ORDER BY `+keys.orderBy()+limitClause(page.Limit), append(whereArgs, afterArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	tasks, next, err := keys.trimPage(r.db, tasks, page.Limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	tasks := scanTaskListItems(r.db, rows)
 
 	// Group by deletion date
 	dateMap := make(map[string][]model.TaskListItem)
@@ -704,141 +560,10 @@ func (r *ViewRepository) Trash(limit, offset int) (*model.LogbookView, error) {
 		groups = []model.DateGroup{}
 	}
 
-	return &model.LogbookView{Groups: groups, Total: total}, nil
-}
-
-type upcomingItem struct {
-	task model.TaskListItem
-	date string // the schedule_date for grouping
-}
-
-func scanUpcomingTaskListItems(db *sql.DB, rows *sql.Rows) []upcomingItem {
-	var items []upcomingItem
-	for rows.Next() {
-		var t model.TaskListItem
-		var whenEvening, highPriority, hasNotes, hasLinks, hasFiles, hasRepeat, hasReminders int
-		var scheduleDate string
-		_ = rows.Scan(
-			&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &whenEvening, &highPriority,
-			&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
-			&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
-			&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
-			&t.ChecklistCount, &t.ChecklistDone,
-			&hasNotes, &hasLinks, &hasFiles, &hasRepeat, &hasReminders,
-			&t.FirstReminderType, &t.FirstReminderValue, &t.FirstReminderExactAt,
-			&t.FirstScheduleTime, &t.FirstScheduleEndTime,
-			&t.ScheduleEntryID,
-			&scheduleDate,
-		)
-		_ = whenEvening // column retained in DB but no longer exposed
-		t.HighPriority = highPriority == 1
-		t.HasNotes = hasNotes == 1
-		t.HasLinks = hasLinks == 1
-		t.HasFiles = hasFiles == 1
-		t.HasRepeatRule = hasRepeat == 1
-		t.HasReminders = hasReminders == 1
-		// Get tags
-		tagRows, _ := db.Query(
-			"SELECT t.id, t.title, t.color FROM tags t JOIN task_tags tt ON t.id = tt.tag_id WHERE tt.task_id = ?", t.ID)
-		if tagRows != nil {
-			var tags []model.TagRef
-			for tagRows.Next() {
-				var tag model.TagRef
-				_ = tagRows.Scan(&tag.ID, &tag.Title, &tag.Color)
-				tags = append(tags, tag)
-			}
-			tagRows.Close()
-			if tags == nil {
-				tags = []model.TagRef{}
-			}
-			t.Tags = tags
-		} else {
-			t.Tags = []model.TagRef{}
-		}
-		// Resolve project/area names
-		if t.ProjectID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM projects WHERE id = ?", *t.ProjectID).Scan(&name); err == nil {
-				t.ProjectName = &name
-			}
-		}
-		if t.AreaID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM areas WHERE id = ?", *t.AreaID).Scan(&name); err == nil {
-				t.AreaName = &name
-			}
-		}
-		items = append(items, upcomingItem{task: t, date: scheduleDate})
-	}
-	return items
-}
-
-// scanTodayTaskListItems scans rows that include ts.start_time, ts.end_time,
-// and ts.id (schedule_entry_id) from a LEFT JOIN on task_schedules.
-// Unlike scanUpcomingTaskListItems, there is no extra schedule_date grouping column.
-func scanTodayTaskListItems(db *sql.DB, rows *sql.Rows) []model.TaskListItem {
-	var tasks []model.TaskListItem
-	for rows.Next() {
-		var t model.TaskListItem
-		var whenEvening, highPriority, hasNotes, hasLinks, hasFiles, hasRepeat, hasReminders int
-		_ = rows.Scan(
-			&t.ID, &t.Title, &t.Notes, &t.Status, &t.WhenDate, &whenEvening, &highPriority,
-			&t.Deadline, &t.ProjectID, &t.AreaID, &t.HeadingID,
-			&t.SortOrderToday, &t.SortOrderProject, &t.SortOrderHeading,
-			&t.CompletedAt, &t.CanceledAt, &t.DeletedAt, &t.CreatedAt, &t.UpdatedAt,
-			&t.ChecklistCount, &t.ChecklistDone,
-			&hasNotes, &hasLinks, &hasFiles, &hasRepeat, &hasReminders,
-			&t.FirstReminderType, &t.FirstReminderValue, &t.FirstReminderExactAt,
-			&t.FirstScheduleTime, &t.FirstScheduleEndTime,
-			&t.ScheduleEntryID,
-		)
-		_ = whenEvening // column retained in DB but no longer exposed
-		t.HighPriority = highPriority == 1
-		t.HasNotes = hasNotes == 1
-		t.HasLinks = hasLinks == 1
-		t.HasFiles = hasFiles == 1
-		t.HasRepeatRule = hasRepeat == 1
-		t.HasReminders = hasReminders == 1
-		// Get tags
-		tagRows, _ := db.Query(
-			"SELECT t.id, t.title, t.color FROM tags t JOIN task_tags tt ON t.id = tt.tag_id WHERE tt.task_id = ?", t.ID)
-		if tagRows != nil {
-			var tags []model.TagRef
-			for tagRows.Next() {
-				var tag model.TagRef
-				_ = tagRows.Scan(&tag.ID, &tag.Title, &tag.Color)
-				tags = append(tags, tag)
-			}
-			tagRows.Close()
-			if tags == nil {
-				tags = []model.TagRef{}
-			}
-			t.Tags = tags
-		} else {
-			t.Tags = []model.TagRef{}
-		}
-		// Resolve project/area names
-		if t.ProjectID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM projects WHERE id = ?", *t.ProjectID).Scan(&name); err == nil {
-				t.ProjectName = &name
-			}
-		}
-		if t.AreaID != nil {
-			var name string
-			if err := db.QueryRow("SELECT title FROM areas WHERE id = ?", *t.AreaID).Scan(&name); err == nil {
-				t.AreaName = &name
-			}
-		}
-		tasks = append(tasks, t)
-	}
-	if tasks == nil {
-		tasks = []model.TaskListItem{}
-	}
-	return tasks
+	return &model.LogbookView{Groups: groups, Total: total, NextCursor: next}, nil
 }
 
-func groupByProject(db *sql.DB, tasks []model.TaskListItem) []model.TaskGroup {
+func groupByProject(db querier, tasks []model.TaskListItem) []model.TaskGroup {
 	if len(tasks) == 0 {
 		return []model.TaskGroup{}
 	}
@@ -860,7 +585,10 @@ func groupByProject(db *sql.DB, tasks []model.TaskListItem) []model.TaskGroup {
 	var groups []model.TaskGroup
 	for _, key := range projectOrder {
 		g := model.TaskGroup{Tasks: projectMap[key]}
-		if key != "" {
+		// Tasks come with their project's name; look up any that did not.
+		if name := projectMap[key][0].ProjectName; name != nil {
+			g.Project = &model.Ref{ID: key, Title: *name}
+		} else if key != "" {
 			g.Project = getRef(db, "projects", key)
 		}
 		groups = append(groups, g)
@@ -868,19 +596,31 @@ func groupByProject(db *sql.DB, tasks []model.TaskListItem) []model.TaskGroup {
 	return groups
 }
 
-func (r *ViewRepository) Counts(reviewAfterDays *int, includeRecurring bool) (*model.ViewCounts, error) {
+func (r *ViewRepository) Counts(reviewAfterDays *int, includeRecurring bool, f model.ViewFilter) (*model.ViewCounts, error) {
 	today := time.Now().Format("2006-01-02")
+	where, whereArgs := viewFilterSQL(f)
 	var c model.ViewCounts
-	err := r.db.QueryRow(`
-		SELECT
-			(SELECT COUNT(*) FROM tasks WHERE project_id IS NULL AND area_id IS NULL AND status = 'open' AND when_date IS NULL AND deleted_at IS NULL),
-			(SELECT COUNT(*) FROM tasks WHERE status = 'open' AND (when_date = ? OR deadline = ?) AND (deadline IS NULL OR deadline >= ?) AND deleted_at IS NULL),
-			(SELECT COUNT(*) FROM tasks WHERE status = 'open' AND deadline < ? AND deleted_at IS NULL),
-			(SELECT COUNT(*) FROM tasks WHERE status = 'open' AND when_date IS NULL AND deleted_at IS NULL AND (project_id IS NOT NULL OR area_id IS NOT NULL OR deadline IS NOT NULL)),
-			(SELECT COUNT(*) FROM tasks WHERE status = 'open' AND when_date = 'someday' AND deleted_at IS NULL),
-			(SELECT COUNT(*) FROM tasks WHERE status IN ('completed', 'canceled', 'wont_do') AND deleted_at IS NULL),
-			(SELECT COUNT(*) FROM tasks WHERE deleted_at IS NOT NULL)
-	`, today, today, today, today).Scan(&c.Inbox, &c.Today, &c.Overdue, &c.Anytime, &c.Someday, &c.Logbook, &c.Trash)
+	views := []struct {
+		cond string
+		args []interface{}
+		dest *int
+	}{
+		{"t.project_id IS NULL AND t.area_id IS NULL AND t.status = 'open' AND t.when_date IS NULL AND t.deleted_at IS NULL", nil, &c.Inbox},
+		{"t.status = 'open' AND (t.when_date = ? OR t.deadline = ?) AND (t.deadline IS NULL OR t.deadline >= ?) AND t.deleted_at IS NULL", []interface{}{today, today, today}, &c.Today},
+		{"t.status = 'open' AND t.deadline < ? AND t.deleted_at IS NULL", []interface{}{today}, &c.Overdue},
+		{"t.status = 'open' AND t.when_date IS NULL AND t.deleted_at IS NULL AND (t.project_id IS NOT NULL OR t.area_id IS NOT NULL OR t.deadline IS NOT NULL)", nil, &c.Anytime},
+		{"t.status = 'open' AND t.when_date = 'someday' AND t.deleted_at IS NULL", nil, &c.Someday},
+		{"t.status IN ('completed', 'canceled', 'wont_do') AND t.deleted_at IS NULL", nil, &c.Logbook},
+		{"t.deleted_at IS NOT NULL", nil, &c.Trash},
+	}
+	var selects []string
+	var args, dests []interface{}
+	for _, v := range views {
+		selects = append(selects, "(SELECT COUNT(*) FROM tasks t WHERE "+v.cond+where+")")
+		args = append(append(args, v.args...), whereArgs...)
+		dests = append(dests, v.dest)
+	}
+	err := r.db.QueryRow("SELECT "+strings.Join(selects, ", "), args...).Scan(dests...)
 	if err != nil {
 		return nil, err
 	}
@@ -889,14 +629,14 @@ func (r *ViewRepository) Counts(reviewAfterDays *int, includeRecurring bool) (*m
 	if reviewAfterDays != nil && *reviewAfterDays > 0 {
 		recurringClause := ""
 		if !includeRecurring {
-			recurringClause = " AND NOT EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = tasks.id)"
+			recurringClause = " AND NOT EXISTS(SELECT 1 FROM repeat_rules WHERE task_id = t.id)"
 		}
 		_ = r.db.QueryRow(`
-			SELECT COUNT(*) FROM tasks
-			WHERE status = 'open' AND deleted_at IS NULL
-				AND date(updated_at) < date('now', '-' || ? || ' days')
-				AND NOT (project_id IS NULL AND area_id IS NULL AND when_date IS NULL)
-		`+recurringClause, *reviewAfterDays).Scan(&c.Review)
+			SELECT COUNT(*) FROM tasks t
+			WHERE t.status = 'open' AND t.deleted_at IS NULL
+				AND date(t.updated_at) < date('now', '-' || ? || ' days')
+				AND NOT (t.project_id IS NULL AND t.area_id IS NULL AND t.when_date IS NULL)
+		`+recurringClause+where, append([]interface{}{*reviewAfterDays}, whereArgs...)...).Scan(&c.Review)
 	}
 
 	return &c, nil
@@ -914,92 +654,8 @@ func ParseIntDefault(s string, def int) int {
 	return n
 }
 
-// populateActionableScheduleFlags sets HasActionableSchedules on each task
-// that has uncompleted schedule entries requiring confirmation — i.e. past
-// entries (will be auto-completed) or future/someday entries (will be deleted).
-// If the only uncompleted entries are for today, no flag is set because
-// completing today's entry is the normal expected behavior.
-func populateActionableScheduleFlags(db *sql.DB, tasks []model.TaskListItem) {
-	if len(tasks) == 0 {
-		return
-	}
-	today := time.Now().Format("2006-01-02")
-	for i, t := range tasks {
-		var count int
-		err := db.QueryRow(
-			"SELECT COUNT(*) FROM task_schedules WHERE task_id = ? AND completed = 0 AND (when_date != ? OR when_date = 'someday')",
-			t.ID, today,
-		).Scan(&count)
-		if err == nil && count > 0 {
-			tasks[i].HasActionableSchedules = true
-		}
-	}
-	populateAllSchedulesCompleted(db, tasks)
-	populateFirstScheduleCompleted(db, tasks)
-}
-
-// populateAllSchedulesCompleted sets AllTodaySchedulesCompleted on each
-// task that has schedule entries and ALL of them are completed.
-func populateAllSchedulesCompleted(db *sql.DB, tasks []model.TaskListItem) {
-	if len(tasks) == 0 {
-		return
-	}
-	for i, t := range tasks {
-		var total, completed int
-		err := db.QueryRow(
-			"SELECT COUNT(*), SUM(CASE WHEN completed = 1 THEN 1 ELSE 0 END) FROM task_schedules WHERE task_id = ?",
-			t.ID,
-		).Scan(&total, &completed)
-		if err == nil && total > 0 && completed == total {
-			tasks[i].AllTodaySchedulesCompleted = true
-		}
-	}
-}
-
-// populateFirstScheduleCompleted sets FirstScheduleCompleted on each task
-// whose displayed schedule entry is completed. Uses ScheduleEntryID if present
-// (Today view), otherwise checks the first schedule by sort_order.
-func populateFirstScheduleCompleted(db *sql.DB, tasks []model.TaskListItem) {
-	if len(tasks) == 0 {
-		return
-	}
-	for i, t := range tasks {
-		if t.FirstScheduleTime == nil {
-			continue
-		}
-		var completed int
-		var err error
-		if t.ScheduleEntryID != nil {
-			err = db.QueryRow(
-				"SELECT completed FROM task_schedules WHERE id = ?",
-				*t.ScheduleEntryID,
-			).Scan(&completed)
-		} else {
-			err = db.QueryRow(
-				"SELECT completed FROM task_schedules WHERE task_id = ? ORDER BY sort_order ASC LIMIT 1",
-				t.ID,
-			).Scan(&completed)
-		}
-		if err == nil && completed == 1 {
-			tasks[i].FirstScheduleCompleted = true
-		}
-	}
-}
-
-// populatePastScheduleCounts sets PastScheduleCount on each task that has
-// schedule entries with when_date before the given date.
-func populatePastScheduleCounts(db *sql.DB, tasks []model.TaskListItem, before string) {
-	if len(tasks) == 0 {
-		return
-	}
-	for i, t := range tasks {
-		var count int
-		err := db.QueryRow(
-			`SELECT COUNT(*) FROM task_schedules WHERE task_id = ? AND when_date < ? AND when_date != 'someday' AND completed = 0`,
-			t.ID, before,
-		).Scan(&count)
-		if err == nil {
-			tasks[i].PastScheduleCount = count
-		}
-	}
+// scannedScheduleTimes returns the destinations of the ts.start_time,
+// ts.end_time and ts.id columns of a list joined with its schedule entries.
+func scannedScheduleTimes(t *model.TaskListItem) []interface{} {
+	return []interface{}{&t.FirstScheduleTime, &t.FirstScheduleEndTime, &t.ScheduleEntryID}
 }
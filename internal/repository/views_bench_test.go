@@ -0,0 +1,239 @@
+package repository_test
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+// benchTasks is the size of the view benchmark fixture.
+const benchTasks = 50000
+
+// seedViewFixture fills db with n tasks spread over every view, with the
+// tags, checklists, reminders, schedules, repeat rules and attachments a
+// long-used database collects. It is deterministic for a given day.
+func seedViewFixture(tb testing.TB, db *sql.DB, n int) {
+	tb.Helper()
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	day := func(offset int) string { return now.AddDate(0, 0, offset).Format("2006-01-02") }
+	stamp := func(offset int) string { return now.AddDate(0, 0, offset).Format("2006-01-02 15:04:05") }
+
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	exec := func(query string, args ...interface{}) {
+		if _, err := tx.Exec(query, args...); err != nil {
+			tb.Fatalf("seed %q: %v", query, err)
+		}
+	}
+
+	const areas, projects, tags = 12, 60, 20
+	for a := 0; a < areas; a++ {
+		exec("INSERT INTO areas (id, title, sort_order) VALUES (?, ?, ?)",
+			fmt.Sprintf("area-%02d", a), fmt.Sprintf("Area %d", a), a)
+	}
+	for p := 0; p < projects; p++ {
+		status := "open"
+		if p%12 == 11 {
+			status = "completed"
+		}
+		exec("INSERT INTO projects (id, title, area_id, status, sort_order) VALUES (?, ?, ?, ?, ?)",
+			fmt.Sprintf("project-%02d", p), fmt.Sprintf("Project %d", p), fmt.Sprintf("area-%02d", p%areas), status, p)
+	}
+	for g := 0; g < tags; g++ {
+		exec("INSERT INTO tags (id, title, sort_order) VALUES (?, ?, ?)",
+			fmt.Sprintf("tag-%02d", g), fmt.Sprintf("tag%d", g), tags-g)
+	}
+
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("task-%06d", i)
+		status, whenDate, deadline := "open", interface{}(nil), interface{}(nil)
+		var completedAt, canceledAt, deletedAt interface{}
+		created := stamp(-rng.Intn(400) - 30)
+		updated := stamp(-rng.Intn(30))
+
+		switch r := rng.Intn(100); {
+		case r < 30:
+			status, completedAt = "completed", stamp(-rng.Intn(365))
+		case r < 34:
+			status, canceledAt = "canceled", stamp(-rng.Intn(365))
+		case r < 35:
+			status, canceledAt = "wont_do", stamp(-rng.Intn(365))
+		case r < 40:
+			deletedAt = stamp(-rng.Intn(30))
+		case r < 55:
+			whenDate = day(0)
+		case r < 65:
+			whenDate = day(1 + rng.Intn(60))
+		case r < 70:
+			whenDate = day(-1 - rng.Intn(20))
+		case r < 78:
+			whenDate = "someday"
+		}
+		if rng.Intn(8) == 0 {
+			deadline = day(rng.Intn(40) - 10)
+		}
+
+		var projectID, areaID interface{}
+		switch r := rng.Intn(10); {
+		case r < 4:
+			projectID = fmt.Sprintf("project-%02d", rng.Intn(projects))
+		case r < 6:
+			areaID = fmt.Sprintf("area-%02d", rng.Intn(areas))
+		}
+		notes := ""
+		if i%3 == 0 {
+			notes = "Some notes"
+		}
+		exec(`INSERT INTO tasks (id, title, notes, status, when_date, deadline, project_id, area_id,
+				high_priority, sort_order_today, sort_order_project, completed_at, canceled_at, deleted_at,
+				created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, fmt.Sprintf("Task %d", i), notes, status, whenDate, deadline, projectID, areaID,
+			boolInt(i%7 == 0), float64(i*1024), float64(i*1024), completedAt, canceledAt, deletedAt,
+			created, updated)
+
+		for _, g := range rng.Perm(tags)[:rng.Intn(4)] {
+			exec("INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)", id, fmt.Sprintf("tag-%02d", g))
+		}
+		for c, items := 0, rng.Intn(5); c < items; c++ {
+			exec("INSERT INTO checklist_items (id, task_id, title, completed, sort_order) VALUES (?, ?, ?, ?, ?)",
+				fmt.Sprintf("%s-c%d", id, c), id, "Step", boolInt(rng.Intn(2) == 0), c)
+		}
+		if i%5 == 0 {
+			exec("INSERT INTO reminders (id, task_id, type, value, created_at) VALUES (?, ?, 'minutes_before', ?, ?)",
+				id+"-r0", id, 15, created)
+			second := updated
+			if i%10 == 0 {
+				second = created
+			}
+			exec("INSERT INTO reminders (id, task_id, type, value, created_at) VALUES (?, ?, 'at_start', 0, ?)",
+				id+"-r1", id, second)
+		}
+		if i%9 == 0 {
+			exec("INSERT INTO attachments (id, task_id, type, url) VALUES (?, ?, 'link', 'https://example.com')", id+"-l", id)
+		}
+		if i%13 == 0 {
+			exec("INSERT INTO attachments (id, task_id, type, url) VALUES (?, ?, 'file', '/files/x')", id+"-f", id)
+		}
+
+		if date, ok := whenDate.(string); ok && date != "someday" {
+			var start, end interface{}
+			if i%4 == 0 {
+				start, end = fmt.Sprintf("%02d:00", 8+rng.Intn(14)), fmt.Sprintf("%02d:30", 8+rng.Intn(14))
+			}
+			exec("INSERT INTO task_schedules (id, task_id, when_date, start_time, end_time, sort_order, completed) VALUES (?, ?, ?, ?, ?, 0, ?)",
+				id+"-s0", id, date, start, end, boolInt(i%17 == 0))
+			if i%6 == 0 {
+				exec("INSERT INTO task_schedules (id, task_id, when_date, start_time, sort_order, completed) VALUES (?, ?, ?, ?, ?, ?)",
+					id+"-s1", id, day(rng.Intn(30)-10), "18:00", boolInt(i%12 != 0), boolInt(rng.Intn(3) == 0))
+			}
+			if i%11 == 0 && status == "open" {
+				exec(`INSERT INTO repeat_rules (id, task_id, frequency, mode, pattern)
+					VALUES (?, ?, 'daily', 'fixed', '{"type":"daily","every":3,"mode":"fixed"}')`, id+"-rr", id)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// setupViewBench seeds the fixture and resets the benchmark timer.
+func setupViewBench(b *testing.B) *repository.ViewRepository {
+	db := testutil.SetupTestDB(b)
+	seedViewFixture(b, db, benchTasks)
+	b.ReportAllocs()
+	b.ResetTimer()
+	return repository.NewViewRepository(db)
+}
+
+func BenchmarkViewInbox(b *testing.B) {
+	repo := setupViewBench(b)
+	days := 14
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Inbox(&days, false, model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewToday(b *testing.B) {
+	repo := setupViewBench(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Today("18:00", model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewUpcoming(b *testing.B) {
+	repo := setupViewBench(b)
+	until := time.Now().AddDate(0, 1, 0).Format("2006-01-02")
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Upcoming("", until, nil, model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewAnytime(b *testing.B) {
+	repo := setupViewBench(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Anytime(model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewSomeday(b *testing.B) {
+	repo := setupViewBench(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Someday(model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewLogbook(b *testing.B) {
+	repo := setupViewBench(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Logbook(model.Page{Limit: 50}, model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewTrash(b *testing.B) {
+	repo := setupViewBench(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Trash(model.Page{Limit: 50}, model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkViewCounts(b *testing.B) {
+	repo := setupViewBench(b)
+	days := 14
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Counts(&days, false, model.ViewFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package repository_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the view golden files in testdata/views")
+
+// seedGoldenFixture fills db with a small, fully specified set of tasks that
+// lands in every view and exercises every list detail: tags, checklist
+// counts, links and files, reminders, repeat rules, schedules (including
+// evening, completed and past entries) and headings. Dates are relative to
+// now; every timestamp is explicit so the views render the same each day.
+func seedGoldenFixture(t *testing.T, db *sql.DB, now time.Time) {
+	t.Helper()
+	day := func(offset int) string { return now.AddDate(0, 0, offset).Format("2006-01-02") }
+	stamp := func(offset int, clock string) string { return day(offset) + " " + clock }
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("seed %q: %v", query, err)
+		}
+	}
+	created := stamp(-60, "08:00:00")
+
+	exec("INSERT INTO areas (id, title, sort_order, created_at, updated_at) VALUES ('a1', 'Home', 1, ?, ?), ('a2', 'Work', 2, ?, ?)",
+		created, created, created, created)
+	exec(`INSERT INTO projects (id, title, area_id, status, sort_order, created_at, updated_at) VALUES
+		('p1', 'Renovation', 'a1', 'open', 1, ?, ?),
+		('p2', 'Launch', 'a2', 'open', 2, ?, ?),
+		('p3', 'Archive', 'a2', 'completed', 3, ?, ?)`,
+		created, created, created, created, created, created)
+	exec("INSERT INTO headings (id, title, project_id, sort_order) VALUES ('h1', 'Planning', 'p1', 1), ('h2', 'Build', 'p1', 2)")
+	exec("INSERT INTO tags (id, title, sort_order, color) VALUES ('g1', 'errand', 2, NULL), ('g2', 'focus', 1, '#ff0000')")
+
+	type task struct {
+		id, title, notes, status       string
+		whenDate, deadline             interface{}
+		project, area, heading         interface{}
+		evening, highPriority          bool
+		completedAt, canceled, deleted interface{}
+		updated                        string
+	}
+	tasks := []task{
+		{id: "inbox-plain", title: "Call the plumber"},
+		{id: "inbox-stale", title: "Sort old mail", notes: "Box in the hall", updated: stamp(-30, "09:00:00")},
+		{id: "today-project", title: "Order tiles", whenDate: day(0), project: "p1", heading: "h1"},
+		{id: "today-scheduled", title: "Stand-up", whenDate: day(0), area: "a2", highPriority: true},
+		{id: "today-evening", title: "Read", whenDate: day(0), evening: true},
+		{id: "today-deadline", title: "Submit report", deadline: day(0), area: "a2"},
+		{id: "today-first-done", title: "Take pills", whenDate: day(0)},
+		{id: "overdue", title: "Renew passport", whenDate: day(-5), deadline: day(-2)},
+		{id: "earlier", title: "Fix bike", whenDate: day(-3), area: "a1"},
+		{id: "upcoming-project", title: "Write press release", whenDate: day(1), project: "p2"},
+		{id: "upcoming-multi", title: "Workshop", whenDate: day(2), notes: "Two sessions"},
+		{id: "upcoming-repeat", title: "Water plants", whenDate: day(3), area: "a1"},
+		{id: "anytime-area", title: "Plan garden", area: "a1", updated: stamp(-20, "08:30:00")},
+		{id: "anytime-heading", title: "Pick paint", project: "p1", heading: "h2"},
+		{id: "anytime-archived", title: "Old follow-up", project: "p3"},
+		{id: "someday-area", title: "Learn Italian", whenDate: "someday", area: "a2"},
+		{id: "someday-project", title: "Launch party", whenDate: "someday", project: "p2", updated: stamp(-25, "14:00:00")},
+		{id: "done-today", title: "Pay rent", status: "completed", completedAt: stamp(0, "07:30:00")},
+		{id: "done-earlier", title: "Measure kitchen", status: "completed", project: "p1", heading: "h1", completedAt: stamp(-10, "16:00:00")},
+		{id: "canceled", title: "Book venue", status: "canceled", project: "p2", canceled: stamp(-4, "11:00:00")},
+		{id: "wont-do", title: "Repaint fence", status: "wont_do", area: "a1", canceled: stamp(-6, "12:00:00")},
+		{id: "trashed", title: "Draft slogan", project: "p2", deleted: stamp(-1, "18:00:00")},
+		{id: "trashed-inbox", title: "Duplicate", deleted: stamp(-2, "10:00:00")},
+	}
+	for i, tk := range tasks {
+		status, updated := tk.status, tk.updated
+		if status == "" {
+			status = "open"
+		}
+		if updated == "" {
+			updated = stamp(-1, "12:00:00")
+		}
+		exec(`INSERT INTO tasks (id, title, notes, status, when_date, when_evening, deadline, project_id, area_id, heading_id,
+				high_priority, sort_order_today, sort_order_project, sort_order_heading, completed_at, canceled_at, deleted_at,
+				created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tk.id, tk.title, tk.notes, status, tk.whenDate, boolInt(tk.evening), tk.deadline, tk.project, tk.area, tk.heading,
+			boolInt(tk.highPriority), float64(i+1)*1024, float64(len(tasks)-i)*1024, float64(i+1), tk.completedAt, tk.canceled, tk.deleted,
+			created, updated)
+	}
+
+	exec(`INSERT INTO task_tags (task_id, tag_id) VALUES
+		('inbox-stale', 'g1'), ('today-project', 'g1'), ('today-project', 'g2'),
+		('today-scheduled', 'g2'), ('someday-area', 'g1'), ('done-earlier', 'g2'), ('trashed', 'g2')`)
+	exec(`INSERT INTO checklist_items (id, task_id, title, completed, sort_order) VALUES
+		('c1', 'inbox-stale', 'Bills', 1, 1), ('c2', 'inbox-stale', 'Letters', 0, 2), ('c3', 'inbox-stale', 'Flyers', 0, 3),
+		('c4', 'today-project', 'Measure', 1, 1), ('c5', 'today-project', 'Call shop', 1, 2),
+		('c6', 'anytime-heading', 'Samples', 0, 1), ('c7', 'done-earlier', 'Walls', 1, 1)`)
+	exec(`INSERT INTO attachments (id, task_id, type, url, created_at) VALUES
+		('l1', 'today-project', 'link', 'https://example.com/tiles', ?),
+		('f1', 'upcoming-project', 'file', '/files/draft.pdf', ?),
+		('l2', 'someday-area', 'link', 'https://example.com/course', ?)`, created, created, created)
+	exec(`INSERT INTO reminders (id, task_id, type, value, exact_at, created_at) VALUES
+		('r1', 'today-project', 'at_start', 0, NULL, ?),
+		('r2', 'today-project', 'minutes_before', 15, NULL, ?),
+		('r3', 'upcoming-project', 'exact', 0, ?, ?),
+		('r4', 'today-scheduled', 'minutes_before', 5, NULL, ?)`,
+		stamp(-2, "10:00:00"), stamp(-3, "10:00:00"), stamp(1, "09:00:00"), created, created)
+	exec(`INSERT INTO repeat_rules (id, task_id, frequency, mode, pattern, created_at) VALUES
+		('rr1', 'today-scheduled', 'daily', 'fixed', '{"type":"daily","every":1,"mode":"fixed"}', ?),
+		('rr2', 'upcoming-repeat', 'weekly', 'fixed', '{"type":"daily","every":7,"mode":"fixed"}', ?),
+		('rr3', 'anytime-area', 'monthly', 'after_completion', '{"type":"monthly_dom","every":1,"mode":"after_completion","day":1}', ?)`,
+		created, created, created)
+	exec(`INSERT INTO task_schedules (id, task_id, when_date, start_time, end_time, sort_order, completed, created_at) VALUES
+		('s1', 'today-scheduled', ?, '09:00', '09:15', 1, 0, ?),
+		('s2', 'today-scheduled', ?, '19:00', '19:30', 2, 0, ?),
+		('s3', 'today-evening', ?, '20:30', NULL, 1, 0, ?),
+		('s4', 'today-first-done', ?, '07:00', NULL, 1, 1, ?),
+		('s5', 'today-first-done', ?, '12:00', NULL, 2, 0, ?),
+		('s6', 'earlier', ?, NULL, NULL, 1, 0, ?),
+		('s7', 'earlier', ?, '10:00', NULL, 2, 0, ?),
+		('s8', 'upcoming-multi', ?, '08:00', '12:00', 1, 0, ?),
+		('s9', 'upcoming-multi', ?, '13:00', '17:00', 2, 0, ?),
+		('s10', 'upcoming-project', ?, NULL, NULL, 1, 0, ?),
+		('s11', 'upcoming-repeat', ?, NULL, NULL, 1, 0, ?)`,
+		day(0), created, day(0), created, day(0), created, day(0), created, day(0), created,
+		day(-4), created, day(-3), created, day(2), created, day(4), created, day(1), created, day(3), created)
+}
+
+// goldenDate matches the dates the views render, alone or inside timestamps.
+var goldenDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+
+// relativeDates rewrites each date in b as its offset from today
+// ("today", "today+3", "today-2") so golden files do not go stale.
+func relativeDates(b []byte, today string) []byte {
+	base, _ := time.Parse("2006-01-02", today)
+	return goldenDate.ReplaceAllFunc(b, func(m []byte) []byte {
+		d, err := time.Parse("2006-01-02", string(m))
+		if err != nil {
+			return m
+		}
+		days := int(math.Round(d.Sub(base).Hours() / 24))
+		if days == 0 {
+			return []byte("today")
+		}
+		return []byte(fmt.Sprintf("today%+d", days))
+	})
+}
+
+// TestViewGoldenOutput pins the JSON of every view over a fixture that covers
+// all list details, so a change to how the lists are built cannot alter what
+// clients receive. Run with -update to rewrite testdata/views.
+func TestViewGoldenOutput(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	now := time.Now()
+	seedGoldenFixture(t, db, now)
+	repo := repository.NewViewRepository(db)
+	today := now.Format("2006-01-02")
+	days := 14
+
+	views := []struct {
+		name string
+		load func() (interface{}, error)
+	}{
+		{"inbox", func() (interface{}, error) { return repo.Inbox(&days, false, model.ViewFilter{}) }},
+		{"inbox_recurring", func() (interface{}, error) { return repo.Inbox(&days, true, model.ViewFilter{}) }},
+		{"today", func() (interface{}, error) { return repo.Today("18:00", model.ViewFilter{}) }},
+		{"upcoming", func() (interface{}, error) {
+			return repo.Upcoming(today, now.AddDate(0, 0, 21).Format("2006-01-02"), nil, model.ViewFilter{})
+		}},
+		{"anytime", func() (interface{}, error) { return repo.Anytime(model.ViewFilter{}) }},
+		{"someday", func() (interface{}, error) { return repo.Someday(model.ViewFilter{}) }},
+		{"logbook", func() (interface{}, error) { return repo.Logbook(model.Page{Limit: 50}, model.ViewFilter{}) }},
+		{"trash", func() (interface{}, error) { return repo.Trash(model.Page{Limit: 50}, model.ViewFilter{}) }},
+		{"counts", func() (interface{}, error) { return repo.Counts(&days, false, model.ViewFilter{}) }},
+	}
+	for _, v := range views {
+		t.Run(v.name, func(t *testing.T) {
+			view, err := v.load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			got, err := json.MarshalIndent(view, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(relativeDates(got, today), '\n')
+
+			path := filepath.Join("testdata", "views", v.name+".json")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+				for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+					var g, w string
+					if i < len(gotLines) {
+						g = gotLines[i]
+					}
+					if i < len(wantLines) {
+						w = wantLines[i]
+					}
+					if g != w {
+						t.Fatalf("%s differs at line %d:\n got: %s\nwant: %s", path, i+1, g, w)
+					}
+				}
+			}
+		})
+	}
+}
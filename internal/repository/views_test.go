@@ -1,6 +1,8 @@
 package repository_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,7 +30,7 @@ func TestViewInboxShowsOnlyUnassignedOpenTasks(t *testing.T) {
 	_, _ = db.Exec("INSERT INTO areas (id, title) VALUES ('a1', 'Work')")
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Area task", AreaID: strPtr("a1")})
 
-	view, err := viewRepo.Inbox(nil, true)
+	view, err := viewRepo.Inbox(nil, true, model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed to get inbox: %v", err)
 	}
@@ -49,7 +51,7 @@ func TestViewInboxExcludesCompletedTasks(t *testing.T) {
 	_, _ = taskRepo.Complete(task.ID)
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Open"})
 
-	view, _ := viewRepo.Inbox(nil, true)
+	view, _ := viewRepo.Inbox(nil, true, model.ViewFilter{})
 	if len(view.Tasks) != 1 {
 		t.Fatalf("expected 1 open inbox task, got %d", len(view.Tasks))
 	}
@@ -59,7 +61,7 @@ func TestViewInboxEmpty(t *testing.T) {
 	db := testutil.SetupTestDB(t)
 	viewRepo := repository.NewViewRepository(db)
 
-	view, err := viewRepo.Inbox(nil, true)
+	view, err := viewRepo.Inbox(nil, true, model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed: %v", err)
 	}
@@ -87,7 +89,7 @@ func TestViewTodayStructure(t *testing.T) {
 		EndTime:   &endTime,
 	})
 
-	view, err := viewRepo.Today("18:00")
+	view, err := viewRepo.Today("18:00", model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed: %v", err)
 	}
@@ -113,7 +115,7 @@ func TestViewLogbook(t *testing.T) {
 	_, _ = taskRepo.Cancel(t2.ID)
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Still open"})
 
-	view, err := viewRepo.Logbook(50, 0)
+	view, err := viewRepo.Logbook(model.Page{Limit: 50}, model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed: %v", err)
 	}
@@ -130,11 +132,99 @@ func TestViewLogbook(t *testing.T) {
 	}
 }
 
+func TestViewFilterAndSort(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	tagRepo := repository.NewTagRepository(db, nil)
+	viewRepo := repository.NewViewRepository(db)
+
+	work, _ := tagRepo.Create(model.CreateTagInput{Title: "work"})
+	area, _ := repository.NewAreaRepository(db, nil).Create(model.CreateAreaInput{Title: "Work"})
+	launch, _ := repository.NewProjectRepository(db, nil).Create(model.CreateProjectInput{Title: "Launch", AreaID: &area.ID})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "b report", TagIDs: []string{work.ID}})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "c slides", TagIDs: []string{work.ID}, HighPriority: true})
+	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "a groceries"})
+	shipped, _ := taskRepo.Create(model.CreateTaskInput{Title: "Ship it", ProjectID: &launch.ID})
+	_, _ = taskRepo.Complete(shipped.ID)
+	other, _ := taskRepo.Create(model.CreateTaskInput{Title: "Other"})
+	_, _ = taskRepo.Complete(other.ID)
+
+	inbox, err := viewRepo.Inbox(nil, true, model.ViewFilter{Tags: []string{"work"}, Sort: "-title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox.Tasks) != 2 || inbox.Tasks[0].Title != "c slides" || inbox.Tasks[1].Title != "b report" {
+		t.Errorf("expected work tasks by title descending, got %+v", inbox.Tasks)
+	}
+
+	inbox, _ = viewRepo.Inbox(nil, true, model.ViewFilter{Priority: "high"})
+	if len(inbox.Tasks) != 1 || inbox.Tasks[0].Title != "c slides" {
+		t.Errorf("expected the high priority task, got %+v", inbox.Tasks)
+	}
+
+	logbook, err := viewRepo.Logbook(model.Page{Limit: 50}, model.ViewFilter{Project: "launch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logbook.Total != 1 || len(logbook.Groups) != 1 || logbook.Groups[0].Tasks[0].ID != shipped.ID {
+		t.Errorf("expected only the project's task, got %+v", logbook)
+	}
+
+	counts, err := viewRepo.Counts(nil, true, model.ViewFilter{Tags: []string{work.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Inbox != 2 {
+		t.Errorf("expected an inbox count of 2, got %d", counts.Inbox)
+	}
+}
+
+func TestViewLogbookPaged(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	viewRepo := repository.NewViewRepository(db)
+
+	for _, title := range []string{"d", "a", "e", "c", "b"} {
+		task, _ := taskRepo.Create(model.CreateTaskInput{Title: title})
+		_, _ = taskRepo.Complete(task.ID)
+	}
+
+	var titles []string
+	page := model.Page{Limit: 2}
+	for {
+		view, err := viewRepo.Logbook(page, model.ViewFilter{Sort: "title"})
+		if err != nil {
+			t.Fatalf("failed: %v", err)
+		}
+		if view.Total != 5 {
+			t.Errorf("expected total=5, got %d", view.Total)
+		}
+		for _, g := range view.Groups {
+			for _, task := range g.Tasks {
+				titles = append(titles, task.Title)
+			}
+		}
+		if view.NextCursor == "" {
+			break
+		}
+		page.Cursor = view.NextCursor
+	}
+	if got := strings.Join(titles, ""); got != "abcde" {
+		t.Errorf("expected every task once by title, got %q", got)
+	}
+
+	// A cursor only pages the list and order it came from.
+	first, _ := viewRepo.Logbook(model.Page{Limit: 2}, model.ViewFilter{})
+	if _, err := viewRepo.Trash(model.Page{Cursor: first.NextCursor}, model.ViewFilter{}); !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("expected an invalid cursor error, got %v", err)
+	}
+}
+
 func TestViewLogbookEmpty(t *testing.T) {
 	db := testutil.SetupTestDB(t)
 	viewRepo := repository.NewViewRepository(db)
 
-	view, err := viewRepo.Logbook(50, 0)
+	view, err := viewRepo.Logbook(model.Page{Limit: 50}, model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed: %v", err)
 	}
@@ -157,7 +247,7 @@ func TestViewUpcoming(t *testing.T) {
 	_, _ = taskRepo.Create(model.CreateTaskInput{Title: "Next week", WhenDate: &nextWeek})
 
 	today := time.Now().Format("2006-01-02")
-	view, err := viewRepo.Upcoming(today)
+	view, err := viewRepo.Upcoming(today, "", nil, model.ViewFilter{})
 	if err != nil {
 		t.Fatalf("failed: %v", err)
 	}
@@ -169,3 +259,48 @@ func TestViewUpcoming(t *testing.T) {
 		t.Errorf("expected first date=%q, got %q", tomorrow, view.Dates[0].Date)
 	}
 }
+
+func TestViewUpcomingProjectsRepeatingTasks(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	taskRepo := repository.NewTaskRepository(db, nil)
+	ruleRepo := repository.NewRepeatRuleRepository(db, nil)
+	viewRepo := repository.NewViewRepository(db)
+
+	task, _ := taskRepo.Create(model.CreateTaskInput{Title: "Water plants", WhenDate: strPtr("2025-03-10")})
+	_, err := ruleRepo.Upsert(task.ID, model.CreateRepeatRuleInput{Pattern: &model.RecurrencePattern{
+		Type: model.PatternWeekly, Every: 1, Mode: model.RecurrenceModeFixed, On: []string{"mon"},
+	}})
+	if err != nil {
+		t.Fatalf("upsert rule: %v", err)
+	}
+
+	view, err := viewRepo.Upcoming("2025-03-10", "2025-03-24", nil, model.ViewFilter{})
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	var got []string
+	for _, group := range view.Dates {
+		for _, item := range group.Tasks {
+			if item.ID == task.ID {
+				got = append(got, group.Date)
+				if item.Projected != (group.Date != "2025-03-10") {
+					t.Errorf("%s: projected = %v", group.Date, item.Projected)
+				}
+			}
+		}
+	}
+	want := []string{"2025-03-10", "2025-03-17", "2025-03-24"}
+	if len(got) != len(want) {
+		t.Fatalf("expected dates %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected dates %v, got %v", want, got)
+		}
+	}
+
+	view, _ = viewRepo.Upcoming("2025-03-10", "", nil, model.ViewFilter{})
+	if len(view.Dates) != 1 {
+		t.Errorf("expected no projection without projectUntil, got %d date groups", len(view.Dates))
+	}
+}
@@ -2,7 +2,6 @@ package router
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"io/fs"
 	"log"
@@ -12,8 +11,10 @@ import (
 	"time"
 
 	"github.com/collinjanssen/thingstodo/internal/config"
+	"github.com/collinjanssen/thingstodo/internal/database"
 	"github.com/collinjanssen/thingstodo/internal/frontend"
 	"github.com/collinjanssen/thingstodo/internal/handler"
+	"github.com/collinjanssen/thingstodo/internal/metrics"
 	mw "github.com/collinjanssen/thingstodo/internal/middleware"
 	"github.com/collinjanssen/thingstodo/internal/push"
 	"github.com/collinjanssen/thingstodo/internal/recurrence"
@@ -23,13 +24,17 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Scheduler) http.Handler {
+func New(pools *database.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Scheduler) http.Handler {
 	_ = mime.AddExtensionType(".webmanifest", "application/manifest+json")
 
 	r := chi.NewRouter()
 	r.Use(mw.Logger)
+	r.Use(mw.Telemetry)
 
-	// Repositories
+	// Repositories write through the single writer connection. The list and
+	// view reads clients repeat after every change event run on the reader
+	// pool instead, so they neither queue behind writes nor hold them up.
+	db := pools.Write
 	changeLogRepo := repository.NewChangeLogRepository(db)
 	taskRepo := repository.NewTaskRepository(db, changeLogRepo)
 	projectRepo := repository.NewProjectRepository(db, changeLogRepo)
@@ -39,26 +44,42 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 	checklistRepo := repository.NewChecklistRepository(db, changeLogRepo)
 	attachmentRepo := repository.NewAttachmentRepository(db, changeLogRepo)
 	repeatRuleRepo := repository.NewRepeatRuleRepository(db, changeLogRepo)
-	searchRepo := repository.NewSearchRepository(db)
-	viewRepo := repository.NewViewRepository(db)
+	projectRepeatRuleRepo := repository.NewProjectRepeatRuleRepository(db, changeLogRepo)
+	searchRepo := repository.NewSearchRepository(pools.Read)
+	synonymRepo := repository.NewSynonymRepository(db)
+	viewRepo := repository.NewViewRepository(pools.Read)
+	seriesRepo := repository.NewSeriesRepository(pools.Read)
 	userRepo := repository.NewUserRepository(db)
 	settingsRepo := repository.NewUserSettingsRepository(db)
 	savedFilterRepo := repository.NewSavedFilterRepository(db)
+	smartListRepo := repository.NewSmartListRepository(db)
 	scheduleRepo := repository.NewScheduleRepository(db, changeLogRepo)
 	reminderRepo := repository.NewReminderRepository(db, changeLogRepo)
 	pushSubRepo := repository.NewPushSubscriptionRepository(db)
+	syncConflictRepo := repository.NewSyncConflictRepository(db)
+	encryptionKeyRepo := repository.NewEncryptionKeyRepository(db)
+	taskRepo.SetReader(pools.Read)
+	projectRepo.SetReader(pools.Read)
+	areaRepo.SetReader(pools.Read)
+	tagRepo.SetReader(pools.Read)
+	smartListRepo.SetReader(pools.Read)
 
 	// Handlers
 	taskH := handler.NewTaskHandler(taskRepo, scheduleRepo, reminderRepo, settingsRepo, broker, sched)
-	projectH := handler.NewProjectHandler(projectRepo, broker)
+	projectH := handler.NewProjectHandler(projectRepo, broker, sched)
 	areaH := handler.NewAreaHandler(areaRepo, broker)
 	tagH := handler.NewTagHandler(tagRepo, broker)
 	headingH := handler.NewHeadingHandler(headingRepo, broker)
 	checklistH := handler.NewChecklistHandler(checklistRepo, broker)
-	attachmentH := handler.NewAttachmentHandler(attachmentRepo, broker, cfg.AttachmentsPath, cfg.MaxUploadSize)
-	repeatRuleH := handler.NewRepeatRuleHandler(repeatRuleRepo, taskRepo, recurrence.NewEngine(), broker)
-	searchH := handler.NewSearchHandler(searchRepo)
-	viewH := handler.NewViewHandler(viewRepo, settingsRepo)
+	attachmentH := handler.NewAttachmentHandler(attachmentRepo, broker, cfg.AttachmentsPath, cfg.MaxUploadSize, cfg.EncryptionKey)
+	repeatRuleH := handler.NewRepeatRuleHandler(repeatRuleRepo, taskRepo, settingsRepo, recurrence.NewEngine(), broker)
+	projectRepeatRuleH := handler.NewProjectRepeatRuleHandler(projectRepeatRuleRepo, projectRepo, settingsRepo, recurrence.NewEngine(), broker)
+	searchH := handler.NewSearchHandler(searchRepo, sched)
+	synonymH := handler.NewSynonymHandler(synonymRepo)
+	parseH := handler.NewParseHandler()
+	viewH := handler.NewViewHandler(viewRepo, settingsRepo, smartListRepo)
+	smartListH := handler.NewSmartListHandler(smartListRepo, broker)
+	seriesH := handler.NewSeriesHandler(seriesRepo, cfg.Location)
 	authH := handler.NewAuthHandler(userRepo, cfg)
 	settingsH := handler.NewUserSettingsHandler(settingsRepo)
 	savedFilterH := handler.NewSavedFilterHandler(savedFilterRepo, broker)
@@ -68,8 +89,11 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 	ntfySender := push.NewNtfySender(settingsRepo, userRepo)
 	notifier := push.NewDispatcher(pushSender, ntfySender, settingsRepo, userRepo)
 	pushSubH := handler.NewPushSubscriptionHandler(pushSubRepo, cfg, notifier)
-	syncH := handler.NewSyncHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, tagRepo, checklistRepo, headingRepo, attachmentRepo, scheduleRepo, reminderRepo, repeatRuleRepo, sched)
+	syncH := handler.NewSyncHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, tagRepo, checklistRepo, headingRepo, attachmentRepo, scheduleRepo, reminderRepo, repeatRuleRepo, syncConflictRepo, sched, broker)
 	eventH := handler.NewEventHandler(broker)
+	encryptionH := handler.NewEncryptionHandler(encryptionKeyRepo)
+	historyH := handler.NewHistoryHandler(changeLogRepo, taskRepo, projectRepo, areaRepo, checklistRepo, broker)
+	databaseH := handler.NewDatabaseHandler(pools)
 
 	var oidcH *handler.OIDCHandler
 	if cfg.AuthMode == "oidc" {
@@ -88,6 +112,9 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 	})
 
+	// Prometheus metrics, unauthenticated like the health check
+	r.Handle("/metrics", metrics.Handler())
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		// Auth endpoints (no middleware)
@@ -134,6 +161,8 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 			r.Patch("/tasks/{id}/move", taskH.Move)
 			r.Patch("/tasks/reorder", taskH.Reorder)
 			r.Post("/tasks/bulk", taskH.BulkAction)
+			r.Get("/tasks/{id}/history", historyH.TaskHistory)
+			r.Post("/tasks/{id}/history/{seq}/revert", historyH.RevertTask)
 
 			// Checklist
 			r.Get("/tasks/{id}/checklist", checklistH.List)
@@ -152,6 +181,8 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 			r.Get("/tasks/{id}/repeat", repeatRuleH.Get)
 			r.Put("/tasks/{id}/repeat", repeatRuleH.Upsert)
 			r.Delete("/tasks/{id}/repeat", repeatRuleH.Delete)
+			r.Get("/tasks/{id}/repeat/preview", repeatRuleH.Preview)
+			r.Patch("/tasks/{id}/skip", repeatRuleH.Skip)
 
 			// Schedules
 			r.Get("/tasks/{id}/schedules", scheduleH.List)
@@ -179,6 +210,11 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 			r.Delete("/projects/{id}", projectH.Delete)
 			r.Patch("/projects/{id}/complete", projectH.Complete)
 			r.Patch("/projects/reorder", projectH.Reorder)
+			r.Get("/projects/{id}/repeat", projectRepeatRuleH.Get)
+			r.Put("/projects/{id}/repeat", projectRepeatRuleH.Upsert)
+			r.Delete("/projects/{id}/repeat", projectRepeatRuleH.Delete)
+			r.Get("/projects/{id}/history", historyH.ProjectHistory)
+			r.Post("/projects/{id}/history/{seq}/revert", historyH.RevertProject)
 
 			// Headings
 			r.Get("/projects/{id}/headings", headingH.List)
@@ -194,6 +230,8 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 			r.Patch("/areas/{id}", areaH.Update)
 			r.Delete("/areas/{id}", areaH.Delete)
 			r.Patch("/areas/reorder", areaH.Reorder)
+			r.Get("/areas/{id}/history", historyH.AreaHistory)
+			r.Post("/areas/{id}/history/{seq}/revert", historyH.RevertArea)
 
 			// Tags
 			r.Get("/tags", tagH.List)
@@ -212,23 +250,55 @@ func New(db *sql.DB, cfg config.Config, broker *sse.Broker, sched *scheduler.Sch
 			r.Get("/views/logbook", viewH.Logbook)
 			r.Get("/views/trash", viewH.Trash)
 			r.Get("/views/counts", viewH.Counts)
+			r.Get("/views/smart/{id}", smartListH.View)
+
+			// Series
+			r.Get("/series", seriesH.List)
+			r.Get("/series/{id}/stats", seriesH.Stats)
 
 			// User Settings
 			r.Get("/user/settings", settingsH.Get)
 			r.Patch("/user/settings", settingsH.Update)
+			r.Get("/user/settings/calendars", settingsH.Calendars)
+			r.Get("/user/settings/holidays", settingsH.Holidays)
 
 			// Saved Filters
 			r.Get("/saved-filters", savedFilterH.List)
 			r.Post("/saved-filters", savedFilterH.Create)
 			r.Delete("/saved-filters/{id}", savedFilterH.Delete)
 
+			r.Get("/smart-lists", smartListH.List)
+			r.Post("/smart-lists", smartListH.Create)
+			r.Patch("/smart-lists/{id}", smartListH.Update)
+			r.Delete("/smart-lists/{id}", smartListH.Delete)
+
+			// Client-side encryption key
+			r.Get("/encryption/key", encryptionH.GetKey)
+			r.Put("/encryption/key", encryptionH.PutKey)
+
 			// Search
 			r.Get("/search", searchH.Search)
+			r.Post("/search/reindex", searchH.Reindex)
+			r.Get("/search/synonyms", synonymH.List)
+			r.Post("/search/synonyms", synonymH.Create)
+			r.Delete("/search/synonyms/{id}", synonymH.Delete)
+
+			// Parsing
+			r.Get("/parse/recurrence", parseH.Recurrence)
 
 			// Sync
 			r.Get("/sync/pull", syncH.Pull)
 			r.Post("/sync/push", syncH.Push)
 			r.Get("/sync/full", syncH.Full)
+			r.Get("/sync/conflicts", syncH.ListConflicts)
+			r.Get("/sync/conflicts/{id}", syncH.GetConflict)
+			r.Post("/sync/conflicts/{id}/resolve", syncH.ResolveConflict)
+
+			// History & undo
+			r.Post("/undo", historyH.Undo)
+
+			// Database
+			r.Get("/database/stats", databaseH.Stats)
 		})
 	})
 
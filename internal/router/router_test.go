@@ -1,6 +1,7 @@
 package router_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -14,7 +15,8 @@ import (
 )
 
 func TestHealthEndpoint(t *testing.T) {
-	db := testutil.SetupTestDB(t)
+	pools := testutil.SetupTestPools(t)
+	db := pools.Write
 	cfg := config.Config{AuthMode: "proxy", AttachmentsPath: t.TempDir()}
 	broker := sse.NewBroker()
 	taskRepo := repository.NewTaskRepository(db, nil)
@@ -30,10 +32,47 @@ func TestHealthEndpoint(t *testing.T) {
 	changeLogRepo := repository.NewChangeLogRepository(db)
 	sched := scheduler.New(db, taskRepo, ruleRepo, checklistRepo, attachRepo, scheduleRepo, reminderRepo, settingsRepo, userRepo, changeLogRepo, pushSender, broker, time.UTC)
 
-	handler := router.New(db, cfg, broker, sched)
+	handler := router.New(pools, cfg, broker, sched)
 	client := testutil.NewTestClient(t, handler)
 
 	resp := client.Get("/health")
 	testutil.AssertStatus(t, resp, 200)
 	testutil.AssertJSONField(t, resp, "status", "ok")
 }
+
+func TestMetricsEndpoint(t *testing.T) {
+	pools := testutil.SetupTestPools(t)
+	db := pools.Write
+	cfg := config.Config{AuthMode: "proxy", AttachmentsPath: t.TempDir()}
+	broker := sse.NewBroker()
+	taskRepo := repository.NewTaskRepository(db, nil)
+	ruleRepo := repository.NewRepeatRuleRepository(db, nil)
+	checklistRepo := repository.NewChecklistRepository(db, nil)
+	attachRepo := repository.NewAttachmentRepository(db, nil)
+	scheduleRepo := repository.NewScheduleRepository(db, nil)
+	reminderRepo := repository.NewReminderRepository(db, nil)
+	settingsRepo := repository.NewUserSettingsRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	pushSubRepo := repository.NewPushSubscriptionRepository(db)
+	pushSender := push.NewSender(pushSubRepo, "", "", "")
+	changeLogRepo := repository.NewChangeLogRepository(db)
+	sched := scheduler.New(db, taskRepo, ruleRepo, checklistRepo, attachRepo, scheduleRepo, reminderRepo, settingsRepo, userRepo, changeLogRepo, pushSender, broker, time.UTC)
+
+	handler := router.New(pools, cfg, broker, sched)
+	client := testutil.NewTestClient(t, handler)
+
+	testutil.AssertStatus(t, client.Get("/health"), 200)
+
+	resp := client.Get("/metrics")
+	testutil.AssertStatus(t, resp, 200)
+	body := string(resp.Body)
+	for _, want := range []string{
+		`thingstodo_http_request_duration_seconds_count{method="GET",route="/health",status="200"} `,
+		`thingstodo_sqlite_query_duration_seconds_count{pool="write"} `,
+		"# TYPE thingstodo_sse_subscribers gauge",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/textextract"
+)
+
+// attachmentBatch is how many files are read between checks for new work.
+const attachmentBatch = 25
+
+// SetAttachmentIndexing turns on text extraction for uploaded files stored in
+// dir, encrypted at rest with key (nil if not).
+func (s *Scheduler) SetAttachmentIndexing(dir string, key *atrest.Key) {
+	s.attachmentsPath = dir
+	s.attachmentKey = key
+}
+
+// IndexAttachments extracts the text of every file attachment that hasn't
+// been read yet and returns how many it read. Only one run goes at a time;
+// a call during a run returns 0 straight away.
+func (s *Scheduler) IndexAttachments() int {
+	if s.attachmentsPath == "" || !s.indexing.TryLock() {
+		return 0
+	}
+	defer s.indexing.Unlock()
+
+	total := 0
+	for {
+		pending, err := s.textRepo.ListPending(attachmentBatch)
+		if err != nil {
+			log.Printf("attachment indexing: list pending: %v", err)
+			return total
+		}
+		if len(pending) == 0 {
+			return total
+		}
+		for _, att := range pending {
+			status, text, errMsg := s.extractAttachment(att.Title, att.MimeType, att.URL)
+			if err := s.textRepo.Save(att.ID, status, text, errMsg); err != nil {
+				// Most likely deleted meanwhile; stop so a persistent error
+				// can't spin here, and try again on the next run.
+				log.Printf("attachment indexing: save %s: %v", att.ID, err)
+				return total
+			}
+			total++
+		}
+	}
+}
+
+func (s *Scheduler) extractAttachment(name, mimeType, storedName string) (status, text, errMsg string) {
+	data, err := atrest.ReadFile(filepath.Join(s.attachmentsPath, storedName), s.attachmentKey)
+	if err != nil {
+		return repository.AttachmentTextFailed, "", err.Error()
+	}
+	text, err = textextract.Extract(name, mimeType, data)
+	switch {
+	case errors.Is(err, textextract.ErrUnsupported):
+		return repository.AttachmentTextUnsupported, "", ""
+	case err != nil:
+		return repository.AttachmentTextFailed, "", err.Error()
+	}
+	return repository.AttachmentTextIndexed, text, ""
+}
+
+// ReindexAttachments forgets all extracted text and reads every file again
+// in the background. It returns how many files are queued.
+func (s *Scheduler) ReindexAttachments() (int, error) {
+	n, err := s.textRepo.Reset()
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		if read := s.IndexAttachments(); read > 0 {
+			log.Printf("reindexed %d attachments", read)
+		}
+	}()
+	return n, nil
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/collinjanssen/thingstodo/internal/atrest"
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+)
+
+func TestIndexAttachments(t *testing.T) {
+	s := newTestScheduler(t)
+	dir := t.TempDir()
+	keyText, err := atrest.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := atrest.ParseKey(keyText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetAttachmentIndexing(dir, key)
+
+	task, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Renew lease"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"terms.md":  "# Lease\n\nThe landlord covers plumbing repairs.",
+		"photo.jpg": "\xff\xd8\xff",
+	}
+	ids := map[string]string{}
+	for name, content := range files {
+		stored := model.NewID() + filepath.Ext(name)
+		if err := atrest.WriteFile(filepath.Join(dir, stored), []byte(content), key, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		att, err := s.attachRepo.Create(task.ID, model.CreateAttachmentInput{Type: "file", Title: name, URL: stored})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = att.ID
+	}
+	missing, _ := s.attachRepo.Create(task.ID, model.CreateAttachmentInput{Type: "file", Title: "gone.txt", URL: "gone.txt"})
+	if _, err := s.attachRepo.Create(task.ID, model.CreateAttachmentInput{Type: "file", Title: "secret.txt", URL: "secret.txt", Encrypted: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.IndexAttachments(); n != 3 {
+		t.Fatalf("expected 3 files read, got %d", n)
+	}
+	for id, want := range map[string]string{
+		ids["terms.md"]:  repository.AttachmentTextIndexed,
+		ids["photo.jpg"]: repository.AttachmentTextUnsupported,
+		missing.ID:       repository.AttachmentTextFailed,
+	} {
+		if got, _ := s.textRepo.Status(id); got != want {
+			t.Errorf("attachment %s: status %q, want %q", id, got, want)
+		}
+	}
+	if n := s.IndexAttachments(); n != 0 {
+		t.Errorf("expected nothing left to read, got %d", n)
+	}
+
+	results, err := repository.NewSearchRepository(s.db).Search("plumbing", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != task.ID {
+		t.Fatalf("expected the task, got %+v", results)
+	}
+	if results[0].Attachment == nil || results[0].Attachment.Title != "terms.md" ||
+		!strings.Contains(results[0].AttachmentSnippet, "<mark>plumbing</mark>") {
+		t.Errorf("expected a snippet from terms.md, got %+v %q", results[0].Attachment, results[0].AttachmentSnippet)
+	}
+
+	// Deleting the attachment drops its text from the index.
+	if err := s.attachRepo.Delete(ids["terms.md"]); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = repository.NewSearchRepository(s.db).Search("plumbing", 20)
+	if len(results) != 0 {
+		t.Errorf("expected no results after delete, got %+v", results)
+	}
+
+	// A reindex reads the file again once it's back.
+	if err := os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("found it"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if queued, err := s.textRepo.Reset(); err != nil || queued != 2 {
+		t.Fatalf("expected 2 queued, got %d (%v)", queued, err)
+	}
+	if n := s.IndexAttachments(); n != 2 {
+		t.Errorf("expected 2 files read, got %d", n)
+	}
+	if got, _ := s.textRepo.Status(missing.ID); got != repository.AttachmentTextIndexed {
+		t.Errorf("expected the restored file indexed, got %q", got)
+	}
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/metrics"
+	"github.com/collinjanssen/thingstodo/internal/tracing"
+)
+
+var (
+	jobDuration = metrics.NewHistogram("thingstodo_scheduler_job_duration_seconds",
+		"Time taken by each run of a scheduler job.", metrics.DefBuckets, "job")
+	remindersFired = metrics.NewCounter("thingstodo_reminders_fired_total",
+		"Reminders fired, by the notifier that delivered them.", "notifier")
+	reminderFailures = metrics.NewCounter("thingstodo_reminder_failures_total",
+		"Reminders whose notification failed to send, by notifier.", "notifier")
+)
+
+// job wraps a cron func so each run is timed and traced under name.
+func job(name string, run func()) func() {
+	duration := jobDuration.With(name)
+	return func() {
+		start := time.Now()
+		_, span := tracing.Start(context.Background(), "scheduler."+name)
+		defer func() {
+			duration.Observe(time.Since(start).Seconds())
+			span.End(nil)
+		}()
+		run()
+	}
+}
@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"log"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/recurrence"
+)
+
+// HandleProjectDone is called when a repeating project is completed or
+// canceled. It creates the project's next instance regardless of mode.
+func (s *Scheduler) HandleProjectDone(projectID string) {
+	rule, err := s.projectRuleRepo.GetByProject(projectID)
+	if err != nil || rule == nil {
+		return
+	}
+	s.createNextProject(rule)
+}
+
+// runFixedProjectRules creates the next instance of finished projects whose
+// fixed-schedule rule has come due by now.
+func (s *Scheduler) runFixedProjectRules(now string) {
+	rules, err := s.projectRuleRepo.ListAll()
+	if err != nil {
+		log.Printf("scheduler: list project rules: %v", err)
+		return
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Pattern.Mode != model.RecurrenceModeFixed {
+			continue
+		}
+		project, err := s.projectRepo.GetByID(rule.ProjectID)
+		if err != nil || project == nil || project.Status == "open" {
+			continue
+		}
+		next, _ := s.calculateNextDate(projectOccurrence(project), rule.Pattern)
+		if next > now {
+			continue
+		}
+		s.createNextProject(rule)
+	}
+}
+
+// projectOccurrence is the project's place in its series: its when_date, or
+// "" when it has none and the series continues from today.
+func projectOccurrence(project *model.ProjectDetail) string {
+	if project.WhenDate == nil || *project.WhenDate == "someday" {
+		return ""
+	}
+	return *project.WhenDate
+}
+
+// createNextProject copies the rule's project to its next occurrence with
+// every heading and task, reopened and shifted by as many days as the project
+// itself, and moves the rule onto the copy. Tasks that repeat on their own
+// keep their own series and are not copied.
+func (s *Scheduler) createNextProject(rule *model.ProjectRepeatRule) *model.ProjectDetail {
+	original, err := s.projectRepo.GetByID(rule.ProjectID)
+	if err != nil || original == nil {
+		return nil
+	}
+	next, occurrence := s.calculateNextDate(projectOccurrence(original), rule.Pattern)
+	if next == "" {
+		if err := s.projectRuleRepo.DeleteByProject(original.ID); err != nil {
+			log.Printf("scheduler: delete rule for project %s: %v", original.ID, err)
+		}
+		log.Printf("scheduler: repeat series for project %s has ended", original.ID)
+		return nil
+	}
+	nextDate, _ := recurrence.SplitDateTime(next)
+
+	delta, shiftable := dayDelta(original.WhenDate, nextDate)
+	shift := func(date *string) *string {
+		if date == nil {
+			return nil
+		}
+		if d, ok := shiftDate(*date, delta); ok && shiftable {
+			return &d
+		}
+		if *date == "someday" {
+			return date
+		}
+		return nil // a concrete date with nothing to shift it by
+	}
+
+	seriesID := original.SeriesID
+	if seriesID == nil {
+		seriesID = &original.ID
+	}
+	project, err := s.projectRepo.Create(model.CreateProjectInput{
+		Title:    original.Title,
+		Notes:    original.Notes,
+		AreaID:   original.AreaID,
+		WhenDate: &nextDate,
+		Deadline: shift(original.Deadline),
+		TagIDs:   tagRefIDs(original.Tags),
+		SeriesID: seriesID,
+	})
+	if err != nil {
+		log.Printf("scheduler: create project instance of %s: %v", original.ID, err)
+		return nil
+	}
+	// Take the finished project's place in the sidebar.
+	if err := s.projectRepo.Reorder([]model.SimpleReorderItem{{ID: project.ID, SortOrder: original.SortOrder}}); err != nil {
+		log.Printf("scheduler: reorder project %s: %v", project.ID, err)
+	}
+
+	headingIDs := make(map[string]string, len(original.Headings))
+	for _, h := range original.Headings {
+		created, err := s.headingRepo.Create(project.ID, model.CreateHeadingInput{Title: h.Title})
+		if err != nil {
+			log.Printf("scheduler: copy heading %s to project %s: %v", h.ID, project.ID, err)
+			continue
+		}
+		headingIDs[h.ID] = created.ID
+	}
+
+	items := append([]model.TaskListItem{}, original.TasksWithoutHeading...)
+	for _, h := range original.Headings {
+		items = append(items, h.Tasks...)
+	}
+	items = append(items, original.CompletedTasks...)
+
+	var order []model.ReorderItem
+	for _, item := range items {
+		task, err := s.taskRepo.GetByID(item.ID)
+		if err != nil || task == nil || task.SeriesID != nil {
+			continue
+		}
+		input := model.CreateTaskInput{
+			Title:        task.Title,
+			WhenDate:     shift(task.WhenDate),
+			HighPriority: task.HighPriority,
+			Deadline:     shift(task.Deadline),
+			ProjectID:    &project.ID,
+			AreaID:       task.AreaID,
+			TagIDs:       tagRefIDs(task.Tags),
+		}
+		if rule.Copy.Notes {
+			input.Notes = task.Notes
+		}
+		if task.HeadingID != nil {
+			if id, ok := headingIDs[*task.HeadingID]; ok {
+				input.HeadingID = &id
+			}
+		}
+		created, err := s.taskRepo.Create(input)
+		if err != nil {
+			log.Printf("scheduler: copy task %s to project %s: %v", task.ID, project.ID, err)
+			continue
+		}
+		if input.WhenDate != nil {
+			s.copySchedulesToNewInstance(task.Schedules, created.ID, delta, shiftable)
+		}
+		if rule.Copy.Checklist {
+			for _, ci := range task.Checklist {
+				if _, err := s.checklistRepo.Create(created.ID, model.CreateChecklistInput{Title: ci.Title}); err != nil {
+					log.Printf("scheduler: copy checklist item for task %s: %v", created.ID, err)
+				}
+			}
+		}
+		if rule.Copy.Attachments {
+			for _, att := range task.Attachments {
+				if _, err := s.attachRepo.Create(created.ID, model.CreateAttachmentInput{
+					Type:      att.Type,
+					Title:     att.Title,
+					URL:       att.URL,
+					MimeType:  att.MimeType,
+					FileSize:  att.FileSize,
+					Encrypted: att.Encrypted,
+				}); err != nil {
+					log.Printf("scheduler: copy attachment for task %s: %v", created.ID, err)
+				}
+			}
+		}
+		s.copyRemindersToNewInstance(task.ID, created.ID)
+		order = append(order,
+			model.ReorderItem{ID: created.ID, SortField: "sort_order_project", SortOrder: task.SortOrderProject},
+			model.ReorderItem{ID: created.ID, SortField: "sort_order_heading", SortOrder: task.SortOrderHeading},
+		)
+	}
+	if len(order) > 0 {
+		if err := s.taskRepo.Reorder(order); err != nil {
+			log.Printf("scheduler: order tasks of project %s: %v", project.ID, err)
+		}
+	}
+
+	if err := s.projectRuleRepo.DeleteByProject(original.ID); err != nil {
+		log.Printf("scheduler: delete rule for project %s: %v", original.ID, err)
+	}
+	pattern := rule.Pattern
+	pattern.Occurrence = occurrence
+	copyOpts := rule.Copy
+	if _, err := s.projectRuleRepo.Upsert(project.ID, model.CreateProjectRepeatRuleInput{
+		Pattern: &pattern,
+		Copy:    &copyOpts,
+	}); err != nil {
+		log.Printf("scheduler: upsert rule for project %s: %v", project.ID, err)
+	}
+
+	log.Printf("scheduler: created project instance %s from %s (next: %s)", project.ID, original.ID, nextDate)
+	project, _ = s.projectRepo.GetByID(project.ID)
+	return project
+}
+
+func tagRefIDs(tags []model.TagRef) []string {
+	ids := make([]string, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids
+}
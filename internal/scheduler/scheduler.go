@@ -2,10 +2,15 @@ package scheduler
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/collinjanssen/thingstodo/internal/atrest"
 	"github.com/collinjanssen/thingstodo/internal/model"
 	"github.com/collinjanssen/thingstodo/internal/push"
 	"github.com/collinjanssen/thingstodo/internal/recurrence"
@@ -30,6 +35,20 @@ type Scheduler struct {
 	db            *sql.DB
 	engine        *recurrence.Engine
 	loc           *time.Location
+
+	// Repeating projects are cloned through these.
+	projectRepo     *repository.ProjectRepository
+	projectRuleRepo *repository.ProjectRepeatRuleRepository
+	headingRepo     *repository.HeadingRepository
+
+	changeLogCompactDays   int
+	changeLogRetentionDays int
+
+	// Text extraction from uploaded files; off until attachmentsPath is set.
+	textRepo        *repository.AttachmentTextRepository
+	attachmentsPath string
+	attachmentKey   *atrest.Key
+	indexing        sync.Mutex
 }
 
 func New(db *sql.DB, taskRepo *repository.TaskRepository, ruleRepo *repository.RepeatRuleRepository, checklistRepo *repository.ChecklistRepository, attachRepo *repository.AttachmentRepository, scheduleRepo *repository.ScheduleRepository, reminderRepo *repository.ReminderRepository, settingsRepo *repository.UserSettingsRepository, userRepo *repository.UserRepository, changeLogRepo *repository.ChangeLogRepository, pushSender push.Notifier, broker *sse.Broker, loc *time.Location) *Scheduler {
@@ -49,31 +68,60 @@ func New(db *sql.DB, taskRepo *repository.TaskRepository, ruleRepo *repository.R
 		db:            db,
 		engine:        recurrence.NewEngine(),
 		loc:           loc,
+
+		projectRepo:     repository.NewProjectRepository(db, changeLogRepo),
+		projectRuleRepo: repository.NewProjectRepeatRuleRepository(db, changeLogRepo),
+		headingRepo:     repository.NewHeadingRepository(db, changeLogRepo),
+		textRepo:        repository.NewAttachmentTextRepository(db),
+
+		changeLogCompactDays: 30,
 	}
 }
 
+// SetChangeLogRetention configures change log maintenance. Entries older than
+// compactDays keep only the latest change per entity; entries older than
+// retentionDays are purged outright. A value of 0 disables that step.
+func (s *Scheduler) SetChangeLogRetention(compactDays, retentionDays int) {
+	s.changeLogCompactDays = compactDays
+	s.changeLogRetentionDays = retentionDays
+}
+
 func (s *Scheduler) Start() {
-	if _, err := s.cron.AddFunc("@every 1h", s.processFixedRules); err != nil {
+	if _, err := s.cron.AddFunc("@every 1h", job("recurrence", s.processFixedRules)); err != nil {
 		log.Printf("scheduler: failed to add recurrence cron: %v", err)
 	}
-	if _, err := s.cron.AddFunc("@every 1m", s.processReminders); err != nil {
+	if _, err := s.cron.AddFunc("@every 1m", job("reminders", s.processReminders)); err != nil {
 		log.Printf("scheduler: failed to add reminder cron: %v", err)
 	}
-	if _, err := s.cron.AddFunc("@daily", s.purgeChangeLog); err != nil {
+	if _, err := s.cron.AddFunc("@daily", job("changelog", s.purgeChangeLog)); err != nil {
 		log.Printf("scheduler: failed to add change log purge cron: %v", err)
 	}
+	if _, err := s.cron.AddFunc("@every 1m", job("attachments", func() { s.IndexAttachments() })); err != nil {
+		log.Printf("scheduler: failed to add attachment indexing cron: %v", err)
+	}
 	s.cron.Start()
 	log.Println("scheduler started")
 }
 
 func (s *Scheduler) purgeChangeLog() {
-	purged, err := s.changeLogRepo.PurgeOlderThan(90)
-	if err != nil {
-		log.Printf("change log purge error: %v", err)
-		return
+	if s.changeLogCompactDays > 0 {
+		compacted, err := s.changeLogRepo.CompactOlderThan(s.changeLogCompactDays)
+		if err != nil {
+			log.Printf("change log compaction error: %v", err)
+		} else if compacted > 0 {
+			log.Printf("compacted %d superseded change log entries", compacted)
+		}
 	}
-	if purged > 0 {
-		log.Printf("purged %d old change log entries", purged)
+
+	if s.changeLogRetentionDays > 0 {
+		purged, err := s.changeLogRepo.PurgeOlderThan(s.changeLogRetentionDays)
+		if err != nil {
+			log.Printf("change log purge error: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("purged %d old change log entries", purged)
+		}
 	}
 }
 
@@ -87,28 +135,34 @@ func (s *Scheduler) HandleAfterCompletion(taskID string) {
 	if err != nil || rule == nil || rule.Pattern.Mode != model.RecurrenceModeAfterCompletion {
 		return
 	}
-	s.createNextInstance(taskID, rule)
+	s.createNextInstance(taskID, rule, nil)
 }
 
 // HandleTaskDone is called when a recurring task is finished (completed, canceled, or won't do).
-// It creates the next instance regardless of mode.
-func (s *Scheduler) HandleTaskDone(taskID string) {
+// It creates the next instance regardless of mode. schedules is the task's
+// schedule as it was before cleanup removed its future entries; nil means use
+// whatever the task still has.
+func (s *Scheduler) HandleTaskDone(taskID string, schedules []model.TaskSchedule) {
 	rule, err := s.ruleRepo.GetByTask(taskID)
 	if err != nil || rule == nil {
 		return
 	}
-	s.createNextInstance(taskID, rule)
+	s.createNextInstance(taskID, rule, schedules)
 }
 
 func (s *Scheduler) processFixedRules() {
+	s.runFixedRules(time.Now().In(s.loc).Format(recurrence.DateTimeLayout))
+}
+
+// runFixedRules creates the instances of fixed-schedule rules that are due by
+// now, a date or a date-time in recurrence.DateTimeLayout.
+func (s *Scheduler) runFixedRules(now string) {
 	rules, err := s.ruleRepo.ListAll()
 	if err != nil {
 		log.Printf("scheduler: list rules: %v", err)
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
-
 	for _, rule := range rules {
 		if rule.Pattern.Mode != model.RecurrenceModeFixed {
 			continue
@@ -122,22 +176,205 @@ func (s *Scheduler) processFixedRules() {
 			continue
 		}
 
-		nextDate := s.calculateNextDate(task.WhenDate, rule.Pattern)
-		if nextDate == "" || nextDate > today {
+		next, _ := s.calculateNextDate(currentOccurrence(task, nil), rule.Pattern)
+		if next > now {
 			continue
 		}
 
-		s.createNextInstance(rule.TaskID, &rule)
+		s.createNextInstance(rule.TaskID, &rule, nil)
+	}
+
+	// New instances created above carry their rules along, so list again.
+	rules, err = s.ruleRepo.ListAll()
+	if err != nil {
+		log.Printf("scheduler: list rules: %v", err)
+		return
+	}
+	var engine *recurrence.Engine
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Pattern.Mode != model.RecurrenceModeFixed || rule.CatchUp == "" || rule.CatchUp == model.CatchUpNone {
+			continue
+		}
+		task, err := s.taskRepo.GetByID(rule.TaskID)
+		if err != nil || task == nil || task.Status != "open" || task.DeletedAt != nil {
+			continue
+		}
+		if engine == nil {
+			engine = s.userEngine()
+		}
+		s.catchUp(engine, rule, task, now)
+	}
+
+	s.runFixedProjectRules(now)
+}
+
+// maxCatchUp bounds the occurrences one run catches up on; a rule that is
+// further behind continues on the next run.
+const maxCatchUp = 366
+
+// catchUp applies the rule's catch-up policy to its open task once later
+// occurrences of the series have come due by now.
+func (s *Scheduler) catchUp(engine *recurrence.Engine, rule *model.RepeatRule, task *model.TaskDetail, now string) {
+	if task.WhenDate == nil {
+		return
+	}
+	if _, err := time.Parse("2006-01-02", *task.WhenDate); err != nil {
+		return // someday
+	}
+
+	var dates []string
+	var occurrences []int
+	pattern := rule.Pattern
+	current := currentOccurrence(task, nil)
+	from := current
+	for len(dates) < maxCatchUp {
+		next, occurrence, err := engine.NextInSeries(from, pattern)
+		if errors.Is(err, recurrence.ErrNoMoreOccurrences) {
+			break
+		}
+		if err != nil {
+			log.Printf("scheduler: catch up task %s: %v", task.ID, err)
+			return
+		}
+		if next > now {
+			break
+		}
+		dates = append(dates, next)
+		occurrences = append(occurrences, occurrence)
+		pattern.Occurrence = occurrence
+		from = next
+	}
+	if len(dates) == 0 {
+		return
+	}
+
+	last := len(dates) - 1
+	switch rule.CatchUp {
+	case model.CatchUpAll:
+		previous := task
+		for i, date := range dates {
+			created := s.createInstance(previous, rule, date, occurrences[i], nil)
+			if created == nil {
+				return
+			}
+			// Reload so the next instance copies the full one just made.
+			if previous, _ = s.taskRepo.GetByID(created.ID); previous == nil {
+				return
+			}
+		}
+	case model.CatchUpLatest:
+		for _, date := range dates[:last] {
+			s.recordMissed(task, date)
+		}
+		s.createInstance(task, rule, dates[last], occurrences[last], nil)
+	case model.CatchUpSkip:
+		s.recordMissed(task, current)
+		for _, date := range dates[:last] {
+			s.recordMissed(task, date)
+		}
+		pattern := rule.Pattern
+		pattern.Occurrence = occurrences[last]
+		if _, err := s.ruleRepo.Upsert(task.ID, model.CreateRepeatRuleInput{Pattern: &pattern}); err != nil {
+			log.Printf("scheduler: upsert rule for task %s: %v", task.ID, err)
+			return
+		}
+		date, clock := recurrence.SplitDateTime(dates[last])
+		if _, err := s.taskRepo.Update(task.ID, model.UpdateTaskInput{
+			WhenDate: &date,
+			Raw:      map[string]json.RawMessage{"when_date": json.RawMessage(`"` + date + `"`)},
+		}); err != nil {
+			log.Printf("scheduler: move task %s to %s: %v", task.ID, dates[last], err)
+			return
+		}
+		if clock != "" {
+			if err := s.taskRepo.SetFirstScheduleTime(task.ID, clock); err != nil {
+				log.Printf("scheduler: set start time of task %s: %v", task.ID, err)
+			}
+		}
+	default:
+		return
+	}
+	log.Printf("scheduler: caught up %d occurrence(s) of task %s (%s)", len(dates), task.ID, rule.CatchUp)
+}
+
+// recordMissed logs an occurrence that got no instance of its own as a
+// canceled task in the series, so it shows in the logbook and series stats.
+func (s *Scheduler) recordMissed(task *model.TaskDetail, occurrence string) {
+	date, clock := recurrence.SplitDateTime(occurrence)
+	tagIDs := make([]string, len(task.Tags))
+	for i, t := range task.Tags {
+		tagIDs[i] = t.ID
+	}
+	seriesID := task.SeriesID
+	if seriesID == nil {
+		seriesID = &task.ID
+	}
+	missed, err := s.taskRepo.Create(model.CreateTaskInput{
+		Title:     task.Title,
+		WhenDate:  &date,
+		ProjectID: task.ProjectID,
+		AreaID:    task.AreaID,
+		HeadingID: task.HeadingID,
+		TagIDs:    tagIDs,
+		SeriesID:  seriesID,
+	})
+	if err != nil {
+		log.Printf("scheduler: record missed occurrence %s of task %s: %v", occurrence, task.ID, err)
+		return
+	}
+	if clock != "" {
+		if err := s.taskRepo.SetFirstScheduleTime(missed.ID, clock); err != nil {
+			log.Printf("scheduler: set start time of task %s: %v", missed.ID, err)
+		}
+	}
+	if _, err := s.taskRepo.Cancel(missed.ID); err != nil {
+		log.Printf("scheduler: cancel missed occurrence %s: %v", missed.ID, err)
 	}
 }
 
-func (s *Scheduler) createNextInstance(originalTaskID string, rule *model.RepeatRule) {
+func (s *Scheduler) createNextInstance(originalTaskID string, rule *model.RepeatRule, schedules []model.TaskSchedule) {
 	original, err := s.taskRepo.GetByID(originalTaskID)
 	if err != nil || original == nil {
 		return
 	}
 
-	nextDate := s.calculateNextDate(original.WhenDate, rule.Pattern)
+	next, occurrence := s.calculateNextDate(currentOccurrence(original, schedules), rule.Pattern)
+	if next == "" {
+		s.endSeries(originalTaskID)
+		return
+	}
+	s.createInstance(original, rule, next, occurrence, schedules)
+}
+
+// currentOccurrence is the task's place in its series: its when_date, with
+// the start time of its first schedule entry when it has one. nil schedules
+// means the task's own.
+func currentOccurrence(task *model.TaskDetail, schedules []model.TaskSchedule) string {
+	if task.WhenDate == nil {
+		return ""
+	}
+	if schedules == nil {
+		schedules = task.Schedules
+	}
+	return recurrence.JoinDateTime(*task.WhenDate, model.FirstStartTime(schedules))
+}
+
+// endSeries removes the repeat rule of a series whose count or until date has
+// been reached.
+func (s *Scheduler) endSeries(taskID string) {
+	if err := s.ruleRepo.DeleteByTask(taskID); err != nil {
+		log.Printf("scheduler: delete rule for task %s: %v", taskID, err)
+	}
+	log.Printf("scheduler: repeat series for task %s has ended", taskID)
+}
+
+// createInstance creates the instance of original's series due at next (a
+// date, or a date-time whose time becomes the first schedule entry's start)
+// and moves the repeat rule onto it.
+func (s *Scheduler) createInstance(original *model.TaskDetail, rule *model.RepeatRule, next string, occurrence int, schedules []model.TaskSchedule) *model.TaskDetail {
+	originalTaskID := original.ID
+	nextDate, nextTime := recurrence.SplitDateTime(next)
 
 	// Collect tag IDs from original
 	tagIDs := make([]string, len(original.Tags))
@@ -145,44 +382,78 @@ func (s *Scheduler) createNextInstance(originalTaskID string, rule *model.Repeat
 		tagIDs[i] = t.ID
 	}
 
+	// Everything dated relative to when_date moves by the same number of days.
+	delta, shiftable := dayDelta(original.WhenDate, nextDate)
+
+	var deadline *string
+	if original.Deadline != nil && shiftable {
+		if d, ok := shiftDate(*original.Deadline, delta); ok {
+			deadline = &d
+		}
+	}
+
+	notes := ""
+	if rule.Copy.Notes {
+		notes = original.Notes
+	}
+
 	input := model.CreateTaskInput{
-		Title:     original.Title,
-		Notes:     original.Notes,
-		WhenDate:  &nextDate,
-		Deadline:  nil,
-		ProjectID: original.ProjectID,
-		AreaID:    original.AreaID,
-		HeadingID: original.HeadingID,
-		TagIDs:    tagIDs,
+		Title:        original.Title,
+		Notes:        notes,
+		WhenDate:     &nextDate,
+		HighPriority: original.HighPriority,
+		Deadline:     deadline,
+		ProjectID:    original.ProjectID,
+		AreaID:       original.AreaID,
+		HeadingID:    original.HeadingID,
+		TagIDs:       tagIDs,
+		SeriesID:     original.SeriesID,
+	}
+	if input.SeriesID == nil {
+		input.SeriesID = &original.ID
 	}
 
 	newTask, err := s.taskRepo.Create(input)
 	if err != nil {
 		log.Printf("scheduler: create task instance: %v", err)
-		return
+		return nil
 	}
 
 	// Note: first schedule entry is created by taskRepo.Create via syncFirstScheduleDate
+	if schedules == nil {
+		schedules = original.Schedules
+	}
+	s.copySchedulesToNewInstance(schedules, newTask.ID, delta, shiftable)
+	if nextTime != "" {
+		if err := s.taskRepo.SetFirstScheduleTime(newTask.ID, nextTime); err != nil {
+			log.Printf("scheduler: set start time of task %s: %v", newTask.ID, err)
+		}
+	}
 
 	// Copy checklist items (unchecked)
-	for _, item := range original.Checklist {
-		if _, err := s.checklistRepo.Create(newTask.ID, model.CreateChecklistInput{
-			Title: item.Title,
-		}); err != nil {
-			log.Printf("scheduler: copy checklist item for task %s: %v", newTask.ID, err)
+	if rule.Copy.Checklist {
+		for _, item := range original.Checklist {
+			if _, err := s.checklistRepo.Create(newTask.ID, model.CreateChecklistInput{
+				Title: item.Title,
+			}); err != nil {
+				log.Printf("scheduler: copy checklist item for task %s: %v", newTask.ID, err)
+			}
 		}
 	}
 
 	// Copy attachments (links and files — files share the same stored file on disk)
-	for _, att := range original.Attachments {
-		if _, err := s.attachRepo.Create(newTask.ID, model.CreateAttachmentInput{
-			Type:     att.Type,
-			Title:    att.Title,
-			URL:      att.URL,
-			MimeType: att.MimeType,
-			FileSize: att.FileSize,
-		}); err != nil {
-			log.Printf("scheduler: copy attachment for task %s: %v", newTask.ID, err)
+	if rule.Copy.Attachments {
+		for _, att := range original.Attachments {
+			if _, err := s.attachRepo.Create(newTask.ID, model.CreateAttachmentInput{
+				Type:      att.Type,
+				Title:     att.Title,
+				URL:       att.URL,
+				MimeType:  att.MimeType,
+				FileSize:  att.FileSize,
+				Encrypted: att.Encrypted,
+			}); err != nil {
+				log.Printf("scheduler: copy attachment for task %s: %v", newTask.ID, err)
+			}
 		}
 	}
 
@@ -193,13 +464,20 @@ func (s *Scheduler) createNextInstance(originalTaskID string, rule *model.Repeat
 	if err := s.ruleRepo.DeleteByTask(originalTaskID); err != nil {
 		log.Printf("scheduler: delete rule for task %s: %v", originalTaskID, err)
 	}
+	pattern := rule.Pattern
+	pattern.Occurrence = occurrence
+	copyOpts := rule.Copy
+	catchUp := rule.CatchUp
 	if _, err := s.ruleRepo.Upsert(newTask.ID, model.CreateRepeatRuleInput{
-		Pattern: &rule.Pattern,
+		Pattern: &pattern,
+		Copy:    &copyOpts,
+		CatchUp: &catchUp,
 	}); err != nil {
 		log.Printf("scheduler: upsert rule for task %s: %v", newTask.ID, err)
 	}
 
-	log.Printf("scheduler: created repeat instance %s from %s (next: %s)", newTask.ID, originalTaskID, nextDate)
+	log.Printf("scheduler: created repeat instance %s from %s (next: %s)", newTask.ID, originalTaskID, next)
+	return newTask
 }
 
 func (s *Scheduler) processReminders() {
@@ -356,8 +634,10 @@ func (s *Scheduler) fireReminder(p model.PendingReminder, fireAt time.Time) {
 	body := describeReminder(p.Reminder)
 
 	// Web Push
+	notifier := "none"
 	if s.pushSender != nil && s.pushSender.Enabled() {
 		if user, err := s.userRepo.GetFirst(); err == nil && user != nil {
+			notifier = push.Backend(s.pushSender, user.ID)
 			if err := s.pushSender.Send(user.ID, push.Payload{
 				Title: p.TaskTitle,
 				Body:  body,
@@ -365,9 +645,11 @@ func (s *Scheduler) fireReminder(p model.PendingReminder, fireAt time.Time) {
 				Tag:   p.Reminder.ID,
 			}); err != nil {
 				log.Printf("scheduler: push send error: %v", err)
+				reminderFailures.With(notifier).Inc()
 			}
 		}
 	}
+	remindersFired.With(notifier).Inc()
 
 	// SSE broadcast for in-app toast
 	s.broker.BroadcastJSON("reminder_fired", map[string]interface{}{
@@ -429,21 +711,115 @@ func (s *Scheduler) copyRemindersToNewInstance(originalTaskID, newTaskID string)
 	}
 }
 
-func (s *Scheduler) calculateNextDate(currentDate *string, pattern model.RecurrencePattern) string {
-	fromDate := ""
-	if currentDate != nil {
-		fromDate = *currentDate
+// copySchedulesToNewInstance recreates the original task's schedule slots on
+// the new instance, shifted by delta days. The first slot maps onto the entry
+// taskRepo.Create already made for the new when_date, so only its times are
+// copied; "someday" slots are dropped.
+func (s *Scheduler) copySchedulesToNewInstance(schedules []model.TaskSchedule, newTaskID string, delta int, shiftable bool) {
+	if len(schedules) == 0 {
+		return
+	}
+	sorted := make([]model.TaskSchedule, len(schedules))
+	copy(sorted, schedules)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SortOrder < sorted[j].SortOrder })
+
+	first := sorted[0]
+	if first.StartTime != nil || first.EndTime != nil {
+		existing, err := s.scheduleRepo.ListByTask(newTaskID)
+		if err == nil && len(existing) > 0 {
+			if _, err := s.scheduleRepo.Update(existing[0].ID, model.UpdateTaskScheduleInput{
+				StartTime: first.StartTime,
+				EndTime:   first.EndTime,
+				Raw:       map[string]json.RawMessage{"start_time": nil, "end_time": nil},
+			}); err != nil {
+				log.Printf("scheduler: copy schedule times for task %s: %v", newTaskID, err)
+			}
+		}
+	}
+
+	if !shiftable {
+		return
 	}
+	for _, sched := range sorted[1:] {
+		date, ok := shiftDate(sched.WhenDate, delta)
+		if !ok {
+			continue
+		}
+		if _, err := s.scheduleRepo.Create(newTaskID, model.CreateTaskScheduleInput{
+			WhenDate:  date,
+			StartTime: sched.StartTime,
+			EndTime:   sched.EndTime,
+		}); err != nil {
+			log.Printf("scheduler: copy schedule for task %s: %v", newTaskID, err)
+		}
+	}
+}
 
-	result, err := s.engine.Next(fromDate, pattern)
+// dayDelta returns the number of days from the original when_date to the next
+// one, reporting false when the original has no concrete date.
+func dayDelta(from *string, to string) (int, bool) {
+	if from == nil {
+		return 0, false
+	}
+	a, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return 0, false
+	}
+	b, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return 0, false
+	}
+	return int(b.Sub(a).Hours() / 24), true
+}
+
+// shiftDate moves a YYYY-MM-DD date by days, reporting false for anything
+// that is not a concrete date (e.g. "someday").
+func shiftDate(date string, days int) (string, bool) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", false
+	}
+	return t.AddDate(0, 0, days).Format("2006-01-02"), true
+}
+
+// userEngine returns the recurrence engine for the user's workday calendar,
+// falling back to the Monday-to-Friday engine.
+func (s *Scheduler) userEngine() *recurrence.Engine {
+	user, err := s.userRepo.GetFirst()
+	if err != nil || user == nil {
+		return s.engine
+	}
+	settings, err := s.settingsRepo.GetOrCreate(user.ID)
+	if err != nil {
+		return s.engine
+	}
+	cal, err := recurrence.UserCalendar(settings)
+	if err != nil {
+		log.Printf("scheduler: workday calendar: %v", err)
+		return s.engine
+	}
+	if cal == nil {
+		return s.engine
+	}
+	return recurrence.NewEngineWithCalendar(cal)
+}
+
+// calculateNextDate returns the occurrence after from (a date or date-time,
+// "" for none) with its occurrence number, or "" when the series has ended.
+func (s *Scheduler) calculateNextDate(from string, pattern model.RecurrencePattern) (string, int) {
+	result, occurrence, err := s.userEngine().NextInSeries(from, pattern)
+	if errors.Is(err, recurrence.ErrNoMoreOccurrences) {
+		return "", 0
+	}
 	if err != nil {
 		log.Printf("scheduler: calculate next date: %v", err)
 		// Fallback to simple daily
-		if currentDate != nil {
-			t, _ := time.Parse("2006-01-02", *currentDate)
-			return t.AddDate(0, 0, 1).Format("2006-01-02")
+		if from != "" {
+			date, _ := recurrence.SplitDateTime(from)
+			t, _ := time.Parse("2006-01-02", date)
+			return t.AddDate(0, 0, 1).Format("2006-01-02"), max(pattern.Occurrence, 1) + 1
 		}
-		return time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+		return time.Now().AddDate(0, 0, 1).Format("2006-01-02"), max(pattern.Occurrence, 1) + 1
 	}
-	return result
+	return result, occurrence
 }
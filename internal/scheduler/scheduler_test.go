@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/model"
+	"github.com/collinjanssen/thingstodo/internal/repository"
+	"github.com/collinjanssen/thingstodo/internal/sse"
+	"github.com/collinjanssen/thingstodo/internal/testutil"
+)
+
+func newTestScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+	return New(db,
+		repository.NewTaskRepository(db, nil),
+		repository.NewRepeatRuleRepository(db, nil),
+		repository.NewChecklistRepository(db, nil),
+		repository.NewAttachmentRepository(db, nil),
+		repository.NewScheduleRepository(db, nil),
+		repository.NewReminderRepository(db, nil),
+		repository.NewUserSettingsRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewChangeLogRepository(db),
+		nil, sse.NewBroker(), time.UTC)
+}
+
+// seriesTasks returns the when_date and status of every task in the series,
+// ordered by date.
+func seriesTasks(t *testing.T, s *Scheduler, seriesID string) [][2]string {
+	t.Helper()
+	rows, err := s.db.Query("SELECT when_date, status FROM tasks WHERE series_id = ? ORDER BY when_date, status", seriesID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var row [2]string
+		if err := rows.Scan(&row[0], &row[1]); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+func TestCatchUpPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   [][2]string
+		ruleOn string // when_date of the task holding the rule afterwards
+	}{
+		{model.CatchUpNone, [][2]string{{"2026-03-02", "open"}}, "2026-03-02"},
+		{model.CatchUpAll, [][2]string{
+			{"2026-03-02", "open"}, {"2026-03-03", "open"}, {"2026-03-04", "open"}, {"2026-03-05", "open"},
+		}, "2026-03-05"},
+		{model.CatchUpLatest, [][2]string{
+			{"2026-03-02", "open"}, {"2026-03-03", "canceled"}, {"2026-03-04", "canceled"}, {"2026-03-05", "open"},
+		}, "2026-03-05"},
+		{model.CatchUpSkip, [][2]string{
+			{"2026-03-02", "canceled"}, {"2026-03-03", "canceled"}, {"2026-03-04", "canceled"}, {"2026-03-05", "open"},
+		}, "2026-03-05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s := newTestScheduler(t)
+			when := "2026-03-02"
+			task, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Journal", WhenDate: &when})
+			if err != nil {
+				t.Fatal(err)
+			}
+			policy := tt.policy
+			if _, err := s.ruleRepo.Upsert(task.ID, model.CreateRepeatRuleInput{
+				Pattern: &model.RecurrencePattern{Type: model.PatternDaily, Every: 1, Mode: model.RecurrenceModeFixed},
+				CatchUp: &policy,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			s.runFixedRules("2026-03-05")
+			got := seriesTasks(t, s, task.ID)
+			if len(got) != len(tt.want) {
+				t.Fatalf("series = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("series[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+
+			rules, _ := s.ruleRepo.ListAll()
+			if len(rules) != 1 {
+				t.Fatalf("expected one rule, got %d", len(rules))
+			}
+			holder, _ := s.taskRepo.GetByID(rules[0].TaskID)
+			if holder == nil || *holder.WhenDate != tt.ruleOn {
+				t.Errorf("rule is on %v, want the task due %s", holder, tt.ruleOn)
+			}
+			if rules[0].CatchUp != tt.policy {
+				t.Errorf("catch_up = %q, want %q", rules[0].CatchUp, tt.policy)
+			}
+
+			// A second run has nothing left to catch up on.
+			s.runFixedRules("2026-03-05")
+			if again := seriesTasks(t, s, task.ID); len(again) != len(got) {
+				t.Errorf("second run changed the series: %v", again)
+			}
+		})
+	}
+}
+
+func TestTimeOfDayInstancesCarryStartTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		when    string
+		start   string
+		pattern model.RecurrencePattern
+		want    [2]string // when_date and start_time of the next instance
+	}{
+		{"weekday at 09:30", "2026-03-06", "09:30",
+			model.RecurrencePattern{Type: model.PatternDailyWeekday, Every: 1, Time: "09:30"},
+			[2]string{"2026-03-09", "09:30"}},
+		{"every 4 hours", "2026-03-02", "08:00",
+			model.RecurrencePattern{Type: model.PatternHourly, Every: 4, Time: "08:00", TimeUntil: "20:00"},
+			[2]string{"2026-03-02", "12:00"}},
+		{"last slot of the day", "2026-03-02", "20:00",
+			model.RecurrencePattern{Type: model.PatternHourly, Every: 4, Time: "08:00", TimeUntil: "20:00"},
+			[2]string{"2026-03-03", "08:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScheduler(t)
+			when := tt.when
+			task, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Medication", WhenDate: &when})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.taskRepo.SetFirstScheduleTime(task.ID, tt.start); err != nil {
+				t.Fatal(err)
+			}
+			pattern := tt.pattern
+			pattern.Mode = model.RecurrenceModeFixed
+			if _, err := s.ruleRepo.Upsert(task.ID, model.CreateRepeatRuleInput{Pattern: &pattern}); err != nil {
+				t.Fatal(err)
+			}
+
+			s.HandleTaskDone(task.ID, nil)
+			rules, _ := s.ruleRepo.ListAll()
+			if len(rules) != 1 || rules[0].TaskID == task.ID {
+				t.Fatalf("rule did not move to a new instance: %+v", rules)
+			}
+			next, _ := s.taskRepo.GetByID(rules[0].TaskID)
+			start := model.FirstStartTime(next.Schedules)
+			if next.WhenDate == nil || start == nil {
+				t.Fatalf("next instance has no date or start time: %+v", next)
+			}
+			if got := [2]string{*next.WhenDate, *start}; got != tt.want {
+				t.Errorf("next instance at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatInstanceKeepsEncryptedAttachments(t *testing.T) {
+	s := newTestScheduler(t)
+	when := "2026-03-02"
+	task, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Pay rent", WhenDate: &when})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, att := range []model.CreateAttachmentInput{
+		{Type: "file", Title: "lease.pdf", URL: "lease.pdf", MimeType: "application/pdf", Encrypted: true},
+		{Type: "link", Title: "Bank", URL: "https://bank.example"},
+	} {
+		if _, err := s.attachRepo.Create(task.ID, att); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pattern := model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: model.RecurrenceModeFixed}
+	if _, err := s.ruleRepo.Upsert(task.ID, model.CreateRepeatRuleInput{Pattern: &pattern}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.HandleTaskDone(task.ID, nil)
+	rules, _ := s.ruleRepo.ListAll()
+	if len(rules) != 1 || rules[0].TaskID == task.ID {
+		t.Fatalf("rule did not move to a new instance: %+v", rules)
+	}
+	next, _ := s.taskRepo.GetByID(rules[0].TaskID)
+	encrypted := map[string]bool{}
+	for _, att := range next.Attachments {
+		encrypted[att.Title] = att.Encrypted
+	}
+	if len(encrypted) != 2 || !encrypted["lease.pdf"] || encrypted["Bank"] {
+		t.Errorf("copied attachments encrypted = %v, want lease.pdf only", encrypted)
+	}
+}
+
+func TestRepeatingProjectIsCopiedWhenDue(t *testing.T) {
+	s := newTestScheduler(t)
+	area, err := repository.NewAreaRepository(s.db, nil).Create(model.CreateAreaInput{Title: "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	when, deadline := "2026-03-01", "2026-03-05"
+	project, err := s.projectRepo.Create(model.CreateProjectInput{Title: "Monthly closing", AreaID: &area.ID, WhenDate: &when, Deadline: &deadline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	heading, err := s.headingRepo.Create(project.ID, model.CreateHeadingInput{Title: "Invoices"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskDate := "2026-03-03"
+	invoices, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Send invoices", WhenDate: &taskDate, ProjectID: &project.ID, HeadingID: &heading.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.checklistRepo.Create(invoices.ID, model.CreateChecklistInput{Title: "Client A"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.attachRepo.Create(invoices.ID, model.CreateAttachmentInput{Type: "file", Title: "rates.pdf", URL: "rates.pdf", Encrypted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.taskRepo.Complete(invoices.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.taskRepo.Create(model.CreateTaskInput{Title: "Reconcile bank", ProjectID: &project.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.projectRuleRepo.Upsert(project.ID, model.CreateProjectRepeatRuleInput{
+		Pattern: &model.RecurrencePattern{Type: model.PatternMonthlyDOM, Every: 1, Mode: model.RecurrenceModeFixed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.projectRepo.Complete(project.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	s.runFixedRules("2026-03-20")
+	if rules, _ := s.projectRuleRepo.ListAll(); len(rules) != 1 || rules[0].ProjectID != project.ID {
+		t.Fatalf("project was copied before its next occurrence: %+v", rules)
+	}
+
+	s.runFixedRules("2026-04-01")
+	rules, _ := s.projectRuleRepo.ListAll()
+	if len(rules) != 1 || rules[0].ProjectID == project.ID {
+		t.Fatalf("rule did not move to a new instance: %+v", rules)
+	}
+	if rules[0].Pattern.Occurrence != 2 {
+		t.Errorf("occurrence = %d, want 2", rules[0].Pattern.Occurrence)
+	}
+	next, _ := s.projectRepo.GetByID(rules[0].ProjectID)
+	if next.Title != "Monthly closing" || next.Status != "open" || *next.WhenDate != "2026-04-01" || *next.Deadline != "2026-04-05" {
+		t.Errorf("next instance = %s %s %v %v", next.Title, next.Status, *next.WhenDate, *next.Deadline)
+	}
+	if next.SeriesID == nil || *next.SeriesID != project.ID {
+		t.Errorf("series_id = %v, want %s", next.SeriesID, project.ID)
+	}
+	if len(next.Headings) != 1 || next.Headings[0].Title != "Invoices" || len(next.Headings[0].Tasks) != 1 {
+		t.Fatalf("headings = %+v", next.Headings)
+	}
+	copied := next.Headings[0].Tasks[0]
+	if copied.Title != "Send invoices" || *copied.WhenDate != "2026-04-03" || copied.ChecklistCount != 1 || copied.ChecklistDone != 0 {
+		t.Errorf("copied task = %s %v checklist %d/%d", copied.Title, *copied.WhenDate, copied.ChecklistDone, copied.ChecklistCount)
+	}
+	if detail, _ := s.taskRepo.GetByID(copied.ID); len(detail.Attachments) != 1 || !detail.Attachments[0].Encrypted {
+		t.Errorf("copied attachments = %+v, want one encrypted", detail.Attachments)
+	}
+	if len(next.TasksWithoutHeading) != 1 || next.TasksWithoutHeading[0].Title != "Reconcile bank" {
+		t.Errorf("tasks without heading = %+v", next.TasksWithoutHeading)
+	}
+
+	old, _ := s.projectRepo.GetByID(project.ID)
+	if old.Status != "completed" || len(old.CompletedTasks) != 1 || old.RepeatRule != nil {
+		t.Errorf("finished project changed: status %s, %d completed tasks, rule %v", old.Status, len(old.CompletedTasks), old.RepeatRule)
+	}
+}
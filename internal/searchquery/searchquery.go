@@ -0,0 +1,252 @@
+// Package searchquery parses search queries that mix full-text terms with
+// filters, e.g. `invoice tag:work project:"Q3 close" due:<2026-11-01 is:open`.
+package searchquery
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Term is a full-text term. Bare words match as prefixes; quoted phrases
+// match exactly.
+type Term struct {
+	Text    string
+	Phrase  bool
+	Negated bool
+	Pos     int
+}
+
+// Filter is a key:value operator. Op is "=", "<", "<=", ">" or ">=";
+// comparisons only apply to date keys.
+type Filter struct {
+	Key     string
+	Op      string
+	Value   string
+	Negated bool
+	Pos     int
+}
+
+type Query struct {
+	Terms   []Term
+	Filters []Filter
+}
+
+// ParseError reports where a query went wrong. Pos and End are 0-based
+// character offsets into the query, End exclusive.
+type ParseError struct {
+	Pos int
+	End int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// keys maps accepted filter names to their canonical key.
+var keys = map[string]string{
+	"tag":       "tag",
+	"tags":      "tag",
+	"project":   "project",
+	"area":      "area",
+	"heading":   "heading",
+	"is":        "is",
+	"has":       "has",
+	"priority":  "priority",
+	"due":       "due",
+	"deadline":  "due",
+	"when":      "when",
+	"scheduled": "when",
+	"created":   "created",
+	"completed": "completed",
+	"type":      "type",
+}
+
+var dateKeys = map[string]bool{"due": true, "when": true, "created": true, "completed": true}
+
+var values = map[string]map[string]string{
+	"is": {
+		"open": "open", "completed": "completed", "done": "completed",
+		"canceled": "canceled", "cancelled": "canceled", "wontdo": "wont_do", "wont_do": "wont_do",
+		"someday": "someday", "inbox": "inbox", "repeating": "repeating", "overdue": "overdue",
+	},
+	"has": {
+		"attachment": "attachment", "attachments": "attachment",
+		"file": "file", "files": "file", "link": "link", "links": "link",
+		"notes": "notes", "deadline": "deadline", "checklist": "checklist",
+		"reminder": "reminder", "reminders": "reminder", "repeat": "repeat",
+		"tag": "tag", "tags": "tag",
+	},
+	"priority": {"high": "high", "normal": "normal"},
+	"type": {
+		"task": "task", "tasks": "task", "project": "project", "projects": "project",
+		"area": "area", "areas": "area", "heading": "heading", "headings": "heading",
+		"checklist": "checklist_item", "checklist_item": "checklist_item",
+	},
+}
+
+// Parse splits a query into terms and filters. Words are separated by
+// whitespace; a leading "-" negates a word, phrase or filter.
+func Parse(input string) (*Query, error) {
+	p := &parser{src: []rune(input)}
+	q := &Query{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		start := p.pos
+		negated := false
+		if p.src[p.pos] == '-' && p.pos+1 < len(p.src) && !unicode.IsSpace(p.src[p.pos+1]) {
+			negated = true
+			p.pos++
+		}
+
+		if p.src[p.pos] == '"' {
+			text, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(text) == "" {
+				return nil, &ParseError{Pos: start, End: p.pos, Msg: "empty phrase"}
+			}
+			q.Terms = append(q.Terms, Term{Text: text, Phrase: true, Negated: negated, Pos: start})
+			continue
+		}
+
+		wordStart := p.pos
+		for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) && p.src[p.pos] != ':' && p.src[p.pos] != '"' {
+			p.pos++
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == ':' && p.pos > wordStart {
+			f, err := p.filter(start, wordStart)
+			if err != nil {
+				return nil, err
+			}
+			f.Negated = negated
+			q.Filters = append(q.Filters, *f)
+			continue
+		}
+		// A plain word; stray quotes or colons inside it are just text.
+		for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) {
+			p.pos++
+		}
+		q.Terms = append(q.Terms, Term{Text: string(p.src[wordStart:p.pos]), Negated: negated, Pos: start})
+	}
+	if len(q.Terms) == 0 && len(q.Filters) == 0 {
+		return nil, &ParseError{Pos: 0, End: len(p.src), Msg: "empty query"}
+	}
+	return q, nil
+}
+
+type parser struct {
+	src []rune
+	pos int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// quoted reads a double-quoted string starting at p.pos.
+func (p *parser) quoted() (string, error) {
+	open := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		return "", &ParseError{Pos: open, End: len(p.src), Msg: "unterminated quote"}
+	}
+	text := string(p.src[open+1 : p.pos])
+	p.pos++
+	return text, nil
+}
+
+// filter reads the rest of key:value; p.pos is on the colon.
+func (p *parser) filter(start, keyStart int) (*Filter, error) {
+	name := strings.ToLower(string(p.src[keyStart:p.pos]))
+	key, ok := keys[name]
+	if !ok {
+		return nil, &ParseError{Pos: keyStart, End: p.pos, Msg: fmt.Sprintf("unknown filter %q", name)}
+	}
+	p.pos++ // colon
+
+	opStart := p.pos
+	op := "="
+	for _, candidate := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(string(p.src[p.pos:min(p.pos+2, len(p.src))]), candidate) {
+			op = candidate
+			p.pos += len(candidate)
+			break
+		}
+	}
+	if op != "=" && !dateKeys[key] {
+		return nil, &ParseError{Pos: opStart, End: p.pos, Msg: fmt.Sprintf("%s: does not take comparisons", name)}
+	}
+
+	valueStart := p.pos
+	var value string
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		v, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(v)
+	} else {
+		for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) {
+			p.pos++
+		}
+		value = string(p.src[valueStart:p.pos])
+	}
+	if value == "" {
+		return nil, &ParseError{Pos: start, End: p.pos, Msg: fmt.Sprintf("%s: needs a value", name)}
+	}
+
+	bad := func(msg string) error {
+		return &ParseError{Pos: valueStart, End: p.pos, Msg: msg}
+	}
+	switch {
+	case values[key] != nil:
+		canonical, ok := values[key][strings.ToLower(value)]
+		if !ok {
+			return nil, bad(fmt.Sprintf("unknown value %q for %s:", value, name))
+		}
+		value = canonical
+	case dateKeys[key]:
+		value = strings.ToLower(value)
+		switch value {
+		case "today", "tomorrow", "yesterday":
+		case "none", "someday":
+			if op != "=" {
+				return nil, bad(fmt.Sprintf("%s cannot be compared", value))
+			}
+			if (value == "someday" && key != "when") || (value == "none" && key == "created") {
+				return nil, bad(fmt.Sprintf("unknown value %q for %s:", value, name))
+			}
+		default:
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return nil, bad("dates are written YYYY-MM-DD, today, tomorrow or yesterday")
+			}
+		}
+	}
+	return &Filter{Key: key, Op: op, Value: value, Pos: start}, nil
+}
+
+// ResolveDate turns a date filter value into a date, given today's date.
+// Values other than today, tomorrow and yesterday are returned as they are.
+func ResolveDate(value string, today time.Time) string {
+	switch value {
+	case "today":
+		return today.Format("2006-01-02")
+	case "tomorrow":
+		return today.AddDate(0, 0, 1).Format("2006-01-02")
+	case "yesterday":
+		return today.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return value
+}
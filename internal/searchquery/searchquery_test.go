@@ -0,0 +1,59 @@
+package searchquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`invoice tag:work project:"Q3 close" due:<2026-11-01 -is:Done "net 30" -draft`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTerms := []Term{
+		{Text: "invoice", Pos: 0},
+		{Text: "net 30", Phrase: true, Pos: 61},
+		{Text: "draft", Negated: true, Pos: 70},
+	}
+	wantFilters := []Filter{
+		{Key: "tag", Op: "=", Value: "work", Pos: 8},
+		{Key: "project", Op: "=", Value: "Q3 close", Pos: 17},
+		{Key: "due", Op: "<", Value: "2026-11-01", Pos: 36},
+		{Key: "is", Op: "=", Value: "completed", Negated: true, Pos: 52},
+	}
+	if !reflect.DeepEqual(q.Terms, wantTerms) {
+		t.Errorf("terms = %+v, want %+v", q.Terms, wantTerms)
+	}
+	if !reflect.DeepEqual(q.Filters, wantFilters) {
+		t.Errorf("filters = %+v, want %+v", q.Filters, wantFilters)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		pos, end int
+	}{
+		{"", 0, 0},
+		{"invoice colour:red", 8, 14},
+		{`invoice project:"Q3 close`, 16, 25},
+		{"tag:<work", 4, 5},
+		{"due:soon", 4, 8},
+		{"when:>someday", 6, 13},
+		{"is:", 0, 3},
+		{"has:kittens", 4, 11},
+		{`say "hi`, 4, 7},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q) error = %v, want a ParseError", tt.input, err)
+			continue
+		}
+		if parseErr.Pos != tt.pos || parseErr.End != tt.end {
+			t.Errorf("Parse(%q) error at %d-%d, want %d-%d (%v)", tt.input, parseErr.Pos, parseErr.End, tt.pos, tt.end, err)
+		}
+	}
+}
@@ -3,6 +3,14 @@ package sse
 import (
 	"encoding/json"
 	"sync"
+
+	"github.com/collinjanssen/thingstodo/internal/metrics"
+)
+
+var (
+	subscribers   = metrics.NewGauge("thingstodo_sse_subscribers", "Clients subscribed to the event stream.").With()
+	droppedEvents = metrics.NewCounter("thingstodo_sse_dropped_events_total",
+		"Events not delivered because a client's buffer was full.").With()
 )
 
 type Event struct {
@@ -25,6 +33,9 @@ func (b *Broker) Subscribe(clientID string) <-chan Event {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	ch := make(chan Event, 64)
+	if _, ok := b.clients[clientID]; !ok {
+		subscribers.Add(1)
+	}
 	b.clients[clientID] = ch
 	return ch
 }
@@ -35,6 +46,7 @@ func (b *Broker) Unsubscribe(clientID string) {
 	if ch, ok := b.clients[clientID]; ok {
 		close(ch)
 		delete(b.clients, clientID)
+		subscribers.Add(-1)
 	}
 }
 
@@ -49,6 +61,7 @@ func (b *Broker) Broadcast(event Event, excludeClient string) {
 		case ch <- event:
 		default:
 			// Drop event if client is too slow.
+			droppedEvents.Inc()
 		}
 	}
 }
@@ -12,9 +12,17 @@ import (
 	"github.com/collinjanssen/thingstodo/internal/database"
 )
 
-// SetupTestDB creates a temporary SQLite database with all migrations applied.
+// SetupTestDB creates a temporary SQLite database with all migrations applied
+// and returns its writer pool, which every repository can run on.
 // The database and temp directory are cleaned up when the test finishes.
-func SetupTestDB(t *testing.T) *sql.DB {
+func SetupTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+	return SetupTestPools(t).Write
+}
+
+// SetupTestPools creates a temporary SQLite database with all migrations
+// applied and returns both of its connection pools.
+func SetupTestPools(t testing.TB) *database.DB {
 	t.Helper()
 	dir := t.TempDir()
 	dbPath := dir + "/test.db"
@@ -72,6 +80,12 @@ func (tc *TestClient) Patch(path string, body interface{}) *TestResponse {
 	return tc.do("PATCH", path, body)
 }
 
+// Put sends a PUT request with a JSON body.
+func (tc *TestClient) Put(path string, body interface{}) *TestResponse {
+	tc.t.Helper()
+	return tc.do("PUT", path, body)
+}
+
 // Delete sends a DELETE request to the given path.
 func (tc *TestClient) Delete(path string) *TestResponse {
 	tc.t.Helper()
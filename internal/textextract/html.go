@@ -0,0 +1,72 @@
+package textextract
+
+import (
+	"html"
+	"strings"
+)
+
+// blockTags end a line of text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "td": true, "th": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true, "blockquote": true,
+	"pre": true, "title": true, "dt": true, "dd": true,
+}
+
+// htmlText strips tags, comments, scripts and styles and decodes entities.
+func htmlText(src string) string {
+	var b strings.Builder
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			b.WriteString(html.UnescapeString(src))
+			break
+		}
+		b.WriteString(html.UnescapeString(src[:lt]))
+		src = src[lt:]
+
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+		gt := strings.IndexByte(src, '>')
+		if gt < 0 {
+			break
+		}
+		closing := strings.HasPrefix(src, "</")
+		name := tagName(src[1:gt])
+		src = src[gt+1:]
+
+		if !closing && (name == "script" || name == "style") {
+			// Skip to the closing tag; their content is not text.
+			end := strings.Index(strings.ToLower(src), "</"+name)
+			if end < 0 {
+				break
+			}
+			src = src[end:]
+			continue
+		}
+		if blockTags[name] {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// tagName returns the lower-cased name of a tag given what's between < and >.
+func tagName(tag string) string {
+	tag = strings.TrimPrefix(tag, "/")
+	end := strings.IndexFunc(tag, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '/'
+	})
+	if end >= 0 {
+		tag = tag[:end]
+	}
+	return strings.ToLower(tag)
+}
@@ -0,0 +1,336 @@
+package textextract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// pdfText reads the text shown by a PDF's content streams. It understands
+// unfiltered and FlateDecode streams and strings in PDFDocEncoding or
+// UTF-16; fonts with custom encodings come out garbled or not at all.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF")
+	}
+	var b strings.Builder
+	rest := data
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := streamDict(rest[:start])
+		body := rest[start+len("stream"):]
+		// The keyword is followed by CRLF or LF.
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+
+		content, ok := decodeStream(dict, body[:end])
+		if !ok {
+			continue
+		}
+		showText(&b, content)
+		if b.Len() > MaxText {
+			break
+		}
+	}
+	return b.String(), nil
+}
+
+// streamDict returns the dictionary in front of a stream keyword.
+func streamDict(before []byte) []byte {
+	if i := bytes.LastIndex(before, []byte("obj")); i >= 0 {
+		return before[i:]
+	}
+	return nil
+}
+
+// decodeStream undoes a stream's filter. Streams that can't hold page text,
+// like images and fonts, are skipped.
+func decodeStream(dict, raw []byte) ([]byte, bool) {
+	for _, skip := range []string{"/Image", "/FontFile", "/Length1", "/XRef", "/Metadata"} {
+		if bytes.Contains(dict, []byte(skip)) {
+			return nil, false
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return raw, true
+	}
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Contains(dict, []byte("/DecodeParms")) {
+		return nil, false
+	}
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(raw))
+	}
+	// Truncated streams are common; keep whatever inflated.
+	out, _ := io.ReadAll(io.LimitReader(r, 16*MaxText))
+	return out, len(out) > 0
+}
+
+// showText writes the strings drawn between BT and ET in a content stream.
+func showText(b *strings.Builder, content []byte) {
+	lex := pdfLexer{src: content}
+	var operands []pdfToken
+	inText := false
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			return
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.text {
+		case "BT":
+			inText = true
+		case "ET":
+			if inText {
+				b.WriteByte('\n')
+			}
+			inText = false
+		case "Tj", "'", "\"":
+			if inText && len(operands) > 0 {
+				if tok.text != "Tj" {
+					b.WriteByte('\n')
+				}
+				if last := operands[len(operands)-1]; last.kind == pdfString {
+					b.WriteString(last.text)
+				}
+			}
+		case "TJ":
+			if inText {
+				for _, op := range operands {
+					switch {
+					case op.kind == pdfString:
+						b.WriteString(op.text)
+					case op.kind == pdfNumber:
+						// A large negative kern is how many PDFs draw a space.
+						if n, err := strconv.ParseFloat(op.text, 64); err == nil && n < -150 {
+							b.WriteByte(' ')
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if inText && len(operands) >= 2 && operands[len(operands)-1].text != "0" {
+				b.WriteByte('\n')
+			} else if inText {
+				b.WriteByte(' ')
+			}
+		case "T*", "Tm":
+			if inText {
+				b.WriteByte('\n')
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfString
+	pdfNumber
+	pdfOther
+)
+
+type pdfToken struct {
+	kind pdfTokenKind
+	text string
+}
+
+type pdfLexer struct {
+	src []byte
+	pos int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, text: decodePDFString(l.literal())}, true
+		case c == '<' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '<':
+			l.pos += 2
+			return pdfToken{kind: pdfOther, text: "<<"}, true
+		case c == '<':
+			return pdfToken{kind: pdfString, text: decodePDFString(l.hex())}, true
+		case c == '>' || c == '[' || c == ']' || c == '{' || c == '}':
+			l.pos++
+			if c == '>' && l.pos < len(l.src) && l.src[l.pos] == '>' {
+				l.pos++
+			}
+			return pdfToken{kind: pdfOther, text: string(c)}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			l.word()
+			return pdfToken{kind: pdfOther, text: string(l.src[start:l.pos])}, true
+		default:
+			start := l.pos
+			l.word()
+			if l.pos == start {
+				l.pos++
+				continue
+			}
+			text := string(l.src[start:l.pos])
+			if strings.IndexFunc(text, func(r rune) bool { return !strings.ContainsRune("+-.0123456789", r) }) < 0 {
+				return pdfToken{kind: pdfNumber, text: text}, true
+			}
+			return pdfToken{kind: pdfOperator, text: text}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// word advances over a run of regular characters.
+func (l *pdfLexer) word() {
+	for l.pos < len(l.src) && !isPDFSpace(l.src[l.pos]) && !isPDFDelimiter(l.src[l.pos]) {
+		l.pos++
+	}
+}
+
+// literal reads a (string), handling nesting and escapes.
+func (l *pdfLexer) literal() []byte {
+	var out []byte
+	depth := 0
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+		case '\\':
+			if l.pos >= len(l.src) {
+				return out
+			}
+			e := l.src[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A line continuation.
+				if e == '\r' && l.pos < len(l.src) && l.src[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.src[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hex reads a <hex string>.
+func (l *pdfLexer) hex() []byte {
+	var digits []byte
+	l.pos++
+	for l.pos < len(l.src) && l.src[l.pos] != '>' {
+		if c := l.src[l.pos]; unicode.Is(unicode.ASCII_Hex_Digit, rune(c)) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		out[i] = unhex(digits[2*i])<<4 | unhex(digits[2*i+1])
+	}
+	return out
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// decodePDFString turns string bytes into text: UTF-16BE with a byte order
+// mark, otherwise Latin-1 (close enough to PDFDocEncoding). Control
+// characters, which mostly come from glyph-indexed fonts, are dropped.
+func decodePDFString(raw []byte) string {
+	var runes []rune
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		runes = utf16.Decode(units)
+	} else {
+		runes = make([]rune, len(raw))
+		for i, c := range raw {
+			runes[i] = rune(c)
+		}
+	}
+	var b strings.Builder
+	for _, r := range runes {
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+// Package textextract pulls searchable text out of uploaded files: plain
+// text, Markdown, HTML, PDF and DOCX. Extraction is best effort; layout is
+// lost and only words and line breaks are kept.
+package textextract
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is returned for files whose type has no extractor.
+var ErrUnsupported = errors.New("unsupported file type")
+
+// MaxText caps the text kept per file; the rest is dropped.
+const MaxText = 1 << 20
+
+// Format names a supported file type.
+type Format string
+
+const (
+	Plain    Format = "text"
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	PDF      Format = "pdf"
+	DOCX     Format = "docx"
+)
+
+var extFormats = map[string]Format{
+	".txt":      Plain,
+	".text":     Plain,
+	".log":      Plain,
+	".csv":      Plain,
+	".md":       Markdown,
+	".markdown": Markdown,
+	".htm":      HTML,
+	".html":     HTML,
+	".pdf":      PDF,
+	".docx":     DOCX,
+}
+
+var mimeFormats = map[string]Format{
+	"text/plain":      Plain,
+	"text/csv":        Plain,
+	"text/markdown":   Markdown,
+	"text/x-markdown": Markdown,
+	"text/html":       HTML,
+	"application/pdf": PDF,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": DOCX,
+}
+
+// Detect picks a format from a file name and MIME type. The extension wins,
+// since browsers often upload Markdown and DOCX as application/octet-stream.
+func Detect(name, mimeType string) (Format, bool) {
+	if f, ok := extFormats[strings.ToLower(filepath.Ext(name))]; ok {
+		return f, true
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		if f, ok := mimeFormats[mediaType]; ok {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Extract returns the text of a file. It returns ErrUnsupported if the
+// format can't be detected.
+func Extract(name, mimeType string, data []byte) (string, error) {
+	format, ok := Detect(name, mimeType)
+	if !ok {
+		return "", ErrUnsupported
+	}
+	var text string
+	var err error
+	switch format {
+	case Plain, Markdown:
+		text, err = plainText(data)
+	case HTML:
+		text = htmlText(string(data))
+	case PDF:
+		text, err = pdfText(data)
+	case DOCX:
+		text, err = docxText(data)
+	}
+	if err != nil {
+		return "", err
+	}
+	return clip(normalize(text)), nil
+}
+
+func plainText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", errors.New("text is not valid UTF-8")
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
+
+// normalize collapses runs of spaces within lines and drops blank lines.
+func normalize(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// clip cuts text to MaxText bytes without splitting a character.
+func clip(text string) string {
+	if len(text) <= MaxText {
+		return text
+	}
+	cut := MaxText
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
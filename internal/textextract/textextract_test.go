@@ -0,0 +1,104 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name, mime string
+		data       []byte
+		expected   string
+	}{
+		{"notes.txt", "text/plain", []byte("\ufeffQuarterly   numbers\n\n\nare in"), "Quarterly numbers\nare in"},
+		{"README", "text/markdown; charset=utf-8", []byte("# Setup\n\nRun `make`."), "# Setup\nRun `make`."},
+		{"page.html", "", []byte(`<html><head><title>Menu</title><style>p{color:red}</style></head>
+<body><!-- nav --><p>Soup &amp; <b>bread</b></p><script>var x = "<p>";</script><div>Tea&nbsp;only</div></body></html>`),
+			"Menu\nSoup & bread\nTea only"},
+		{"report.docx", "application/octet-stream", docx(t, "Budget review", "Travel\tfood"), "Budget review\nTravel food"},
+		{"scan.pdf", "application/pdf", pdf(t, false, "BT /F1 12 Tf 72 720 Td (Invoice \\(draft\\)) Tj 0 -14 Td [(Total) -250 (due)] TJ ET"), "Invoice (draft)\nTotal due"},
+		{"deflated.pdf", "application/pdf", pdf(t, true, "BT <FEFF004300610066006500A0> Tj T* (caf\\351) Tj ET"), "Cafe\ncafé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.name, tt.mime, tt.data)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Extract = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	for _, name := range []string{"photo.jpg", "archive.zip", "noext"} {
+		if _, err := Extract(name, "application/octet-stream", []byte{0xff, 0xd8}); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Extract(%q) error = %v, want ErrUnsupported", name, err)
+		}
+	}
+	if _, err := Extract("broken.pdf", "", []byte("not a pdf")); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected a parse error for a broken PDF, got %v", err)
+	}
+}
+
+func docx(t *testing.T, paragraphs ...string) []byte {
+	t.Helper()
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range paragraphs {
+		body.WriteString("<w:p>")
+		for i, run := range bytes.Split([]byte(p), []byte("\t")) {
+			if i > 0 {
+				body.WriteString("<w:r><w:tab/></w:r>")
+			}
+			fmt.Fprintf(&body, "<w:r><w:t>%s</w:t></w:r>", run)
+		}
+		body.WriteString("</w:p>")
+	}
+	body.WriteString("</w:body></w:document>")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string][]byte{
+		"[Content_Types].xml": []byte(`<?xml version="1.0"?><Types/>`),
+		"word/document.xml":   body.Bytes(),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pdf(t *testing.T, deflate bool, content string) []byte {
+	t.Helper()
+	stream, filter := []byte(content), ""
+	if deflate {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(stream)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		stream, filter = buf.Bytes(), " /Filter /FlateDecode"
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	b.WriteString("2 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	fmt.Fprintf(&b, "3 0 obj\n<< /Length %d%s >>\nstream\n", len(stream), filter)
+	b.Write(stream)
+	b.WriteString("\nendstream\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
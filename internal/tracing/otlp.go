@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/collinjanssen/thingstodo/internal/metrics"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+var droppedSpans = metrics.NewCounter("thingstodo_tracing_dropped_spans_total",
+	"Spans dropped because the export queue was full.").With()
+
+// Options configures where spans are exported.
+type Options struct {
+	// Endpoint is the URL spans are posted to, such as
+	// http://localhost:4318/v1/traces. Tracing stays off when it is empty.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+}
+
+var active atomic.Pointer[exporter]
+
+func current() *exporter { return active.Load() }
+
+// exporter batches ended spans and posts them as OTLP JSON. Spans are
+// dropped rather than blocking a request when the queue is full.
+type exporter struct {
+	opts   Options
+	client *http.Client
+	queue  chan *Span
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Setup starts exporting spans and returns a func that flushes the spans
+// still queued and stops the exporter.
+func Setup(opts Options) (shutdown func(context.Context) error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = "thingstodo"
+	}
+	e := &exporter{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *Span, queueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	active.Store(e)
+	go e.run()
+	return func(ctx context.Context) error {
+		active.CompareAndSwap(e, nil)
+		close(e.stop)
+		select {
+		case <-e.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+		droppedSpans.Inc()
+	}
+}
+
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Printf("tracing: export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	add := func(s *Span) {
+		batch = append(batch, s)
+		if len(batch) == batchSize {
+			flush()
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			add(s)
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.queue:
+					add(s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// The OTLP/HTTP JSON encoding of an export request, trimmed to the fields
+// spans here use.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// statusError is the OTLP status code of a failed span.
+const statusError = 2
+
+func (e *exporter) export(batch []*Span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = s.otlp()
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			keyValue(attribute{"service.name", e.opts.ServiceName}),
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "thingstodo"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := otlpSpan{
+		TraceID:           s.trace.String(),
+		SpanID:            s.id.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parent != (SpanID{}) {
+		out.ParentSpanID = s.parent.String()
+	}
+	for _, a := range s.attrs {
+		out.Attributes = append(out.Attributes, keyValue(a))
+	}
+	if s.err != nil {
+		out.Status = &otlpStatus{Code: statusError, Message: s.err.Error()}
+	}
+	return out
+}
+
+// keyValue encodes an attribute as an OTLP AnyValue. 64-bit integers are
+// strings in OTLP JSON.
+func keyValue(a attribute) otlpKeyValue {
+	var v map[string]any
+	switch x := a.value.(type) {
+	case string:
+		v = map[string]any{"stringValue": x}
+	case bool:
+		v = map[string]any{"boolValue": x}
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(x)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": x}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+	return otlpKeyValue{Key: a.key, Value: v}
+}
@@ -0,0 +1,158 @@
+// Package tracing records spans and exports them to an OpenTelemetry
+// collector over OTLP/HTTP. Until Setup is called with an endpoint, Start
+// returns a nil span and every span method is a no-op, so call sites need no
+// checks of their own.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// spanContext identifies a span, local or remote, that new spans descend from.
+type spanContext struct {
+	trace TraceID
+	span  SpanID
+}
+
+type ctxKey struct{}
+
+// Span is one timed operation. A nil *Span is valid and records nothing.
+type Span struct {
+	exp    *exporter
+	trace  TraceID
+	id     SpanID
+	parent SpanID
+	kind   Kind
+	start  time.Time
+
+	mu    sync.Mutex
+	name  string
+	attrs []attribute
+	end   time.Time
+	err   error
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// Start starts an internal span as a child of the span in ctx, or as the
+// root of a new trace, and returns ctx carrying it.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, KindInternal)
+}
+
+// StartChild starts an internal span like Start, but only as a child of a
+// span already in ctx; otherwise it returns nil. Fine-grained work such as a
+// single SQL statement is traced this way, as part of a request or job
+// rather than as a trace of its own.
+func StartChild(ctx context.Context, name string) *Span {
+	if _, ok := ctx.Value(ctxKey{}).(spanContext); !ok {
+		return nil
+	}
+	_, s := start(ctx, name, KindInternal)
+	return s
+}
+
+// StartServer starts a server span for an incoming request, continuing the
+// trace in its traceparent header when there is one.
+func StartServer(r *http.Request, name string) (context.Context, *Span) {
+	ctx := r.Context()
+	if sc, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		ctx = context.WithValue(ctx, ctxKey{}, sc)
+	}
+	return start(ctx, name, KindServer)
+}
+
+func start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	exp := current()
+	if exp == nil {
+		return ctx, nil
+	}
+	s := &Span{exp: exp, name: name, kind: kind, start: time.Now()}
+	if parent, ok := ctx.Value(ctxKey{}).(spanContext); ok {
+		s.trace, s.parent = parent.trace, parent.span
+	} else {
+		rand.Read(s.trace[:])
+	}
+	rand.Read(s.id[:])
+	return context.WithValue(ctx, ctxKey{}, spanContext{trace: s.trace, span: s.id}), s
+}
+
+// SetName renames the span, for names only known once the work is done
+// such as a request's route.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttr records an attribute. Values are strings, bools, ints or floats;
+// anything else is recorded as its string form.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{key, value})
+	s.mu.Unlock()
+}
+
+// End finishes the span, marking it failed when err is not nil, and queues
+// it for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end, s.err = time.Now(), err
+	s.mu.Unlock()
+	s.exp.enqueue(s)
+}
+
+// parseTraceparent reads a W3C traceparent header:
+// version-traceid-spanid-flags, all lowercase hex.
+func parseTraceparent(h string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.trace[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.span[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	if sc.trace == (TraceID{}) || sc.span == (SpanID{}) {
+		return sc, false
+	}
+	return sc, true
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestStartWithoutSetupIsNoop(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "noop")
+	if span != nil || got != ctx {
+		t.Fatal("expected no span before Setup")
+	}
+	span.SetAttr("k", "v")
+	span.End(errors.New("ignored"))
+}
+
+func TestExportContinuesTraceparent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received otlpRequest
+		auth     string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	shutdown := Setup(Options{Endpoint: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+
+	req := httptest.NewRequest("GET", "/api/views/today", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := StartServer(req, "GET /api/views/today")
+	_, child := Start(ctx, "ViewRepository.Today")
+	child.SetAttr("rows", 3)
+	child.End(errors.New("boom"))
+	server.End(nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if auth != "Bearer token" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected export: %+v", received)
+	}
+	if name := received.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"]; name != "thingstodo" {
+		t.Errorf("service.name = %v", name)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	c, s := spans[0], spans[1]
+	if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || c.TraceID != s.TraceID {
+		t.Errorf("trace ids %s, %s do not continue the traceparent", s.TraceID, c.TraceID)
+	}
+	if s.ParentSpanID != "00f067aa0ba902b7" || s.Kind != KindServer {
+		t.Errorf("server span parent %s kind %d", s.ParentSpanID, s.Kind)
+	}
+	if c.ParentSpanID != s.SpanID || c.Kind != KindInternal {
+		t.Errorf("child span parent %s kind %d, want parent %s", c.ParentSpanID, c.Kind, s.SpanID)
+	}
+	if c.Status == nil || c.Status.Code != statusError || c.Status.Message != "boom" {
+		t.Errorf("child status = %+v", c.Status)
+	}
+	if s.Status != nil {
+		t.Errorf("server status = %+v, want unset", s.Status)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0].Value["intValue"] != "3" {
+		t.Errorf("child attributes = %+v", c.Attributes)
+	}
+}
+
+func TestParseTraceparentRejectsInvalid(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := parseTraceparent(h); ok {
+			t.Errorf("parseTraceparent(%q) accepted", h)
+		}
+	}
+}
+
+func TestStartChildOnlyWithinATrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	shutdown := Setup(Options{Endpoint: srv.URL})
+	defer shutdown(context.Background()) //nolint:errcheck
+
+	if span := StartChild(context.Background(), "db.query"); span != nil {
+		t.Error("expected no span outside a trace")
+	}
+	ctx, parent := Start(context.Background(), "view.today")
+	child := StartChild(ctx, "db.query")
+	if child == nil || child.parent != parent.id || child.trace != parent.trace {
+		t.Fatalf("expected a child of %s, got %+v", parent.id, child)
+	}
+}
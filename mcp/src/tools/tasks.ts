@@ -17,6 +17,8 @@ export function registerTaskTools(server: McpServer) {
       when_before: z.string().optional().describe('Tasks scheduled before this date'),
       when_after: z.string().optional().describe('Tasks scheduled after this date'),
       has_deadline: z.boolean().optional().describe('Filter tasks that have a deadline'),
+      limit: z.number().optional().describe('Max results per page (all when omitted, up to 500)'),
+      cursor: z.string().optional().describe('next_cursor of the previous page'),
     },
     async (params) => {
       const qs = new URLSearchParams();
@@ -30,6 +32,8 @@ export function registerTaskTools(server: McpServer) {
       if (params.when_before) qs.set('when_before', params.when_before);
       if (params.when_after) qs.set('when_after', params.when_after);
       if (params.has_deadline !== undefined) qs.set('has_deadline', String(params.has_deadline));
+      if (params.limit !== undefined) qs.set('limit', String(params.limit));
+      if (params.cursor) qs.set('cursor', params.cursor);
       const data = await client.get(`/api/tasks?${qs.toString()}`);
       return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
     },
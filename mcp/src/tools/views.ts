@@ -2,35 +2,74 @@ import { McpServer } from '@modelcontextprotocol/sdk/server/mcp.js';
 import { z } from 'zod';
 import * as client from '../client.js';
 
+// Filter and sort parameters every view endpoint accepts.
+const filterShape = {
+  tag: z.array(z.string()).optional().describe('Tag IDs or names; tasks must have all of them'),
+  project: z.string().optional().describe('Project ID or name'),
+  area: z.string().optional().describe('Area ID or name (includes tasks in its projects)'),
+  priority: z.enum(['high', 'normal']).optional().describe('Task priority'),
+  deadline: z
+    .string()
+    .optional()
+    .describe('"any", "none", "overdue", or a date (YYYY-MM-DD, today, tomorrow) the deadline is on or before'),
+  sort: z
+    .string()
+    .optional()
+    .describe('when, deadline, created, updated, title or priority; prefix with - to reverse'),
+};
+
+type Filters = {
+  tag?: string[];
+  project?: string;
+  area?: string;
+  priority?: 'high' | 'normal';
+  deadline?: string;
+  sort?: string;
+};
+
+function viewPath(path: string, filters: Filters, extra: Record<string, string | undefined> = {}): string {
+  const params = new URLSearchParams();
+  for (const tag of filters.tag ?? []) params.append('tag', tag);
+  for (const [key, value] of Object.entries({ ...extra, ...filters, tag: undefined })) {
+    if (value !== undefined) params.set(key, String(value));
+  }
+  const qs = params.toString();
+  return qs ? `${path}?${qs}` : path;
+}
+
 export function registerViewTools(server: McpServer) {
-  server.tool('get_today', "Get today's tasks grouped by section (overdue, today, this evening)", {}, async () => {
-    const data = await client.get('/api/views/today');
-    return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
-  });
+  server.tool(
+    'get_today',
+    "Get today's tasks grouped by section (overdue, today, this evening)",
+    filterShape,
+    async (filters) => {
+      const data = await client.get(viewPath('/api/views/today', filters));
+      return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
+    },
+  );
 
   server.tool(
     'get_upcoming',
     'Get upcoming scheduled tasks. Optionally specify a start date.',
-    { from: z.string().optional().describe('ISO date to start from (defaults to today)') },
-    async ({ from }) => {
-      const path = from ? `/api/views/upcoming?from=${from}` : '/api/views/upcoming';
-      const data = await client.get(path);
+    { from: z.string().optional().describe('ISO date to start from (defaults to today)'), ...filterShape },
+    async ({ from, ...filters }) => {
+      const data = await client.get(viewPath('/api/views/upcoming', filters, { from }));
       return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
     },
   );
 
-  server.tool('get_inbox', 'Get inbox tasks and review section', {}, async () => {
-    const data = await client.get('/api/views/inbox');
+  server.tool('get_inbox', 'Get inbox tasks and review section', filterShape, async (filters) => {
+    const data = await client.get(viewPath('/api/views/inbox', filters));
     return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
   });
 
-  server.tool('get_anytime', 'Get tasks without a scheduled date (anytime tasks)', {}, async () => {
-    const data = await client.get('/api/views/anytime');
+  server.tool('get_anytime', 'Get tasks without a scheduled date (anytime tasks)', filterShape, async (filters) => {
+    const data = await client.get(viewPath('/api/views/anytime', filters));
     return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
   });
 
-  server.tool('get_someday', 'Get someday tasks', {}, async () => {
-    const data = await client.get('/api/views/someday');
+  server.tool('get_someday', 'Get someday tasks', filterShape, async (filters) => {
+    const data = await client.get(viewPath('/api/views/someday', filters));
     return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
   });
 
@@ -39,14 +78,16 @@ export function registerViewTools(server: McpServer) {
     'Get completed and canceled tasks',
     {
       limit: z.number().optional().describe('Max results (default 50)'),
-      offset: z.number().optional().describe('Offset for pagination'),
+      cursor: z.string().optional().describe('next_cursor of the previous page'),
+      ...filterShape,
     },
-    async ({ limit, offset }) => {
-      const params = new URLSearchParams();
-      if (limit !== undefined) params.set('limit', String(limit));
-      if (offset !== undefined) params.set('offset', String(offset));
-      const qs = params.toString();
-      const data = await client.get(`/api/views/logbook${qs ? '?' + qs : ''}`);
+    async ({ limit, cursor, ...filters }) => {
+      const data = await client.get(
+        viewPath('/api/views/logbook', filters, {
+          limit: limit === undefined ? undefined : String(limit),
+          cursor,
+        }),
+      );
       return { content: [{ type: 'text' as const, text: JSON.stringify(data, null, 2) }] };
     },
   );